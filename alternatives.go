@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// translationChoice is a word with more than one translation, the one the
+// student picks is saved
+type translationChoice struct {
+	word         string
+	translations []string
+	offline      bool
+	// reverse choices are saved with the translation as the word, so the
+	// sidebar always lists words of the language
+	reverse bool
+	// source is the detected language of a word in another language
+	source string
+}
+
+// translationReceived saves a single translation right away and offers a
+// choice between several. Translating the same word again before they arrive
+// saves the best one
+func (m *model) translationReceived(msg TranslationReceived) tea.Cmd {
+	acceptTop := false
+	if msg.Word == m.awaitingTranslation {
+		acceptTop = m.acceptTopTranslation
+		m.awaitingTranslation, m.acceptTopTranslation = "", false
+	}
+	if msg.Err != nil {
+		m.setStatus(statusError, translationFailure("Failed to translate", msg.Err))
+		return nil
+	}
+	c := translationChoice{word: msg.Word, translations: msg.Translations, offline: msg.Offline, reverse: msg.Reverse, source: msg.Source}
+	if len(c.translations) == 1 || acceptTop {
+		return m.saveTranslation(c, 0)
+	}
+	// An open choice is left with its best translation
+	var cmd tea.Cmd
+	if m.choosing != nil {
+		cmd = m.chooseTranslation(0)
+	}
+	m.choosing = &c
+	m.UpdateStatus("Pick a translation, " + translationDirection(m.config, c.reverse))
+	m.resize()
+	return cmd
+}
+
+func (m *model) saveTranslation(c translationChoice, i int) tea.Cmd {
+	word, translation := c.word, c.translations[i]
+	if c.reverse {
+		word, translation = translation, word
+	}
+	// Marks translations from the offline dictionary
+	if c.offline {
+		translation += " ·"
+	}
+	if c.source != "" {
+		translation += " (" + c.source + ")"
+	}
+	if c.reverse {
+		m.UpdateStatus(fmt.Sprintf("Saved %s, %s", word, translationDirection(m.config, true)))
+	}
+	language := m.config.Language
+	if c.source != "" {
+		language = c.source
+	}
+	return m.saveWord(word, translation, language)
+}
+
+func (m *model) chooseTranslation(i int) tea.Cmd {
+	c := m.choosing
+	m.choosing = nil
+	m.setStatus(statusReady, "Ready")
+	m.resize()
+	return m.saveTranslation(*c, i)
+}
+
+// choiceKey saves the translation of the number pressed, or the best one on
+// the translate key. esc saves none, other keys save the best one and are
+// sent again to work as usual
+func (m *model) choiceKey(msg tea.KeyMsg) (tea.Cmd, bool) {
+	key := msg.String()
+	if key == "esc" {
+		m.choosing = nil
+		m.UpdateStatus("Translation not saved")
+		m.resize()
+		return nil, true
+	}
+	if m.keymap.Action(key) == ActionTranslate {
+		return m.chooseTranslation(0), true
+	}
+	if n, err := strconv.Atoi(key); err == nil && n >= 1 && n <= len(m.choosing.translations) {
+		return m.chooseTranslation(n - 1), true
+	}
+	return tea.Batch(m.chooseTranslation(0), func() tea.Msg { return msg }), true
+}
+
+func (m model) choiceView() string {
+	var st strings.Builder
+	fmt.Fprintf(&st, "%s →", m.choosing.word)
+	for i, translation := range m.choosing.translations {
+		fmt.Fprintf(&st, "  %d. %s", i+1, translation)
+	}
+	if note := m.grammarNotes[m.choosing.word]; note != "" {
+		fmt.Fprintf(&st, "  (%s)", note)
+	}
+	fmt.Fprintf(&st, "  [1-%d pick, %s first, esc skip]", len(m.choosing.translations), m.keymap.Key(ActionTranslate))
+	return styles.choice.Width(m.fullWidth).Render(st.String())
+}
+
+// saveWord adds a word to the sidebar, with show_ipa its pronunciation is
+// looked up and with show_examples an example sentence is queued
+func (m *model) saveWord(word string, translation string, language string) tea.Cmd {
+	m.wordsStore.Add(word, translation)
+	m.wordsStore.SetNote(word, m.grammarNotes[word])
+	var cmds []tea.Cmd
+	if m.config.ShowIPA {
+		cmds = append(cmds, lookUpIPA(word, language))
+	}
+	// Examples of words in another language would be in the wrong one
+	if m.config.ShowExamples && language == m.config.Language {
+		cmds = append(cmds, m.queueExample(word))
+	}
+	return tea.Batch(cmds...)
+}
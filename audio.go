@@ -0,0 +1,180 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// trimFrame is the number of samples whose RMS decides if they are silent,
+// 10ms at the recording sample rate
+const trimFrame = sampleRate / 100
+
+func frameRMS(frame []int16) float64 {
+	if len(frame) == 0 {
+		return 0
+	}
+	var sumSquares float64
+	for _, sample := range frame {
+		s := float64(sample) / math.MaxInt16
+		sumSquares += s * s
+	}
+	return math.Sqrt(sumSquares / float64(len(frame)))
+}
+
+// trimSilence drops the frames quieter than threshold from both ends, keeping
+// padding samples around the speech, pure silence trims to nothing
+func trimSilence(samples []int16, threshold float64, frameSize int, padding int) []int16 {
+	first, last := -1, -1
+	for start := 0; start < len(samples); start += frameSize {
+		end := min(start+frameSize, len(samples))
+		if frameRMS(samples[start:end]) < threshold {
+			continue
+		}
+		if first < 0 {
+			first = start
+		}
+		last = end
+	}
+	if first < 0 {
+		return samples[:0]
+	}
+	return samples[max(first-padding, 0):min(last+padding, len(samples))]
+}
+
+// paddingSamples converts a duration to a number of mono samples
+func paddingSamples(d time.Duration) int {
+	return int(d.Seconds() * sampleRate)
+}
+
+// normalizePeak is the level quiet recordings are raised to, -3 dBFS
+var normalizePeak = math.Pow(10, -3.0/20)
+
+// applyGain multiplies every sample in place, clipping at the int16 range
+func applyGain(samples []int16, gain float64) {
+	for i, sample := range samples {
+		amplified := math.Round(float64(sample) * gain)
+		samples[i] = int16(max(min(amplified, math.MaxInt16), math.MinInt16))
+	}
+}
+
+// normalizeGain is the gain bringing the loudest sample to normalizePeak,
+// silence is left alone
+func normalizeGain(samples []int16) float64 {
+	var peak float64
+	for _, sample := range samples {
+		peak = max(peak, math.Abs(float64(sample)))
+	}
+	if peak == 0 {
+		return 1
+	}
+	return normalizePeak * math.MaxInt16 / peak
+}
+
+// downmix averages interleaved frames into mono
+func downmix(samples []int16, channels int) []int16 {
+	if channels <= 1 {
+		return samples
+	}
+	mono := make([]int16, len(samples)/channels)
+	for i := range mono {
+		var sum int
+		for c := 0; c < channels; c++ {
+			sum += int(samples[i*channels+c])
+		}
+		mono[i] = int16(sum / channels)
+	}
+	return mono
+}
+
+// resample converts mono samples between rates by linear interpolation
+func resample(samples []int16, from, to int) []int16 {
+	if from == to || from <= 0 || len(samples) == 0 {
+		return samples
+	}
+	out := make([]int16, int(int64(len(samples))*int64(to)/int64(from)))
+	step := float64(from) / float64(to)
+	for i := range out {
+		pos := float64(i) * step
+		j := int(pos)
+		if j+1 >= len(samples) {
+			out[i] = samples[len(samples)-1]
+			continue
+		}
+		frac := pos - float64(j)
+		out[i] = int16(math.Round(float64(samples[j])*(1-frac) + float64(samples[j+1])*frac))
+	}
+	return out
+}
+
+// isEmptyRecording reports whether a WAV recording is too short or too quiet
+// to be worth transcribing, silentPeak is relative to full scale
+func isEmptyRecording(wav []byte, minDuration time.Duration, silentPeak float64) bool {
+	pcm := wav[min(wavHeaderSize, len(wav)):]
+	n := len(pcm) / 2
+	if time.Duration(n)*time.Second/sampleRate < minDuration {
+		return true
+	}
+	var peak float64
+	for i := 0; i < n; i++ {
+		sample := int16(pcm[2*i]) | int16(pcm[2*i+1])<<8
+		peak = max(peak, math.Abs(float64(sample)))
+	}
+	return peak/math.MaxInt16 < silentPeak
+}
+
+// noiseGateCalibration is how long the noise floor is measured at the start
+// of a recording
+const noiseGateCalibration = 200 * time.Millisecond
+
+// noiseGateHangover is how long the gate stays open after a loud frame, so
+// the quiet ends of words aren't cut
+const noiseGateHangover = 150 * time.Millisecond
+
+// noiseGate silences frames that stay below the noise floor, measured at the
+// start of the recording, plus a margin
+type noiseGate struct {
+	margin float64
+	// calibration is the number of samples used to measure the floor
+	calibration int
+	measured    int
+	sumSquares  float64
+	floor       float64
+	// hangover is the number of samples passed after a loud frame, open is
+	// how many of them are left
+	hangover int
+	open     int
+}
+
+func newNoiseGate(margin float64, calibration int, hangover int) *noiseGate {
+	return &noiseGate{margin: margin, calibration: calibration, hangover: hangover}
+}
+
+// process gates little endian S16 frames in place
+func (g *noiseGate) process(frames []byte) {
+	samples := len(frames) / 2
+	var sumSquares float64
+	for i := 0; i < samples; i++ {
+		s := float64(int16(frames[2*i])|int16(frames[2*i+1])<<8) / math.MaxInt16
+		sumSquares += s * s
+	}
+
+	if g.measured < g.calibration {
+		g.measured += samples
+		g.sumSquares += sumSquares
+		g.floor = math.Sqrt(g.sumSquares / float64(g.measured))
+		return
+	}
+
+	if samples == 0 {
+		return
+	}
+	if math.Sqrt(sumSquares/float64(samples)) >= g.floor+g.margin {
+		g.open = g.hangover
+		return
+	}
+	if g.open > 0 {
+		g.open -= samples
+		return
+	}
+	clear(frames)
+}
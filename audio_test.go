@@ -0,0 +1,409 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+// tone is n samples alternating between amplitude and its negative
+func tone(n int, amplitude int16) []int16 {
+	samples := make([]int16, n)
+	for i := range samples {
+		samples[i] = amplitude
+		if i%2 == 1 {
+			samples[i] = -amplitude
+		}
+	}
+	return samples
+}
+
+func TestTrimSilence(t *testing.T) {
+	const frame = 10
+	silence := make([]int16, 3*frame)
+	speech := tone(2*frame, 10000)
+	bracketed := slices.Concat(silence, speech, silence)
+
+	tests := []struct {
+		name       string
+		samples    []int16
+		padding    int
+		start, end int
+	}{
+		{"pure silence", make([]int16, 5*frame), 0, 0, 0},
+		{"speech bracketed by silence", bracketed, 0, 3 * frame, 5 * frame},
+		{"padding is kept", bracketed, 5, 3*frame - 5, 5*frame + 5},
+		{"padding stops at the ends", bracketed, 100, 0, 8 * frame},
+		{"all speech", speech, 0, 0, 2 * frame},
+		{"short last frame", slices.Concat(silence, tone(frame+3, 10000)), 0, 3 * frame, 4*frame + 3},
+		{"quiet noise stays silent", slices.Concat(tone(frame, 50), speech, tone(frame, 50)), 0, frame, 3 * frame},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := trimSilence(tt.samples, 0.01, frame, tt.padding)
+			want := tt.samples[tt.start:tt.end]
+			if !slices.Equal(got, want) {
+				t.Errorf("kept %d samples, want samples %d to %d", len(got), tt.start, tt.end)
+			}
+		})
+	}
+}
+
+func TestPaddingSamples(t *testing.T) {
+	if got := paddingSamples(250 * time.Millisecond); got != 4000 {
+		t.Errorf("250ms is %d samples", got)
+	}
+}
+
+func TestRecorderTrimsSilence(t *testing.T) {
+	silence := make([]int16, sampleRate/2)
+	speech := tone(sampleRate/10, 10000)
+	audio := newFakeAudio(pcm(silence...), pcm(speech...), pcm(silence...))
+	r := NewRecorder(WithAudioContext(audio), WithSilenceTrim(0.01, 0))
+
+	result := record(r)
+	<-audio.delivered
+	r.Stop()
+	got := <-result
+	if got.err != nil {
+		t.Fatal(got.err)
+	}
+	if n := (len(got.wav) - wavHeaderSize) / 2; n != len(speech) {
+		t.Errorf("kept %d samples of %d spoken", n, len(speech))
+	}
+}
+
+func TestApplyGain(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []int16
+		gain    float64
+		want    []int16
+	}{
+		{"amplifies", []int16{100, -100, 0}, 2, []int16{200, -200, 0}},
+		{"rounds", []int16{3, -3}, 1.5, []int16{5, -5}},
+		{"attenuates", []int16{1000, -1000}, 0.5, []int16{500, -500}},
+		{"clips positive", []int16{20000, 32767}, 2, []int16{32767, 32767}},
+		{"clips negative", []int16{-20000, -32768}, 2, []int16{-32768, -32768}},
+		{"clips only the loud samples", []int16{10000, 20000, -20000}, 3, []int16{30000, 32767, -32768}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples := slices.Clone(tt.samples)
+			applyGain(samples, tt.gain)
+			if !slices.Equal(samples, tt.want) {
+				t.Errorf("got %v, want %v", samples, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeGain(t *testing.T) {
+	// -3 dBFS
+	peak := int16(math.Round(normalizePeak * math.MaxInt16))
+	tests := []struct {
+		name    string
+		samples []int16
+	}{
+		{"quiet", []int16{100, -1000, 500}},
+		{"loud", []int16{32767, -20000}},
+		{"negative peak", []int16{-32768, 100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples := slices.Clone(tt.samples)
+			applyGain(samples, normalizeGain(samples))
+			var got int16
+			for _, sample := range samples {
+				got = max(got, int16(math.Abs(float64(sample))))
+			}
+			if math.Abs(float64(got-peak)) > 1 {
+				t.Errorf("peak %d, want %d", got, peak)
+			}
+		})
+	}
+
+	if gain := normalizeGain(make([]int16, 10)); gain != 1 {
+		t.Errorf("silence gets a gain of %v", gain)
+	}
+}
+
+func TestRecorderGain(t *testing.T) {
+	audio := newFakeAudio(pcm(100, -200, 20000))
+	r := NewRecorder(WithAudioContext(audio), WithGain(2))
+
+	result := record(r)
+	<-audio.delivered
+	r.Stop()
+	got := <-result
+	if want := samplesToWAV([]int16{200, -400, 32767}, sampleRate, channels); !slices.Equal(got.wav, want) {
+		t.Errorf("recorded %v", got.wav[wavHeaderSize:])
+	}
+}
+
+func TestDownmix(t *testing.T) {
+	tests := []struct {
+		name     string
+		samples  []int16
+		channels int
+		want     []int16
+	}{
+		{"mono", []int16{1, 2, 3}, 1, []int16{1, 2, 3}},
+		{"stereo", []int16{100, 300, -100, -300, 32767, 32767}, 2, []int16{200, -200, 32767}},
+		{"opposite channels cancel", []int16{1000, -1000}, 2, []int16{0}},
+		{"extremes don't overflow", []int16{-32768, -32768}, 2, []int16{-32768}},
+		{"four channels", []int16{4, 8, 12, 16}, 4, []int16{10}},
+		{"incomplete last frame", []int16{2, 4, 6}, 2, []int16{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := downmix(tt.samples, tt.channels); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// ramp is n samples rising by step
+func ramp(n int, step float64) []int16 {
+	samples := make([]int16, n)
+	for i := range samples {
+		samples[i] = int16(math.Round(float64(i) * step))
+	}
+	return samples
+}
+
+func TestResample(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to int
+		samples  int
+	}{
+		{"48kHz", 48000, sampleRate, 4800},
+		{"44.1kHz", 44100, sampleRate, 4410},
+		{"8kHz", 8000, sampleRate, 800},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A linear ramp stays on its line when interpolated linearly
+			got := resample(ramp(tt.samples, 1), tt.from, tt.to)
+			if want := tt.samples * tt.to / tt.from; len(got) != want {
+				t.Fatalf("%d samples, want %d", len(got), want)
+			}
+			step := float64(tt.from) / float64(tt.to)
+			for i, sample := range got {
+				want := math.Min(float64(i)*step, float64(tt.samples-1))
+				if math.Abs(float64(sample)-want) > 1 {
+					t.Fatalf("sample %d is %d, want %.1f", i, sample, want)
+				}
+			}
+		})
+	}
+
+	same := []int16{1, 2, 3}
+	if got := resample(same, sampleRate, sampleRate); !slices.Equal(got, same) {
+		t.Errorf("resampling to the same rate gave %v", got)
+	}
+	if got := resample(nil, 48000, sampleRate); len(got) != 0 {
+		t.Errorf("resampling nothing gave %v", got)
+	}
+}
+
+func TestRecorderConvertsStereo48kHz(t *testing.T) {
+	// 100ms of stereo with the right channel at twice the left
+	left := ramp(4800, 1)
+	stereo := make([]int16, 0, 2*len(left))
+	for _, sample := range left {
+		stereo = append(stereo, sample, 2*sample)
+	}
+	audio := newFakeAudio(pcm(stereo...))
+	audio.rate, audio.channels = 48000, 2
+	r := NewRecorder(WithAudioContext(audio))
+
+	result := record(r)
+	<-audio.delivered
+	r.Stop()
+	got := <-result
+	if got.err != nil {
+		t.Fatal(got.err)
+	}
+	if rate := binary.LittleEndian.Uint32(got.wav[24:28]); rate != sampleRate {
+		t.Errorf("sample rate %d", rate)
+	}
+	if n := binary.LittleEndian.Uint16(got.wav[22:24]); n != channels {
+		t.Errorf("%d channels", n)
+	}
+	samples := (len(got.wav) - wavHeaderSize) / 2
+	if samples != sampleRate/10 {
+		t.Fatalf("%d samples, want %d", samples, sampleRate/10)
+	}
+	// Sample i is at 3i in the left channel and 6i in the right
+	for _, i := range []int{0, 1, 100, 1000} {
+		sample := int16(binary.LittleEndian.Uint16(got.wav[wavHeaderSize+2*i:]))
+		if want := int16(3 * i * 3 / 2); sample != want {
+			t.Errorf("sample %d is %d, want %d", i, sample, want)
+		}
+	}
+}
+
+func TestIsEmptyRecording(t *testing.T) {
+	const minDuration = 300 * time.Millisecond
+	wav := func(samples []int16) []byte {
+		return samplesToWAV(samples, sampleRate, channels)
+	}
+	tests := []struct {
+		name string
+		wav  []byte
+		want bool
+	}{
+		{"nothing", nil, true},
+		{"only the header", wav(nil), true},
+		{"too short", wav(tone(sampleRate/10, 20000)), true},
+		{"just long enough", wav(tone(sampleRate*3/10, 20000)), false},
+		{"silent", wav(make([]int16, sampleRate)), true},
+		{"hum below the peak", wav(tone(sampleRate, 500)), true},
+		{"one loud sample", wav(append(make([]int16, sampleRate), -20000)), false},
+		{"speech", wav(tone(sampleRate, 8000)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isEmptyRecording(tt.wav, minDuration, 0.02); got != tt.want {
+				t.Errorf("isEmptyRecording is %v", got)
+			}
+		})
+	}
+}
+
+func TestEmptyRecordingIsNotUploaded(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []int16
+		upload  bool
+	}{
+		{"accidental double press", tone(sampleRate/20, 20000), false},
+		{"silence", make([]int16, sampleRate), false},
+		{"speech", tone(sampleRate, 8000), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeTranscriber{text: "Hallo", confidence: 1}
+			m := newTranscriberModel(t, fake)
+			audio := newFakeAudio(pcm(tt.samples...))
+			m.recorder = NewRecorder(WithAudioContext(audio))
+
+			result := record(m.recorder)
+			<-audio.delivered
+			m.recorder.Stop()
+			<-result
+
+			cmd := m.transcribeRecording()
+			if uploaded := cmd != nil; uploaded != tt.upload {
+				t.Fatalf("uploaded is %v with status %q", uploaded, m.status.text)
+			}
+			if !tt.upload && m.status.text != "Nothing recorded" {
+				t.Errorf("status is %q", m.status.text)
+			}
+			if !tt.upload && m.lastRecording != nil {
+				t.Error("the empty recording can be transcribed again")
+			}
+		})
+	}
+}
+
+func TestNoiseGate(t *testing.T) {
+	const (
+		frame       = 10
+		calibration = 2 * frame
+		hangover    = 2 * frame
+		hum         = 1000
+		speech      = 10000
+	)
+	tests := []struct {
+		name string
+		// floor is the level of the calibration frames
+		floor int16
+		// frames are the levels after the calibration, kept whether they pass
+		frames []int16
+		kept   []bool
+	}{
+		{"speech in silence passes", 0, []int16{speech}, []bool{true}},
+		{"hum at the floor is gated", hum, []int16{hum, hum}, []bool{false, false}},
+		{"hum within the margin is gated", hum, []int16{hum + 100}, []bool{false}},
+		{"speech above the hum passes", hum, []int16{hum, speech, hum, hum, hum}, []bool{false, true, true, true, false}},
+		{"loud frames restart the hangover", hum, []int16{speech, hum, speech, hum, hum, hum}, []bool{true, true, true, true, true, false}},
+		{"loud calibration lets only louder frames through", speech, []int16{speech, 2 * speech}, []bool{false, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gate := newNoiseGate(0.01, calibration, hangover)
+			for i := 0; i < calibration/frame; i++ {
+				calibrating := pcm(tone(frame, tt.floor)...)
+				gate.process(calibrating)
+				if !slices.Equal(calibrating, pcm(tone(frame, tt.floor)...)) {
+					t.Fatalf("calibration frame %d was gated", i)
+				}
+			}
+			for i, level := range tt.frames {
+				frame := pcm(tone(frame, level)...)
+				gate.process(frame)
+				want := make([]byte, len(frame))
+				if tt.kept[i] {
+					want = pcm(tone(len(frame)/2, level)...)
+				}
+				if !slices.Equal(frame, want) {
+					t.Errorf("frame %d at %d kept %v, want %v", i, level, !tt.kept[i], tt.kept[i])
+				}
+			}
+		})
+	}
+
+	gate := newNoiseGate(0.01, 0, 0)
+	empty := []byte{}
+	gate.process(empty)
+	if gate.open != 0 {
+		t.Error("an empty frame opened the gate")
+	}
+}
+
+func TestRecorderNoiseGate(t *testing.T) {
+	// 200ms of hum calibrate the floor, then 100ms of hum, a word and hum,
+	// delivered in 10ms frames like a device does
+	samples := slices.Concat(tone(sampleRate*3/10, 1000), tone(sampleRate/10, 10000), tone(sampleRate/2, 1000))
+	var frames [][]byte
+	for frame := range slices.Chunk(samples, sampleRate/100) {
+		frames = append(frames, pcm(frame...))
+	}
+	audio := newFakeAudio(frames...)
+	r := NewRecorder(WithAudioContext(audio), WithNoiseGate(0.01))
+
+	result := record(r)
+	<-audio.delivered
+	r.Stop()
+	got := <-result
+	if got.err != nil {
+		t.Fatal(got.err)
+	}
+	samples = make([]int16, (len(got.wav)-wavHeaderSize)/2)
+	for i := range samples {
+		samples[i] = int16(binary.LittleEndian.Uint16(got.wav[wavHeaderSize+2*i:]))
+	}
+	// The hum after the calibration is as long as the word
+	calibration, words := sampleRate/5, sampleRate/10
+	if samples[0] != 1000 {
+		t.Error("the calibration was gated")
+	}
+	if samples[calibration] != 0 {
+		t.Error("the hum after the calibration passed")
+	}
+	if samples[calibration+words] != 10000 {
+		t.Error("the word was gated")
+	}
+	if samples[calibration+2*words] != 1000 {
+		t.Error("the gate closed right after the word")
+	}
+	if samples[len(samples)-1] != 0 {
+		t.Error("the hum after the hangover passed")
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"lazylang/piper"
+	"log/slog"
+
+	"github.com/gen2brain/malgo"
+)
+
+var (
+	ErrAudioUnavailable = errors.New("audio system unavailable")
+	ErrNoCaptureDevice  = errors.New("no capture device found")
+	ErrCaptureFailed    = errors.New("capture device failed to start")
+)
+
+// CaptureDevice is an opened microphone delivering S16 frames to the
+// callback it was opened with
+type CaptureDevice interface {
+	Start() error
+	Stop() error
+	// Close releases the device, it is called once after Stop
+	Close()
+	SampleRate() int
+	Channels() int
+}
+
+// AudioContext opens capture devices, the Recorder uses miniaudio unless
+// another context is given with WithAudioContext
+type AudioContext interface {
+	// OpenCapture opens the device whose name contains deviceName, or the
+	// default device when it is empty or not found
+	OpenCapture(deviceName string, onFrames func(frames []byte)) (CaptureDevice, error)
+}
+
+type malgoAudioContext struct{}
+
+type malgoCaptureDevice struct {
+	ctx    *malgo.AllocatedContext
+	device *malgo.Device
+}
+
+func (malgoAudioContext) OpenCapture(deviceName string, onFrames func(frames []byte)) (CaptureDevice, error) {
+	ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrAudioUnavailable, err)
+	}
+
+	// Zero channels and sample rate capture in the native format of the
+	// device, the Recorder converts it to 16kHz mono
+	deviceConfig := malgo.DefaultDeviceConfig(malgo.Capture)
+	deviceConfig.Capture.Format = malgo.FormatS16
+	deviceConfig.Capture.Channels = 0
+	deviceConfig.SampleRate = 0
+
+	if deviceName != "" {
+		id, err := piper.FindDevice(ctx.Context, malgo.Capture, deviceName)
+		if err != nil {
+			slog.Warn("Input device not available, using the default", "device", deviceName, "error", err)
+		} else {
+			deviceConfig.Capture.DeviceID = id.Pointer()
+		}
+	}
+
+	callbacks := malgo.DeviceCallbacks{
+		Data: func(pOutputSample, pInputSamples []byte, framecount uint32) {
+			onFrames(pInputSamples)
+		},
+	}
+
+	device, err := malgo.InitDevice(ctx.Context, deviceConfig, callbacks)
+	if err != nil {
+		_ = ctx.Uninit()
+		ctx.Free()
+		return nil, fmt.Errorf("%w: %w", ErrNoCaptureDevice, err)
+	}
+	return &malgoCaptureDevice{ctx: ctx, device: device}, nil
+}
+
+func (d *malgoCaptureDevice) Start() error {
+	if err := d.device.Start(); err != nil {
+		return fmt.Errorf("%w: %w", ErrCaptureFailed, err)
+	}
+	return nil
+}
+
+func (d *malgoCaptureDevice) Stop() error {
+	return d.device.Stop()
+}
+
+func (d *malgoCaptureDevice) Close() {
+	d.device.Uninit()
+	_ = d.ctx.Uninit()
+	d.ctx.Free()
+}
+
+func (d *malgoCaptureDevice) SampleRate() int {
+	return int(d.device.SampleRate())
+}
+
+func (d *malgoCaptureDevice) Channels() int {
+	return int(d.device.CaptureChannels())
+}
+
+// recordingErrorStatus describes why the microphone couldn't be used
+func recordingErrorStatus(err error) string {
+	switch {
+	case errors.Is(err, ErrAudioUnavailable):
+		return "Audio system unavailable"
+	case errors.Is(err, ErrNoCaptureDevice):
+		return "No capture device found"
+	case errors.Is(err, ErrCaptureFailed):
+		return "Microphone busy or unavailable"
+	default:
+		return "Microphone unavailable"
+	}
+}
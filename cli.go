@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lazylang/piper"
+	"os"
+	"strings"
+)
+
+// takeFlag takes --name <value> or --name=<value> out of the arguments
+func takeFlag(args []string, name string) (string, []string) {
+	var value string
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--"+name && i+1 < len(args):
+			value = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], "--"+name+"="):
+			value = strings.TrimPrefix(args[i], "--"+name+"=")
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return value, rest
+}
+
+// takeSwitch takes --name out of the arguments and reports whether it was
+// there
+func takeSwitch(args []string, name string) (bool, []string) {
+	found := false
+	var rest []string
+	for _, arg := range args {
+		if arg == "--"+name {
+			found = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+	return found, rest
+}
+
+// runSubcommand handles the commands that run instead of the TUI, it reports
+// whether args named a subcommand
+func runSubcommand(args []string, profile string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	var err error
+	switch args[0] {
+	case "devices":
+		err = ListDevices()
+	case "voices":
+		err = runVoicesCommand(args[1:], profile)
+	case "config":
+		err = runConfigCommand(args[1:])
+	case "doctor":
+		err = runDoctor(profile)
+	default:
+		return false
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	return true
+}
+
+const voicesUsage = "usage: lazylang voices languages | refresh | list <language> | download <voice> | installed | remove [--force] <voice>"
+
+// runVoicesCommand lists and downloads Piper voices so they can be fetched
+// before going offline
+func runVoicesCommand(args []string, profile string) error {
+	if len(args) == 0 {
+		return errors.New(voicesUsage)
+	}
+	config := configuredConfig(profile)
+	tts := config.TTSBackend
+	dir, err := voicesDir(tts.VoicesDir)
+	if err != nil {
+		return err
+	}
+	if err := configureHTTP(config); err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "languages":
+		return piper.ListLanguages(dir)
+	case "refresh":
+		voices, err := piper.RefreshVoices(dir)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d voices available\n", len(voices))
+		return nil
+	case "list":
+		if len(args) != 2 {
+			return errors.New(voicesUsage)
+		}
+		return piper.ListVoices(dir, args[1])
+	case "download":
+		if len(args) != 2 {
+			return errors.New(voicesUsage)
+		}
+		var current string
+		err := piper.DownloadVoice(dir, "", args[1], func(file string, done, total int64) {
+			if current != "" && file != current {
+				fmt.Fprintln(os.Stderr)
+			}
+			current = file
+			if total > 0 {
+				fmt.Fprintf(os.Stderr, "\r%s %3d%%", file, done*100/total)
+			} else {
+				fmt.Fprintf(os.Stderr, "\r%s %d bytes", file, done)
+			}
+		})
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Downloaded", args[1])
+		return nil
+	case "installed":
+		return listInstalledVoices(dir)
+	case "remove":
+		force := len(args) == 3 && args[1] == "--force"
+		if len(args) != 2 && !force {
+			return errors.New(voicesUsage)
+		}
+		key := strings.TrimSuffix(args[len(args)-1], ".onnx")
+		if !force && key == strings.TrimSuffix(tts.Voice, ".onnx") {
+			return fmt.Errorf("%s is the voice in the config, pass --force to remove it anyway", key)
+		}
+		if err := piper.DeleteVoice(dir, key); err != nil {
+			return err
+		}
+		fmt.Println("Removed", key)
+		return nil
+	default:
+		return errors.New(voicesUsage)
+	}
+}
+
+func listInstalledVoices(dir piper.Dir) error {
+	voices, err := piper.ListInstalledVoices(dir)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	fmt.Printf("Installed voices (%d):\n", len(voices))
+	fmt.Println(strings.Repeat("-", 70))
+	for _, voice := range voices {
+		fmt.Printf("  %-40s %-8s %10s\n", voice.Key, voice.Language, piper.FormatSize(voice.Size))
+		total += voice.Size
+	}
+	fmt.Printf("Total: %s\n", piper.FormatSize(total))
+	return nil
+}
+
+// configuredConfig reads the profile from the config file without
+// validating it or filling in the defaults
+func configuredConfig(profile string) Config {
+	path, err := GetConfigPath()
+	if err != nil {
+		return NewConfig()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return NewConfig()
+	}
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Config{}
+	}
+	if selected, err := selectProfile(config, profile, nil); err == nil {
+		return selected
+	}
+	return config
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type slashCommand struct {
+	name        string
+	usage       string
+	description string
+	run         func(m *model, arg string) tea.Cmd
+}
+
+// slashCommands are meta actions typed into the text input, they are never
+// sent to the LLM as user turns
+var slashCommands = []slashCommand{
+	{
+		name:        "translate",
+		usage:       "/translate <sentence>",
+		description: "translate a sentence into the sidebar",
+		run: func(m *model, arg string) tea.Cmd {
+			if arg == "" {
+				m.inputError = "Usage: /translate <sentence>"
+				return nil
+			}
+			if !m.config.Translation.IsEnabled() {
+				m.inputError = "Translation disabled"
+				return nil
+			}
+			return GetTranslation(arg, *m)
+		},
+	},
+	{
+		name:        "words",
+		usage:       "/words <word>, <word>...",
+		description: "translate a list of words into the sidebar",
+		run: func(m *model, arg string) tea.Cmd {
+			var words []string
+			for _, word := range strings.Split(arg, ",") {
+				if word = strings.TrimSpace(word); word != "" {
+					words = append(words, word)
+				}
+			}
+			if len(words) == 0 {
+				m.inputError = "Usage: /words <word>, <word>..."
+				return nil
+			}
+			if !m.config.Translation.IsEnabled() {
+				m.inputError = "Translation disabled"
+				return nil
+			}
+			m.setStatus(statusBusy, fmt.Sprintf("Translating %d words", len(words)))
+			return GetWordsTranslation(words, *m)
+		},
+	},
+	{
+		name:        "say",
+		usage:       "/say <word>",
+		description: "look up how to say a word of your language",
+		run: func(m *model, arg string) tea.Cmd {
+			if arg == "" {
+				m.inputError = "Usage: /say <word>"
+				return nil
+			}
+			if !m.config.Translation.IsEnabled() {
+				m.inputError = "Translation disabled"
+				return nil
+			}
+			m.setStatus(statusBusy, "Translating "+translationDirection(m.config, true))
+			return GetReverseTranslation(arg, *m)
+		},
+	},
+	{
+		name:        "slower",
+		usage:       "/slower",
+		description: "ask the teacher for simpler, shorter sentences",
+		run: func(m *model, arg string) tea.Cmd {
+			m.Session.addInstruction(m.config, "Use simple vocabulary and short, slowly paced sentences.")
+			m.UpdateStatus("Teacher will speak simpler")
+			return nil
+		},
+	},
+	{
+		name:        "explain",
+		usage:       "/explain <word>",
+		description: "explain the meaning of a word",
+		run: func(m *model, arg string) tea.Cmd {
+			if arg == "" {
+				m.inputError = "Usage: /explain <word>"
+				return nil
+			}
+			m.setStatus(statusBusy, "Explaining")
+			return GetExplanation(arg, *m)
+		},
+	},
+	{
+		name:        "topic",
+		usage:       "/topic <scenario>",
+		description: "set the scenario of the conversation",
+		run: func(m *model, arg string) tea.Cmd {
+			if arg == "" {
+				m.inputError = "Usage: /topic <scenario>"
+				return nil
+			}
+			m.Session.addInstruction(m.config, fmt.Sprintf("The conversation takes place in the following scenario: %s.", arg))
+			m.UpdateStatus("Topic set")
+			return nil
+		},
+	},
+	{
+		name:        "clear",
+		usage:       "/clear",
+		description: "clear the conversation and its memory",
+		run: func(m *model, arg string) tea.Cmd {
+			m.Session.reset(m.config)
+			m.refreshViewport()
+			m.UpdateStatus("Conversation cleared")
+			return nil
+		},
+	},
+}
+
+func NewInput() textinput.Model {
+	input := textinput.New()
+	input.Placeholder = "Type a message or /command"
+	input.Prompt = "> "
+	return input
+}
+
+type ExplanationReceived struct {
+	sessionID   int
+	explanation string
+}
+
+// GetExplanation asks the LLM about a word outside of the conversation chain
+// so it doesn't end up in the conversation memory
+func GetExplanation(word string, m model) tea.Cmd {
+	sessionID := m.Session.id
+	return func() tea.Msg {
+		prompt := fmt.Sprintf("Explain the meaning of the %s word %q in simple %s, in one or two sentences.", languageName(m.config.Language), word, languageName(m.config.Language))
+		explanation, err := generateChatCompletion(context.Background(), m.llm, prompt)
+		if err != nil {
+			slog.Error("Failed to explain", "word", word, "error", err)
+			return StatusChanged{kind: statusError, status: "Failed to explain"}
+		}
+		return ExplanationReceived{sessionID: sessionID, explanation: explanation}
+	}
+}
+
+func commandList() string {
+	usages := make([]string, len(slashCommands))
+	for i, c := range slashCommands {
+		usages[i] = c.usage
+	}
+	return strings.Join(usages, ", ")
+}
+
+// runSlashCommand parses and runs a line starting with "/"
+func (m *model) runSlashCommand(line string) tea.Cmd {
+	name, arg, _ := strings.Cut(strings.TrimPrefix(line, "/"), " ")
+	for _, c := range slashCommands {
+		if c.name == name {
+			return c.run(m, strings.TrimSpace(arg))
+		}
+	}
+	m.inputError = fmt.Sprintf("Unknown command /%s. Available: %s", name, commandList())
+	return nil
+}
+
+func (m *model) startTyping() tea.Cmd {
+	m.typing = true
+	m.inputError = ""
+	m.resize()
+	return m.input.Focus()
+}
+
+func (m *model) stopTyping() {
+	m.typing = false
+	m.inputError = ""
+	m.input.Blur()
+	m.input.Reset()
+	m.resize()
+}
+
+func (m model) updateInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.stopTyping()
+		if m.confirming != nil {
+			return m, m.discardConfirmation()
+		}
+		return m, nil
+	case "enter":
+		line := strings.TrimSpace(m.input.Value())
+		if line == "" {
+			return m, nil
+		}
+		m.inputError = ""
+
+		// An edited transcription is sent as the turn it was recorded for
+		if m.confirming != nil {
+			m.stopTyping()
+			return m, m.sendConfirmed(line)
+		}
+
+		if strings.HasPrefix(line, "/") {
+			cmd := m.runSlashCommand(line)
+			if m.inputError != "" {
+				m.resize()
+				return m, cmd
+			}
+			m.stopTyping()
+			return m, cmd
+		}
+
+		m.stopTyping()
+		return m, m.sendTurn(m.Session, m.nextTurn(m.Session), line)
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+func (m model) inputView() string {
+	if !m.typing {
+		if m.confirming != nil {
+			return m.confirmView()
+		}
+		if m.askReset {
+			return m.resetView()
+		}
+		if m.choosing != nil {
+			return m.choiceView()
+		}
+		if m.sentenceTranslation != nil {
+			return m.sentenceView()
+		}
+		return ""
+	}
+	if m.inputError != "" {
+		return lipgloss.JoinVertical(lipgloss.Left, m.input.View(), styles.inputError.Render(m.inputError))
+	}
+	return m.input.View()
+}
+
+// resize recomputes the viewport height when the input line or the
+// transcription review appears or disappears. A new width rewraps the
+// conversation, keeping the focus on the same word
+func (m *model) resize() {
+	if !m.ready {
+		return
+	}
+	headerHeight := lipgloss.Height(m.headerView()) + 1
+	inputHeight := 0
+	if footer := m.inputView(); footer != "" {
+		inputHeight = lipgloss.Height(footer)
+	}
+	m.viewport.Height = max(0, m.fullHeight-headerHeight-inputHeight)
+	if width := m.viewportWidth(); width != m.viewport.Width {
+		message, word, focused := m.focusedMessage()
+		m.viewport.Width = width
+		if !focused || !m.focusOn(message, word+1) {
+			m.clampFocus()
+		}
+		m.refreshViewport()
+	}
+}
@@ -1,15 +1,67 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"strings"
 
+	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/llms/openai"
+	"github.com/tmc/langchaingo/prompts"
 )
 
+type ResponseStyle string
+
+const (
+	ShortResponse    ResponseStyle = "short"
+	NormalResponse   ResponseStyle = "normal"
+	DetailedResponse ResponseStyle = "detailed"
+)
+
+var responseStyles = []ResponseStyle{ShortResponse, NormalResponse, DetailedResponse}
+
+func (r ResponseStyle) Instruction() string {
+	switch r {
+	case NormalResponse:
+		return "give answers of a few sentences to the questions."
+	case DetailedResponse:
+		return "give detailed answers to the questions, explaining grammar where it helps."
+	default:
+		return "only give short answers to the questions!"
+	}
+}
+
+// MaxTokens limits the completion length, it leaves room for the reasoning
+// tokens of the default model
+func (r ResponseStyle) MaxTokens() int {
+	switch r {
+	case NormalResponse:
+		return 1024
+	case DetailedResponse:
+		return 2048
+	default:
+		return 512
+	}
+}
+
+func (r ResponseStyle) Next() ResponseStyle {
+	for i, style := range responseStyles {
+		if style == r {
+			return responseStyles[(i+1)%len(responseStyles)]
+		}
+	}
+	return ShortResponse
+}
+
+// ChatCompletion is the single place LLM clients are constructed, it wraps
+// the openai client and applies the configured defaults to every call
 type ChatCompletion struct {
-	url   string
-	model string
-	token string
+	url         string
+	model       string
+	token       string
+	temperature float64
+	llm         *openai.LLM
 }
 type Option func(*ChatCompletion)
 
@@ -31,9 +83,17 @@ func WithModel(model string) Option {
 	}
 }
 
-func NewLLM(options ...Option) (*openai.LLM, error) {
+// WithTemperature sets the default sampling temperature, zero keeps the
+// provider default
+func WithTemperature(temperature float64) Option {
+	return func(cc *ChatCompletion) {
+		cc.temperature = temperature
+	}
+}
+
+func NewLLM(options ...Option) (*ChatCompletion, error) {
 	cc := ChatCompletion{
-		url:   "https://api.groq.com/openai/v1",
+		url:   groqAPIBaseURL,
 		model: "openai/gpt-oss-120b",
 		token: os.Getenv("GROQ_API_KEY"),
 	}
@@ -41,10 +101,59 @@ func NewLLM(options ...Option) (*openai.LLM, error) {
 		option(&cc)
 	}
 
-	llm, err := openai.New(openai.WithBaseURL(cc.url), openai.WithToken(cc.token), openai.WithModel(cc.model))
+	llm, err := openai.New(openai.WithBaseURL(cc.url), openai.WithToken(cc.token), openai.WithModel(cc.model), openai.WithHTTPClient(currentHTTPClient{}))
+	if err != nil {
+		return nil, err
+	}
+	cc.llm = llm
+	return &cc, nil
+}
+
+func (cc *ChatCompletion) defaultOptions(options []llms.CallOption) []llms.CallOption {
+	if cc.temperature == 0 {
+		return options
+	}
+	// Options given by the caller come last so they win
+	return append([]llms.CallOption{llms.WithTemperature(cc.temperature)}, options...)
+}
+
+func (cc *ChatCompletion) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	return cc.llm.GenerateContent(ctx, messages, cc.defaultOptions(options)...)
+}
+
+func (cc *ChatCompletion) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, cc, prompt, options...)
+}
 
+// generateChatCompletion runs a one-off prompt outside of any conversation
+// chain, used for side calls such as explanations and summaries
+func generateChatCompletion(ctx context.Context, llm llms.Model, prompt string, options ...llms.CallOption) (string, error) {
+	completion, err := llms.GenerateFromSinglePrompt(ctx, llm, prompt, options...)
 	if err != nil {
-		return llm, err
+		return "", err
+	}
+	return strings.TrimSpace(completion), nil
+}
+
+// NewPrompt builds the teacher prompt, instructions are extra sentences such
+// as a conversation scenario added with slash commands
+func NewPrompt(config Config, instructions []string) prompts.PromptTemplate {
+	var extra strings.Builder
+	for _, instruction := range instructions {
+		fmt.Fprintf(&extra, "  %s\n", instruction)
 	}
-	return llm, nil
+
+	return prompts.NewPromptTemplate(
+		fmt.Sprintf(` You are a %s teacher. Respond to the following question or statement in
+  %s.
+%s
+  Previous conversation history:
+  {{.history}}
+
+  Important: %s
+  Student: {{.text}}
+  Teacher:
+  `, languageName(config.Language), languageName(config.Language), extra.String(), config.ResponseStyle.Instruction()),
+		[]string{"history", "text"},
+	)
 }
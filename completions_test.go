@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// chatRequest is what a client sent to the chat completions endpoint
+type chatRequest struct {
+	path          string
+	authorization string
+	Model         string  `json:"model"`
+	Temperature   float64 `json:"temperature"`
+}
+
+// chatServer answers every chat completion with answer and sends what it
+// received on requests
+func chatServer(t *testing.T, answer string) (*httptest.Server, <-chan chatRequest) {
+	t.Helper()
+	requests := make(chan chatRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := chatRequest{path: r.URL.Path, authorization: r.Header.Get("Authorization")}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("failed to decode the request: %v", err)
+		}
+		requests <- request
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":      "test",
+			"object":  "chat.completion",
+			"model":   request.Model,
+			"choices": []map[string]any{{"index": 0, "message": map[string]string{"role": "assistant", "content": answer}, "finish_reason": "stop"}},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+func TestLLMOptionsReachTheClient(t *testing.T) {
+	server, requests := chatServer(t, " Hallo! ")
+	t.Setenv("TEACHER_KEY", "secret")
+
+	backend := LLMBackend{BaseURL: server.URL, Model: "teacher-1", APIKeyEnv: "TEACHER_KEY", Temperature: 0.3}
+	llm, err := NewLLM(backend.Options("groq")...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	answer, err := generateChatCompletion(context.Background(), llm, "Sag hallo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if answer != "Hallo!" {
+		t.Errorf("answer = %q", answer)
+	}
+
+	request := <-requests
+	if request.path != "/chat/completions" {
+		t.Errorf("path = %q", request.path)
+	}
+	if request.authorization != "Bearer secret" {
+		t.Errorf("Authorization = %q", request.authorization)
+	}
+	if request.Model != "teacher-1" || request.Temperature != 0.3 {
+		t.Errorf("model %q temperature %v, want teacher-1 0.3", request.Model, request.Temperature)
+	}
+
+	// A temperature of the call wins over the configured one
+	if _, err := generateChatCompletion(context.Background(), llm, "Sag hallo", llms.WithTemperature(0.9)); err != nil {
+		t.Fatal(err)
+	}
+	if request := <-requests; request.Temperature != 0.9 {
+		t.Errorf("temperature = %v, want the 0.9 of the call", request.Temperature)
+	}
+}
+
+func TestLLMOptionsGroqKey(t *testing.T) {
+	server, requests := chatServer(t, "ok")
+	t.Setenv(groqKeyEnv, "")
+
+	backend := LLMBackend{BaseURL: server.URL, Model: "m", APIKeyEnv: groqKeyEnv}
+	llm, err := NewLLM(backend.Options("groq-key")...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := generateChatCompletion(context.Background(), llm, "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if request := <-requests; request.authorization != "Bearer groq-key" {
+		t.Errorf("Authorization = %q, want the Groq key given", request.authorization)
+	}
+}
+
+func TestInitialModelUsesTheConfiguredLLM(t *testing.T) {
+	server, requests := chatServer(t, "Guten Tag")
+	config := NewConfig()
+	config.Theme.Preset = "dark"
+	config.LLM = LLMBackend{BaseURL: server.URL, Model: "configured", Temperature: 0.5}
+	m := newConfiguredTestModel(t, config)
+
+	if _, err := generateChatCompletion(context.Background(), m.llm, "Hallo"); err != nil {
+		t.Fatal(err)
+	}
+	request := <-requests
+	if request.Model != "configured" || request.Temperature != 0.5 || request.authorization != "Bearer none" {
+		t.Errorf("request = %+v", request)
+	}
+}
@@ -1,59 +1,370 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"lazylang/piper"
-	"log"
 	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 )
 
-// PiperTts, ElevenLabs
 type TTSBackend struct {
-	Type  string `json:"type"`
+	// Type is piper, elevenlabs, openai or espeak
+	Type string `json:"type"`
+	// Voice is the Piper model file, the ElevenLabs voice id, the OpenAI
+	// voice name or the espeak-ng voice, which defaults to the language
 	Voice string `json:"voice"`
+	// SecondaryVoice is the Piper model reading your own sentences back, so
+	// they are told apart from the answers, empty uses Voice
+	SecondaryVoice string `json:"secondary_voice,omitempty"`
+	// VoicesDir is where Piper voices are stored, PIPER_VOICES_DIR or
+	// $XDG_DATA_HOME/piper-voices by default
+	VoicesDir string `json:"voices_dir,omitempty"`
+	// Server keeps Piper running between answers so short phrases start
+	// faster, it needs a piper-tts version with the HTTP server
+	Server bool `json:"server,omitempty"`
+	// WordPracticeRate is the speech rate of single words spoken with p
+	WordPracticeRate float64 `json:"word_practice_rate"`
+	// SpeechRate speeds up or slows down Piper, 0.7 is slower and 1.5
+	// faster than normal
+	SpeechRate float64 `json:"speech_rate"`
+	// Speaker is a speaker name or id of multi-speaker Piper voices
+	Speaker string `json:"speaker,omitempty"`
+	// Model is the ElevenLabs or OpenAI model id
+	Model string `json:"model,omitempty"`
+	// APIKeyEnv overrides ELEVENLABS_API_KEY or OPENAI_API_KEY
+	APIKeyEnv string `json:"api_key_env,omitempty"`
+	// BaseURL points the openai backend to a compatible server such as
+	// http://localhost:8000/v1
+	BaseURL string `json:"base_url,omitempty"`
+	// Format is the openai response format, pcm or wav
+	Format string `json:"format,omitempty"`
+	// QualityPreference is the order of Piper voice qualities tried when no
+	// voice is set, medium, low, high and x_low by default
+	QualityPreference []string `json:"quality_preference,omitempty"`
+	// Fallback is used when piper-tts is not installed
+	Fallback *TTSBackend `json:"fallback,omitempty"`
 }
 
 type Config struct {
-	Language                  string     `json:"language"`
-	TargetTranslationLanguage string     `json:"target_translation_language"`
-	LibreTranslateURL         string     `json:"libre_translate_url"`
-	TTSBackend                TTSBackend `json:"tts_backend"`
+	// Version is the shape of the file, older ones are migrated on start
+	Version                   int    `json:"version"`
+	Language                  string `json:"language"`
+	TargetTranslationLanguage string `json:"target_translation_language"`
+	LibreTranslateURL         string `json:"libre_translate_url"`
+	// LibreTranslateAPIKey is needed by public instances, the
+	// LIBRETRANSLATE_API_KEY variable overrides it
+	LibreTranslateAPIKey string `json:"libre_translate_api_key,omitempty"`
+	// LibreTranslateAuth is sent to instances behind basic auth
+	LibreTranslateAuth *BasicAuth `json:"libre_translate_auth,omitempty"`
+	// Translation turns the sidebar and LibreTranslate off for practicing
+	// without the native language
+	Translation TranslationConfig `json:"translation"`
+	// APIKeyFile holds the Groq key when GROQ_API_KEY is not set
+	APIKeyFile string     `json:"api_key_file,omitempty"`
+	TTSBackend TTSBackend `json:"tts_backend"`
 	// whispercpp, hosted whispercpp
 	STTBackend STTBackend `json:"stt_backend"`
+	// Karaoke moves the focus along with the word being spoken
+	Karaoke bool `json:"karaoke"`
+	// ShowGloss shows a translation underneath every AI reply
+	ShowGloss bool `json:"show_gloss"`
+	// ShowTransliteration spells the AI replies and the saved words of
+	// Cyrillic and Greek languages in Latin letters
+	ShowTransliteration bool `json:"show_transliteration"`
+	// ShowIPA looks up the pronunciation of saved words on Wiktionary, or
+	// with espeak-ng when it has none
+	ShowIPA bool `json:"show_ipa"`
+	// ShowExamples has the LLM write an example sentence for every saved
+	// word
+	ShowExamples bool `json:"show_examples"`
+	// ShowGrammar has the LLM name the form of a translated word in its
+	// sentence
+	ShowGrammar bool `json:"show_grammar"`
+	// DisableRecap skips the session summary printed on exit
+	DisableRecap bool `json:"disable_recap"`
+	// short, normal, detailed
+	ResponseStyle ResponseStyle `json:"response_style"`
+	// LLM is the teacher model
+	LLM LLMBackend `json:"llm"`
+	// RecordMode is toggle, hold or tap
+	RecordMode RecordMode `json:"record_mode"`
+	// HandsFree records again after every spoken answer, it turns on VAD
+	HandsFree bool `json:"hands_free"`
+	// HandsFreeDelayMs waits after the answer so the speaker isn't recorded
+	HandsFreeDelayMs int `json:"hands_free_delay_ms"`
+	// AudioCues beep when recording starts and stops
+	AudioCues bool `json:"audio_cues"`
+	// Recordings shorter than MinRecordingMs or with a peak below
+	// SilencePeak, relative to full scale, are not uploaded. They default to
+	// 300 and 0.02 when missing from the file, 0 turns the check off
+	MinRecordingMs *int     `json:"min_recording_ms,omitempty"`
+	SilencePeak    *float64 `json:"silence_peak,omitempty"`
+	// MaxRecordingSeconds stops long recordings, zero records until stopped
+	MaxRecordingSeconds int `json:"max_recording_seconds,omitempty"`
+	// VAD stops recording after a pause in speech
+	VAD VADConfig `json:"vad"`
+	// InputGain multiplies the microphone level, zero or one leaves it as is
+	InputGain float64 `json:"input_gain,omitempty"`
+	// AutoNormalize raises the peak of every recording to -3 dBFS
+	AutoNormalize bool `json:"auto_normalize"`
+	// NoiseGate silences keyboard and background noise in recordings
+	NoiseGate NoiseGateConfig `json:"noise_gate"`
+	// TrimSilence cuts the dead air around the speech before uploading
+	TrimSilence TrimConfig `json:"trim_silence"`
+	// SaveRecordings keeps every recording as a WAV file in RecordingsDir
+	SaveRecordings bool   `json:"save_recordings"`
+	RecordingsDir  string `json:"recordings_dir,omitempty"`
+	// KeepRecordings is the number of recordings kept, zero keeps all of them
+	KeepRecordings int `json:"keep_recordings,omitempty"`
+	// ExportDir is where S saves the audio of the focused message
+	ExportDir string `json:"export_dir,omitempty"`
+	// InputDevice is matched against microphone names, see `lazylang devices`
+	InputDevice string `json:"input_device,omitempty"`
+	// Muted starts without speaking answers, m toggles it
+	Muted bool `json:"muted"`
+	// Volume multiplies the playback level, 1 leaves it unchanged
+	Volume float64 `json:"volume"`
+	// HideSidebar starts without the translated words next to the
+	// conversation, | toggles it
+	HideSidebar bool `json:"hide_sidebar"`
+	// SidebarWidth is the percent of the width taken by the sidebar, < and >
+	// change it
+	SidebarWidth int `json:"sidebar_width"`
+	// Theme overrides the colors and the borders of the interface
+	Theme Theme `json:"theme"`
+	// OutputDevice is matched against playback device names
+	OutputDevice string `json:"output_device,omitempty"`
+	// Proxy overrides HTTPS_PROXY for requests leaving the machine
+	Proxy string `json:"proxy,omitempty"`
+	// CACertFile is a PEM file with certificates trusted next to the
+	// system ones, for example of a company proxy
+	CACertFile string `json:"ca_cert_file,omitempty"`
+	// Log sets the level, file and size of the log
+	Log LogConfig `json:"log"`
+	// Keys rebinds actions, for example {"record": "ctrl+r", "quit": ["q", "ctrl+c"]}
+	Keys map[string]KeyList `json:"keys,omitempty"`
+	// TurnPolicy is "queue" or "cancel"
+	TurnPolicy TurnPolicy `json:"turn_policy"`
+	// FallbackLLM answers when the Groq completion fails
+	FallbackLLM *LLMBackend `json:"fallback_llm,omitempty"`
+	// Profiles are complete configs chosen with --profile, DefaultProfile
+	// is used without it
+	Profiles       map[string]Config `json:"profiles,omitempty"`
+	DefaultProfile string            `json:"default_profile,omitempty"`
+	// Profile is the name of the profile in use
+	Profile string `json:"-"`
+}
+
+// LLMBackend is an OpenAI compatible endpoint such as a local Ollama
+type LLMBackend struct {
+	BaseURL string `json:"base_url"`
+	Model   string `json:"model"`
+	// APIKeyEnv names the environment variable holding the API key, local
+	// servers usually don't need one
+	APIKeyEnv   string  `json:"api_key_env"`
+	Temperature float64 `json:"temperature,omitempty"`
+}
+
+// Options configures the client, groqKey is used when the key is read from
+// GROQ_API_KEY but came from a file or the keyring
+func (b LLMBackend) Options(groqKey string) []Option {
+	// The openai client refuses to start without a token
+	token := "none"
+	if b.APIKeyEnv != "" {
+		token = os.Getenv(b.APIKeyEnv)
+	}
+	if token == "" && b.APIKeyEnv == groqKeyEnv {
+		token = groqKey
+	}
+	return []Option{WithBaseURL(b.BaseURL), WithModel(b.Model), WithToken(token), WithTemperature(b.Temperature)}
+}
+
+// VADConfig stops the recording automatically after a pause in speech
+type VADConfig struct {
+	Enabled bool `json:"enabled"`
+	// Threshold is the RMS level, relative to full scale, counted as speech
+	Threshold float64 `json:"threshold"`
+	SilenceMs int     `json:"silence_ms"`
+}
+
+// BasicAuth holds the credentials of a server behind HTTP basic auth
+type BasicAuth struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+// TranslationConfig selects the translation provider or turns translation off
+type TranslationConfig struct {
+	// Enabled defaults to true when it is missing from the file
+	Enabled *bool `json:"enabled,omitempty"`
+	// Provider is libretranslate, deepl or google
+	Provider string `json:"provider,omitempty"`
+	// APIKeyEnv overrides DEEPL_AUTH_KEY or GOOGLE_TRANSLATE_API_KEY,
+	// LibreTranslate uses libre_translate_api_key
+	APIKeyEnv string `json:"api_key_env,omitempty"`
+	// TimeoutSeconds bounds every request, a timed out one is sent again
+	// once
+	TimeoutSeconds int `json:"timeout_seconds"`
+	// Dictionary is a .tsv, StarDict .ifo or wiktextract .jsonl file words
+	// are looked up in when the provider fails
+	Dictionary string `json:"dictionary,omitempty"`
+	// OfflineFirst looks words up in the dictionary before the provider
+	OfflineFirst bool `json:"offline_first,omitempty"`
+	// DetectLanguage translates words the provider detects to be in another
+	// language from that one, LibreTranslate and Google detect languages
+	DetectLanguage bool `json:"detect_language,omitempty"`
+}
+
+// IsEnabled reports whether words are translated into the sidebar
+func (c TranslationConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// NoiseGateConfig silences frames close to the noise floor, which is
+// measured during the first 200ms of every recording
+type NoiseGateConfig struct {
+	Enabled bool `json:"enabled"`
+	// Margin is the RMS level above the floor, relative to full scale, a
+	// frame needs to pass the gate
+	Margin float64 `json:"margin"`
+}
+
+// TrimConfig cuts silence from both ends of a recording
+type TrimConfig struct {
+	Enabled bool `json:"enabled"`
+	// Threshold is the RMS level, relative to full scale, counted as speech
+	Threshold float64 `json:"threshold"`
+	// PaddingMs of silence are kept before and after the speech
+	PaddingMs int `json:"padding_ms"`
 }
 
 type STTBackend struct {
+	// Type is hosted (Groq), openai or deepgram
 	Type  string `json:"type"`
 	Model string `json:"model"`
+	// APIKeyEnv overrides the variable holding the key of the provider,
+	// GROQ_API_KEY, OPENAI_API_KEY or DEEPGRAM_API_KEY by default
+	APIKeyEnv      string `json:"api_key_env,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+	// MaxRetries is how often rate limited or failed uploads are resent
+	MaxRetries int `json:"max_retries"`
+	// UploadFormat is wav, flac or opus, compressing needs ffmpeg
+	UploadFormat string `json:"upload_format,omitempty"`
+	// PromptHint sends the recent conversation along so names and topic
+	// words are recognized
+	PromptHint bool `json:"prompt_hint"`
+	// Transcriptions with a confidence below ConfirmBelow are shown for
+	// review before they are sent, AlwaysConfirm reviews every one
+	ConfirmBelow  float64 `json:"confirm_below"`
+	AlwaysConfirm bool    `json:"always_confirm"`
+}
+
+// LogValue keeps secrets out of the logs by logging only what identifies the
+// setup
+func (c Config) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("profile", c.Profile),
+		slog.String("language", c.Language),
+		slog.String("target_translation_language", c.TargetTranslationLanguage),
+		slog.String("tts", c.TTSBackend.Type),
+		slog.String("voice", c.TTSBackend.Voice),
+		slog.String("stt", c.STTBackend.Type),
+		slog.String("stt_model", c.STTBackend.Model),
+		slog.String("llm", c.LLM.BaseURL),
+		slog.String("llm_model", c.LLM.Model),
+	)
+}
+
+// MinRecording is how long a recording must be to be uploaded
+func (c Config) MinRecording() time.Duration {
+	if c.MinRecordingMs == nil {
+		return defaultMinRecordingMs * time.Millisecond
+	}
+	return time.Duration(*c.MinRecordingMs) * time.Millisecond
 }
 
+// SilenceThreshold is the peak, relative to full scale, a recording must
+// reach to be uploaded
+func (c Config) SilenceThreshold() float64 {
+	if c.SilencePeak == nil {
+		return defaultSilencePeak
+	}
+	return *c.SilencePeak
+}
+
+const (
+	defaultMinRecordingMs = 300
+	defaultSilencePeak    = 0.02
+)
+
 func NewConfig() Config {
+	enabled := true
+	minRecordingMs, silencePeak := defaultMinRecordingMs, defaultSilencePeak
 	return Config{
+		Version:                   ConfigVersion,
 		Language:                  "de",
 		TargetTranslationLanguage: "en",
 		LibreTranslateURL:         "http://localhost:5000",
+		Translation: TranslationConfig{
+			Enabled:        &enabled,
+			TimeoutSeconds: 10,
+		},
 		TTSBackend: TTSBackend{
-			Type:  "piper",
-			Voice: "de_DE-karlsson-low.onnx",
+			Type:             "piper",
+			Voice:            "de_DE-karlsson-low.onnx",
+			SpeechRate:       1,
+			WordPracticeRate: 0.6,
 		},
 		STTBackend: STTBackend{
-			Type:  "hosted",
-			Model: "whisper-large-v3",
+			Type:           "hosted",
+			Model:          "whisper-large-v3",
+			TimeoutSeconds: 30,
+			MaxRetries:     3,
+			PromptHint:     true,
+			ConfirmBelow:   0.5,
+		},
+		ResponseStyle:    ShortResponse,
+		TurnPolicy:       QueueTurns,
+		RecordMode:       ToggleRecording,
+		HandsFreeDelayMs: 500,
+		Volume:           1,
+		SidebarWidth:     25,
+		MinRecordingMs:   &minRecordingMs,
+		SilencePeak:      &silencePeak,
+		VAD: VADConfig{
+			Threshold: 0.02,
+			SilenceMs: 1500,
+		},
+		NoiseGate: NoiseGateConfig{
+			Margin: 0.01,
+		},
+		TrimSilence: TrimConfig{
+			Enabled:   true,
+			Threshold: 0.01,
+			PaddingMs: 200,
+		},
+		Log: LogConfig{
+			Level:     "info",
+			MaxSizeMB: 10,
+		},
+		LLM: LLMBackend{
+			BaseURL:   groqAPIBaseURL,
+			Model:     "openai/gpt-oss-120b",
+			APIKeyEnv: "GROQ_API_KEY",
 		},
 	}
 }
 
-func CreateDefaultConfig() (Config, error) {
+func CreateDefaultConfig(configPath string) (Config, error) {
 	config := NewConfig()
 
-	configPath := GetConfigPath()
-
 	err := os.MkdirAll(filepath.Dir(configPath), 0755)
 	if err != nil {
 		return config, err
@@ -75,23 +386,67 @@ func CreateDefaultConfig() (Config, error) {
 	return config, nil
 }
 
-func GetConfigPath() string {
-	d, err := os.UserHomeDir()
+// ConfigEnv names the config file to use instead of the default one
+const ConfigEnv = "LAZYLANG_CONFIG"
 
+// localConfigName is a config in the working directory, for example one per
+// course
+const localConfigName = "lazylang.json"
+
+// configPath is the config file given with --config
+var configPath string
+
+// SetConfigPath overrides the config file, empty resolves it as usual
+func SetConfigPath(path string) {
+	configPath = path
+}
+
+// GetConfigPath returns the config file given with --config, in
+// LAZYLANG_CONFIG, lazylang.json in the working directory or config.json in
+// ConfigDir, in that order
+func GetConfigPath() (string, error) {
+	if configPath != "" {
+		return configPath, nil
+	}
+	if path := os.Getenv(ConfigEnv); path != "" {
+		return path, nil
+	}
+	if _, err := os.Stat(localConfigName); err == nil {
+		if path, err := filepath.Abs(localConfigName); err == nil {
+			return path, nil
+		}
+	}
+	// An existing config is read even when its directory is read-only
+	if dir, err := preferredDir("XDG_CONFIG_HOME", ".config"); err == nil {
+		path := filepath.Join(dir, "config.json")
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	dir, err := ConfigDir()
 	if err != nil {
-		d = "."
+		return "", err
 	}
-	return filepath.Join(d, ".config", "lazylang", "config.json")
+	return filepath.Join(dir, "config.json"), nil
+}
+
+func isGroqSTT(backend STTBackend) bool {
+	switch backend.Type {
+	case "", "hosted", "groq":
+		return true
+	}
+	return false
 }
 
 var invalidApiKey = errors.New("Invalid API key")
 
 func isValid(config Config, apiKey string) error {
 	model := config.STTBackend.Model
-	client := &http.Client{}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
 	url := fmt.Sprintf("%v/models/%v", groqAPIBaseURL, model)
-	req, err := http.NewRequest("GET", url , nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 
 	if err != nil {
 		return err
@@ -100,55 +455,156 @@ func isValid(config Config, apiKey string) error {
 	req.Header.Set("Authorization", "Bearer "+apiKey)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	log.Println(resp.StatusCode)
 	switch resp.StatusCode {
 	case http.StatusOK:
 		return nil
 	case http.StatusUnauthorized:
 		return invalidApiKey
 	default:
-		return errors.New("Invalid model")
+		return fmt.Errorf("Invalid model %s", model)
 	}
 }
 
-func resolvePiperVoice(language string, defaultConfig Config) (string, string) {
-	voices, err := piper.FetchVoices()
+// resolvePiperVoice picks the voice for the language by the quality
+// preference of the backend
+func resolvePiperVoice(language string, backend TTSBackend, defaultConfig Config) (string, string) {
+	dir, err := voicesDir(backend.VoicesDir)
 	if err != nil {
-		slog.Error("Failed to fetch voices; Defaulting to de_DE-karlsson-low.onnx", "error", err)
+		slog.Error("Failed to resolve voice; Defaulting to de_DE-karlsson-low.onnx", "language", language, "error", err)
 		return defaultConfig.TTSBackend.Voice, defaultConfig.Language
 	}
-	var v string
-	for _, voice := range voices {
-		if voice.Language.Family == language {
-			v = voice.Key
-		}
-	}
-
-	if v == "" {
-		slog.Error("Language not found in voices; Defaulting to de_DE-karlsson-low.onnx", "language", language)
+	voice, err := piper.ResolveVoice(dir, voiceLanguage(language), backend.QualityPreference)
+	if err != nil {
+		slog.Error("Failed to resolve voice; Defaulting to de_DE-karlsson-low.onnx", "language", language, "error", err)
 		return defaultConfig.TTSBackend.Voice, defaultConfig.Language
 	}
-	return v + ".onnx", language
+	return voice.Key + ".onnx", language
 }
 
 func populateDefaults(config Config) Config {
 	defaultConfig := NewConfig()
+	for name, profile := range config.Profiles {
+		config.Profiles[name] = populateDefaults(profile)
+	}
+
 	if config.LibreTranslateURL == "" {
 		config.LibreTranslateURL = defaultConfig.LibreTranslateURL
 	}
 
+	if config.LLM.BaseURL == "" {
+		config.LLM.BaseURL = defaultConfig.LLM.BaseURL
+	}
+	if config.LLM.Model == "" {
+		config.LLM.Model = defaultConfig.LLM.Model
+	}
+	if config.LLM.APIKeyEnv == "" && config.LLM.BaseURL == defaultConfig.LLM.BaseURL {
+		config.LLM.APIKeyEnv = defaultConfig.LLM.APIKeyEnv
+	}
+
+	if config.STTBackend.Type == "" {
+		config.STTBackend.Type = defaultConfig.STTBackend.Type
+	}
+	// Other providers pick their own default model
+	if config.STTBackend.Model == "" && isGroqSTT(config.STTBackend) {
+		config.STTBackend.Model = defaultConfig.STTBackend.Model
+	}
+	if config.STTBackend.TimeoutSeconds == 0 {
+		config.STTBackend.TimeoutSeconds = defaultConfig.STTBackend.TimeoutSeconds
+	}
+	if config.STTBackend.MaxRetries == 0 {
+		config.STTBackend.MaxRetries = defaultConfig.STTBackend.MaxRetries
+	}
+
+	// Without a writable directory recordings and exports are not saved
+	if config.RecordingsDir == "" {
+		config.RecordingsDir, _ = defaultRecordingsDir()
+	}
+	if config.ExportDir == "" {
+		config.ExportDir, _ = defaultExportDir()
+	}
+
+	if config.VAD.Threshold == 0 {
+		config.VAD.Threshold = defaultConfig.VAD.Threshold
+	}
+	if config.VAD.SilenceMs == 0 {
+		config.VAD.SilenceMs = defaultConfig.VAD.SilenceMs
+	}
+
+	if config.NoiseGate.Margin == 0 {
+		config.NoiseGate.Margin = defaultConfig.NoiseGate.Margin
+	}
+
+	if config.TrimSilence.Threshold == 0 {
+		config.TrimSilence.Threshold = defaultConfig.TrimSilence.Threshold
+	}
+	if config.Translation.TimeoutSeconds == 0 {
+		config.Translation.TimeoutSeconds = defaultConfig.Translation.TimeoutSeconds
+	}
+	if config.Log.Level == "" {
+		config.Log.Level = defaultConfig.Log.Level
+	}
+	if config.Log.MaxSizeMB == 0 {
+		config.Log.MaxSizeMB = defaultConfig.Log.MaxSizeMB
+	}
+
+	if config.MinRecordingMs == nil {
+		config.MinRecordingMs = defaultConfig.MinRecordingMs
+	}
+	if config.SilencePeak == nil {
+		config.SilencePeak = defaultConfig.SilencePeak
+	}
+
+	if config.Volume == 0 {
+		config.Volume = defaultConfig.Volume
+	}
+
+	if config.SidebarWidth == 0 {
+		config.SidebarWidth = defaultConfig.SidebarWidth
+	}
+
+	if config.HandsFreeDelayMs == 0 {
+		config.HandsFreeDelayMs = defaultConfig.HandsFreeDelayMs
+	}
+
+	if config.RecordMode == "" {
+		config.RecordMode = defaultConfig.RecordMode
+	}
+
+	if config.TurnPolicy == "" {
+		config.TurnPolicy = defaultConfig.TurnPolicy
+	}
+
+	if config.ResponseStyle == "" {
+		config.ResponseStyle = defaultConfig.ResponseStyle
+	}
+
 	if config.TTSBackend.Type == "" {
 		config.TTSBackend.Type = defaultConfig.TTSBackend.Type
 	}
 
+	if config.TTSBackend.SpeechRate == 0 {
+		config.TTSBackend.SpeechRate = defaultConfig.TTSBackend.SpeechRate
+	}
+	if config.TTSBackend.WordPracticeRate == 0 {
+		config.TTSBackend.WordPracticeRate = defaultConfig.TTSBackend.WordPracticeRate
+	}
+
+	// Unknown languages are kept for the config check to report
+	if language, err := normalizeLanguage(config.Language); err == nil {
+		config.Language = language
+	}
+	if language, err := normalizeLanguage(config.TargetTranslationLanguage); err == nil {
+		config.TargetTranslationLanguage = language
+	}
+
 	if config.TTSBackend.Type == "piper" && config.TTSBackend.Voice == "" {
-		voice, language := resolvePiperVoice(config.Language, defaultConfig)
+		voice, language := resolvePiperVoice(config.Language, config.TTSBackend, defaultConfig)
 		config.TTSBackend.Voice = voice
 		config.Language = language
 	}
@@ -156,38 +612,61 @@ func populateDefaults(config Config) Config {
 	return config
 }
 
-func GetConfig(apiKey string) (Config, error) {
-	configPath := GetConfigPath()
+// GetConfig reads the config file and returns the profile named, the
+// default one or the one picked in the terminal
+func GetConfig(profile string) (Config, error) {
+	configPath, err := GetConfigPath()
+	// The defaults are used without saving them
+	if errors.Is(err, errNoStorage) {
+		return selectProfile(populateDefaults(NewConfig()), profile, nil)
+	}
 	configFile, err := os.Open(configPath)
 
 	if errors.Is(err, os.ErrNotExist) {
-		c, err := CreateDefaultConfig()
+		c, err := CreateDefaultConfig(configPath)
 		if err != nil {
-			return NewConfig(), err
+			return populateDefaults(NewConfig()), err
 		}
-		return c, nil
+		c, err = selectProfile(c, profile, nil)
+		return populateDefaults(c), err
 	}
 
 	if err != nil {
-		return NewConfig(), err
+		return populateDefaults(NewConfig()), err
 	}
 
 	defer configFile.Close()
 
 	byteValue, _ := io.ReadAll(configFile)
-	var config Config
-
-	err = json.Unmarshal(byteValue, &config)
-
+	migrated, changed, err := migrateConfig(byteValue)
 	if err != nil {
-		return NewConfig(), err
+		return populateDefaults(NewConfig()), ConfigError{Path: configPath, Problems: []ConfigProblem{{Message: err.Error()}}}
 	}
-
-	err = isValid(config, apiKey)
+	config, err := loadConfig(configPath, migrated, profile, pickProfile)
 	if err != nil {
-		return NewConfig(), err
+		return populateDefaults(NewConfig()), err
+	}
+	// Written back only once the migrated config loaded
+	if changed {
+		if err := saveMigrated(configPath, byteValue, migrated); err != nil {
+			slog.Error("Failed to save the migrated config", "error", err)
+		} else {
+			slog.Info("Migrated config", "version", ConfigVersion, "backup", configPath+".bak")
+		}
 	}
-
-	config = populateDefaults(config)
 	return config, nil
 }
+
+// loadConfig parses the config file and fills in the defaults of the
+// profile selected
+func loadConfig(path string, data []byte, profile string, pick func(names []string) (string, error)) (Config, error) {
+	config, err := parseConfig(path, data)
+	if err != nil {
+		return config, err
+	}
+	config, err = selectProfile(config, profile, pick)
+	if err != nil {
+		return config, err
+	}
+	return populateDefaults(config), nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGetConfigFirstRunFillsDefaults(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "lazylang", "config.json")
+	t.Setenv(ConfigEnv, path)
+
+	config, err := GetConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("config not created: %v", err)
+	}
+	if config.ExportDir == "" || config.RecordingsDir == "" {
+		t.Errorf("directories not filled in, export_dir %q recordings_dir %q", config.ExportDir, config.RecordingsDir)
+	}
+
+	again, err := GetConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ExportDir != config.ExportDir {
+		t.Errorf("export_dir is %q on the first run and %q after", config.ExportDir, again.ExportDir)
+	}
+}
+
+func TestRecordingChecksTurnOff(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		minDuration time.Duration
+		silencePeak float64
+	}{
+		{"missing", `{}`, 300 * time.Millisecond, 0.02},
+		{"set", `{"min_recording_ms": 500, "silence_peak": 0.1}`, 500 * time.Millisecond, 0.1},
+		{"off", `{"min_recording_ms": 0, "silence_peak": 0}`, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var config Config
+			if err := json.Unmarshal([]byte(tt.file), &config); err != nil {
+				t.Fatal(err)
+			}
+			config = populateDefaults(config)
+			if got := config.MinRecording(); got != tt.minDuration {
+				t.Errorf("minimum recording is %v", got)
+			}
+			if got := config.SilenceThreshold(); got != tt.silencePeak {
+				t.Errorf("silence peak is %v", got)
+			}
+		})
+	}
+}
+
+func TestGetConfigPathOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		flag  bool
+		env   bool
+		local bool
+		// xdg is where XDG_CONFIG_HOME points, absolute or relative
+		xdg  string
+		want string
+	}{
+		{"flag", true, true, true, "absolute", "flag"},
+		{"environment", false, true, true, "absolute", "env"},
+		{"working directory", false, false, true, "absolute", "local"},
+		{"XDG_CONFIG_HOME", false, false, false, "absolute", "xdg"},
+		{"relative XDG_CONFIG_HOME", false, false, false, "relative", "home"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			work, home := t.TempDir(), t.TempDir()
+			t.Chdir(work)
+			t.Setenv("HOME", home)
+			t.Cleanup(func() { SetConfigPath("") })
+
+			paths := map[string]string{
+				"flag":  filepath.Join(t.TempDir(), "flag.json"),
+				"env":   filepath.Join(t.TempDir(), "env.json"),
+				"local": filepath.Join(work, localConfigName),
+				"home":  filepath.Join(home, ".config", "lazylang", "config.json"),
+			}
+			if tt.xdg == "relative" {
+				t.Setenv("XDG_CONFIG_HOME", "xdg")
+				paths["xdg"] = filepath.Join(work, "xdg", "lazylang", "config.json")
+			} else {
+				xdg := t.TempDir()
+				t.Setenv("XDG_CONFIG_HOME", xdg)
+				paths["xdg"] = filepath.Join(xdg, "lazylang", "config.json")
+			}
+			// The XDG and home configs always exist, even under a relative
+			// XDG_CONFIG_HOME
+			for _, path := range []string{paths["xdg"], paths["home"]} {
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.local {
+				if err := os.WriteFile(paths["local"], []byte("{}"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.flag {
+				SetConfigPath(paths["flag"])
+			}
+			if tt.env {
+				t.Setenv(ConfigEnv, paths["env"])
+			} else {
+				t.Setenv(ConfigEnv, "")
+			}
+
+			got, err := GetConfigPath()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != paths[tt.want] {
+				t.Errorf("config path is %q, want %q", got, paths[tt.want])
+			}
+		})
+	}
+}
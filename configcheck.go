@@ -0,0 +1,434 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"lazylang/piper"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ConfigProblem is a mistake in the config file, Line is zero when it isn't
+// tied to one place
+type ConfigProblem struct {
+	Line    int
+	Message string
+}
+
+func (p ConfigProblem) String() string {
+	if p.Line == 0 {
+		return p.Message
+	}
+	return fmt.Sprintf("line %d: %s", p.Line, p.Message)
+}
+
+// ConfigError lists the problems found in the config file
+type ConfigError struct {
+	Path     string
+	Problems []ConfigProblem
+}
+
+func (e ConfigError) Error() string {
+	lines := []string{"invalid config " + e.Path}
+	for _, problem := range e.Problems {
+		lines = append(lines, "  "+problem.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseConfig decodes the config file and checks it for unknown fields and
+// invalid values, the decoded config is returned along with a ConfigError
+// so it can still be shown
+func parseConfig(path string, data []byte) (Config, error) {
+	var config Config
+	data, migrated, err := migrateConfig(data)
+	if err != nil {
+		return config, ConfigError{Path: path, Problems: []ConfigProblem{{Message: err.Error()}}}
+	}
+	err = json.Unmarshal(data, &config)
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return config, ConfigError{Path: path, Problems: []ConfigProblem{{lineAt(data, syntaxErr.Offset), syntaxErr.Error()}}}
+	case errors.As(err, &typeErr):
+		message := fmt.Sprintf("%s should be %s, not a %s", typeErr.Field, jsonTypeName(typeErr.Type), typeErr.Value)
+		return config, ConfigError{Path: path, Problems: []ConfigProblem{{lineAt(data, typeErr.Offset), message}}}
+	case err != nil:
+		return config, err
+	}
+
+	problems := unknownFields(data)
+	// The lines of a migrated config are not the lines of the file
+	if migrated {
+		for i := range problems {
+			problems[i].Line = 0
+		}
+	}
+	problems = append(problems, invalidValues(config)...)
+	if len(problems) > 0 {
+		return config, ConfigError{Path: path, Problems: problems}
+	}
+	return config, nil
+}
+
+// jsonTypeName names a Go type the way it is written in the config
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice:
+		return "a list"
+	default:
+		return "an object"
+	}
+}
+
+func lineAt(data []byte, offset int64) int {
+	offset = min(offset, int64(len(data)))
+	return 1 + bytes.Count(data[:offset], []byte("\n"))
+}
+
+// unknownFields walks the config next to the Config type and reports the
+// keys no field is decoded from, they would be silently ignored otherwise
+func unknownFields(data []byte) []ConfigProblem {
+	var problems []ConfigProblem
+	dec := json.NewDecoder(bytes.NewReader(data))
+	checkFields(dec, data, reflect.TypeOf(Config{}), "", &problems)
+	return problems
+}
+
+func checkFields(dec *json.Decoder, data []byte, t reflect.Type, path string, problems *[]ConfigProblem) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	isProfiles := t.Kind() == reflect.Map && t.Elem().Kind() == reflect.Struct
+	if t.Kind() != reflect.Struct && !isProfiles {
+		return skipValue(dec)
+	}
+
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	// A null or mistyped value is reported by the decoder itself
+	if token != json.Delim('{') {
+		return skipRest(dec, token)
+	}
+
+	if isProfiles {
+		for dec.More() {
+			name, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if err := checkFields(dec, data, t.Elem(), fmt.Sprintf("%s%v.", path, name), problems); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+		return err
+	}
+
+	fields := jsonFields(t)
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key := token.(string)
+		line := lineAt(data, dec.InputOffset())
+
+		// Like encoding/json, keys match field names in any case
+		i := slices.IndexFunc(fields, func(f jsonField) bool { return strings.EqualFold(f.name, key) })
+		if i < 0 {
+			*problems = append(*problems, ConfigProblem{line, unknownFieldMessage(path+key, fields)})
+			if err := skipValue(dec); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := checkFields(dec, data, fields[i].typ, path+fields[i].name+".", problems); err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
+
+func skipValue(dec *json.Decoder) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	return skipRest(dec, token)
+}
+
+// skipRest consumes the rest of an object or array whose first token was
+// already read
+func skipRest(dec *json.Decoder, token json.Token) error {
+	if token != json.Delim('{') && token != json.Delim('[') {
+		return nil
+	}
+	for depth := 1; depth > 0; {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	return nil
+}
+
+type jsonField struct {
+	name string
+	typ  reflect.Type
+}
+
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, jsonField{name, field.Type})
+	}
+	return fields
+}
+
+// unknownFieldMessage suggests the closest known field, misspelled keys are
+// the usual reason for unknown ones
+func unknownFieldMessage(key string, fields []jsonField) string {
+	message := fmt.Sprintf("unknown field %q", key)
+
+	name := key[strings.LastIndex(key, ".")+1:]
+	best, bestDistance := "", len(name)/3+1
+	for _, field := range fields {
+		if d := editDistance(strings.ToLower(name), field.name); d <= bestDistance {
+			best, bestDistance = field.name, d
+		}
+	}
+	if best != "" {
+		message += fmt.Sprintf(", did you mean %q?", best)
+	}
+	return message
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// oneOf reports a value outside of allowed, empty values fall back to the
+// default and are always allowed
+func oneOf[T ~string](field string, value T, allowed ...T) []ConfigProblem {
+	if value == "" || slices.Contains(allowed, value) {
+		return nil
+	}
+	names := make([]string, len(allowed))
+	for i, a := range allowed {
+		names[i] = string(a)
+	}
+	return []ConfigProblem{{Message: fmt.Sprintf("%s is %q, it should be one of %s", field, value, strings.Join(names, ", "))}}
+}
+
+func invalidTTSValues(field string, tts TTSBackend) []ConfigProblem {
+	problems := oneOf(field+".type", tts.Type, "piper", "elevenlabs", "openai", "espeak")
+	problems = append(problems, oneOf(field+".format", tts.Format, "pcm", "wav")...)
+	for _, quality := range tts.QualityPreference {
+		problems = append(problems, oneOf(field+".quality_preference", quality, piper.DefaultQualityPreference...)...)
+	}
+	if tts.Fallback != nil {
+		problems = append(problems, invalidTTSValues(field+".fallback", *tts.Fallback)...)
+	}
+	return problems
+}
+
+// invalidValues checks the fields that only take a few values, in the config
+// and every profile
+func invalidValues(config Config) []ConfigProblem {
+	problems := invalidConfigValues("", config)
+	if config.DefaultProfile != "" {
+		if _, ok := config.Profiles[config.DefaultProfile]; !ok {
+			problems = append(problems, ConfigProblem{Message: fmt.Sprintf("default_profile %q is not one of the profiles", config.DefaultProfile)})
+		}
+	}
+	for _, name := range profileNames(config) {
+		profile := config.Profiles[name]
+		field := "profiles." + name + "."
+		if len(profile.Profiles) > 0 || profile.DefaultProfile != "" {
+			problems = append(problems, ConfigProblem{Message: fmt.Sprintf("%sprofiles can't be nested", field)})
+		}
+		problems = append(problems, invalidConfigValues(field, profile)...)
+	}
+	return problems
+}
+
+func invalidConfigValues(prefix string, config Config) []ConfigProblem {
+	problems := invalidTTSValues(prefix+"tts_backend", config.TTSBackend)
+	if _, err := normalizeLanguage(config.Language); err != nil {
+		problems = append(problems, ConfigProblem{Message: fmt.Sprintf("%slanguage: %v", prefix, err)})
+	}
+	if _, err := normalizeLanguage(config.TargetTranslationLanguage); err != nil {
+		problems = append(problems, ConfigProblem{Message: fmt.Sprintf("%starget_translation_language: %v", prefix, err)})
+	}
+	problems = append(problems, oneOf(prefix+"stt_backend.type", config.STTBackend.Type, "hosted", "groq", "openai", "deepgram")...)
+	problems = append(problems, oneOf(prefix+"stt_backend.upload_format", config.STTBackend.UploadFormat, "wav", "flac", "opus")...)
+	problems = append(problems, oneOf(prefix+"record_mode", config.RecordMode, ToggleRecording, HoldRecording, TapRecording)...)
+	problems = append(problems, oneOf(prefix+"turn_policy", config.TurnPolicy, QueueTurns, CancelTurns)...)
+	problems = append(problems, oneOf(prefix+"translation.provider", config.Translation.Provider, "libretranslate", "deepl", "google")...)
+	problems = append(problems, oneOf(prefix+"log.level", strings.ToLower(config.Log.Level), "debug", "info", "warn", "error")...)
+	problems = append(problems, oneOf(prefix+"response_style", config.ResponseStyle, ShortResponse, NormalResponse, DetailedResponse)...)
+	_, themeProblems := config.Theme.resolve(darkBackground)
+	for _, problem := range themeProblems {
+		problems = append(problems, ConfigProblem{Message: prefix + problem})
+	}
+	if config.SidebarWidth != 0 && config.SidebarWidth != clampSidebarWidth(config.SidebarWidth) {
+		problems = append(problems, ConfigProblem{Message: fmt.Sprintf("%ssidebar_width is %d, it should be between %d and %d", prefix, config.SidebarWidth, minSidebarWidth, maxSidebarWidth)})
+	}
+	if _, err := NewKeymap(config.Keys); err != nil {
+		problems = append(problems, ConfigProblem{Message: strings.TrimPrefix(strings.TrimSuffix(prefix, ".")+": ", ": ") + err.Error()})
+	}
+	return problems
+}
+
+// unknownLanguage checks the language against the Piper voices, it needs the
+// voices list and is skipped when it can't be fetched
+func unknownLanguage(prefix string, config Config) []ConfigProblem {
+	if config.TTSBackend.Type != "" && config.TTSBackend.Type != "piper" {
+		return nil
+	}
+	dir, err := voicesDir(config.TTSBackend.VoicesDir)
+	if err != nil {
+		return []ConfigProblem{{Message: fmt.Sprintf("%stts_backend.voices_dir: %v", prefix, err)}}
+	}
+	voices, err := piper.FetchVoices(dir)
+	if err != nil {
+		return nil
+	}
+
+	families := map[string]bool{}
+	for _, voice := range voices {
+		families[voice.Language.Family] = true
+	}
+	language, err := normalizeLanguage(config.Language)
+	if err != nil || language == "" || families[languageCode(language)] {
+		return nil
+	}
+	languages := make([]string, 0, len(families))
+	for family := range families {
+		languages = append(languages, family)
+	}
+	sort.Strings(languages)
+	return []ConfigProblem{{Message: fmt.Sprintf("%slanguage %q has no Piper voice, use one of %s (see lazylang voices languages)", prefix, config.Language, strings.Join(languages, ", "))}}
+}
+
+// untransliteratedLanguage reports show_transliteration for a language that
+// isn't transliterated, it is only shown by config check as the replies
+// still work without
+func untransliteratedLanguage(prefix string, config Config) []ConfigProblem {
+	language, err := normalizeLanguage(config.Language)
+	if !config.ShowTransliteration || err != nil || language == "" || hasTransliteration(language) {
+		return nil
+	}
+	return []ConfigProblem{{Message: fmt.Sprintf("%sshow_transliteration is not supported for %s, it only transliterates %s", prefix, languageName(language), strings.Join(transliteratedLanguages(), ", "))}}
+}
+
+const configUsage = "usage: lazylang config check"
+
+func runConfigCommand(args []string) error {
+	if len(args) != 1 || args[0] != "check" {
+		return errors.New(configUsage)
+	}
+	return checkConfig(os.Stdout)
+}
+
+// checkConfig reports the problems of the config file and prints the config
+// in effect, with the defaults filled in
+func checkConfig(w io.Writer) error {
+	path, err := GetConfigPath()
+	if err != nil {
+		return err
+	}
+	config := NewConfig()
+	var problems []ConfigProblem
+
+	data, err := os.ReadFile(path)
+	exists := !errors.Is(err, os.ErrNotExist)
+	switch {
+	case !exists:
+		fmt.Fprintf(os.Stderr, "%s doesn't exist, the defaults are used\n", path)
+	case err != nil:
+		return err
+	default:
+		config, err = parseConfig(path, data)
+		var configErr ConfigError
+		if errors.As(err, &configErr) {
+			problems = configErr.Problems
+		} else if err != nil {
+			return err
+		}
+		if err := configureHTTP(config); err != nil {
+			problems = append(problems, ConfigProblem{Message: err.Error()})
+		}
+		problems = append(problems, unknownLanguage("", config)...)
+		problems = append(problems, untransliteratedLanguage("", config)...)
+		for _, name := range profileNames(config) {
+			problems = append(problems, unknownLanguage("profiles."+name+".", config.Profiles[name])...)
+			problems = append(problems, untransliteratedLanguage("profiles."+name+".", config.Profiles[name])...)
+		}
+	}
+
+	resolved, err := json.MarshalIndent(populateDefaults(config), "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, string(resolved))
+
+	if len(problems) > 0 {
+		return ConfigError{Path: path, Problems: problems}
+	}
+	if exists {
+		fmt.Fprintf(os.Stderr, "%s is valid\n", path)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// pendingTranscription is a transcription held back until the student
+// confirmed or edited it
+type pendingTranscription struct {
+	sessionID  int
+	turn       int
+	text       string
+	confidence float64
+}
+
+// needsConfirmation reports whether a transcription is shown for review
+// instead of being sent straight to the LLM
+func (m model) needsConfirmation(confidence float64) bool {
+	return m.config.STTBackend.AlwaysConfirm || confidence < m.config.STTBackend.ConfirmBelow
+}
+
+func (m *model) confirmTranscription(p pendingTranscription) {
+	m.confirming = &p
+	m.UpdateStatus("Check the transcription")
+	m.resize()
+}
+
+// sendConfirmed sends the reviewed text as the turn the transcription was
+// made for
+func (m *model) sendConfirmed(text string) tea.Cmd {
+	p := m.confirming
+	m.confirming = nil
+	m.resize()
+	session := m.findSession(p.sessionID)
+	if session == nil {
+		return nil
+	}
+	if m.isStale(session, p.turn) || text == "" {
+		return m.drainTranscriptions(session)
+	}
+	return tea.Batch(m.sendTurn(session, p.turn, text), m.drainTranscriptions(session))
+}
+
+// discardConfirmation drops the transcription under review and moves on to
+// the next one
+func (m *model) discardConfirmation() tea.Cmd {
+	p := m.confirming
+	m.confirming = nil
+	m.resize()
+	if session := m.findSession(p.sessionID); session != nil {
+		return m.drainTranscriptions(session)
+	}
+	return nil
+}
+
+// confirmKey handles the keys of the review prompt, other keys keep working
+// as usual
+func (m *model) confirmKey(msg tea.KeyMsg) (tea.Cmd, bool) {
+	switch msg.String() {
+	case "enter":
+		return m.sendConfirmed(m.confirming.text), true
+	case "e":
+		m.input.SetValue(m.confirming.text)
+		m.input.CursorEnd()
+		return m.startTyping(), true
+	case "esc":
+		m.UpdateStatus("Transcription discarded")
+		return m.discardConfirmation(), true
+	}
+	return nil, false
+}
+
+func (m model) confirmView() string {
+	return styles.confirm.Width(m.fullWidth).Render(fmt.Sprintf("Heard (%.0f%% sure): %s  [enter send, e edit, esc discard]", m.confirming.confidence*100, m.confirming.text))
+}
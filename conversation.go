@@ -0,0 +1,296 @@
+package main
+
+import (
+	"fmt"
+	"lazylang/piper"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// aiBorder is drawn left of the rows of AI messages, it is not part of the
+// row text so navigation never sees it
+const aiBorder = "│ "
+
+type Role string
+
+const (
+	RoleUser  Role = "You"
+	RoleAI    Role = "AI"
+	RoleScore Role = "Score"
+)
+
+type Message struct {
+	ID   int
+	Role Role
+	Time time.Time
+	Text string
+	// Gloss is a translation of Text shown underneath it, it is never
+	// navigated, translated or spoken
+	Gloss string
+	// Transliteration spells Text in Latin letters underneath it, it is
+	// display only like Gloss
+	Transliteration string
+	// Scores mark the words of a RoleScore Text as matched or not
+	Scores []WordScore
+}
+
+type conversationRow struct {
+	text string
+	// navigable rows can be focused with j/k/w/b, gloss and transliteration
+	// rows are display only
+	navigable bool
+	// message is the index of the message the row belongs to
+	message int
+	// firstWord is the index within the message label of the row's first word
+	firstWord int
+	// border rows are drawn after aiBorder
+	border bool
+}
+
+// rowWords splits a row into the words focus navigation moves over, the
+// rendering, the extraction and the message mapping all use it
+func rowWords(row string) []string {
+	return strings.Fields(row)
+}
+
+func (msg Message) label() string {
+	return fmt.Sprintf("%s: %s ", msg.Role, msg.Text)
+}
+
+// labelStyle colors the role label of the message
+func (msg Message) labelStyle() (lipgloss.Style, bool) {
+	switch msg.Role {
+	case RoleUser:
+		return styles.userLabel, true
+	case RoleAI:
+		return styles.aiLabel, true
+	}
+	return lipgloss.Style{}, false
+}
+
+// wrapRows wraps the text at spaces to the given width. A word longer than
+// the width gets a row of its own instead of being split, so the words of
+// the rows are exactly the words of the text
+func wrapRows(text string, width int) []string {
+	var rows []string
+	for _, line := range strings.Split(text, "\n") {
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			rows = append(rows, "")
+			continue
+		}
+		row := words[0]
+		for _, word := range words[1:] {
+			if width > 0 && ansi.StringWidth(row)+1+ansi.StringWidth(word) > width {
+				rows = append(rows, row)
+				row = word
+				continue
+			}
+			row += " " + word
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// renderRows wraps every message to the width, keeping track of which message
+// and word each row starts with so focus can be mapped back to the message.
+// Turns are separated by a blank row, AI messages are narrower by the border
+func renderRows(messages []Message, width int) []conversationRow {
+	var rows []conversationRow
+	for i, msg := range messages {
+		if i > 0 {
+			rows = append(rows, conversationRow{message: i})
+		}
+		border := msg.Role == RoleAI
+		textWidth := width
+		if border && width > 0 {
+			textWidth = max(1, width-ansi.StringWidth(aiBorder))
+		}
+		text := strings.ReplaceAll(msg.label(), "\n\n", "\n")
+		word := 0
+		for _, row := range wrapRows(text, textWidth) {
+			// Blank lines between paragraphs have no word to focus
+			if len(rowWords(row)) == 0 {
+				rows = append(rows, conversationRow{message: i, border: border})
+				continue
+			}
+			rows = append(rows, conversationRow{text: row, navigable: true, message: i, firstWord: word, border: border})
+			word += len(rowWords(row))
+		}
+		if msg.Transliteration != "" {
+			for _, row := range wrapRows(msg.Transliteration, textWidth) {
+				rows = append(rows, conversationRow{text: row, message: i, border: border})
+			}
+		}
+		if msg.Gloss != "" {
+			for _, row := range wrapRows(msg.Gloss, textWidth) {
+				rows = append(rows, conversationRow{text: row, message: i, border: border})
+			}
+		}
+	}
+	return rows
+}
+
+// focusedMessage maps the focus position to the index of the message and the
+// index of the word within its text, the role label counts as word -1
+func (m model) focusedMessage() (int, int, bool) {
+	nav := 0
+	for _, row := range renderRows(m.messages, m.viewport.Width) {
+		if !row.navigable {
+			continue
+		}
+		if nav == m.focusRow {
+			return row.message, row.firstWord + m.focusWord - 1, true
+		}
+		nav++
+	}
+	return 0, 0, false
+}
+
+// focusOn moves the focus to a word of a message, the role label is word 0
+func (m *model) focusOn(message int, word int) bool {
+	nav := 0
+	for _, row := range renderRows(m.messages, m.viewport.Width) {
+		if !row.navigable {
+			continue
+		}
+		words := len(rowWords(row.text))
+		if row.message == message && word >= row.firstWord && word < row.firstWord+words {
+			m.focusRow, m.focusWord = nav, word-row.firstWord
+			return true
+		}
+		nav++
+	}
+	return false
+}
+
+// clampFocus keeps the focus within the rows when they changed under it
+func (m *model) clampFocus() {
+	rows := m.rows()
+	if len(rows) == 0 {
+		m.focusRow, m.focusWord = 0, 0
+		return
+	}
+	m.focusRow = min(max(m.focusRow, 0), len(rows)-1)
+	m.focusWord = min(max(m.focusWord, 0), len(rowWords(rows[m.focusRow]))-1)
+}
+
+// focusedSentence returns the sentence of the focused message containing the
+// focused word, the role label selects the first sentence
+func (m model) focusedSentence() (string, Message, bool) {
+	msgIdx, wordIdx, ok := m.focusedMessage()
+	if !ok || msgIdx >= len(m.messages) {
+		return "", Message{}, false
+	}
+	msg := m.messages[msgIdx]
+	if msg.Role == RoleScore {
+		return "", msg, false
+	}
+
+	sentences := piper.SplitSentences(msg.Text)
+	word := 0
+	for _, sentence := range sentences {
+		word += len(strings.Fields(sentence))
+		if wordIdx < word {
+			return sentence, msg, true
+		}
+	}
+	if len(sentences) == 0 {
+		return "", msg, false
+	}
+	return sentences[len(sentences)-1], msg, true
+}
+
+// navigableRows returns only the rows that focus navigation operates on
+func navigableRows(rows []conversationRow) []string {
+	var nav []string
+	for _, row := range rows {
+		if row.navigable {
+			nav = append(nav, row.text)
+		}
+	}
+	return nav
+}
+
+func (m model) rows() []string {
+	return navigableRows(renderRows(m.messages, m.viewport.Width))
+}
+
+// renderConversation produces the wrapped viewport text with the focused word
+// and the selection highlighted, glosses and transliterations dimmed and the
+// role labels colored
+func renderConversation(messages []Message, width int, focusRow int, focusWord int, sel *selection) string {
+	var st strings.Builder
+	navIndex := 0
+	for _, row := range renderRows(messages, width) {
+		if row.border {
+			st.WriteString(styles.aiBorder.Render(aiBorder))
+		}
+		switch {
+		case !row.navigable:
+			st.WriteString(styles.gloss.Render(row.text))
+		case sel.hasRow(navIndex):
+			st.WriteString(highlightSelection(row.text, navIndex, focusWord, navIndex == focusRow, sel))
+		case navIndex == focusRow:
+			st.WriteString(HighlightFocusWord(styleRow(row, messages[row.message], focusWord), focusWord))
+		default:
+			st.WriteString(styleRow(row, messages[row.message], -1))
+		}
+		if row.navigable {
+			navIndex++
+		}
+		st.WriteRune('\n')
+	}
+	return st.String()
+}
+
+// styleRow colors the role label and the scored words of a row, the word at
+// skip is left to the focus highlight. The text itself stays unstyled so
+// navigation never sees the styles
+func styleRow(row conversationRow, msg Message, skip int) string {
+	words := rowWords(row.text)
+	for i, word := range words {
+		index := row.firstWord + i
+		switch {
+		case i == skip:
+		case index == 0:
+			if style, ok := msg.labelStyle(); ok {
+				words[i] = style.Render(word)
+			}
+		case index-1 < len(msg.Scores):
+			words[i] = msg.Scores[index-1].style().Render(word)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func (m *model) refreshViewport() {
+	setViewportContent(m, renderConversation(m.messages, m.viewport.Width, m.focusRow, m.focusWord, m.selection()))
+}
+
+// transliteration is the text in Latin letters with show_transliteration,
+// empty for languages written in them
+func (m model) transliteration(text string) string {
+	if !m.config.ShowTransliteration || !hasTransliteration(m.config.Language) {
+		return ""
+	}
+	return transliterate(text, m.config.Language)
+}
+
+// addMessage appends the message to the session, only the active session is
+// re-rendered so background replies don't hijack the view
+func (m *model) addMessage(s *Session, msg Message) int {
+	s.nextMessageID++
+	msg.ID = s.nextMessageID
+	msg.Time = time.Now()
+	s.messages = append(s.messages, msg)
+	if m.isActive(s) {
+		m.refreshViewport()
+		m.viewport.GotoBottom()
+	}
+	return len(s.messages) - 1
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/muesli/termenv"
+)
+
+func TestWrapRows(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		width int
+		want  []string
+	}{
+		{"fits", "eins zwei", 20, []string{"eins zwei"}},
+		{"wraps at spaces", "eins zwei drei vier", 10, []string{"eins zwei", "drei vier"}},
+		{"exact width", "eins zwei", 9, []string{"eins zwei"}},
+		{"long word", "a Donaudampfschifffahrt b", 8, []string{"a", "Donaudampfschifffahrt", "b"}},
+		{"no width", "eins zwei drei", 0, []string{"eins zwei drei"}},
+		{"lines", "eins\n\nzwei", 20, []string{"eins", "", "zwei"}},
+		{"wide runes", "你好 世界", 5, []string{"你好", "世界"}},
+		{"spaces collapse", "  eins   zwei ", 20, []string{"eins zwei"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wrapRows(tt.text, tt.width); !slices.Equal(got, tt.want) {
+				t.Errorf("wrapRows(%q, %d) = %q, want %q", tt.text, tt.width, got, tt.want)
+			}
+		})
+	}
+}
+
+var conversation = []Message{
+	{Role: RoleUser, Text: "Wie spät ist es?"},
+	{Role: RoleAI, Text: "Es ist halb drei am Nachmittag, also Zeit für Kaffee und Kuchen.\n\nMöchtest du etwas trinken?", Gloss: "It is half past two"},
+	{Role: RoleScore, Text: "wie spät", Scores: []WordScore{{Word: "wie", Matched: true}, {Word: "spät"}}},
+}
+
+func TestRenderRowsKeepsWordsAtEveryWidth(t *testing.T) {
+	for _, width := range []int{1, 5, 12, 20, 40, 80} {
+		rows := renderRows(conversation, width)
+		for i, msg := range conversation {
+			var words []string
+			for _, row := range rows {
+				if row.message != i || !row.navigable {
+					continue
+				}
+				if row.firstWord != len(words) {
+					t.Errorf("width %d message %d: row starts at word %d after %d words", width, i, row.firstWord, len(words))
+				}
+				if row.border != (msg.Role == RoleAI) {
+					t.Errorf("width %d message %d: border %v", width, i, row.border)
+				}
+				rowWidth := width
+				if row.border {
+					rowWidth = max(1, width-ansi.StringWidth(aiBorder))
+				}
+				if w := ansi.StringWidth(row.text); w > rowWidth && len(rowWords(row.text)) > 1 {
+					t.Errorf("width %d: row %q is %d wide", width, row.text, w)
+				}
+				words = append(words, rowWords(row.text)...)
+			}
+			if want := strings.Fields(msg.label()); !slices.Equal(words, want) {
+				t.Errorf("width %d message %d: words %q, want %q", width, i, words, want)
+			}
+		}
+	}
+}
+
+func TestRenderRowsSeparatesTurns(t *testing.T) {
+	rows := renderRows(conversation, 80)
+	var blank []int
+	for i, row := range rows {
+		if i > 0 && row.message != rows[i-1].message {
+			if row.text != "" || row.navigable {
+				t.Errorf("message %d starts with %q instead of a blank row", row.message, row.text)
+			}
+			blank = append(blank, row.message)
+		}
+	}
+	if !slices.Equal(blank, []int{1, 2}) {
+		t.Errorf("separators before messages %v, want 1 and 2", blank)
+	}
+}
+
+func TestFocusOnAcrossWidths(t *testing.T) {
+	m := newTestModel(t, conversation...)
+	for _, width := range []int{80, 30, 15, 120, 40} {
+		m = update(t, m, tea.WindowSizeMsg{Width: width, Height: 24})
+		if !m.focusOn(1, 9) {
+			t.Fatalf("width %d: word 9 of the answer not found", width)
+		}
+		message, word, ok := m.focusedMessage()
+		if !ok || message != 1 || word != 8 {
+			t.Errorf("width %d: focused message %d word %d", width, message, word)
+		}
+		if got := rowWords(m.rows()[m.focusRow])[m.focusWord]; got != "für" {
+			t.Errorf("width %d: focused %q", width, got)
+		}
+	}
+	if m.focusOn(1, 100) {
+		t.Error("focused a word past the end of the answer")
+	}
+}
+
+func TestResizeKeepsFocusedWord(t *testing.T) {
+	m := newTestModel(t, conversation...)
+	m.focusOn(1, 12)
+	for _, width := range []int{30, 15, 100} {
+		m = update(t, m, tea.WindowSizeMsg{Width: width, Height: 24})
+		if message, word, _ := m.focusedMessage(); message != 1 || word != 11 {
+			t.Errorf("width %d: focus moved to message %d word %d", width, message, word)
+		}
+	}
+}
+
+func TestClampFocus(t *testing.T) {
+	m := newTestModel(t, conversation...)
+	rows := m.rows()
+
+	m.focusRow, m.focusWord = len(rows)+3, 50
+	m.clampFocus()
+	last := len(rows) - 1
+	if m.focusRow != last || m.focusWord != len(rowWords(rows[last]))-1 {
+		t.Errorf("focus clamped to row %d word %d, want the last word of row %d", m.focusRow, m.focusWord, last)
+	}
+
+	m.focusRow, m.focusWord = -2, -1
+	m.clampFocus()
+	if m.focusRow != 0 || m.focusWord != 0 {
+		t.Errorf("focus clamped to row %d word %d, want 0 0", m.focusRow, m.focusWord)
+	}
+
+	m = newTestModel(t)
+	m.focusRow, m.focusWord = 3, 3
+	m.clampFocus()
+	if m.focusRow != 0 || m.focusWord != 0 {
+		t.Errorf("focus without messages is row %d word %d", m.focusRow, m.focusWord)
+	}
+}
+
+// withColors renders styles with colors, tests don't run in a terminal
+func withColors(t *testing.T) {
+	previous := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.ANSI256)
+	t.Cleanup(func() { lipgloss.SetColorProfile(previous) })
+}
+
+func TestRenderConversationStylesRoles(t *testing.T) {
+	withColors(t)
+	out := renderConversation(conversation, 80, -1, 0, nil)
+	lines := strings.Split(out, "\n")
+
+	if !strings.HasPrefix(lines[0], styles.userLabel.Render("You:")) {
+		t.Errorf("user label not styled: %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[2], styles.aiBorder.Render(aiBorder)+styles.aiLabel.Render("AI:")) {
+		t.Errorf("AI row without border and label: %q", lines[2])
+	}
+	gloss := styles.gloss.Render("It is half past two")
+	if !strings.Contains(out, gloss) {
+		t.Errorf("gloss not dimmed in %q", out)
+	}
+	score := lines[len(lines)-2]
+	if !strings.HasPrefix(score, "Score:") {
+		t.Errorf("score label styled: %q", score)
+	}
+	if !strings.Contains(score, styles.matchedWord.Render("wie")) || !strings.Contains(score, styles.mismatchedWord.Render("spät")) {
+		t.Errorf("score words not colored: %q", score)
+	}
+
+	plain := ansi.Strip(out)
+	for _, row := range renderRows(conversation, 80) {
+		if !strings.Contains(plain, row.text) {
+			t.Errorf("row %q changed by styling", row.text)
+		}
+	}
+}
+
+func TestRenderConversationFocus(t *testing.T) {
+	withColors(t)
+	out := renderConversation(conversation, 80, 1, 3, nil)
+	line := strings.Split(out, "\n")[2]
+	if !strings.Contains(line, styles.focus.Render("halb")) {
+		t.Errorf("focused word not highlighted: %q", line)
+	}
+	if !strings.Contains(line, styles.aiLabel.Render("AI:")) {
+		t.Errorf("label not styled on the focused row: %q", line)
+	}
+}
+
+func TestAddMessageAssignsIDs(t *testing.T) {
+	m := newTestModel(t)
+	var ids []int
+	for _, text := range []string{"eins", "zwei", "drei"} {
+		index := m.addMessage(m.Session, Message{Role: RoleUser, Text: text})
+		if index != len(ids) {
+			t.Errorf("%s added at %d", text, index)
+		}
+		ids = append(ids, m.messages[index].ID)
+		if m.messages[index].Time.IsZero() {
+			t.Errorf("%s has no time", text)
+		}
+	}
+	if !slices.IsSorted(ids) || ids[0] == ids[1] || ids[1] == ids[2] {
+		t.Errorf("ids %v are not increasing", ids)
+	}
+
+	// IDs are never reused, so late replies can't find a newer message
+	m.Session.reset(m.config)
+	index := m.addMessage(m.Session, Message{Role: RoleUser, Text: "vier"})
+	if id := m.messages[index].ID; slices.Contains(ids, id) {
+		t.Errorf("id %d reused after clearing", id)
+	}
+	if m.Session.message(ids[0]) != nil {
+		t.Error("a cleared message is still found")
+	}
+
+	other := NewSession(2, m.llm, NewPrompt(m.config, nil))
+	m.addMessage(other, Message{Role: RoleUser, Text: "anderer Tab"})
+	if len(m.messages) != 1 {
+		t.Errorf("a message of another tab went to the active one: %q", transcript(m))
+	}
+}
+
+func TestEveryRoleRenders(t *testing.T) {
+	plain := ansi.Strip(renderConversation(conversation, 80, -1, 0, nil))
+	want := []string{
+		"You: Wie spät ist es?",
+		"",
+		aiBorder + "AI: Es ist halb drei am Nachmittag, also Zeit für Kaffee und Kuchen.",
+		aiBorder + "Möchtest du etwas trinken?",
+		aiBorder + "It is half past two",
+		"",
+		"Score: wie spät",
+	}
+	lines := strings.Split(strings.TrimSuffix(plain, "\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " ")
+	}
+	if !slices.Equal(lines, want) {
+		t.Errorf("rendered\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"lazylang/piper"
+	"log/slog"
+	"math"
+	"time"
+)
+
+const (
+	cueDuration  = 150 * time.Millisecond
+	cueAmplitude = 0.3
+	startCueHz   = 880
+	stopCueHz    = 440
+)
+
+// cueTone synthesizes a sine burst as little endian S16 mono PCM, the ends
+// are faded so the tone doesn't click
+func cueTone(frequency float64, duration time.Duration) []byte {
+	n := int(duration.Seconds() * sampleRate)
+	fade := n / 10
+	pcm := make([]byte, 0, n*2)
+	for i := 0; i < n; i++ {
+		envelope := 1.0
+		if i < fade {
+			envelope = float64(i) / float64(fade)
+		} else if i >= n-fade {
+			envelope = float64(n-i) / float64(fade)
+		}
+		sample := cueAmplitude * envelope * math.Sin(2*math.Pi*frequency*float64(i)/sampleRate)
+		pcm = binary.LittleEndian.AppendUint16(pcm, uint16(int16(sample*math.MaxInt16)))
+	}
+	return pcm
+}
+
+var (
+	startCue = cueTone(startCueHz, cueDuration)
+	stopCue  = cueTone(stopCueHz, cueDuration)
+)
+
+// playCue blocks until the tone finished playing
+func playCue(pcm []byte) {
+	err := piper.Play(context.Background(), bytes.NewReader(pcm), sampleRate, channels)
+	if err != nil {
+		slog.Error("Failed to play cue", "error", err)
+	}
+}
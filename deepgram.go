@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+const deepgramAPIURL = "https://api.deepgram.com/v1/listen"
+
+// DeepgramTranscriber uses the Deepgram prerecorded audio API
+type DeepgramTranscriber struct {
+	url    string
+	apiKey string
+	model  string
+}
+
+type DeepgramResponse struct {
+	Results struct {
+		Channels []struct {
+			Alternatives []struct {
+				Transcript string  `json:"transcript"`
+				Confidence float64 `json:"confidence"`
+			} `json:"alternatives"`
+		} `json:"channels"`
+	} `json:"results"`
+}
+
+// Transcribe uploads the WAV data as the request body, Deepgram has no free
+// text prompt so the prompt is ignored
+func (d *DeepgramTranscriber) Transcribe(ctx context.Context, audioData []byte, language string, prompt string) (Transcription, error) {
+	query := url.Values{}
+	query.Set("model", d.model)
+	query.Set("smart_format", "true")
+	if language != "" {
+		query.Set("language", language)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", d.url+"?"+query.Encode(), bytes.NewReader(audioData))
+	if err != nil {
+		return Transcription{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Token "+d.apiKey)
+	req.Header.Set("Content-Type", "audio/wav")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return Transcription{}, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Transcription{}, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return Transcription{}, TranscriptionError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var deepgramResp DeepgramResponse
+	if err := json.Unmarshal(body, &deepgramResp); err != nil {
+		return Transcription{}, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(deepgramResp.Results.Channels) == 0 || len(deepgramResp.Results.Channels[0].Alternatives) == 0 {
+		return Transcription{}, fmt.Errorf("failed to parse response: no transcript")
+	}
+
+	alternative := deepgramResp.Results.Channels[0].Alternatives[0]
+	return Transcription{Text: alternative.Transcript, Confidence: alternative.Confidence}, nil
+}
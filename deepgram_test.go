@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDeepgramTranscribe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Token secret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if got := r.Header.Get("Content-Type"); got != "audio/wav" {
+			t.Errorf("Content-Type = %q", got)
+		}
+		query := r.URL.Query()
+		if query.Get("model") != "nova-2" || query.Get("language") != "de" || query.Get("smart_format") != "true" {
+			t.Errorf("query = %v", query)
+		}
+		if body, _ := io.ReadAll(r.Body); string(body) != "wav" {
+			t.Errorf("body = %q", body)
+		}
+		w.Write([]byte(`{"results": {"channels": [{"alternatives": [{"transcript": "Guten Morgen", "confidence": 0.93}]}]}}`))
+	}))
+	defer server.Close()
+
+	deepgram := &DeepgramTranscriber{url: server.URL, apiKey: "secret", model: "nova-2"}
+	transcription, err := deepgram.Transcribe(context.Background(), []byte("wav"), "de", "ignored")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transcription.Text != "Guten Morgen" || transcription.Confidence != 0.93 {
+		t.Errorf("transcription = %+v", transcription)
+	}
+}
+
+func TestDeepgramWithoutLanguage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("language") {
+			t.Errorf("query = %v", r.URL.Query())
+		}
+		w.Write([]byte(`{"results": {"channels": [{"alternatives": [{"transcript": "Hallo"}]}]}}`))
+	}))
+	defer server.Close()
+
+	deepgram := &DeepgramTranscriber{url: server.URL, apiKey: "secret", model: "nova-2"}
+	if _, err := deepgram.Transcribe(context.Background(), []byte("wav"), "", ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeepgramErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		retryable bool
+		wantErr   string
+	}{
+		{"auth failure", http.StatusUnauthorized, `{"err_code": "INVALID_AUTH"}`, false, "status 401"},
+		{"rate limit", http.StatusTooManyRequests, `{}`, true, "status 429"},
+		{"server error", http.StatusBadGateway, `bad gateway`, true, "status 502"},
+		{"malformed", http.StatusOK, `{"results": `, false, "failed to parse response"},
+		{"no channels", http.StatusOK, `{"results": {"channels": []}}`, false, "no transcript"},
+		{"no alternatives", http.StatusOK, `{"results": {"channels": [{"alternatives": []}]}}`, false, "no transcript"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			deepgram := &DeepgramTranscriber{url: server.URL, apiKey: "secret", model: "nova-2"}
+			_, err := deepgram.Transcribe(context.Background(), []byte("wav"), "de", "")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %v, want %q", err, tt.wantErr)
+			}
+			var apiErr TranscriptionError
+			if errors.As(err, &apiErr) && apiErr.StatusCode != tt.status {
+				t.Errorf("status %d", apiErr.StatusCode)
+			}
+			if isRetryable(err) != tt.retryable {
+				t.Errorf("retryable is %v", isRetryable(err))
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+const (
+	deepLAPIURL     = "https://api.deepl.com/v2/translate"
+	deepLFreeAPIURL = "https://api-free.deepl.com/v2/translate"
+	// deepLQuotaExceeded is the status DeepL answers with once the character
+	// quota is used up
+	deepLQuotaExceeded = 456
+)
+
+// DeepLTranslator uses the DeepL API
+type DeepLTranslator struct {
+	url    string
+	apiKey string
+}
+
+// deepLURL is the endpoint of the key, keys of the free plan end in :fx
+func deepLURL(apiKey string) string {
+	if strings.HasSuffix(apiKey, ":fx") {
+		return deepLFreeAPIURL
+	}
+	return deepLAPIURL
+}
+
+// deepLLanguage is the DeepL code of a config language, targets need a
+// variant for English and Portuguese
+func deepLLanguage(language string, isTarget bool) string {
+	code := strings.ToUpper(translationLanguage(language))
+	if !isTarget {
+		return code
+	}
+	if _, region, ok := strings.Cut(language, "-"); ok && (code == "EN" || code == "PT") {
+		return code + "-" + strings.ToUpper(region)
+	}
+	switch code {
+	case "EN":
+		return "EN-US"
+	case "PT":
+		return "PT-PT"
+	}
+	return code
+}
+
+func (d *DeepLTranslator) Translate(ctx context.Context, text string, source string, target string) (string, error) {
+	results, err := d.TranslateBatch(ctx, []string{text}, source, target)
+	if err != nil {
+		return "", err
+	}
+	return results[0].Translation, nil
+}
+
+// deepLBatchSize is the most texts DeepL takes in one request
+const deepLBatchSize = 50
+
+// TranslateBatch sends the texts in requests of up to 50, the results keep
+// their order
+func (d *DeepLTranslator) TranslateBatch(ctx context.Context, texts []string, source string, target string) ([]BatchTranslation, error) {
+	results := make([]BatchTranslation, 0, len(texts))
+	for batch := range slices.Chunk(texts, deepLBatchSize) {
+		translated, err := d.translateBatch(ctx, batch, source, target)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, translated...)
+	}
+	return results, nil
+}
+
+// translateBatch sends up to deepLBatchSize texts in one request
+func (d *DeepLTranslator) translateBatch(ctx context.Context, texts []string, source string, target string) ([]BatchTranslation, error) {
+	reqBody, err := json.Marshal(map[string]any{
+		"text":        texts,
+		"source_lang": deepLLanguage(source, false),
+		"target_lang": deepLLanguage(target, true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal translation request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", d.url, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create translation request: %w", err)
+	}
+	req.Header.Set("Authorization", "DeepL-Auth-Key "+d.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call DeepL: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read translation response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, deepLError(resp.StatusCode, body)
+	}
+
+	var result struct {
+		Translations []struct {
+			Text string `json:"text"`
+		} `json:"translations"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse translation response: %w", err)
+	}
+	translations := make([]string, len(result.Translations))
+	for i, translation := range result.Translations {
+		translations[i] = translation.Text
+	}
+	return batchResults("DeepL", texts, translations)
+}
+
+// deepLError maps the status and the message of DeepL to the common
+// translation errors
+func deepLError(status int, body []byte) error {
+	var result struct {
+		Message string `json:"message"`
+	}
+	message := string(body)
+	if json.Unmarshal(body, &result) == nil && result.Message != "" {
+		message = result.Message
+	}
+
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return fmt.Errorf("%w: DeepL (status %d): %s", errTranslationAuth, status, message)
+	case status == deepLQuotaExceeded || status == http.StatusTooManyRequests:
+		return fmt.Errorf("%w: DeepL (status %d): %s", errTranslationQuota, status, message)
+	case status == http.StatusBadRequest && strings.Contains(message, "not supported"):
+		return fmt.Errorf("%w: DeepL: %s", errUnsupportedPair, message)
+	}
+	return TranslationError{Provider: "DeepL", StatusCode: status, Message: message}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestDeepLTranslate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "DeepL-Auth-Key secret:fx" {
+			t.Errorf("Authorization = %q", got)
+		}
+		var body struct {
+			Text       []string `json:"text"`
+			SourceLang string   `json:"source_lang"`
+			TargetLang string   `json:"target_lang"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(body.Text, []string{"Hund", "Katze"}) || body.SourceLang != "DE" || body.TargetLang != "EN-US" {
+			t.Errorf("request = %+v", body)
+		}
+		w.Write([]byte(`{"translations": [{"text": "dog"}, {"text": "cat"}]}`))
+	}))
+	defer server.Close()
+
+	deepL := &DeepLTranslator{url: server.URL, apiKey: "secret:fx"}
+	results, err := deepL.TranslateBatch(context.Background(), []string{"Hund", "Katze"}, "de", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Translation != "dog" || results[1].Translation != "cat" {
+		t.Errorf("results = %+v", results)
+	}
+}
+
+func TestDeepLTranslateInBatches(t *testing.T) {
+	var sizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Text []string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, len(body.Text))
+		var translations []map[string]string
+		for _, text := range body.Text {
+			translations = append(translations, map[string]string{"text": "translated " + text})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"translations": translations})
+	}))
+	defer server.Close()
+
+	texts := make([]string, 120)
+	for i := range texts {
+		texts[i] = strconv.Itoa(i)
+	}
+	results, err := (&DeepLTranslator{url: server.URL, apiKey: "key"}).TranslateBatch(context.Background(), texts, "de", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(sizes, []int{50, 50, 20}) {
+		t.Errorf("requests of %v texts", sizes)
+	}
+	if len(results) != len(texts) {
+		t.Fatalf("%d results for %d texts", len(results), len(texts))
+	}
+	for i, result := range results {
+		if result.Translation != "translated "+texts[i] {
+			t.Errorf("result %d is %q", i, result.Translation)
+		}
+	}
+}
+
+func TestDeepLLanguage(t *testing.T) {
+	tests := []struct {
+		language string
+		target   bool
+		want     string
+	}{
+		{"de", false, "DE"},
+		{"en", false, "EN"},
+		{"en", true, "EN-US"},
+		{"en-GB", true, "EN-GB"},
+		{"pt", true, "PT-PT"},
+		{"pt-BR", true, "PT-BR"},
+		{"no", true, "NB"},
+	}
+	for _, tt := range tests {
+		if got := deepLLanguage(tt.language, tt.target); got != tt.want {
+			t.Errorf("deepLLanguage(%q, %v) = %q, want %q", tt.language, tt.target, got, tt.want)
+		}
+	}
+	if deepLURL("key:fx") != deepLFreeAPIURL || deepLURL("key") != deepLAPIURL {
+		t.Error("free keys don't use the free endpoint")
+	}
+}
+
+func TestDeepLErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"forbidden", http.StatusForbidden, `{"message": "Wrong key"}`, errTranslationAuth},
+		{"quota", deepLQuotaExceeded, `{"message": "Quota exceeded"}`, errTranslationQuota},
+		{"rate limit", http.StatusTooManyRequests, `{"message": "Too many requests"}`, errTranslationQuota},
+		{"unsupported pair", http.StatusBadRequest, `{"message": "Value for 'target_lang' not supported."}`, errUnsupportedPair},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			_, err := (&DeepLTranslator{url: server.URL, apiKey: "key"}).Translate(context.Background(), "Hund", "de", "en")
+			if !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeepLServerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	_, err := (&DeepLTranslator{url: server.URL, apiKey: "key"}).Translate(context.Background(), "Hund", "de", "en")
+	var apiErr TranslationError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable || apiErr.Provider != "DeepL" {
+		t.Fatalf("error = %v, want a DeepL TranslationError with status 503", err)
+	}
+	if !isTransientTranslationError(err) {
+		t.Error("a server error should be retried")
+	}
+}
+
+func TestDeepLMissingTranslations(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"translations": [{"text": "dog"}]}`))
+	}))
+	defer server.Close()
+
+	_, err := (&DeepLTranslator{url: server.URL, apiKey: "key"}).TranslateBatch(context.Background(), []string{"Hund", "Katze"}, "de", "en")
+	if err == nil {
+		t.Error("expected an error when a text has no translation")
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// minDetectionConfidence is how sure the provider must be that a word is in
+// another language before it is translated from that one, from 0 to 1
+const minDetectionConfidence = 0.5
+
+// LanguageDetector is a Translator that can tell the language of a text
+type LanguageDetector interface {
+	DetectLanguage(ctx context.Context, text string) (language string, confidence float64, err error)
+}
+
+// languageCache remembers the language detected for each word, so words are
+// sent for detection once. An empty language is the configured one
+type languageCache struct {
+	mu        sync.Mutex
+	languages map[string]string
+}
+
+func newLanguageCache() *languageCache {
+	return &languageCache{languages: make(map[string]string)}
+}
+
+// sourceLanguage is the language to translate a word from, another one than
+// the configured language when the translator is confident about it. Failed
+// detections fall back to the configured language and are not cached
+func (c *languageCache) sourceLanguage(translator Translator, word string, config Config) string {
+	detector, ok := translator.(LanguageDetector)
+	if !ok || !config.Translation.DetectLanguage {
+		return config.Language
+	}
+	key := strings.ToLower(word)
+	c.mu.Lock()
+	language, ok := c.languages[key]
+	c.mu.Unlock()
+
+	if !ok {
+		var confidence float64
+		err := retryTranslation(config, func(ctx context.Context) error {
+			var err error
+			language, confidence, err = detector.DetectLanguage(ctx, word)
+			return err
+		})
+		if err != nil {
+			slog.Warn("Failed to detect the language", "word", word, "error", err)
+			return config.Language
+		}
+		slog.Debug("Detected language", "word", word, "language", language, "confidence", confidence)
+		if confidence < minDetectionConfidence || languageCode(language) == languageCode(config.Language) {
+			language = ""
+		}
+		c.mu.Lock()
+		c.languages[key] = language
+		c.mu.Unlock()
+	}
+
+	if language == "" {
+		return config.Language
+	}
+	return language
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gen2brain/malgo"
+)
+
+// ListDevices prints the capture and playback devices
+func ListDevices() error {
+	ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to initialize audio context: %w", err)
+	}
+	defer func() {
+		_ = ctx.Uninit()
+		ctx.Free()
+	}()
+
+	kinds := []struct {
+		title string
+		kind  malgo.DeviceType
+	}{
+		{"Capture devices", malgo.Capture},
+		{"Playback devices", malgo.Playback},
+	}
+
+	for _, k := range kinds {
+		devices, err := ctx.Devices(k.kind)
+		if err != nil {
+			return fmt.Errorf("failed to list devices: %w", err)
+		}
+
+		fmt.Printf("%s (%d):\n", k.title, len(devices))
+		fmt.Println(strings.Repeat("-", 50))
+		for _, device := range devices {
+			isDefault := ""
+			if device.IsDefault != 0 {
+				isDefault = " (default)"
+			}
+			fmt.Printf("  %s%s\n", device.Name(), isDefault)
+		}
+		fmt.Println()
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxDictionaryMeanings is how many meanings of a word are shown, Wiktionary
+// lists dozens for common words
+const maxDictionaryMeanings = 3
+
+// Dictionary is a word list loaded into memory for translating offline
+type Dictionary struct {
+	entries map[string][]string
+}
+
+// DictionaryLoaded is the dictionary of translation.dictionary, it is loaded
+// in the background as large ones take a moment
+type DictionaryLoaded struct {
+	path       string
+	dictionary *Dictionary
+	err        error
+}
+
+func loadDictionary(path string) tea.Cmd {
+	return func() tea.Msg {
+		dictionary, err := LoadDictionary(path)
+		return DictionaryLoaded{path: path, dictionary: dictionary, err: err}
+	}
+}
+
+func (m *model) dictionaryLoaded(msg DictionaryLoaded) {
+	if msg.path != m.config.Translation.Dictionary {
+		return
+	}
+	if msg.err != nil {
+		m.setStatus(statusError, fmt.Sprintf("Dictionary not loaded: %v", msg.err))
+		return
+	}
+	m.dictionary = msg.dictionary
+	m.UpdateStatus(fmt.Sprintf("Dictionary loaded, %d words", msg.dictionary.Len()))
+}
+
+// LoadDictionary reads a tab separated word list (.tsv, .txt), a StarDict
+// dictionary (.ifo next to its .idx and .dict files) or a Wiktionary dump
+// of wiktextract (.jsonl)
+func LoadDictionary(path string) (*Dictionary, error) {
+	d := &Dictionary{entries: make(map[string][]string)}
+	var err error
+	switch {
+	case strings.HasSuffix(path, ".ifo"):
+		err = d.loadStarDict(path)
+	case strings.HasSuffix(path, ".jsonl") || strings.HasSuffix(path, ".json"):
+		err = d.loadWiktextract(path)
+	default:
+		err = d.loadTSV(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the dictionary %s: %w", path, err)
+	}
+	if len(d.entries) == 0 {
+		return nil, fmt.Errorf("the dictionary %s has no words", path)
+	}
+	return d, nil
+}
+
+func (d *Dictionary) Len() int {
+	return len(d.entries)
+}
+
+// dictionaryKey ignores case and the punctuation around a word
+func dictionaryKey(word string) string {
+	word = strings.TrimFunc(word, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	return strings.ToLower(word)
+}
+
+func (d *Dictionary) add(word string, meaning string) {
+	key := dictionaryKey(word)
+	meaning = strings.TrimSpace(meaning)
+	if key == "" || meaning == "" || len(d.entries[key]) >= maxDictionaryMeanings {
+		return
+	}
+	d.entries[key] = append(d.entries[key], meaning)
+}
+
+// Lookup returns the meanings of a word or phrase joined by semicolons
+func (d *Dictionary) Lookup(word string) (string, bool) {
+	meanings, ok := d.Meanings(word)
+	return strings.Join(meanings, "; "), ok
+}
+
+// Meanings returns the meanings of a word or phrase in the order of the
+// dictionary
+func (d *Dictionary) Meanings(word string) ([]string, bool) {
+	meanings, ok := d.entries[dictionaryKey(word)]
+	return meanings, ok
+}
+
+// loadTSV reads lines of a word and its meaning separated by a tab, lines
+// starting with # are comments
+func (d *Dictionary) loadTSV(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		word, meaning, ok := strings.Cut(line, "\t")
+		if ok {
+			d.add(word, strings.ReplaceAll(meaning, "\t", "; "))
+		}
+	}
+	return scanner.Err()
+}
+
+// loadWiktextract reads the JSON lines of a wiktextract dump, such as the
+// ones on kaikki.org, taking the glosses of the senses as meanings
+func (d *Dictionary) loadWiktextract(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	// Entries of common words are large
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry struct {
+			Word   string `json:"word"`
+			Senses []struct {
+				Glosses []string `json:"glosses"`
+			} `json:"senses"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return err
+		}
+		for _, sense := range entry.Senses {
+			if len(sense.Glosses) > 0 {
+				d.add(entry.Word, sense.Glosses[len(sense.Glosses)-1])
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+var markupTag = regexp.MustCompile(`<[^>]*>`)
+
+// loadStarDict reads the index and the definitions next to the .ifo file,
+// either may be gzipped
+func (d *Dictionary) loadStarDict(ifoPath string) error {
+	info, err := os.ReadFile(ifoPath)
+	if err != nil {
+		return err
+	}
+	options := map[string]string{}
+	for _, line := range strings.Split(string(info), "\n") {
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), "="); ok {
+			options[key] = value
+		}
+	}
+	offsetBits := 32
+	if bits, err := strconv.Atoi(options["idxoffsetbits"]); err == nil {
+		offsetBits = bits
+	}
+
+	base := strings.TrimSuffix(ifoPath, ".ifo")
+	index, err := readMaybeGzipped(base+".idx", base+".idx.gz")
+	if err != nil {
+		return err
+	}
+	definitions, err := readMaybeGzipped(base+".dict", base+".dict.dz")
+	if err != nil {
+		return err
+	}
+
+	for len(index) > 0 {
+		end := bytes.IndexByte(index, 0)
+		if end < 0 || len(index) < end+1+offsetBits/8+4 {
+			return errors.New("the .idx file is truncated")
+		}
+		word := string(index[:end])
+		index = index[end+1:]
+		var offset uint64
+		if offsetBits == 64 {
+			offset = binary.BigEndian.Uint64(index)
+			index = index[8:]
+		} else {
+			offset = uint64(binary.BigEndian.Uint32(index))
+			index = index[4:]
+		}
+		size := uint64(binary.BigEndian.Uint32(index))
+		index = index[4:]
+		if offset+size > uint64(len(definitions)) {
+			return errors.New("the .dict file is truncated")
+		}
+		d.add(word, starDictText(definitions[offset:offset+size], options["sametypesequence"]))
+	}
+	return nil
+}
+
+// starDictText extracts the text fields of a definition, markup is removed
+// and binary fields such as sounds are skipped
+func starDictText(data []byte, types string) string {
+	var texts []string
+	for i := 0; len(data) > 0; i++ {
+		var kind byte
+		if types != "" {
+			if i >= len(types) {
+				break
+			}
+			kind = types[i]
+		} else {
+			kind, data = data[0], data[1:]
+		}
+		last := types != "" && i == len(types)-1
+
+		var field []byte
+		switch {
+		case unicode.IsUpper(rune(kind)):
+			// Binary data is prefixed with its size unless it is the last
+			// field of a sametypesequence
+			if last {
+				return strings.Join(texts, "; ")
+			}
+			if len(data) < 4 {
+				return strings.Join(texts, "; ")
+			}
+			size := min(int(binary.BigEndian.Uint32(data)), len(data)-4)
+			data = data[4+size:]
+			continue
+		case last:
+			field, data = data, nil
+		default:
+			end := bytes.IndexByte(data, 0)
+			if end < 0 {
+				end = len(data)
+			}
+			field = data[:end]
+			data = data[min(end+1, len(data)):]
+		}
+
+		text := string(field)
+		if kind == 'h' || kind == 'g' || kind == 'x' {
+			text = markupTag.ReplaceAllString(text, " ")
+		}
+		if text = strings.Join(strings.Fields(text), " "); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "; ")
+}
+
+// readMaybeGzipped reads the first of the files that exists, gunzipping the
+// second one
+func readMaybeGzipped(path string, gzipPath string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if !errors.Is(err, os.ErrNotExist) {
+		return data, err
+	}
+	file, err := os.Open(gzipPath)
+	if err != nil {
+		return nil, fmt.Errorf("neither %s nor %s found", path, gzipPath)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
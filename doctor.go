@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lazylang/piper"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gen2brain/malgo"
+)
+
+// doctorCheck is one item checked by lazylang doctor, failed optional checks
+// don't fail the command
+type doctorCheck struct {
+	name     string
+	optional bool
+	// run returns what was found or why the check failed
+	run  func(config Config) (string, error)
+	hint string
+}
+
+var doctorChecks = []doctorCheck{
+	{
+		name: "Groq API key",
+		run:  checkGroq,
+		hint: "Create a key at https://console.groq.com and export it as GROQ_API_KEY or put it into api_key_file",
+	},
+	{
+		name: "piper-tts",
+		run:  checkPiper,
+		hint: "Install it with `pip install piper-tts` or set tts_backend.type to espeak",
+	},
+	{
+		name:     "Piper voice",
+		optional: true,
+		run:      checkVoice,
+		hint:     "Run `lazylang voices download <voice>`, LazyLang also downloads it on start",
+	},
+	{
+		name: "Translation",
+		run:  checkTranslation,
+		hint: "Start LibreTranslate, for example with `docker compose up`, or check libre_translate_url, libre_translate_auth and the translation provider",
+	},
+	{
+		name:     "Writable directories",
+		optional: true,
+		run:      checkStorage,
+		hint:     "Set XDG_CONFIG_HOME, XDG_STATE_HOME and voices_dir to writable locations",
+	},
+	{
+		name: "Capture device",
+		run:  checkCapture,
+		hint: "Check the microphone is connected and not muted, `lazylang devices` lists the names for input_device",
+	},
+	{
+		name: "Playback device",
+		run:  checkPlayback,
+		hint: "Check the speakers are connected, `lazylang devices` lists the names for output_device",
+	},
+}
+
+// errSkipped marks a check that doesn't apply to the config
+var errSkipped = errors.New("skipped")
+
+// runDoctor checks the config and everything LazyLang needs, it fails when
+// a required check failed
+func runDoctor(profile string) error {
+	config := NewConfig()
+	failed := 0
+
+	path, err := GetConfigPath()
+	var data []byte
+	if err == nil {
+		data, err = os.ReadFile(path)
+	}
+	switch {
+	case errors.Is(err, errNoStorage):
+		config = populateDefaults(config)
+		printCheck("Config", "", err, "Set XDG_CONFIG_HOME or LAZYLANG_CONFIG to a writable location", true)
+	case errors.Is(err, os.ErrNotExist):
+		config = populateDefaults(config)
+		printCheck("Config", fmt.Sprintf("%s doesn't exist, using the defaults", path), nil, "", false)
+	case err != nil:
+		failed++
+		printCheck("Config", "", err, "", false)
+	default:
+		loaded, err := loadConfig(path, data, profile, nil)
+		if err != nil {
+			failed++
+			printCheck("Config", "", err, "Run `lazylang config check` for details", false)
+		} else {
+			config = loaded
+			printCheck("Config", path, nil, "", false)
+		}
+	}
+	if err := configureHTTP(config); err != nil {
+		failed++
+		printCheck("HTTP client", "", err, "Check proxy and ca_cert_file in the config", false)
+	}
+
+	for _, check := range doctorChecks {
+		detail, err := check.run(config)
+		if errors.Is(err, errSkipped) {
+			fmt.Printf("- %s: %s\n", check.name, detail)
+			continue
+		}
+		if err != nil && !check.optional {
+			failed++
+		}
+		printCheck(check.name, detail, err, check.hint, check.optional)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d checks failed", failed)
+	}
+	return nil
+}
+
+func printCheck(name string, detail string, err error, hint string, optional bool) {
+	switch {
+	case err == nil:
+		fmt.Printf("✓ %s: %s\n", name, detail)
+	case optional:
+		fmt.Printf("! %s: %v\n", name, err)
+	default:
+		fmt.Printf("✗ %s: %v\n", name, err)
+	}
+	if err != nil && hint != "" {
+		fmt.Printf("    %s\n", hint)
+	}
+}
+
+func checkGroq(config Config) (string, error) {
+	if !isGroqSTT(config.STTBackend) {
+		return "transcription uses " + config.STTBackend.Type, errSkipped
+	}
+	apiKey, err := groqAPIKey(config)
+	if err != nil {
+		return "", err
+	}
+	if err := isValid(config, apiKey); err != nil {
+		return "", err
+	}
+	return "valid for " + config.STTBackend.Model, nil
+}
+
+func checkPiper(config Config) (string, error) {
+	if !isPiper(config.TTSBackend) {
+		return "speech uses " + config.TTSBackend.Type, errSkipped
+	}
+	if !piper.Installed() {
+		return "", piper.ErrPiperNotInstalled
+	}
+	version, err := piper.Version()
+	if err != nil {
+		return "installed, version unknown", nil
+	}
+	return "version " + version, nil
+}
+
+func checkVoice(config Config) (string, error) {
+	if !isPiper(config.TTSBackend) {
+		return "speech uses " + config.TTSBackend.Type, errSkipped
+	}
+	dir, err := voicesDir(config.TTSBackend.VoicesDir)
+	if err != nil {
+		return "", err
+	}
+	voice := strings.TrimSuffix(config.TTSBackend.Voice, ".onnx")
+	if err := piper.VerifyVoice(dir, voice); err != nil {
+		return "", fmt.Errorf("%s: %w", voice, err)
+	}
+	return voice + " in " + dir.Path, nil
+}
+
+// checkTranslation asks LibreTranslate for its languages, the other
+// providers translate a word
+func checkTranslation(config Config) (string, error) {
+	if !config.Translation.IsEnabled() {
+		return "translation is disabled", errSkipped
+	}
+	translator, err := NewTranslator(config)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	libre, ok := translator.(*LibreTranslator)
+	if !ok {
+		if _, err := translator.Translate(ctx, "hello", "en", config.Language); err != nil {
+			return "", err
+		}
+		return config.Translation.Provider + " translates", nil
+	}
+
+	req, err := libre.newRequest(ctx, "GET", "/languages", nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return "", fmt.Errorf("%s rejected the basic auth credentials with status %d", libre.url, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s answered with status %d", libre.url, resp.StatusCode)
+	}
+	return "LibreTranslate reachable at " + libre.url, nil
+}
+
+// checkStorage finds the directories LazyLang writes to, they are replaced by
+// temporary ones when they can't be written
+func checkStorage(config Config) (string, error) {
+	ConfigDir()
+	if config.Log.File == "" {
+		StateDir()
+	}
+	if _, err := voicesDir(config.TTSBackend.VoicesDir); err != nil {
+		return "", err
+	}
+	if warning := storageWarning(); warning != "" {
+		return "", errors.New(warning)
+	}
+	return "config, log and voices directories are writable", nil
+}
+
+// checkCapture records for a moment and expects frames to arrive
+func checkCapture(config Config) (string, error) {
+	var frames atomic.Int64
+	device, err := malgoAudioContext{}.OpenCapture(config.InputDevice, func(data []byte) {
+		frames.Add(int64(len(data)))
+	})
+	if err != nil {
+		return "", err
+	}
+	defer device.Close()
+	if err := device.Start(); err != nil {
+		return "", err
+	}
+	time.Sleep(300 * time.Millisecond)
+	device.Stop()
+	if frames.Load() == 0 {
+		return "", errors.New("the device delivered no audio")
+	}
+	return deviceName(config.InputDevice), nil
+}
+
+// checkPlayback starts the output device with silence
+func checkPlayback(config Config) (string, error) {
+	ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to initialize audio context: %w", err)
+	}
+	defer func() {
+		_ = ctx.Uninit()
+		ctx.Free()
+	}()
+
+	deviceConfig := malgo.DefaultDeviceConfig(malgo.Playback)
+	deviceConfig.Playback.Format = malgo.FormatS16
+	deviceConfig.Playback.Channels = 1
+	deviceConfig.SampleRate = 22050
+	if config.OutputDevice != "" {
+		id, err := piper.FindDevice(ctx.Context, malgo.Playback, config.OutputDevice)
+		if err != nil {
+			return "", err
+		}
+		deviceConfig.Playback.DeviceID = id.Pointer()
+	}
+
+	device, err := malgo.InitDevice(ctx.Context, deviceConfig, malgo.DeviceCallbacks{
+		Data: func(output, input []byte, frameCount uint32) {
+			clear(output)
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer device.Uninit()
+	if err := device.Start(); err != nil {
+		return "", err
+	}
+	time.Sleep(100 * time.Millisecond)
+	device.Stop()
+	return deviceName(config.OutputDevice), nil
+}
+
+func deviceName(name string) string {
+	if name == "" {
+		return "default device works"
+	}
+	return fmt.Sprintf("%q works", name)
+}
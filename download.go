@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"lazylang/piper"
+	"log/slog"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// DownloadFailed is sent when a voice could not be downloaded
+type DownloadFailed struct {
+	err        error
+	model      string
+	completion string
+}
+
+// DownloadFinished is sent once a voice was downloaded, completion is the
+// answer that couldn't be spoken without it
+type DownloadFinished struct {
+	model      string
+	completion string
+}
+
+// DownloadProgress is sent while a voice downloads, the next update is read
+// from updates
+type DownloadProgress struct {
+	file    string
+	percent int
+	updates <-chan tea.Msg
+}
+
+// downloadVoice downloads into the voices_dir configured in the background
+// and reports progress through a channel read one message at a time
+func downloadVoice(msg DownloadModel, configuredDir string) tea.Cmd {
+	updates := make(chan tea.Msg)
+	go func() {
+		defer close(updates)
+		dir, err := voicesDir(configuredDir)
+		if err != nil {
+			updates <- DownloadFailed{err: err, model: msg.model, completion: msg.completion}
+			return
+		}
+		lastPercent := -1
+		err = piper.DownloadVoice(dir, languageCode(msg.language), msg.model, func(file string, done, total int64) {
+			if total <= 0 {
+				return
+			}
+			percent := int(done * 100 / total)
+			if percent == lastPercent {
+				return
+			}
+			lastPercent = percent
+			updates <- DownloadProgress{file: file, percent: percent, updates: updates}
+		})
+		if err != nil {
+			updates <- DownloadFailed{err: err, model: msg.model, completion: msg.completion}
+			return
+		}
+		updates <- DownloadFinished{model: msg.model, completion: msg.completion}
+	}()
+	return waitForDownload(updates)
+}
+
+// missingVoice is the configured Piper voice when it still has to be
+// downloaded, it is fetched at startup so the first answer isn't delayed
+func missingVoice(speaker Speaker) string {
+	voice, ok := speaker.(*piper.PiperVoice)
+	if !ok || voice.ModelInstalled() {
+		return ""
+	}
+	return voice.Model
+}
+
+// downloadModel starts downloading a voice, answers needing the voice being
+// downloaded already wait for it instead
+func (m *model) downloadModel(msg DownloadModel) tea.Cmd {
+	if msg.model == m.downloadingVoice {
+		if msg.completion != "" {
+			m.awaitingVoice = append(m.awaitingVoice, msg.completion)
+		}
+		m.setStatus(statusBusy, "Waiting for the voice download")
+		return nil
+	}
+	if m.downloadingVoice == "" {
+		m.downloadingVoice = msg.model
+	}
+	m.setStatus(statusBusy, "Downloading tts model")
+	return downloadVoice(msg, m.config.TTSBackend.VoicesDir)
+}
+
+// waitingAnswers returns the answers to speak once the download of model
+// ended
+func (m *model) waitingAnswers(model string, completion string) []string {
+	var answers []string
+	if model == m.downloadingVoice {
+		answers = m.awaitingVoice
+		m.downloadingVoice, m.awaitingVoice = "", nil
+	}
+	if completion != "" {
+		answers = append(answers, completion)
+	}
+	return answers
+}
+
+// voiceDownloaded speaks the answers that arrived during the download
+func (m *model) voiceDownloaded(msg DownloadFinished) tea.Cmd {
+	answers := m.waitingAnswers(msg.model, msg.completion)
+	if len(answers) == 0 {
+		m.UpdateStatus("Voice downloaded")
+		return nil
+	}
+	if m.config.Muted {
+		m.setStatus(statusReady, "Ready")
+		return nil
+	}
+	m.setStatus(statusSpeaking, "Speaking")
+	return Speak(strings.Join(answers, "\n"), *m)
+}
+
+// speakWithoutVoice switches to espeak-ng for the rest of the session when the
+// Piper voice is missing, so answers are still heard offline
+func (m *model) speakWithoutVoice(msg DownloadFailed) tea.Cmd {
+	slog.Error("Failed to download voice", "error", msg.err)
+	answers := m.waitingAnswers(msg.model, msg.completion)
+	// Only answers waiting for the main voice need the fallback
+	if msg.model != m.config.TTSBackend.Voice || !espeakInstalled() {
+		m.setStatus(statusError, "Failed to download model")
+		return nil
+	}
+	m.speaker = &EspeakSpeaker{voice: m.config.Language}
+	m.UpdateStatus("Using espeak-ng fallback")
+	if len(answers) == 0 || m.config.Muted {
+		return nil
+	}
+	return Speak(strings.Join(answers, "\n"), *m)
+}
+
+func waitForDownload(updates <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-updates
+		if !ok {
+			return nil
+		}
+		return msg
+	}
+}
+
+func (p DownloadProgress) status() string {
+	return fmt.Sprintf("Downloading %s %d%%", p.file, p.percent)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+)
+
+// encodedAudio is a recording ready for upload
+type encodedAudio struct {
+	data     []byte
+	filename string
+	mimeType string
+}
+
+func wavAudio(wav []byte) encodedAudio {
+	return encodedAudio{data: wav, filename: "audio.wav", mimeType: "audio/wav"}
+}
+
+// ffmpegFormats maps the upload_format config values to ffmpeg arguments
+var ffmpegFormats = map[string]struct {
+	args     []string
+	filename string
+	mimeType string
+}{
+	"flac": {[]string{"-f", "flac"}, "audio.flac", "audio/flac"},
+	"opus": {[]string{"-c:a", "libopus", "-b:a", "24k", "-f", "ogg"}, "audio.ogg", "audio/ogg"},
+}
+
+// encodeForUpload compresses the WAV recording with ffmpeg, any failure
+// including a missing ffmpeg falls back to the WAV itself
+func encodeForUpload(ctx context.Context, wav []byte, format string) encodedAudio {
+	if format == "" || format == "wav" {
+		return wavAudio(wav)
+	}
+
+	encoding, ok := ffmpegFormats[format]
+	if !ok {
+		slog.Warn("Unknown upload format, uploading wav", "format", format)
+		return wavAudio(wav)
+	}
+
+	data, err := runFFmpeg(ctx, wav, encoding.args)
+	if err != nil {
+		slog.Warn("Failed to encode recording, uploading wav", "format", format, "error", err)
+		return wavAudio(wav)
+	}
+	return encodedAudio{data: data, filename: encoding.filename, mimeType: encoding.mimeType}
+}
+
+func runFFmpeg(ctx context.Context, wav []byte, outputArgs []string) ([]byte, error) {
+	path, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, err
+	}
+
+	args := append([]string{"-hide_banner", "-loglevel", "error", "-f", "wav", "-i", "pipe:0"}, outputArgs...)
+	args = append(args, "pipe:1")
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdin = bytes.NewReader(wav)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
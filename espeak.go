@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"lazylang/piper"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// EspeakSpeaker reads text with espeak-ng, robotic but available offline
+// for nearly every language
+type EspeakSpeaker struct {
+	// voice is an espeak-ng voice or language code such as de or en-us
+	voice string
+
+	speaking bool
+	mu       sync.RWMutex
+}
+
+// espeakInstalled reports whether espeak-ng can be run
+func espeakInstalled() bool {
+	_, err := exec.LookPath("espeak-ng")
+	return err == nil
+}
+
+func (e *EspeakSpeaker) IsSpeaking() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.speaking
+}
+
+// Speak pipes the WAV espeak-ng writes to stdout into the playback device
+func (e *EspeakSpeaker) Speak(ctx context.Context, text string) error {
+	e.mu.Lock()
+	e.speaking = true
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.speaking = false
+		e.mu.Unlock()
+	}()
+
+	cmd := exec.CommandContext(ctx, "espeak-ng", "--stdout", "-v", e.voice)
+	cmd.Stdin = strings.NewReader(text)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	pipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create pipe: %w", err)
+	}
+
+	err = cmd.Start()
+	if errors.Is(err, exec.ErrNotFound) {
+		return fmt.Errorf("espeak-ng not installed")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to start espeak-ng: %w", err)
+	}
+
+	audio := bufio.NewReader(pipe)
+	rate, channels, err := readWAVHeader(audio)
+	if err == nil {
+		err = piper.Play(ctx, audio, rate, channels)
+	}
+	// Wait closes the pipe, so it runs after playback read everything
+	waitErr := cmd.Wait()
+	if ctx.Err() != nil {
+		return nil
+	}
+	if waitErr != nil {
+		return fmt.Errorf("espeak-ng failed: %w: %s", waitErr, strings.TrimSpace(stderr.String()))
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// exampleInterval spaces the example requests, so saving a list of words
+// doesn't send them all to the LLM at once
+const exampleInterval = 2 * time.Second
+
+// ExampleReceived is an example sentence for a saved word
+type ExampleReceived struct {
+	word    string
+	example string
+	err     error
+}
+
+// exampleDue starts the next example waiting in the queue
+type exampleDue struct{}
+
+// GetExample asks the LLM for an example sentence outside of the conversation
+// chain, like GetExplanation
+func GetExample(word string, m model) tea.Cmd {
+	llm, language := m.llm, m.config.Language
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(appContext, time.Minute)
+		defer cancel()
+		prompt := fmt.Sprintf("Write one short, simple example sentence in %s using %q. Reply with the sentence only.", languageName(language), word)
+		example, err := generateChatCompletion(ctx, llm, prompt)
+		return ExampleReceived{word: word, example: example, err: err}
+	}
+}
+
+// queueExample adds the word to the words waiting for an example, one is
+// generated at a time
+func (m *model) queueExample(word string) tea.Cmd {
+	for _, queued := range m.exampleQueue {
+		if queued == word {
+			return nil
+		}
+	}
+	m.exampleQueue = append(m.exampleQueue, word)
+	if m.generatingExample {
+		return nil
+	}
+	return m.nextExample()
+}
+
+func (m *model) nextExample() tea.Cmd {
+	if len(m.exampleQueue) == 0 {
+		m.generatingExample = false
+		return nil
+	}
+	word := m.exampleQueue[0]
+	m.exampleQueue = m.exampleQueue[1:]
+	m.generatingExample = true
+	return GetExample(word, *m)
+}
+
+// exampleReceived stores the example and starts the next one after a pause,
+// a failed one is only logged
+func (m *model) exampleReceived(msg ExampleReceived) tea.Cmd {
+	if msg.err != nil {
+		slog.Warn("Failed to generate an example", "word", msg.word, "error", msg.err)
+	} else {
+		m.wordsStore.SetExample(msg.word, msg.example)
+	}
+	if len(m.exampleQueue) == 0 {
+		m.generatingExample = false
+		return nil
+	}
+	return tea.Tick(exampleInterval, func(time.Time) tea.Msg { return exampleDue{} })
+}
+
+// regenerateExample replaces the example of the focused word when it is saved
+func (m *model) regenerateExample() tea.Cmd {
+	word := isAlpha.FindString(m.getFocusedWord())
+	if !m.wordsStore.Has(word) {
+		m.UpdateStatus("Not a saved word")
+		return nil
+	}
+	m.UpdateStatus("Writing a new example")
+	return m.queueExample(word)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// exportNameWords is how many words of the text name an exported file
+const exportNameWords = 5
+
+func defaultExportDir() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "exports"), nil
+}
+
+// synthesizer is implemented by speakers that can render speech to PCM
+// without playing it
+type synthesizer interface {
+	Synthesize(ctx context.Context, text string) (pcm []byte, sampleRate int, err error)
+}
+
+// exportName builds a file name from the first words of text, keeping only
+// letters and digits so it is safe on every filesystem
+func exportName(text string) string {
+	words := strings.Fields(text)
+	if len(words) > exportNameWords {
+		words = words[:exportNameWords]
+	}
+	var parts []string
+	for _, word := range words {
+		word = strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, word)
+		if word != "" {
+			parts = append(parts, word)
+		}
+	}
+	if len(parts) == 0 {
+		return "answer"
+	}
+	return strings.Join(parts, "-")
+}
+
+// writeExport writes data to dir/name.wav, adding a number to the name
+// instead of overwriting an existing file
+func writeExport(dir string, name string, data []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create export directory: %w", err)
+	}
+	for i := 1; ; i++ {
+		path := filepath.Join(dir, name+".wav")
+		if i > 1 {
+			path = filepath.Join(dir, fmt.Sprintf("%s-%d.wav", name, i))
+		}
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to create %s: %w", path, err)
+		}
+		_, err = file.Write(data)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		return path, nil
+	}
+}
+
+// ExportAudio synthesizes text and saves it as a WAV file
+func ExportAudio(s synthesizer, text string, dir string) tea.Cmd {
+	return func() tea.Msg {
+		pcm, rate, err := s.Synthesize(context.Background(), speakableText(text))
+		if err != nil {
+			slog.Error("Failed to synthesize audio", "error", err)
+			return StatusChanged{kind: statusError, status: "Failed to save audio"}
+		}
+		path, err := writeExport(dir, exportName(text), pcmToWAV(pcm, rate, 1))
+		if err != nil {
+			slog.Error("Failed to save audio", "error", err)
+			return StatusChanged{kind: statusError, status: "Failed to save audio"}
+		}
+		return StatusChanged{status: "Saved " + path}
+	}
+}
+
+// exportFocusedAudio handles S, it saves the spoken focused message
+func (m *model) exportFocusedAudio() tea.Cmd {
+	s, ok := m.speaker.(synthesizer)
+	if !ok {
+		m.UpdateStatus("Saving audio needs the piper tts backend")
+		return nil
+	}
+	index, _, ok := m.focusedMessage()
+	if !ok || index >= len(m.messages) || m.messages[index].Role == RoleScore {
+		m.UpdateStatus("Nothing to save")
+		return nil
+	}
+	if m.config.ExportDir == "" {
+		m.UpdateStatus("Saving audio needs a writable directory")
+		return nil
+	}
+	m.setStatus(statusBusy, "Saving audio")
+	return ExportAudio(s, m.messages[index].Text, m.config.ExportDir)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+const googleTranslateAPIURL = "https://translation.googleapis.com/language/translate/v2"
+
+// GoogleTranslator uses the Google Cloud Translation v2 REST API with an API
+// key
+type GoogleTranslator struct {
+	url    string
+	apiKey string
+}
+
+// googleLanguage is the Google code of a config language, only Chinese and
+// Portuguese keep their region
+func googleLanguage(language string) string {
+	code, region, ok := strings.Cut(language, "-")
+	if ok && (code == "zh" || code == "pt") {
+		return code + "-" + region
+	}
+	return code
+}
+
+func (g *GoogleTranslator) Translate(ctx context.Context, text string, source string, target string) (string, error) {
+	results, err := g.TranslateBatch(ctx, []string{text}, source, target)
+	if err != nil {
+		return "", err
+	}
+	return results[0].Translation, nil
+}
+
+// TranslateBatch sends all texts in one request, Google takes up to 128
+func (g *GoogleTranslator) TranslateBatch(ctx context.Context, texts []string, source string, target string) ([]BatchTranslation, error) {
+	reqBody, err := json.Marshal(map[string]any{
+		"q":      texts,
+		"source": googleLanguage(source),
+		"target": googleLanguage(target),
+		"format": "text",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal translation request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", g.url+"?key="+url.QueryEscape(g.apiKey), bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create translation request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		// The error contains the URL with the key
+		return nil, fmt.Errorf("failed to call Google Translate: %w", redactKey(err, g.apiKey))
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read translation response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, googleError(resp.StatusCode, body)
+	}
+
+	var result struct {
+		Data struct {
+			Translations []struct {
+				TranslatedText string `json:"translatedText"`
+			} `json:"translations"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse translation response: %w", err)
+	}
+	translations := make([]string, len(result.Data.Translations))
+	for i, translation := range result.Data.Translations {
+		// Even plain text comes back with some characters escaped
+		translations[i] = html.UnescapeString(translation.TranslatedText)
+	}
+	return batchResults("Google Translate", texts, translations)
+}
+
+// DetectLanguage asks Google for the language of the text
+func (g *GoogleTranslator) DetectLanguage(ctx context.Context, text string) (string, float64, error) {
+	reqBody, err := json.Marshal(map[string]any{"q": []string{text}})
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to marshal detection request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", g.url+"/detect?key="+url.QueryEscape(g.apiKey), bytes.NewReader(reqBody))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create detection request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to call Google Translate: %w", redactKey(err, g.apiKey))
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read detection response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, googleError(resp.StatusCode, body)
+	}
+
+	// Each text has a list of detections, the most likely first
+	var result struct {
+		Data struct {
+			Detections [][]struct {
+				Language   string  `json:"language"`
+				Confidence float64 `json:"confidence"`
+			} `json:"detections"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", 0, fmt.Errorf("failed to parse detection response: %w", err)
+	}
+	if len(result.Data.Detections) == 0 || len(result.Data.Detections[0]) == 0 {
+		return "", 0, fmt.Errorf("Google Translate detected no language")
+	}
+	detection := result.Data.Detections[0][0]
+	return detection.Language, detection.Confidence, nil
+}
+
+// redactKey removes the API key from the URL in err
+func redactKey(err error, apiKey string) error {
+	var urlErr *url.Error
+	if apiKey == "" || !errors.As(err, &urlErr) {
+		return err
+	}
+	return fmt.Errorf("%s: %w", strings.ReplaceAll(urlErr.URL, url.QueryEscape(apiKey), "REDACTED"), urlErr.Err)
+}
+
+// googleError maps the status and the error reasons of Google to the common
+// translation errors
+func googleError(status int, body []byte) error {
+	var result struct {
+		Error struct {
+			Message string `json:"message"`
+			Errors  []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+		} `json:"error"`
+	}
+	message := string(body)
+	var reasons []string
+	if json.Unmarshal(body, &result) == nil && result.Error.Message != "" {
+		message = result.Error.Message
+		for _, e := range result.Error.Errors {
+			reasons = append(reasons, e.Reason)
+		}
+	}
+	hasReason := func(names ...string) bool {
+		return slices.ContainsFunc(reasons, func(r string) bool { return slices.Contains(names, r) })
+	}
+
+	switch {
+	case status == http.StatusTooManyRequests || hasReason("dailyLimitExceeded", "userRateLimitExceeded", "rateLimitExceeded", "quotaExceeded"):
+		return fmt.Errorf("%w: Google Translate (status %d): %s", errTranslationQuota, status, message)
+	case status == http.StatusUnauthorized || status == http.StatusForbidden || hasReason("keyInvalid"):
+		return fmt.Errorf("%w: Google Translate (status %d): %s", errTranslationAuth, status, message)
+	case status == http.StatusBadRequest && strings.Contains(message, "language pair"):
+		return fmt.Errorf("%w: Google Translate: %s", errUnsupportedPair, message)
+	}
+	return TranslationError{Provider: "Google Translate", StatusCode: status, Message: message}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGoogleTranslate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("key"); got != "secret" {
+			t.Errorf("key = %q", got)
+		}
+		var body struct {
+			Q      []string `json:"q"`
+			Source string   `json:"source"`
+			Target string   `json:"target"`
+			Format string   `json:"format"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(body.Q, []string{"Hund", "Katze"}) || body.Source != "de" || body.Target != "pt-BR" || body.Format != "text" {
+			t.Errorf("request = %+v", body)
+		}
+		w.Write([]byte(`{"data": {"translations": [{"translatedText": "c&#227;o"}, {"translatedText": "gato"}]}}`))
+	}))
+	defer server.Close()
+
+	google := &GoogleTranslator{url: server.URL, apiKey: "secret"}
+	results, err := google.TranslateBatch(context.Background(), []string{"Hund", "Katze"}, "de", "pt-BR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Translation != "cão" || results[1].Translation != "gato" {
+		t.Errorf("results = %+v", results)
+	}
+}
+
+func TestGoogleErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"invalid key", http.StatusBadRequest, `{"error": {"message": "API key not valid", "errors": [{"reason": "keyInvalid"}]}}`, errTranslationAuth},
+		{"forbidden", http.StatusForbidden, `{"error": {"message": "Forbidden"}}`, errTranslationAuth},
+		{"daily limit", http.StatusForbidden, `{"error": {"message": "Limit", "errors": [{"reason": "dailyLimitExceeded"}]}}`, errTranslationQuota},
+		{"rate limit", http.StatusTooManyRequests, `{"error": {"message": "Slow down"}}`, errTranslationQuota},
+		{"unsupported pair", http.StatusBadRequest, `{"error": {"message": "Bad language pair: de|xx"}}`, errUnsupportedPair},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			_, err := (&GoogleTranslator{url: server.URL, apiKey: "key"}).Translate(context.Background(), "Hund", "de", "en")
+			if !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGoogleRejectedRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": {"message": "Invalid value"}}`))
+	}))
+	defer server.Close()
+
+	_, err := (&GoogleTranslator{url: server.URL, apiKey: "key"}).Translate(context.Background(), "Hund", "de", "en")
+	var apiErr TranslationError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != "Invalid value" {
+		t.Fatalf("error = %v, want a TranslationError with the message of Google", err)
+	}
+	if isTransientTranslationError(err) {
+		t.Error("a rejected request should not be retried")
+	}
+}
+
+func TestGoogleRedactsKey(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	_, err := (&GoogleTranslator{url: server.URL, apiKey: "secret"}).Translate(context.Background(), "Hund", "de", "en")
+	if err == nil {
+		t.Fatal("expected an error from a closed server")
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("the key leaked into %q", err)
+	}
+}
+
+func TestGoogleDetectLanguage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/detect") {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		w.Write([]byte(`{"data": {"detections": [[{"language": "fr", "confidence": 0.9}]]}}`))
+	}))
+	defer server.Close()
+
+	language, confidence, err := (&GoogleTranslator{url: server.URL, apiKey: "key"}).DetectLanguage(context.Background(), "bonjour")
+	if err != nil || language != "fr" || confidence != 0.9 {
+		t.Errorf("DetectLanguage = %q %v %v", language, confidence, err)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// GrammarNoted is a one line note on the form of a word in its sentence, such
+// as "dative plural of Kind"
+type GrammarNoted struct {
+	word string
+	note string
+}
+
+// GetGrammarNote asks the LLM about the word in its sentence outside of the
+// conversation chain, like GetExplanation
+func GetGrammarNote(word string, sentence string, m model) tea.Cmd {
+	llm, language, target := m.llm, m.config.Language, m.config.TargetTranslationLanguage
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(appContext, time.Minute)
+		defer cancel()
+		prompt := fmt.Sprintf("In the %s sentence %q, what form is the word %q? Answer in %s with one short line naming the form and the dictionary form, such as \"dative plural of Kind\", and nothing else.", languageName(language), sentence, word, languageName(target))
+		note, err := generateChatCompletion(ctx, llm, prompt)
+		if err != nil {
+			slog.Warn("Failed to get a grammar note", "word", word, "error", err)
+			return nil
+		}
+		// Only the first line, models like to explain
+		note, _, _ = strings.Cut(note, "\n")
+		return GrammarNoted{word: word, note: strings.TrimSpace(note)}
+	}
+}
+
+// grammarNote asks for the note of the focused word with show_grammar, it
+// arrives after the translation and is added to the saved word. Translating
+// the word again while it is on its way asks for no second note
+func (m *model) grammarNote(word string) tea.Cmd {
+	if !m.config.ShowGrammar || !m.config.Translation.IsEnabled() || word == m.awaitingTranslation {
+		return nil
+	}
+	sentence, _, ok := m.focusedSentence()
+	if !ok {
+		return nil
+	}
+	return GetGrammarNote(word, sentence, *m)
+}
+
+// grammarNoted keeps the note until the word is saved, the translation may
+// still be waiting to be picked
+func (m *model) grammarNoted(msg GrammarNoted) {
+	if msg.note == "" {
+		return
+	}
+	m.grammarNotes[msg.word] = msg.note
+	m.wordsStore.SetNote(msg.word, msg.note)
+	// The note is shown in the choice between translations
+	if m.choosing != nil {
+		m.resize()
+	}
+}
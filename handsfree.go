@@ -0,0 +1,51 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// HandsFreeListen starts the next recording of the hands-free loop
+type HandsFreeListen struct{}
+
+// listenAfterGrace waits for the speaker to fall silent so the end of the
+// teacher's answer isn't recorded
+func (m model) listenAfterGrace() tea.Cmd {
+	grace := time.Duration(m.config.HandsFreeDelayMs) * time.Millisecond
+	return tea.Tick(grace, func(time.Time) tea.Msg {
+		return HandsFreeListen{}
+	})
+}
+
+// handsFreeListen starts recording unless the loop was stopped or something
+// else is going on in the meantime
+func (m *model) handsFreeListen() tea.Cmd {
+	if !m.handsFree || m.recorder.IsRecording() || m.speech.IsSpeaking() || m.typing || m.confirming != nil {
+		return nil
+	}
+	return m.startRecording()
+}
+
+func (m *model) toggleHandsFree() tea.Cmd {
+	if !m.config.VAD.Enabled {
+		m.UpdateStatus("Hands-free needs vad.enabled in the config")
+		return nil
+	}
+	m.handsFree = !m.handsFree
+	if !m.handsFree {
+		m.UpdateStatus("Hands-free off")
+		return nil
+	}
+	m.UpdateStatus("Hands-free on")
+	return m.handsFreeListen()
+}
+
+// stopHandsFree breaks the loop, a recording in progress is dropped
+func (m *model) stopHandsFree() {
+	if !m.handsFree {
+		return
+	}
+	m.handsFree = false
+	m.recorder.Stop()
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// helpKey handles keys while the help is shown, everything but closing it
+// is ignored
+func (m *model) helpKey(key string) {
+	if key == "esc" || m.keymap.Action(key) == ActionHelp {
+		m.showHelp = false
+	}
+}
+
+// helpView lists the effective key of every action centered in an area of
+// width and height, in two columns when one doesn't fit
+func (m model) helpView(width, height int) string {
+	keys := make([]string, len(defaultKeyBindings))
+	keyWidth := 0
+	for i, binding := range defaultKeyBindings {
+		keys[i] = strings.Join(m.keymap.Keys(binding.action), " / ")
+		if keys[i] == "" {
+			keys[i] = "(unbound)"
+		}
+		keyWidth = max(keyWidth, lipgloss.Width(keys[i]))
+	}
+	lines := make([]string, len(defaultKeyBindings))
+	for i, binding := range defaultKeyBindings {
+		lines[i] = styles.helpKey.Width(keyWidth).Render(keys[i]) + "  " + binding.description
+	}
+
+	// Title, blank lines, hint and the border take six lines
+	body := strings.Join(lines, "\n")
+	if len(lines)+6 > height {
+		half := (len(lines) + 1) / 2
+		left := strings.Join(lines[:half], "\n")
+		right := strings.Join(lines[half:], "\n")
+		body = lipgloss.JoinHorizontal(lipgloss.Top, left, "    ", right)
+	}
+	box := styles.help.Render("Keys\n\n" + body + "\n\n" + styles.gloss.Render(m.keymap.Key(ActionHelp)+" or esc closes the help"))
+	return lipgloss.Place(width, height, lipgloss.Center, lipgloss.Center, box)
+}
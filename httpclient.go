@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"lazylang/piper"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// httpClient sends every request leaving the machine, configureHTTP replaces
+// it with one following the proxy and certificate settings
+var httpClient = http.DefaultClient
+
+// currentHTTPClient sends with whatever httpClient is at the time, so clients
+// built before a config reload follow the new proxy
+type currentHTTPClient struct{}
+
+func (currentHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	return httpClient.Do(req)
+}
+
+// NewHTTPClient builds the client for outbound requests. proxy overrides
+// HTTPS_PROXY and HTTP_PROXY, caCertFile is trusted next to the system
+// certificates. There is no overall timeout as voice downloads take long,
+// requests pass a context instead
+func NewHTTPClient(proxy string, caCertFile string) (*http.Client, error) {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		// Completions are only answered once they are generated
+		ResponseHeaderTimeout: 2 * time.Minute,
+		IdleConnTimeout:       90 * time.Second,
+		ForceAttemptHTTP2:     true,
+	}
+
+	if proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy %q, use a URL like http://proxy:8080", proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if caCertFile != "" {
+		pem, err := os.ReadFile(caCertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca_cert_file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates in %s", caCertFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// configureHTTP makes the main and the piper package send their requests
+// with the client of the config
+func configureHTTP(config Config) error {
+	client, err := NewHTTPClient(config.Proxy, config.CACertFile)
+	if err != nil {
+		return err
+	}
+	httpClient = client
+	piper.SetHTTPClient(client)
+	return nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"io"
+	"lazylang/piper"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// proxyServer is a forward proxy that answers requests itself and records
+// the hosts they were meant for
+type proxyServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	hosts []string
+}
+
+func newProxyServer(t *testing.T) *proxyServer {
+	p := &proxyServer{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.hosts = append(p.hosts, r.Method+" "+r.Host)
+		p.mu.Unlock()
+		if r.Method == http.MethodConnect {
+			http.Error(w, "no tunnels", http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"results": {"channels": [{"alternatives": [{"transcript": "Hallo"}]}]}}`))
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *proxyServer) requests() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.hosts...)
+}
+
+// writeCACert writes the certificate of the TLS server as a PEM file
+func writeCACert(t *testing.T, server *httptest.Server) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "ca.pem")
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}
+	if err := os.WriteFile(file, pem.EncodeToMemory(block), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// restoreHTTPClients puts back the clients configureHTTP replaces
+func restoreHTTPClients(t *testing.T) {
+	previous := httpClient
+	t.Cleanup(func() {
+		httpClient = previous
+		piper.SetHTTPClient(http.DefaultClient)
+	})
+}
+
+func TestHTTPClientProxy(t *testing.T) {
+	proxy := newProxyServer(t)
+	client, err := NewHTTPClient(proxy.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get("http://api.example.invalid/v1/listen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := proxy.requests(); len(got) != 1 || got[0] != "GET api.example.invalid" {
+		t.Errorf("proxy got %q", got)
+	}
+}
+
+func TestHTTPClientInvalidProxy(t *testing.T) {
+	for _, proxy := range []string{"proxy:8080", "://proxy", "http://"} {
+		if _, err := NewHTTPClient(proxy, ""); err == nil || !strings.Contains(err.Error(), "invalid proxy") {
+			t.Errorf("proxy %q gave %v", proxy, err)
+		}
+	}
+}
+
+func TestHTTPClientCACert(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	// The rejected handshake is expected
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	untrusting, err := NewHTTPClient("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := untrusting.Get(server.URL); err == nil {
+		t.Error("the server is trusted without its certificate")
+	}
+
+	client, err := NewHTTPClient("", writeCACert(t, server))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("the server isn't trusted with its certificate: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestHTTPClientCACertErrors(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file string
+		want string
+	}{
+		{filepath.Join(dir, "missing.pem"), "failed to read ca_cert_file"},
+		{notPEM, "no PEM certificates in " + notPEM},
+	}
+	for _, tt := range tests {
+		if _, err := NewHTTPClient("", tt.file); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s gave %v, want %q", tt.file, err, tt.want)
+		}
+	}
+}
+
+// TestConfigureHTTP checks that transcriptions, completions made with a
+// client built before and voice downloads all go through the proxy
+func TestConfigureHTTP(t *testing.T) {
+	restoreHTTPClients(t)
+	proxy := newProxyServer(t)
+
+	var config Config
+	config.Proxy = proxy.URL
+	if err := configureHTTP(config); err != nil {
+		t.Fatal(err)
+	}
+
+	deepgram := &DeepgramTranscriber{url: "http://deepgram.example.invalid/v1/listen", apiKey: "secret", model: "nova-2"}
+	if _, err := deepgram.Transcribe(context.Background(), []byte("wav"), "de", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://llm.example.invalid/models", nil)
+	resp, err := currentHTTPClient{}.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if _, err := piper.FetchVoices(piper.Dir{Path: t.TempDir()}); err == nil {
+		t.Error("voices.json was downloaded past a proxy refusing tunnels")
+	}
+
+	want := []string{"POST deepgram.example.invalid", "GET llm.example.invalid", "CONNECT huggingface.co:443"}
+	got := proxy.requests()
+	if len(got) < len(want) {
+		t.Fatalf("proxy got %q, want %q", got, want)
+	}
+	for i, w := range want {
+		if got[i] != w {
+			t.Errorf("request %d went to %q, want %q", i, got[i], w)
+		}
+	}
+}
+
+func TestConfigureHTTPInvalidProxy(t *testing.T) {
+	restoreHTTPClients(t)
+	before := httpClient
+
+	var config Config
+	config.Proxy = "proxy:8080"
+	if err := configureHTTP(config); err == nil {
+		t.Fatal("an invalid proxy is accepted")
+	}
+	if httpClient != before {
+		t.Error("the client was replaced by a failed config")
+	}
+}
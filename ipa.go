@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	wiktionaryAPIURL = "https://en.wiktionary.org/w/api.php"
+	// ipaTimeout bounds the Wiktionary request, the IPA is a nice to have
+	ipaTimeout = 5 * time.Second
+)
+
+// ipaCache holds the IPA looked up for each language and word, empty when
+// none was found so failed words aren't looked up again
+var ipaCache sync.Map
+
+// IPAReceived is the pronunciation of a saved word
+type IPAReceived struct {
+	word string
+	ipa  string
+}
+
+// lookUpIPA finds the IPA of a word on Wiktionary, falling back to espeak-ng.
+// Failures are only logged, the word is shown without it
+func lookUpIPA(word string, language string) tea.Cmd {
+	return func() tea.Msg {
+		key := languageCode(language) + "\x00" + word
+		if ipa, ok := ipaCache.Load(key); ok {
+			return IPAReceived{word: word, ipa: ipa.(string)}
+		}
+		ipa, err := wiktionaryIPA(word, language)
+		if err != nil {
+			slog.Debug("Failed to look up the IPA on Wiktionary", "word", word, "error", err)
+		}
+		if ipa == "" && espeakInstalled() {
+			if ipa, err = espeakIPA(word, language); err != nil {
+				slog.Debug("Failed to look up the IPA with espeak-ng", "word", word, "error", err)
+			}
+		}
+		// Network failures are tried again with the next save
+		if err == nil {
+			ipaCache.Store(key, ipa)
+		}
+		return IPAReceived{word: word, ipa: ipa}
+	}
+}
+
+// wiktionaryIPA reads the first IPA template of the language from the
+// wikitext of the word's page, such as {{IPA|de|/haʊ̯s/}}. Pages are case
+// sensitive so the lower case word is tried too
+func wiktionaryIPA(word string, language string) (string, error) {
+	template := regexp.MustCompile(`\{\{IPA\|` + regexp.QuoteMeta(languageCode(language)) + `\|([^|}]+)`)
+	pages := []string{word}
+	if lower := strings.ToLower(word); lower != word {
+		pages = append(pages, lower)
+	}
+	for _, page := range pages {
+		wikitext, err := wiktionaryWikitext(page)
+		if err != nil {
+			return "", err
+		}
+		if match := template.FindStringSubmatch(wikitext); match != nil {
+			return strings.TrimSpace(match[1]), nil
+		}
+	}
+	return "", nil
+}
+
+// wiktionaryWikitext returns the source of a Wiktionary page, empty when
+// there is no such page
+func wiktionaryWikitext(page string) (string, error) {
+	ctx, cancel := context.WithTimeout(appContext, ipaTimeout)
+	defer cancel()
+	query := url.Values{
+		"action":        {"parse"},
+		"page":          {page},
+		"prop":          {"wikitext"},
+		"format":        {"json"},
+		"formatversion": {"2"},
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", wiktionaryAPIURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	// Wikimedia refuses requests without a descriptive user agent
+	req.Header.Set("User-Agent", "lazylang (https://github.com/Lumberj3ck/LeLang)")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Wiktionary returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Parse struct {
+			Wikitext string `json:"wikitext"`
+		} `json:"parse"`
+		// A missing page is reported as an error in the body
+		Error *struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if result.Error != nil && result.Error.Code != "missingtitle" {
+		return "", fmt.Errorf("Wiktionary error %s", result.Error.Code)
+	}
+	return result.Parse.Wikitext, nil
+}
+
+// espeakIPA is the pronunciation espeak-ng would speak, without the stress
+// of a dictionary but available offline
+func espeakIPA(word string, language string) (string, error) {
+	ctx, cancel := context.WithTimeout(appContext, ipaTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "espeak-ng", "-q", "--ipa", "-v", languageCode(language), word).Output()
+	if err != nil {
+		return "", err
+	}
+	ipa := strings.Join(strings.Fields(string(output)), " ")
+	if ipa == "" {
+		return "", nil
+	}
+	return "/" + ipa + "/", nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// SpokenWord is sent whenever playback reaches the next word of a message,
+// the next update is read from updates
+type SpokenWord struct {
+	sessionID int
+	messageID int
+	// index is the word of the message as shown, not as spoken
+	index   int
+	updates <-chan tea.Msg
+}
+
+// trackedSpeaker reports the index of the word being played
+type trackedSpeaker interface {
+	SpeakTracked(ctx context.Context, text string, onWord func(index int)) error
+}
+
+// SpeakHighlighted speaks an answer and follows it with the focus, speakers
+// without word tracking just speak
+func SpeakHighlighted(text string, sessionID int, messageID int, m model) tea.Cmd {
+	speaker, ok := m.speaker.(trackedSpeaker)
+	if !ok {
+		return Speak(text, m)
+	}
+
+	// Word updates are dropped while the UI is behind, the result is not
+	updates := make(chan tea.Msg, 1)
+	shown := spokenWords(text)
+	go func() {
+		defer close(updates)
+		err := m.speech.Do(func(ctx context.Context) error {
+			return speaker.SpeakTracked(ctx, speakableText(text), func(index int) {
+				if index >= len(shown) {
+					return
+				}
+				select {
+				case updates <- SpokenWord{sessionID: sessionID, messageID: messageID, index: shown[index], updates: updates}:
+				default:
+				}
+			})
+		})
+		updates <- speechResult(err, text)
+	}()
+	return waitForSpeech(updates)
+}
+
+func waitForSpeech(updates <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-updates
+		if !ok {
+			return nil
+		}
+		return msg
+	}
+}
+
+// highlightSpokenWord moves the focus to the word being spoken when its
+// message is in the active tab
+func (m *model) highlightSpokenWord(msg SpokenWord) {
+	if msg.sessionID != m.Session.id {
+		return
+	}
+	for i, message := range m.messages {
+		// The role label is the first word of the first row
+		if message.ID == msg.messageID && m.focusOn(i, msg.index+1) {
+			m.refreshViewport()
+			return
+		}
+	}
+}
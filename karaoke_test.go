@@ -0,0 +1,50 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSpokenWords(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []int
+	}{
+		{"plain", "Guten Morgen", []int{0, 1}},
+		{"link", "Lies [den Artikel](https://example.com) jetzt", []int{0, 1, 2, 3}},
+		{"url", "Siehe https://example.com/a/b hier", []int{0, 1, 2}},
+		{"emoji", "Super 🎉 gemacht", []int{0, 2}},
+		{"list", "- eins\n- zwei", []int{1, 3}},
+		{"numbered list", "1. eins\n2. zwei", []int{1, 3}},
+		{"heading", "## Titel\nText", []int{1, 2}},
+		{"emphasis", "das ist **sehr** _gut_", []int{0, 1, 2, 3}},
+		{"code fence", "```go\nfmt.Println()\n```\nfertig", []int{1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := spokenWords(tt.text)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("spokenWords(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+			if spoken := len(strings.Fields(speakableText(tt.text))); len(got) != spoken {
+				t.Errorf("%d words mapped, %d spoken", len(got), spoken)
+			}
+		})
+	}
+}
+
+func TestSpokenWordFocusesShownWord(t *testing.T) {
+	m := newTestModel(t, Message{Role: RoleAI, Text: "Das ist **sehr** 🎉 [gut](https://example.com) so"})
+	msg := m.messages[0]
+
+	// The speaker says "Das ist sehr gut so", so its word 4 is "so"
+	index := spokenWords(msg.Text)[4]
+	m = update(t, m, SpokenWord{sessionID: m.Session.id, messageID: msg.ID, index: index})
+
+	// so is the sixth word shown
+	if _, word, ok := m.focusedMessage(); !ok || word != 5 {
+		t.Errorf("focused word %d, want the spoken word so at 5", word)
+	}
+}
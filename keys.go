@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Action is something a key does, the names are used in the keys section of
+// the config
+type Action string
+
+const (
+	ActionRecord            Action = "record"
+	ActionPlayRecording     Action = "play_recording"
+	ActionRetranscribe      Action = "retranscribe"
+	ActionNextLine          Action = "next_line"
+	ActionPrevLine          Action = "prev_line"
+	ActionNextWord          Action = "next_word"
+	ActionPrevWord          Action = "prev_word"
+	ActionWordEnd           Action = "word_end"
+	ActionLineStart         Action = "line_start"
+	ActionLineEnd           Action = "line_end"
+	ActionTop               Action = "top"
+	ActionBottom            Action = "bottom"
+	ActionTranslate         Action = "translate"
+	ActionTranslateSentence Action = "translate_sentence"
+	ActionVisual            Action = "visual"
+	ActionReverseTranslate  Action = "reverse_translate"
+	ActionNewExample        Action = "new_example"
+	ActionMute              Action = "mute"
+	ActionReplay            Action = "replay"
+	ActionSpeakSentence     Action = "speak_sentence"
+	ActionPronounceWord     Action = "pronounce_word"
+	ActionExportAudio       Action = "export_audio"
+	ActionRepeat            Action = "repeat"
+	ActionStopSpeaking      Action = "stop_speaking"
+	ActionHandsFree         Action = "hands_free"
+	ActionVolumeUp          Action = "volume_up"
+	ActionVolumeDown        Action = "volume_down"
+	ActionFaster            Action = "faster"
+	ActionSlower            Action = "slower"
+	ActionFixVoice          Action = "fix_voice"
+	ActionResponseLength    Action = "response_length"
+	ActionType              Action = "type"
+	ActionCommand           Action = "command"
+	ActionNewTab            Action = "new_tab"
+	ActionNextTab           Action = "next_tab"
+	ActionPrevTab           Action = "prev_tab"
+	ActionCloseTab          Action = "close_tab"
+	ActionToggleSidebar     Action = "toggle_sidebar"
+	ActionSidebarWider      Action = "sidebar_wider"
+	ActionSidebarNarrower   Action = "sidebar_narrower"
+	ActionHelp              Action = "help"
+	ActionQuit              Action = "quit"
+)
+
+// keyBinding is an action with its default keys
+type keyBinding struct {
+	action      Action
+	keys        []string
+	description string
+}
+
+// defaultKeyBindings lists every action in the order they are documented
+var defaultKeyBindings = []keyBinding{
+	{ActionRecord, []string{"ctrl+b"}, "Start/stop recording"},
+	{ActionPlayRecording, []string{"P"}, "Play back the last recording"},
+	{ActionRetranscribe, []string{"T"}, "Transcribe the last recording again"},
+	{ActionNextLine, []string{"j"}, "Move focus down one line"},
+	{ActionPrevLine, []string{"k"}, "Move focus up one line"},
+	{ActionNextWord, []string{"w"}, "Move focus to the next word"},
+	{ActionPrevWord, []string{"b"}, "Move focus to the previous word"},
+	{ActionWordEnd, []string{"e"}, "Move focus to the end of the word, the next one as focus covers whole words"},
+	{ActionLineStart, []string{"0"}, "Move focus to the first word of the line"},
+	{ActionLineEnd, []string{"$"}, "Move focus to the last word of the line"},
+	{ActionTop, []string{"g g"}, "Move focus to the first line of the conversation"},
+	{ActionBottom, []string{"G"}, "Move focus to the last line of the conversation"},
+	{ActionTranslate, []string{"enter"}, "Translate the focused word"},
+	{ActionTranslateSentence, []string{"t"}, "Translate the sentence of the focused word"},
+	{ActionVisual, []string{"v"}, "Select a phrase with w/b and translate it with enter"},
+	{ActionReverseTranslate, []string{"n"}, "Look up how to say a word of your language"},
+	{ActionNewExample, []string{"x"}, "Write a new example sentence for the focused saved word"},
+	{ActionMute, []string{"m"}, "Mute or unmute the spoken answers"},
+	{ActionReplay, []string{"R"}, "Replay the last spoken answer"},
+	{ActionSpeakSentence, []string{"s"}, "Speak the sentence under the focus"},
+	{ActionPronounceWord, []string{"p"}, "Say the focused word twice, slowly"},
+	{ActionExportAudio, []string{"S"}, "Save the focused message as a WAV file"},
+	{ActionRepeat, []string{"r"}, "Repeat the last answer and get a pronunciation score"},
+	{ActionStopSpeaking, []string{"esc"}, "Stop speaking and the hands-free loop"},
+	{ActionHandsFree, []string{"H"}, "Toggle hands-free mode"},
+	{ActionVolumeUp, []string{"ctrl+up"}, "Raise the playback volume"},
+	{ActionVolumeDown, []string{"ctrl+down"}, "Lower the playback volume"},
+	{ActionFaster, []string{"+"}, "Speak faster"},
+	{ActionSlower, []string{"-"}, "Speak slower"},
+	{ActionFixVoice, []string{"V"}, "Switch to a voice speaking the language"},
+	{ActionResponseLength, []string{"L"}, "Cycle the response length"},
+	{ActionType, []string{"i"}, "Type a message instead of speaking"},
+	{ActionCommand, []string{"/"}, "Type a slash command"},
+	{ActionNewTab, []string{"ctrl+t"}, "Open a conversation tab"},
+	{ActionNextTab, []string{"tab"}, "Switch to the next tab"},
+	{ActionPrevTab, []string{"shift+tab"}, "Switch to the previous tab"},
+	{ActionCloseTab, []string{"ctrl+w"}, "Close the tab"},
+	{ActionToggleSidebar, []string{"|"}, "Hide or show the sidebar"},
+	{ActionSidebarWider, []string{"<"}, "Widen the sidebar"},
+	{ActionSidebarNarrower, []string{">"}, "Narrow the sidebar"},
+	{ActionHelp, []string{"?"}, "Show this help"},
+	{ActionQuit, []string{"ctrl+c", "q"}, "Quit"},
+}
+
+// KeyList is one key or a list of keys in the config
+type KeyList []string
+
+func (k *KeyList) UnmarshalJSON(data []byte) error {
+	var key string
+	if err := json.Unmarshal(data, &key); err == nil {
+		*k = KeyList{key}
+		return nil
+	}
+	var keys []string
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("keys must be a key or a list of keys: %w", err)
+	}
+	*k = keys
+	return nil
+}
+
+// keySequenceTimeout is how long the keys of a sequence such as g g may be
+// apart
+const keySequenceTimeout = time.Second
+
+// Keymap maps the keys bubbletea reports to their action. Sequences of keys
+// are written with spaces between the keys
+type Keymap struct {
+	actions map[string]Action
+	// keys are the effective keys of every action
+	keys map[Action][]string
+	// prefixes are the beginnings of the sequences with their action
+	prefixes map[string]Action
+}
+
+// NewKeymap applies the keys section of the config to the defaults, unknown
+// actions and keys bound twice are errors
+func NewKeymap(overrides map[string]KeyList) (Keymap, error) {
+	km := Keymap{actions: make(map[string]Action), keys: make(map[Action][]string), prefixes: make(map[string]Action)}
+	for _, binding := range defaultKeyBindings {
+		km.keys[binding.action] = binding.keys
+	}
+
+	// Sorted so the same config always reports the same error
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		action := Action(name)
+		if _, ok := km.keys[action]; !ok {
+			return Keymap{}, fmt.Errorf("unknown action %q in keys, known actions are %s", name, strings.Join(actionNames(), ", "))
+		}
+		km.keys[action] = overrides[name]
+	}
+
+	for _, binding := range defaultKeyBindings {
+		for _, key := range km.keys[binding.action] {
+			if other, ok := km.actions[key]; ok {
+				return Keymap{}, fmt.Errorf("key %q is bound to both %s and %s", key, other, binding.action)
+			}
+			km.actions[key] = binding.action
+			keys := strings.Fields(key)
+			for i := 1; i < len(keys); i++ {
+				km.prefixes[strings.Join(keys[:i], " ")] = binding.action
+			}
+		}
+	}
+	// A key starting a sequence would always wait for the next one
+	for key, action := range km.actions {
+		if sequence, ok := km.prefixes[key]; ok {
+			return Keymap{}, fmt.Errorf("key %q is bound to %s and starts a sequence of %s", key, action, sequence)
+		}
+	}
+	return km, nil
+}
+
+// Action returns the action bound to key, empty when there is none
+func (km Keymap) Action(key string) Action {
+	return km.actions[key]
+}
+
+// StartsSequence reports whether keys are the beginning of a sequence
+func (km Keymap) StartsSequence(keys string) bool {
+	_, ok := km.prefixes[keys]
+	return ok
+}
+
+// Key is the first key bound to action, for hints in the status
+func (km Keymap) Key(action Action) string {
+	if keys := km.keys[action]; len(keys) > 0 {
+		return keys[0]
+	}
+	return "(unbound)"
+}
+
+// Keys returns the keys bound to action
+func (km Keymap) Keys(action Action) []string {
+	return km.keys[action]
+}
+
+func actionNames() []string {
+	names := make([]string, len(defaultKeyBindings))
+	for i, binding := range defaultKeyBindings {
+		names[i] = string(binding.action)
+	}
+	return names
+}
+
+// keySequenceExpired drops the keys typed of a sequence that wasn't finished
+// in time
+type keySequenceExpired struct {
+	id int
+}
+
+// resolveKey returns the action of the key. A key starting a sequence waits
+// for the next one, keys that end no sequence are dropped as in vim
+func (m *model) resolveKey(key string) (Action, tea.Cmd) {
+	if m.pendingKeys != "" {
+		key = m.pendingKeys + " " + key
+		m.pendingKeys = ""
+		if !m.keymap.StartsSequence(key) && m.keymap.Action(key) == "" {
+			return "", nil
+		}
+	}
+	if m.keymap.StartsSequence(key) {
+		m.pendingKeys = key
+		m.pendingKeysID++
+		id := m.pendingKeysID
+		return "", tea.Tick(keySequenceTimeout, func(time.Time) tea.Msg { return keySequenceExpired{id: id} })
+	}
+	return m.keymap.Action(key), nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// typeKeys sends the keys of a binding such as "g g" to the model one by one
+func typeKeys(t *testing.T, m model, keys string) (model, tea.Cmd) {
+	t.Helper()
+	var cmd tea.Cmd
+	for _, key := range strings.Fields(keys) {
+		var next tea.Model
+		next, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		m = next.(model)
+	}
+	return m, cmd
+}
+
+// newMotionModel has a conversation taller than the viewport
+func newMotionModel(t *testing.T) model {
+	t.Helper()
+	var messages []Message
+	for i := range 30 {
+		messages = append(messages, Message{Role: RoleUser, Text: fmt.Sprintf("Satz %d eins zwei drei", i)})
+	}
+	m := newTestModel(t, messages...)
+	if len(m.rows()) <= m.viewport.Height {
+		t.Fatalf("%d rows fit the viewport", len(m.rows()))
+	}
+	return m
+}
+
+func TestMotions(t *testing.T) {
+	m := newMotionModel(t)
+	rows := m.rows()
+	lastRow := len(rows) - 1
+	lastWord := func(row int) int { return len(rowWords(rows[row])) - 1 }
+
+	tests := []struct {
+		name              string
+		row, word         int
+		keys              string
+		wantRow, wantWord int
+	}{
+		{"line start", 3, 2, "0", 3, 0},
+		{"line end", 3, 0, "$", 3, lastWord(3)},
+		{"word end", 3, 0, "e", 3, 1},
+		{"word end of the line", 3, lastWord(3), "e", 4, 0},
+		{"word end of the conversation", lastRow, lastWord(lastRow), "e", lastRow, lastWord(lastRow)},
+		{"bottom", 3, 2, "G", lastRow, 0},
+		{"top", lastRow, 2, "g g", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := m
+			m.focusRow, m.focusWord = tt.row, tt.word
+			m, _ = typeKeys(t, m, tt.keys)
+			if m.focusRow != tt.wantRow || m.focusWord != tt.wantWord {
+				t.Errorf("focus moved to row %d word %d, want row %d word %d", m.focusRow, m.focusWord, tt.wantRow, tt.wantWord)
+			}
+		})
+	}
+}
+
+func TestMotionsWithoutMessages(t *testing.T) {
+	m := newTestModel(t)
+	for _, keys := range []string{"0", "$", "e", "G", "g g"} {
+		m, _ = typeKeys(t, m, keys)
+		if m.focusRow != 0 || m.focusWord != 0 {
+			t.Errorf("%s moved focus to row %d word %d", keys, m.focusRow, m.focusWord)
+		}
+	}
+}
+
+func TestTopAndBottomScroll(t *testing.T) {
+	m := newMotionModel(t)
+
+	m, _ = typeKeys(t, m, "G")
+	if !m.viewport.AtBottom() {
+		t.Errorf("G scrolled to %d", m.viewport.YOffset)
+	}
+	m, _ = typeKeys(t, m, "g g")
+	if m.viewport.YOffset != 0 {
+		t.Errorf("g g scrolled to %d", m.viewport.YOffset)
+	}
+}
+
+func TestKeySequence(t *testing.T) {
+	m := newMotionModel(t)
+	m.focusRow, m.focusWord = 5, 2
+
+	m, cmd := typeKeys(t, m, "g")
+	if m.focusRow != 5 || m.pendingKeys != "g" {
+		t.Fatalf("g moved focus to row %d with %q pending", m.focusRow, m.pendingKeys)
+	}
+	if cmd == nil {
+		t.Fatal("no timeout for the sequence")
+	}
+
+	// A key ending no sequence is dropped with the sequence, as in vim
+	m, _ = typeKeys(t, m, "G")
+	if m.focusRow != 5 || m.pendingKeys != "" {
+		t.Errorf("g G moved focus to row %d with %q pending", m.focusRow, m.pendingKeys)
+	}
+	m, _ = typeKeys(t, m, "g g")
+	if m.focusRow != 0 || m.pendingKeys != "" {
+		t.Errorf("g g moved focus to row %d with %q pending", m.focusRow, m.pendingKeys)
+	}
+}
+
+func TestKeySequenceTimeout(t *testing.T) {
+	m := newMotionModel(t)
+	m.focusRow = 5
+
+	m, _ = typeKeys(t, m, "g")
+	expired := keySequenceExpired{id: m.pendingKeysID}
+	m = update(t, m, expired)
+	m, _ = typeKeys(t, m, "g")
+	if m.focusRow != 5 || m.pendingKeys != "g" {
+		t.Fatalf("a g after the timeout moved focus to row %d with %q pending", m.focusRow, m.pendingKeys)
+	}
+
+	// The timeout of an earlier g doesn't cut the new sequence short
+	m = update(t, m, expired)
+	m, _ = typeKeys(t, m, "g")
+	if m.focusRow != 0 {
+		t.Errorf("g g after an old timeout moved focus to row %d", m.focusRow)
+	}
+}
+
+func TestNewKeymapSequences(t *testing.T) {
+	km, err := NewKeymap(map[string]KeyList{"top": {"g t"}, "bottom": {"g b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !km.StartsSequence("g") || km.StartsSequence("g t") || km.StartsSequence("t") {
+		t.Error("wrong sequence prefixes")
+	}
+	if km.Action("g t") != ActionTop || km.Action("g b") != ActionBottom || km.Action("g") != "" {
+		t.Error("wrong sequence actions")
+	}
+
+	_, err = NewKeymap(map[string]KeyList{"record": {"g"}})
+	if err == nil || !strings.Contains(err.Error(), `key "g" is bound to record and starts a sequence of top`) {
+		t.Errorf("a key starting a sequence gave %v", err)
+	}
+}
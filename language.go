@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// languageInfo holds the codes the services expect for a language
+type languageInfo struct {
+	// code is ISO 639-1, as used by Whisper, Deepgram and Piper voice families
+	code   string
+	name   string
+	native string
+	// region is the Piper voice region preferred when none is configured
+	region string
+	// translation is the LibreTranslate code when it differs from code
+	translation string
+}
+
+var languages = []languageInfo{
+	{code: "ar", name: "Arabic", native: "العربية", region: "ar_JO"},
+	{code: "cs", name: "Czech", native: "Čeština", region: "cs_CZ"},
+	{code: "da", name: "Danish", native: "Dansk", region: "da_DK"},
+	{code: "de", name: "German", native: "Deutsch", region: "de_DE"},
+	{code: "el", name: "Greek", native: "Ελληνικά", region: "el_GR"},
+	{code: "en", name: "English", native: "English", region: "en_US"},
+	{code: "es", name: "Spanish", native: "Español", region: "es_ES"},
+	{code: "fi", name: "Finnish", native: "Suomi", region: "fi_FI"},
+	{code: "fr", name: "French", native: "Français", region: "fr_FR"},
+	{code: "hu", name: "Hungarian", native: "Magyar", region: "hu_HU"},
+	{code: "it", name: "Italian", native: "Italiano", region: "it_IT"},
+	{code: "nl", name: "Dutch", native: "Nederlands", region: "nl_NL"},
+	{code: "no", name: "Norwegian", native: "Norsk", region: "no_NO", translation: "nb"},
+	{code: "pl", name: "Polish", native: "Polski", region: "pl_PL"},
+	{code: "pt", name: "Portuguese", native: "Português", region: "pt_PT"},
+	{code: "ru", name: "Russian", native: "Русский", region: "ru_RU"},
+	{code: "sv", name: "Swedish", native: "Svenska", region: "sv_SE"},
+	{code: "tr", name: "Turkish", native: "Türkçe", region: "tr_TR"},
+	{code: "uk", name: "Ukrainian", native: "Українська", region: "uk_UA"},
+	{code: "zh", name: "Chinese", native: "中文", region: "zh_CN"},
+}
+
+// languageTag is a language code with an optional region, de, de-DE or de_DE
+var languageTag = regexp.MustCompile(`^([a-zA-Z]{2,3})(?:[-_]([a-zA-Z]{2}))?$`)
+
+func findLanguage(code string) (languageInfo, bool) {
+	for _, info := range languages {
+		if info.code == code {
+			return info, true
+		}
+	}
+	return languageInfo{}, false
+}
+
+// normalizeLanguage turns a language code, a code with region or an English
+// or native language name into the code used in the config, de or pt-BR.
+// Codes missing from the table are kept so every Whisper language works
+func normalizeLanguage(language string) (string, error) {
+	language = strings.TrimSpace(language)
+	if language == "" {
+		return "", nil
+	}
+	for _, info := range languages {
+		if strings.EqualFold(language, info.name) || strings.EqualFold(language, info.native) {
+			return info.code, nil
+		}
+	}
+	match := languageTag.FindStringSubmatch(language)
+	if match == nil {
+		return "", fmt.Errorf("unknown language %q, use a code such as de or a name such as German", language)
+	}
+	code := strings.ToLower(match[1])
+	if match[2] == "" {
+		return code, nil
+	}
+	return code + "-" + strings.ToUpper(match[2]), nil
+}
+
+// languageCode drops the region, it is what transcription and the Piper
+// voice families expect
+func languageCode(language string) string {
+	code, _, _ := strings.Cut(language, "-")
+	return code
+}
+
+// translationLanguage is the code LibreTranslate knows the language by
+func translationLanguage(language string) string {
+	code := languageCode(language)
+	if info, ok := findLanguage(code); ok && info.translation != "" {
+		return info.translation
+	}
+	return code
+}
+
+// voiceLanguage is the Piper language code voices are preferred from, the
+// configured region or the usual one of the language
+func voiceLanguage(language string) string {
+	code, region, ok := strings.Cut(language, "-")
+	if ok {
+		return code + "_" + region
+	}
+	if info, found := findLanguage(code); found {
+		return info.region
+	}
+	return code
+}
+
+// languageName is the English name used in the prompts, the code when the
+// language isn't in the table
+func languageName(language string) string {
+	if info, ok := findLanguage(languageCode(language)); ok {
+		return info.name
+	}
+	return language
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"lazylang/piper"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// whisperLanguages are some of the language codes Whisper transcribes
+var whisperLanguages = map[string]bool{
+	"ar": true, "be": true, "bg": true, "cs": true, "da": true, "de": true, "el": true,
+	"en": true, "es": true, "fi": true, "fr": true, "hu": true, "it": true, "ja": true,
+	"nl": true, "no": true, "pl": true, "pt": true, "ru": true, "sr": true, "sv": true,
+	"tr": true, "uk": true, "zh": true,
+}
+
+// fixtureVoicesDir is a voices directory with a fresh copy of the voices list
+// in testdata, it has voices for every language in the table
+func fixtureVoicesDir(t *testing.T) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "voices.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "voices.json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestEveryLanguageHasDefaults(t *testing.T) {
+	dir := piper.Dir{Path: fixtureVoicesDir(t), ReadOnly: true}
+	for _, info := range languages {
+		t.Run(info.name, func(t *testing.T) {
+			if !whisperLanguages[languageCode(info.code)] {
+				t.Errorf("Whisper doesn't know %q", info.code)
+			}
+			if family, _, _ := strings.Cut(info.region, "_"); family != info.code {
+				t.Errorf("region %s isn't a region of %s", info.region, info.code)
+			}
+			voice, err := piper.ResolveVoice(dir, voiceLanguage(info.code), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if voice.Language.Code != info.region {
+				t.Errorf("resolved %s, want a voice of %s", voice.Key, info.region)
+			}
+			if normalized, err := normalizeLanguage(info.name); err != nil || normalized != info.code {
+				t.Errorf("the name %s is %q, %v", info.name, normalized, err)
+			}
+			if normalized, err := normalizeLanguage(info.native); err != nil || normalized != info.code {
+				t.Errorf("the native name %s is %q, %v", info.native, normalized, err)
+			}
+		})
+	}
+}
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := []struct {
+		language string
+		want     string
+		wantErr  bool
+	}{
+		{"de", "de", false},
+		{"DE", "de", false},
+		{" de ", "de", false},
+		{"de-DE", "de-DE", false},
+		{"de_de", "de-DE", false},
+		{"German", "de", false},
+		{"german", "de", false},
+		{"Deutsch", "de", false},
+		{"pt-br", "pt-BR", false},
+		{"Español", "es", false},
+		{"Français", "fr", false},
+		{"Українська", "uk", false},
+		{"中文", "zh", false},
+		{"Norwegian", "no", false},
+		{"ja", "ja", false},
+		{"haw", "haw", false},
+		{"", "", false},
+		{"Klingon", "", true},
+		{"de-DEU", "", true},
+	}
+	for _, tt := range tests {
+		got, err := normalizeLanguage(tt.language)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("normalizeLanguage(%q) = %q, %v, want %q", tt.language, got, err, tt.want)
+		}
+	}
+}
+
+func TestServiceLanguageCodes(t *testing.T) {
+	tests := []struct {
+		language    string
+		whisper     string
+		translation string
+		voice       string
+		name        string
+	}{
+		{"de", "de", "de", "de_DE", "German"},
+		{"de-AT", "de", "de", "de_AT", "German"},
+		{"en", "en", "en", "en_US", "English"},
+		{"en-GB", "en", "en", "en_GB", "English"},
+		{"es", "es", "es", "es_ES", "Spanish"},
+		{"es-MX", "es", "es", "es_MX", "Spanish"},
+		{"fr", "fr", "fr", "fr_FR", "French"},
+		{"it", "it", "it", "it_IT", "Italian"},
+		{"nl", "nl", "nl", "nl_NL", "Dutch"},
+		{"no", "no", "nb", "no_NO", "Norwegian"},
+		{"pl", "pl", "pl", "pl_PL", "Polish"},
+		{"pt", "pt", "pt", "pt_PT", "Portuguese"},
+		{"pt-BR", "pt", "pt", "pt_BR", "Portuguese"},
+		{"ru", "ru", "ru", "ru_RU", "Russian"},
+		{"sv", "sv", "sv", "sv_SE", "Swedish"},
+		{"tr", "tr", "tr", "tr_TR", "Turkish"},
+		{"uk", "uk", "uk", "uk_UA", "Ukrainian"},
+		{"zh", "zh", "zh", "zh_CN", "Chinese"},
+		{"ja", "ja", "ja", "ja", "ja"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.language, func(t *testing.T) {
+			if got := languageCode(tt.language); got != tt.whisper {
+				t.Errorf("transcription language %q, want %q", got, tt.whisper)
+			}
+			if got := translationLanguage(tt.language); got != tt.translation {
+				t.Errorf("translation language %q, want %q", got, tt.translation)
+			}
+			if got := voiceLanguage(tt.language); got != tt.voice {
+				t.Errorf("voice language %q, want %q", got, tt.voice)
+			}
+			if got := languageName(tt.language); got != tt.name {
+				t.Errorf("name %q, want %q", got, tt.name)
+			}
+		})
+	}
+}
+
+func TestResolvePiperVoiceFromTable(t *testing.T) {
+	backend := TTSBackend{VoicesDir: fixtureVoicesDir(t)}
+	tests := []struct {
+		language string
+		want     string
+	}{
+		{"es", "es_ES-davefx-medium.onnx"},
+		{"es-MX", "es_MX-claude-high.onnx"},
+		{"pt-BR", "pt_BR-faber-medium.onnx"},
+		{"nl", "nl_NL-mls-medium.onnx"},
+		{"it", "it_IT-riccardo-x_low.onnx"},
+	}
+	for _, tt := range tests {
+		if voice, _ := resolvePiperVoice(tt.language, backend, NewConfig()); voice != tt.want {
+			t.Errorf("resolvePiperVoice(%q) = %s, want %s", tt.language, voice, tt.want)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// LibreTranslator uses a LibreTranslate instance
+type LibreTranslator struct {
+	url    string
+	apiKey string
+	auth   *BasicAuth
+
+	// languages are the pairs the instance translates, fetched once
+	mu        sync.Mutex
+	languages []libreLanguage
+}
+
+// libreLanguage is a language of /languages with the ones it translates into
+type libreLanguage struct {
+	Code    string   `json:"code"`
+	Targets []string `json:"targets"`
+}
+
+// LanguagePairChecked tells whether the instance translates between the
+// configured languages, it is checked at start
+type LanguagePairChecked struct {
+	err error
+}
+
+func checkLanguagePair(translator Translator, config Config) tea.Cmd {
+	return func() tea.Msg {
+		libre, ok := translator.(*LibreTranslator)
+		if !ok {
+			return LanguagePairChecked{}
+		}
+		ctx, cancel := context.WithTimeout(appContext, time.Duration(config.Translation.TimeoutSeconds)*time.Second)
+		defer cancel()
+		return LanguagePairChecked{err: libre.checkPair(ctx, config.Language, config.TargetTranslationLanguage)}
+	}
+}
+
+// languagePairChecked warns about a pair the instance doesn't translate, an
+// unreachable instance shows when a word is translated
+func (m *model) languagePairChecked(msg LanguagePairChecked) {
+	var pairErr UnsupportedPairError
+	if errors.As(msg.err, &pairErr) {
+		m.addWarning(pairErr.Hint())
+	} else if msg.err != nil {
+		slog.Warn("Failed to check the translation languages", "error", msg.err)
+	}
+}
+
+// checkPair returns an UnsupportedPairError when the instance doesn't
+// translate from source into target
+func (l *LibreTranslator) checkPair(ctx context.Context, source string, target string) error {
+	languages, err := l.supportedLanguages(ctx)
+	if err != nil {
+		return err
+	}
+	source, target = translationLanguage(source), translationLanguage(target)
+	for _, language := range languages {
+		if language.Code != source {
+			continue
+		}
+		if slices.Contains(language.Targets, target) {
+			return nil
+		}
+		return UnsupportedPairError{Provider: "LibreTranslate", Source: source, Target: target, Targets: language.Targets}
+	}
+	return UnsupportedPairError{Provider: "LibreTranslate", Source: source, Target: target}
+}
+
+// supportedLanguages fetches /languages once, a failed request is tried again
+// next time
+func (l *LibreTranslator) supportedLanguages(ctx context.Context) ([]libreLanguage, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.languages != nil {
+		return l.languages, nil
+	}
+
+	req, err := l.newRequest(ctx, "GET", "/languages", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create languages request: %w", err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call LibreTranslate: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read languages response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, libreTranslateError(resp.StatusCode, body)
+	}
+	if err := json.Unmarshal(body, &l.languages); err != nil {
+		return nil, fmt.Errorf("failed to parse languages response: %w", err)
+	}
+	return l.languages, nil
+}
+
+// pairError explains a rejected pair with the targets of the source language
+// when the instance lists them
+func (l *LibreTranslator) pairError(ctx context.Context, source string, target string, err error) error {
+	if pairErr := l.checkPair(ctx, source, target); errors.As(pairErr, new(UnsupportedPairError)) {
+		return pairErr
+	}
+	return err
+}
+
+// libreTranslateURL is LIBRETRANSLATE_URL or the configured URL
+func libreTranslateURL(config Config) string {
+	if baseURL := os.Getenv("LIBRETRANSLATE_URL"); baseURL != "" {
+		return strings.TrimSuffix(baseURL, "/")
+	}
+	return strings.TrimSuffix(config.LibreTranslateURL, "/")
+}
+
+// libreTranslateAPIKey is LIBRETRANSLATE_API_KEY or the configured key
+func libreTranslateAPIKey(config Config) string {
+	if key := os.Getenv("LIBRETRANSLATE_API_KEY"); key != "" {
+		return key
+	}
+	return config.LibreTranslateAPIKey
+}
+
+// newRequest builds a request to the endpoint of the instance with the basic
+// auth credentials
+func (l *LibreTranslator) newRequest(ctx context.Context, method string, endpoint string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, l.url+endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if l.auth != nil {
+		req.SetBasicAuth(l.auth.User, l.auth.Password)
+	}
+	return req, nil
+}
+
+func (l *LibreTranslator) Translate(ctx context.Context, text string, source string, target string) (string, error) {
+	translations, err := l.translate(ctx, text, source, target, 0)
+	if err != nil {
+		return "", err
+	}
+	return translations[0], nil
+}
+
+// TranslateAlternatives asks for up to count alternatives besides the best
+// translation, instances before 1.6 leave them out
+func (l *LibreTranslator) TranslateAlternatives(ctx context.Context, text string, source string, target string, count int) ([]string, error) {
+	return l.translate(ctx, text, source, target, count)
+}
+
+func (l *LibreTranslator) translate(ctx context.Context, text string, source string, target string, alternatives int) ([]string, error) {
+	fields := map[string]any{
+		"q":      text,
+		"source": translationLanguage(source),
+		"target": translationLanguage(target),
+		"format": "text",
+	}
+	if l.apiKey != "" {
+		fields["api_key"] = l.apiKey
+	}
+	if alternatives > 0 {
+		fields["alternatives"] = alternatives
+	}
+	reqBody, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal translation request: %w", err)
+	}
+
+	req, err := l.newRequest(ctx, "POST", "/translate", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create translation request: %w", err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call LibreTranslate: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read translation response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, l.translationError(ctx, source, target, resp.StatusCode, body)
+	}
+
+	var result struct {
+		TranslatedText string   `json:"translatedText"`
+		Alternatives   []string `json:"alternatives"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse translation response: %w", err)
+	}
+
+	return append([]string{result.TranslatedText}, result.Alternatives...), nil
+}
+
+// TranslateBatch sends all texts in one request, instances before 1.3 don't
+// take lists
+func (l *LibreTranslator) TranslateBatch(ctx context.Context, texts []string, source string, target string) ([]BatchTranslation, error) {
+	fields := map[string]any{
+		"q":      texts,
+		"source": translationLanguage(source),
+		"target": translationLanguage(target),
+		"format": "text",
+	}
+	if l.apiKey != "" {
+		fields["api_key"] = l.apiKey
+	}
+	reqBody, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal translation request: %w", err)
+	}
+
+	req, err := l.newRequest(ctx, "POST", "/translate", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create translation request: %w", err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call LibreTranslate: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read translation response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, l.translationError(ctx, source, target, resp.StatusCode, body)
+	}
+
+	var result struct {
+		TranslatedText []string `json:"translatedText"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse translation response: %w", err)
+	}
+	return batchResults("LibreTranslate", texts, result.TranslatedText)
+}
+
+// DetectLanguage asks the instance for the most likely language of the text
+func (l *LibreTranslator) DetectLanguage(ctx context.Context, text string) (string, float64, error) {
+	fields := map[string]string{"q": text}
+	if l.apiKey != "" {
+		fields["api_key"] = l.apiKey
+	}
+	reqBody, err := json.Marshal(fields)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to marshal detection request: %w", err)
+	}
+
+	req, err := l.newRequest(ctx, "POST", "/detect", bytes.NewReader(reqBody))
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create detection request: %w", err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to call LibreTranslate: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read detection response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, libreTranslateError(resp.StatusCode, body)
+	}
+
+	// The most likely language comes first, the confidence is in percent
+	var result []struct {
+		Language   string  `json:"language"`
+		Confidence float64 `json:"confidence"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", 0, fmt.Errorf("failed to parse detection response: %w", err)
+	}
+	if len(result) == 0 {
+		return "", 0, fmt.Errorf("LibreTranslate detected no language")
+	}
+	return result[0].Language, result[0].Confidence / 100, nil
+}
+
+// translationError is libreTranslateError with the targets the source
+// language has when the pair is rejected
+func (l *LibreTranslator) translationError(ctx context.Context, source string, target string, status int, body []byte) error {
+	err := libreTranslateError(status, body)
+	if errors.Is(err, errUnsupportedPair) {
+		return l.pairError(ctx, source, target, err)
+	}
+	return err
+}
+
+// libreTranslateError maps the status and the error message of LibreTranslate
+// to the common translation errors
+func libreTranslateError(status int, body []byte) error {
+	var result struct {
+		Error string `json:"error"`
+	}
+	message := string(body)
+	if json.Unmarshal(body, &result) == nil && result.Error != "" {
+		message = result.Error
+	}
+
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return fmt.Errorf("%w: LibreTranslate (status %d): %s", errTranslationAuth, status, message)
+	case status == http.StatusTooManyRequests:
+		return fmt.Errorf("%w: LibreTranslate (status %d): %s", errTranslationQuota, status, message)
+	// Public instances answer a missing key with 400
+	case status == http.StatusBadRequest && strings.Contains(strings.ToLower(message), "api key"):
+		return fmt.Errorf("%w: LibreTranslate (status %d): %s", errTranslationAuth, status, message)
+	case status == http.StatusBadRequest && strings.Contains(message, "not supported"):
+		return fmt.Errorf("%w: LibreTranslate: %s", errUnsupportedPair, message)
+	}
+	return TranslationError{Provider: "LibreTranslate", StatusCode: status, Message: message}
+}
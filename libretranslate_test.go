@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// libreServer answers /translate with translate and /languages with German
+// translating into English and French
+func libreServer(t *testing.T, translate http.HandlerFunc) *LibreTranslator {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/languages":
+			w.Write([]byte(`[{"code": "de", "targets": ["en", "fr"]}, {"code": "en", "targets": ["de"]}]`))
+		case "/translate":
+			translate(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return &LibreTranslator{url: server.URL}
+}
+
+func TestLibreTranslate(t *testing.T) {
+	libre := libreServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "me" || password != "pass" {
+			t.Errorf("basic auth = %q %q %v", user, password, ok)
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body["q"] != "Hund" || body["source"] != "de" || body["target"] != "en" || body["api_key"] != "key" || body["alternatives"] != 2.0 {
+			t.Errorf("request = %v", body)
+		}
+		w.Write([]byte(`{"translatedText": "dog", "alternatives": ["hound", "Dog"]}`))
+	})
+	libre.apiKey = "key"
+	libre.auth = &BasicAuth{User: "me", Password: "pass"}
+
+	translations, err := libre.TranslateAlternatives(context.Background(), "Hund", "de", "en", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(translations, []string{"dog", "hound", "Dog"}) {
+		t.Errorf("translations = %q", translations)
+	}
+	if unique := uniqueTranslations(translations); !slices.Equal(unique, []string{"dog", "hound"}) {
+		t.Errorf("unique translations = %q", unique)
+	}
+}
+
+func TestLibreTranslateBatch(t *testing.T) {
+	libre := libreServer(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Q []string `json:"q"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(body.Q, []string{"Hund", "Katze"}) {
+			t.Errorf("q = %q", body.Q)
+		}
+		w.Write([]byte(`{"translatedText": ["dog", "cat"]}`))
+	})
+
+	results, err := libre.TranslateBatch(context.Background(), []string{"Hund", "Katze"}, "de", "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || results[0].Translation != "dog" || results[1].Translation != "cat" {
+		t.Errorf("results = %+v", results)
+	}
+}
+
+func TestLibreTranslateErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"forbidden", http.StatusForbidden, `{"error": "Invalid API key"}`, errTranslationAuth},
+		{"missing key", http.StatusBadRequest, `{"error": "Please contact the server operator to get an API key"}`, errTranslationAuth},
+		{"rate limit", http.StatusTooManyRequests, `{"error": "Slowdown"}`, errTranslationQuota},
+		{"unsupported pair", http.StatusBadRequest, `{"error": "es (Spanish) is not supported"}`, errUnsupportedPair},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			libre := libreServer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+			_, err := libre.Translate(context.Background(), "Hund", "de", "es")
+			if !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLibreTranslateUnsupportedPairNamesTargets(t *testing.T) {
+	libre := libreServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": "es (Spanish) is not supported"}`))
+	})
+
+	_, err := libre.Translate(context.Background(), "Hund", "de", "es")
+	var pairErr UnsupportedPairError
+	if !errors.As(err, &pairErr) {
+		t.Fatalf("error = %v, want an UnsupportedPairError", err)
+	}
+	if !slices.Equal(pairErr.Targets, []string{"en", "fr"}) {
+		t.Errorf("targets = %q, want the targets of German", pairErr.Targets)
+	}
+	if got := translationFailure("", err); got != pairErr.Hint() {
+		t.Errorf("status %q, want the hint", got)
+	}
+}
+
+func TestLibreCheckPair(t *testing.T) {
+	libre := libreServer(t, http.NotFound)
+	if err := libre.checkPair(context.Background(), "de", "en"); err != nil {
+		t.Errorf("de to en: %v", err)
+	}
+	var pairErr UnsupportedPairError
+	if err := libre.checkPair(context.Background(), "pl", "en"); !errors.As(err, &pairErr) || len(pairErr.Targets) != 0 {
+		t.Errorf("pl to en: %v", err)
+	}
+}
+
+func TestTranslationFailure(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{errTranslationAuth, "Translation auth failed"},
+		{errTranslationQuota, "Translation quota exhausted"},
+		{errUnsupportedPair, "Translation between these languages is not supported"},
+		{context.DeadlineExceeded, "Translation timed out"},
+		{TranslationError{StatusCode: 502}, "Translation server error (HTTP 502)"},
+		{TranslationError{StatusCode: 400}, "Translation request rejected (HTTP 400)"},
+		{errors.New("other"), "status"},
+	}
+	for _, tt := range tests {
+		if got := translationFailure("status", tt.err); got != tt.want {
+			t.Errorf("translationFailure(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
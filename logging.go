@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// LogConfig sets where the log is written and how much of it
+type LogConfig struct {
+	// Level is debug, info, warn or error
+	Level string `json:"level"`
+	// File defaults to lazylang.log in StateDir
+	File string `json:"file,omitempty"`
+	// MaxSizeMB rotates the file into File.1 when it grows larger, 10 by
+	// default
+	MaxSizeMB int `json:"max_size_mb"`
+}
+
+func (c LogConfig) path() (string, error) {
+	if c.File != "" {
+		return c.File, nil
+	}
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "lazylang.log"), nil
+}
+
+var (
+	// logLevel is changed when the config is reloaded
+	logLevel = new(slog.LevelVar)
+	// debugLogging is set by --debug and wins over the configured level
+	debugLogging bool
+)
+
+// setLogLevel applies the level of the config unless --debug was given
+func setLogLevel(config LogConfig) error {
+	if debugLogging {
+		logLevel.Set(slog.LevelDebug)
+		return nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(config.Level)); err != nil {
+		return fmt.Errorf("invalid log level %q", config.Level)
+	}
+	logLevel.Set(level)
+	return nil
+}
+
+// setupLogging sends slog and the log package to the log file, nothing is
+// logged when it can't be opened
+func setupLogging(config LogConfig) (io.Closer, error) {
+	if err := setLogLevel(config); err != nil {
+		return nil, err
+	}
+
+	file, err := openLogFile(config)
+	if err != nil {
+		// Logging to the terminal would draw over the TUI
+		slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+		return nil, err
+	}
+
+	// SetDefault also routes log.Printf through the handler
+	slog.SetDefault(slog.New(slog.NewTextHandler(file, &slog.HandlerOptions{Level: logLevel})))
+	return file, nil
+}
+
+func openLogFile(config LogConfig) (*rotatingFile, error) {
+	path, err := config.path()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create the log directory: %w", err)
+	}
+	return openRotatingFile(path, int64(config.MaxSizeMB)<<20)
+}
+
+// rotatingFile moves the log to path.1 once it reaches maxSize, zero never
+// rotates
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	size    int64
+	file    *os.File
+}
+
+func openRotatingFile(path string, maxSize int64) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open the log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open the log file: %w", err)
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		r.file.Close()
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return 0, err
+		}
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
@@ -3,23 +3,21 @@ package main
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"lazylang/piper"
 	"log"
 	"log/slog"
-	"net/http"
 	"os"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/tmc/langchaingo/chains"
-	"github.com/tmc/langchaingo/memory"
-	"github.com/tmc/langchaingo/prompts"
 
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -27,12 +25,10 @@ import (
 )
 
 const (
-	sampleRate = 16000
-	channels   = 1
+	sampleRate     = 16000
+	channels       = 1
 	groqAPIBaseURL = "https://api.groq.com/openai/v1"
 )
-var groqAudioAPIURL = fmt.Sprintf("%v/audio/transcriptions", groqAPIBaseURL)
-
 
 // WAV header constants
 const (
@@ -45,64 +41,236 @@ const (
 
 var isAlpha = regexp.MustCompile(`[\p{L}]+`)
 
-type GroqTranscriptionResponse struct {
-	Text string `json:"text"`
-}
-
 type model struct {
-	llmChain    *chains.LLMChain
+	// Session is the active conversation tab
+	*Session
+	sessions      []*Session
+	nextSessionID int
+	llm           *ChatCompletion
+	fallbackLLM   *ChatCompletion
+
 	viewport    viewport.Model
-	content     string
 	ready       bool
 	recorder    *Recorder
-	apiKey      string
-	piperVoice  *piper.PiperVoice
-	status      string
-	focusWord   int
-	focusRow    int
-	fullWidth   int
-	cancelSpeak context.CancelFunc
-	wordsStore  *WordsStore
-	config      Config
+	transcriber Transcriber
+	// translator is nil when translation is disabled
+	translator Translator
+	// detectedLanguages caches the languages detected for words
+	detectedLanguages *languageCache
+	// visualAnchor is where the selection of visual mode started, nil
+	// outside of it
+	visualAnchor *wordPos
+	// dictionary is nil until translation.dictionary is loaded
+	dictionary *Dictionary
+	// sentenceTranslation is shown below the conversation until esc
+	sentenceTranslation *SentenceTranslated
+	// choosing is the translations of a word to pick the saved one from
+	choosing *translationChoice
+	// awaitingTranslation is the word last sent for translation, translating
+	// it again before it arrives sets acceptTopTranslation
+	awaitingTranslation  string
+	acceptTopTranslation bool
+	// exampleQueue is the saved words waiting for an example sentence, one
+	// is generated at a time
+	exampleQueue      []string
+	generatingExample bool
+	// lastClick is the word clicked last, a second click on it soon after
+	// translates it
+	lastClick wordClick
+	// sidebarFocus is the saved word clicked in the sidebar
+	sidebarFocus string
+	// pendingKeys are the keys typed of a sequence such as g g, a new
+	// pendingKeysID drops them after keySequenceTimeout
+	pendingKeys   string
+	pendingKeysID int
+	// grammarNotes are the notes of show_grammar by word, they may arrive
+	// before the word is saved
+	grammarNotes map[string]string
+	// lastHoldKey is when space was last seen in hold record mode
+	lastHoldKey time.Time
+	// recordingID tells the elapsed time ticks of recordings apart
+	recordingID      int
+	recordingStarted time.Time
+	// transcribedRecording is the recordingID last sent for transcription,
+	// VAD and a manual stop may both finish the same recording
+	transcribedRecording int
+	// lastRecording is kept so a failed transcription can be retried
+	lastRecording []byte
+	apiKey        string
+	speaker       Speaker
+	status        Status
+	// spinner turns while the status is busy, spinning is set while its
+	// ticks are on their way
+	spinner   spinner.Model
+	spinning  bool
+	fullWidth int
+	// downloadingVoice is the voice being downloaded, answers needing it
+	// wait in awaitingVoice
+	downloadingVoice string
+	awaitingVoice    []string
+	keymap           Keymap
+	// showHelp covers the conversation with the key bindings
+	showHelp bool
+	// speech plays answers one at a time, Clear stops them
+	speech     *SpeechQueue
+	wordsStore *WordsStore
+	config     Config
+
+	fullHeight int
+	started    time.Time
+	// typing is set while the text input has focus, keys go to the input
+	// instead of the navigation bindings
+	typing     bool
+	input      textinput.Model
+	inputError string
+	// confirming is a transcription waiting to be sent, edited or dropped
+	confirming *pendingTranscription
+	// handsFree starts recording again after every spoken answer
+	handsFree bool
+	// warning is shown above the header until the problem is fixed
+	warning string
+	// transcriptionDisabled is set when Groq rejected the key
+	transcriptionDisabled bool
+	// loadedConfig is the config as read from the file, before keys changed
+	// it, so a reload only overrides what was edited
+	loadedConfig Config
+	// askReset asks whether to clear the conversation after a reload
+	// changed the language
+	askReset bool
 }
 
 func initialModel(apiKey string, config Config) model {
-	llm, err := NewLLM()
+	// The terminal is asked before the program reads its input
+	if config.Theme.Preset == "" {
+		darkBackground = lipgloss.HasDarkBackground()
+	}
+	themeProblems := applyTheme(config.Theme)
+
+	llm, err := NewLLM(config.LLM.Options(apiKey)...)
 	if err != nil {
 		fmt.Printf("Error creating LLM: %v\n", err)
 		os.Exit(1)
 	}
 
-	prompt := prompts.NewPromptTemplate(
-		fmt.Sprintf(` You are a %s teacher. Respond to the following question or statement in
-  %s.
+	var fallbackLLM *ChatCompletion
+	if config.FallbackLLM != nil {
+		fallbackLLM, err = NewLLM(config.FallbackLLM.Options(apiKey)...)
+		if err != nil {
+			slog.Error("Failed to create fallback LLM", "error", err)
+		}
+	}
 
-  Previous conversation history:
-  {{.history}}
+	transcriber, err := NewTranscriber(config.STTBackend, apiKey)
+	if err != nil {
+		fmt.Printf("Error creating transcriber: %v\n", err)
+		os.Exit(1)
+	}
 
-  Important: only give short answers to the questions!
-  Student: {{.text}}
-  Teacher:
-  `, config.Language, config.Language),
-		[]string{"history", "text"},
-	)
+	var translator Translator
+	if config.Translation.IsEnabled() {
+		if translator, err = NewTranslator(config); err != nil {
+			fmt.Printf("Error creating translator: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
-	llmChain := chains.NewLLMChain(llm, prompt)
-	llmChain.Memory = memory.NewConversationBuffer()
-	piperVoice := piper.NewPiperVoice(piper.WithModel(config.TTSBackend.Voice), piper.WithLanguage(config.Language))
-	return model{
-		llmChain:   llmChain,
-		recorder:   NewRecorder(),
-		apiKey:     apiKey,
-		status:     "Ready",
-		piperVoice: piperVoice,
-		wordsStore: NewWordsStore(),
-		config:     config,
+	// The hands-free loop relies on voice activity detection to end turns
+	if config.HandsFree {
+		config.VAD.Enabled = true
 	}
+
+	piper.SetOutputDevice(config.OutputDevice)
+	piper.SetVolume(config.Volume)
+
+	recorderOptions := []RecorderOption{WithInputDevice(config.InputDevice)}
+	if config.VAD.Enabled {
+		recorderOptions = append(recorderOptions, WithVAD(config.VAD.Threshold, time.Duration(config.VAD.SilenceMs)*time.Millisecond))
+	}
+	if config.InputGain > 0 {
+		recorderOptions = append(recorderOptions, WithGain(config.InputGain))
+	}
+	if config.AutoNormalize {
+		recorderOptions = append(recorderOptions, WithAutoNormalize())
+	}
+	if config.NoiseGate.Enabled {
+		recorderOptions = append(recorderOptions, WithNoiseGate(config.NoiseGate.Margin))
+	}
+	if config.AudioCues {
+		recorderOptions = append(recorderOptions, WithStartCue(func() { playCue(startCue) }))
+	}
+	if config.TrimSilence.Enabled {
+		recorderOptions = append(recorderOptions, WithSilenceTrim(config.TrimSilence.Threshold, time.Duration(config.TrimSilence.PaddingMs)*time.Millisecond))
+	}
+
+	keymap, err := NewKeymap(config.Keys)
+	if err != nil {
+		fmt.Printf("Error in the keys config: %v\n", err)
+		os.Exit(1)
+	}
+
+	speaker, warning, err := NewSpeaker(config.TTSBackend, config.Language)
+	if err != nil {
+		fmt.Printf("Error creating speaker: %v\n", err)
+		os.Exit(1)
+	}
+
+	status := Status{kind: statusReady, text: "Ready"}
+	downloading := missingVoice(speaker)
+	if downloading != "" {
+		status = Status{kind: statusBusy, text: "Downloading tts model"}
+	}
+
+	session := NewSession(1, llm, NewPrompt(config, nil))
+	m := model{
+		Session:           session,
+		sessions:          []*Session{session},
+		nextSessionID:     session.id,
+		llm:               llm,
+		fallbackLLM:       fallbackLLM,
+		recorder:          NewRecorder(recorderOptions...),
+		transcriber:       transcriber,
+		translator:        translator,
+		detectedLanguages: newLanguageCache(),
+		apiKey:            apiKey,
+		status:            status,
+		spinner:           newStatusSpinner(),
+		speaker:           speaker,
+		downloadingVoice:  downloading,
+		speech:            NewSpeechQueue(),
+		keymap:            keymap,
+		warning:           warning,
+		wordsStore:        NewWordsStore(),
+		grammarNotes:      make(map[string]string),
+		config:            config,
+		loadedConfig:      config,
+		input:             NewInput(),
+		started:           time.Now(),
+		handsFree:         config.HandsFree,
+	}
+	m.addWarning(storageWarning())
+	m.addVoiceMismatchWarning()
+	for _, problem := range themeProblems {
+		m.addWarning(problem)
+	}
+	return m
 }
 
 func (m model) Init() tea.Cmd {
-	return nil
+	var cmds []tea.Cmd
+	if m.downloadingVoice != "" {
+		cmds = append(cmds, downloadVoice(DownloadModel{model: m.downloadingVoice, language: m.config.Language}, m.config.TTSBackend.VoicesDir))
+	}
+	if isGroqSTT(m.config.STTBackend) {
+		cmds = append(cmds, checkGroqKey(m.config, m.apiKey))
+	}
+	if m.config.Translation.IsEnabled() && m.config.Translation.Dictionary != "" {
+		cmds = append(cmds, loadDictionary(m.config.Translation.Dictionary))
+	}
+	if m.config.Translation.IsEnabled() {
+		cmds = append(cmds, checkLanguagePair(m.translator, m.config))
+	}
+	cmds = append(cmds, watchConfig(configModTime()))
+	return tea.Batch(cmds...)
 }
 
 func EmptyCmd() tea.Msg {
@@ -110,27 +278,64 @@ func EmptyCmd() tea.Msg {
 }
 
 type RecordingStarted struct{}
+
+// RecordingFailed is sent when the microphone couldn't be opened
+type RecordingFailed struct {
+	err error
+}
+
 type TranscriptionReceived struct {
+	sessionID     int
+	turn          int
 	transcription string
+	confidence    float64
+}
+type TranscriptionRetry struct {
+	sessionID int
+	turn      int
+	attempt   int
+	wav       []byte
 }
+
 type StatusChanged struct {
+	kind   statusKind
 	status string
+	// spoken is set when an answer finished playing
+	spoken bool
 }
 type ReadyCompletion struct {
+	sessionID  int
+	turn       int
 	completion string
 	addContent bool
+	// fallback is set when the fallback LLM answered
+	fallback bool
 }
 
-func GetLlmCompletion(text string, m model) tea.Cmd {
+func GetLlmCompletion(ctx context.Context, text string, s *Session, turn int, m model) tea.Cmd {
+	maxTokens := m.config.ResponseStyle.MaxTokens()
+	fallbackLLM := m.fallbackLLM
 	return func() tea.Msg {
-		output, err := chains.Call(context.Background(), m.llmChain, map[string]any{"text": text})
+		inputs := map[string]any{"text": text}
+		output, err := chains.Call(ctx, s.llmChain, inputs, chains.WithMaxTokens(maxTokens))
+		fallback := false
+		if err != nil && ctx.Err() == nil && fallbackLLM != nil {
+			slog.Warn("Primary LLM failed, using fallback", "error", err)
+			// The fallback chain shares the prompt and memory of the session
+			// so the conversation continues where it left off
+			fallbackChain := chains.NewLLMChain(fallbackLLM, s.llmChain.Prompt)
+			fallbackChain.Memory = s.llmChain.Memory
+			output, err = chains.Call(ctx, fallbackChain, inputs, chains.WithMaxTokens(maxTokens))
+			fallback = true
+		}
 		if err != nil {
-			return StatusChanged{status: "Failed get completion"}
+			slog.Error("Failed to get completion", "error", err)
+			return CompletionFailed{sessionID: s.id, turn: turn, status: "Failed get completion"}
 		}
 		if output["text"] == nil {
-			return StatusChanged{status: "No completion"}
+			return CompletionFailed{sessionID: s.id, turn: turn, status: "No completion"}
 		}
-		return ReadyCompletion{completion: output["text"].(string), addContent: true}
+		return ReadyCompletion{sessionID: s.id, turn: turn, completion: output["text"].(string), addContent: true, fallback: fallback}
 	}
 }
 
@@ -140,104 +345,101 @@ type DownloadModel struct {
 	completion string
 }
 
-func Speak(ctx context.Context, text string, m model) tea.Cmd {
+// Speak queues text behind the answers already being spoken
+func Speak(text string, m model) tea.Cmd {
+	speaker := m.speaker
 	return func() tea.Msg {
-		err := m.piperVoice.Speak(ctx, text)
-		if err != nil {
-			switch err := err.(type) {
-			case piper.StoppedSpeaking:
-				return ""
-			case piper.ErrorModelNotFound:
-				return DownloadModel{model: err.Model, language: err.Language, completion: text}
-			default:
-				log.Printf("Error speaking: %v\n", err)
-				return StatusChanged{status: "Failed to speak"}
-			}
-		}
-		return StatusChanged{status: "Ready"}
+		err := m.speech.Do(func(ctx context.Context) error {
+			return speaker.Speak(ctx, speakableText(text))
+		})
+		return speechResult(err, text)
 	}
 }
 
-func HighlightFocusWord(wrapped_text string, focusRow int, focusWord int) string {
-	var st strings.Builder
-	for i, row := range strings.Split(strings.TrimSpace(wrapped_text), "\n") {
-		if i == focusRow {
-			for i, word := range strings.Split(strings.TrimSpace(row), " ") {
-				if i == focusWord {
-					log.Printf("FocusWord: %q %v", word, i)
-					st.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("205")).Render(word))
-				} else {
-					st.WriteString(word)
-				}
-				st.WriteRune(' ')
+// speechResult turns the outcome of speaking an answer into a message
+func speechResult(err error, text string) tea.Msg {
+	if err != nil {
+		switch err := err.(type) {
+		case piper.StoppedSpeaking:
+			return ""
+		case piper.ErrorModelNotFound:
+			return DownloadModel{model: err.Model, language: err.Language, completion: text}
+		default:
+			slog.Error("Failed to speak", "error", err)
+			if errors.Is(err, piper.ErrPiperNotInstalled) {
+				return StatusChanged{kind: statusError, status: "piper-tts not installed"}
 			}
-		} else {
-			st.WriteString(row)
+			var execErr piper.PiperExecError
+			if errors.As(err, &execErr) && execErr.FirstLine() != "" {
+				return StatusChanged{kind: statusError, status: "Failed to speak: " + execErr.FirstLine()}
+			}
+			var apiErr SpeechAPIError
+			if errors.As(err, &apiErr) {
+				return StatusChanged{kind: statusError, status: fmt.Sprintf("Failed to speak: %s returned status %d", apiErr.Provider, apiErr.StatusCode)}
+			}
+			return StatusChanged{kind: statusError, status: "Failed to speak"}
 		}
-		st.WriteRune('\n')
 	}
-
-	return st.String()
-}
-
-type TranslationReceived struct {
-	Word        string
-	Translation string
+	return StatusChanged{kind: statusReady, status: "Ready", spoken: true}
 }
 
-func GetTranslation(word string, m model) tea.Cmd {
+// PlayRecording plays back a WAV recording through the same playback path
+// Piper uses
+func PlayRecording(speech *SpeechQueue, wav []byte) tea.Cmd {
 	return func() tea.Msg {
-		baseURL := os.Getenv("LIBRETRANSLATE_URL")
-		if baseURL == "" {
-			baseURL = m.config.LibreTranslateURL
-		}
-
-		reqBody, err := json.Marshal(map[string]string{
-			"q":      word,
-			"source": m.config.Language,
-			"target": m.config.TargetTranslationLanguage,
-			"format": "text",
+		pcm := wav[min(wavHeaderSize, len(wav)):]
+		err := speech.Do(func(ctx context.Context) error {
+			return piper.Play(ctx, bytes.NewReader(pcm), sampleRate, channels)
 		})
-		if err != nil {
-			log.Printf("Error marshaling translation request: %v", err)
-			return StatusChanged{status: "Failed to translate"}
+		if _, ok := err.(piper.StoppedSpeaking); ok {
+			return ""
 		}
-
-		resp, err := http.Post(baseURL+"/translate", "application/json", bytes.NewReader(reqBody))
-		if err != nil {
-			log.Printf("Error calling LibreTranslate: %v", err)
-			return StatusChanged{status: "Failed to translate"}
-		}
-		defer resp.Body.Close()
-
-		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			log.Printf("Error reading translation response: %v", err)
-			return StatusChanged{status: "Failed to translate"}
-		}
-
-		if resp.StatusCode != http.StatusOK {
-			log.Printf("LibreTranslate error (status %d): %s", resp.StatusCode, string(body))
-			return StatusChanged{status: "Failed to translate"}
+			slog.Error("Failed to play recording", "error", err)
+			return StatusChanged{kind: statusError, status: "Failed to play recording"}
 		}
+		return StatusChanged{kind: statusReady, status: "Ready"}
+	}
+}
 
-		var result struct {
-			TranslatedText string `json:"translatedText"`
-		}
-		if err := json.Unmarshal(body, &result); err != nil {
-			log.Printf("Error parsing translation response: %v", err)
-			return StatusChanged{status: "Failed to translate"}
-		}
+// translateWord adds the translation of a word or phrase to the sidebar,
+// without translation it is explained in the language itself
+func (m *model) translateWord(word string) tea.Cmd {
+	if !m.config.Translation.IsEnabled() {
+		m.setStatus(statusBusy, "Explaining")
+		return GetExplanation(word, *m)
+	}
+	if word == m.awaitingTranslation {
+		m.acceptTopTranslation = true
+		return nil
+	}
+	m.awaitingTranslation, m.acceptTopTranslation = word, false
+	return GetTranslation(word, *m)
+}
 
-		return TranslationReceived{Word: word, Translation: result.TranslatedText}
+// translateFocusedWord translates the focused word without the punctuation
+// around it, with show_grammar its form is noted too
+func (m *model) translateFocusedWord() tea.Cmd {
+	word := isAlpha.FindString(m.getFocusedWord())
+	if word == "" {
+		m.UpdateStatus("Nothing to translate")
+		return EmptyCmd
 	}
+	return tea.Batch(m.grammarNote(word), m.translateWord(word))
 }
 
-func (m *model) UpdateStatus(status string) {
-	if m.recorder.IsRecording() || m.piperVoice.IsSpeaking() {
-		return
+func HighlightFocusWord(row string, focusWord int) string {
+	var st strings.Builder
+	for i, word := range rowWords(row) {
+		if i == focusWord {
+			slog.Debug("FocusWord", "word", word, "index", i)
+			st.WriteString(styles.focus.Render(word))
+		} else {
+			st.WriteString(word)
+		}
+		st.WriteRune(' ')
 	}
-	m.status = status
+	return st.String()
 }
 
 func setViewportContent(m *model, content string) {
@@ -245,69 +447,330 @@ func setViewportContent(m *model, content string) {
 	m.viewport.SetContent(content)
 }
 
-func getWrappedContent(content string, width int) string {
-	return lipgloss.NewStyle().Width(width).Render(content)
+// Update handles msg, the spinner starts turning when a busy status was set
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := m.update(msg)
+	m = next.(model)
+	if m.status.kind != statusBusy || m.spinning {
+		return m, cmd
+	}
+	m.spinning = true
+	return m, tea.Batch(cmd, m.spinner.Tick)
 }
 
-func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case spinner.TickMsg:
+		// The ticks end with the busy status
+		if m.status.kind != statusBusy {
+			m.spinning = false
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+
 	case DownloadModel:
-		m.UpdateStatus("Downloading tts model")
+		return m, m.downloadModel(msg)
+
+	case DownloadFinished:
+		return m, m.voiceDownloaded(msg)
+
+	case DownloadProgress:
+		m.setStatus(statusBusy, msg.status())
+		return m, waitForDownload(msg.updates)
+
+	case DownloadFailed:
+		return m, m.speakWithoutVoice(msg)
+
+	case LanguagePairChecked:
+		m.languagePairChecked(msg)
+
+	case GroqKeyChecked:
+		m.groqKeyChecked(msg)
+	case ConfigPolled:
+		if msg.changed {
+			return m, tea.Batch(watchConfig(msg.modTime), reloadConfig(m.config.Profile))
+		}
+		return m, watchConfig(msg.modTime)
+	case ConfigReloaded:
+		return m, m.applyConfig(msg)
+	case VoiceResolved:
+		return m, m.voiceResolved(msg)
+	case StatusChanged:
+		m.setStatus(msg.kind, msg.status)
+		if msg.spoken && m.handsFree {
+			return m, m.listenAfterGrace()
+		}
+	case SpokenWord:
+		m.highlightSpokenWord(msg)
+		return m, waitForSpeech(msg.updates)
+	case HandsFreeListen:
+		return m, m.handsFreeListen()
+	case RecordingFinished:
+		// A manual stop already started the transcription
+		if msg.byVAD && msg.id != m.transcribedRecording {
+			return m, m.transcribeRecording()
+		}
+
+	case RecordingFailed:
+		if errors.Is(msg.err, ErrAlreadyRecording) {
+			break
+		}
+		slog.Error("Recording failed", "error", msg.err)
+		m.handsFree = false
+		m.setStatus(statusError, recordingErrorStatus(msg.err))
+
+	case RecordingTick:
+		return m, m.checkRecordingTime(msg.id)
+
+	case HoldCheck:
+		return m, m.checkHold()
+
+	case VADTick:
+		if !m.recorder.IsRecording() {
+			break
+		}
+		m.setStatus(statusRecording, m.recordingProgress())
+		return m, vadTick()
+
+	case TranscriptionRetry:
+		m.setStatus(statusBusy, "Transcription failed (retrying…)")
+		// Back off exponentially, 1s, 2s, 4s...
+		backoff := time.Second << (msg.attempt - 1)
+		retry := m.transcribeAttempt(msg.sessionID, msg.turn, msg.attempt, msg.wav)
 		return m, func() tea.Msg {
-			err := piper.DownloadVoice(msg.language, msg.model)
-			if err != nil {
-				return StatusChanged{status: "Failed to download model"}
-			}
-			return ReadyCompletion{completion: msg.completion, addContent: false}
+			time.Sleep(backoff)
+			return retry()
 		}
 
-	case StatusChanged:
-		m.UpdateStatus(msg.status)
+	case RepeatPrompted:
+		return m, m.startRecording()
+
+	case CompletionFailed:
+		session := m.findSession(msg.sessionID)
+		if session == nil || m.isStale(session, msg.turn) {
+			break
+		}
+		m.setStatus(statusError, msg.status)
+		return m, m.finishTurn(session)
+
 	case ReadyCompletion:
+		var glossCmd, nextCmd tea.Cmd
+		messageID := -1
 		if msg.addContent {
-			sanitisedCompletion := strings.ReplaceAll(msg.completion, "\n\n", "\n")
-			m.content = fmt.Sprintf("%sAI: %s \n", m.content, sanitisedCompletion)
-			highlightedCompletion := HighlightFocusWord(m.content, m.focusRow, m.focusWord)
-			setViewportContent(&m, highlightedCompletion)
-			m.viewport.GotoBottom()
+			session := m.findSession(msg.sessionID)
+			if session == nil || m.isStale(session, msg.turn) {
+				break
+			}
+			session.lastCompletion = msg.completion
+			index := m.addMessage(session, Message{Role: RoleAI, Text: msg.completion, Transliteration: m.transliteration(msg.completion)})
+			messageID = session.messages[index].ID
+			if m.config.ShowGloss && m.config.Translation.IsEnabled() {
+				glossCmd = GetGloss(session.id, messageID, msg.completion, m)
+			}
+			nextCmd = m.finishTurn(session)
+			if !m.isActive(session) {
+				m.UpdateStatus(fmt.Sprintf("New reply in %s", session.name))
+				return m, tea.Batch(glossCmd, nextCmd)
+			}
 		}
 
-		m.UpdateStatus("Speaking")
+		if m.config.Muted {
+			m.setStatus(statusReady, "Ready")
+			if m.handsFree {
+				return m, tea.Batch(glossCmd, nextCmd, m.listenAfterGrace())
+			}
+			return m, tea.Batch(glossCmd, nextCmd)
+		}
+
+		if msg.fallback {
+			m.setStatus(statusSpeaking, "Speaking (fallback LLM)")
+		} else {
+			m.setStatus(statusSpeaking, "Speaking")
+		}
+
+		speak := Speak(msg.completion, m)
+		if m.config.Karaoke && messageID >= 0 {
+			speak = SpeakHighlighted(msg.completion, m.Session.id, messageID, m)
+		}
+		return m, tea.Batch(speak, glossCmd, nextCmd)
 
-		ctx, cancel := context.WithCancel(context.Background())
-		m.cancelSpeak = cancel
-		return m, Speak(ctx, msg.completion, m)
+	case GlossReceived:
+		session := m.findSession(msg.sessionID)
+		if session == nil {
+			break
+		}
+		message := session.message(msg.messageID)
+		if message == nil {
+			break
+		}
+		message.Gloss = msg.gloss
+		if m.isActive(session) {
+			m.refreshViewport()
+		}
 
 	case TranscriptionReceived:
-		m.content = fmt.Sprintf("%sYou:%s \n", m.content, msg.transcription)
-		highlighted := HighlightFocusWord(m.content, m.focusRow, m.focusWord)
-		setViewportContent(&m, highlighted)
-		m.viewport.GotoBottom()
-		return m, GetLlmCompletion(msg.transcription, m)
+		session := m.findSession(msg.sessionID)
+		if session == nil {
+			break
+		}
+		session.transcriptionArrived(msg.turn, transcribedTurn{text: msg.transcription, confidence: msg.confidence})
+		return m, m.drainTranscriptions(session)
+
+	case TranscriptionFailed:
+		m.setStatus(statusError, msg.status)
+		session := m.findSession(msg.sessionID)
+		if session == nil {
+			break
+		}
+		session.transcriptionArrived(msg.turn, transcribedTurn{failed: true})
+		return m, m.drainTranscriptions(session)
+
+	case ExplanationReceived:
+		session := m.findSession(msg.sessionID)
+		if session == nil {
+			break
+		}
+		m.addMessage(session, Message{Role: RoleAI, Text: msg.explanation})
+		m.setStatus(statusReady, "Ready")
+
+	case SentenceTranslated:
+		m.sentenceTranslated(msg)
+
+	case DictionaryLoaded:
+		m.dictionaryLoaded(msg)
 
 	case TranslationReceived:
-		m.wordsStore.Add(msg.Word, msg.Translation)
+		return m, m.translationReceived(msg)
+
+	case IPAReceived:
+		m.wordsStore.SetIPA(msg.word, msg.ipa)
+
+	case tea.MouseMsg:
+		if cmd, ok := m.click(msg); ok {
+			return m, cmd
+		}
+
+	case keySequenceExpired:
+		if msg.id == m.pendingKeysID {
+			m.pendingKeys = ""
+		}
+
+	case GrammarNoted:
+		m.grammarNoted(msg)
+
+	case ExampleReceived:
+		return m, m.exampleReceived(msg)
+
+	case exampleDue:
+		return m, m.nextExample()
+
+	case WordsTranslated:
+		return m, m.wordsTranslated(msg)
 
 	case tea.KeyMsg:
-		switch k := msg.String(); k {
-		case "enter":
-			selectedWord := m.getFocusedWord()
-			clearedWord := isAlpha.FindString(selectedWord)
-			if clearedWord == "" {
-				m.UpdateStatus("Nothing to translate")
-				return m, EmptyCmd
+		if m.typing {
+			return m.updateInput(msg)
+		}
+		if m.showHelp {
+			m.helpKey(msg.String())
+			return m, nil
+		}
+		if m.askReset && m.resetKey(msg.String()) {
+			return m, nil
+		}
+		if m.visualAnchor != nil {
+			if cmd, ok := m.visualKey(msg.String()); ok {
+				return m, cmd
 			}
-			return m, GetTranslation(clearedWord, m)
+		}
+		if m.choosing != nil {
+			if cmd, ok := m.choiceKey(msg); ok {
+				return m, cmd
+			}
+		}
+		if m.sentenceTranslation != nil && m.sentenceKey(msg.String()) {
+			return m, nil
+		}
+		if m.confirming != nil {
+			if cmd, ok := m.confirmKey(msg); ok {
+				return m, cmd
+			}
+		}
 
-		case "esc":
-			if m.cancelSpeak != nil {
-				m.cancelSpeak()
+		if m.config.RecordMode == HoldRecording && msg.String() == " " {
+			return m, m.holdKey()
+		}
+		if m.config.RecordMode == TapRecording && m.recorder.IsRecording() {
+			m.recorder.Stop()
+			return m, m.transcribeRecording()
+		}
+
+		action, cmd := m.resolveKey(msg.String())
+		if cmd != nil {
+			return m, cmd
+		}
+		switch action {
+		case ActionType:
+			return m, m.startTyping()
+		case ActionCommand:
+			cmd := m.startTyping()
+			m.input.SetValue("/")
+			m.input.CursorEnd()
+			return m, cmd
+		case ActionTranslate:
+			return m, m.translateFocusedWord()
+		case ActionVisual:
+			m.startVisual()
+		case ActionNewExample:
+			return m, m.regenerateExample()
+		case ActionReverseTranslate:
+			cmd := m.startTyping()
+			m.input.SetValue("/say ")
+			m.input.CursorEnd()
+			return m, cmd
+		case ActionTranslateSentence:
+			return m, m.translateFocusedSentence()
+
+		case ActionRepeat:
+			if m.lastCompletion == "" || m.recorder.IsRecording() {
+				m.UpdateStatus("Nothing to repeat")
+				return m, EmptyCmd
 			}
-			m.UpdateStatus("Ready")
-		case "j":
-			wrappedCompletion := getWrappedContent(m.content, m.viewport.Width)
-			rows := strings.Split(strings.TrimSpace(wrappedCompletion), "\n")
-			if len(rows) == 0 || len(wrappedCompletion) == 0 {
+			m.speech.Clear()
+			m.repeatTarget = m.lastCompletion
+			m.setStatus(statusSpeaking, "Repeat after me")
+			return m, SpeakForRepeat(m.repeatTarget, m)
+
+		case ActionStopSpeaking:
+			m.speech.Clear()
+			m.repeatTarget = ""
+			m.stopHandsFree()
+			m.setStatus(statusReady, "Ready")
+		case ActionHandsFree:
+			return m, m.toggleHandsFree()
+		case ActionMute:
+			m.toggleMute()
+		case ActionReplay:
+			return m, m.replayAnswer()
+		case ActionExportAudio:
+			return m, m.exportFocusedAudio()
+		case ActionPronounceWord:
+			return m, m.pronounceFocusedWord()
+		case ActionSpeakSentence:
+			return m, m.speakFocusedSentence()
+		case ActionVolumeUp:
+			m.setVolume(m.config.Volume + volumeStep)
+		case ActionVolumeDown:
+			m.setVolume(m.config.Volume - volumeStep)
+		case ActionFaster:
+			m.setSpeechRate(m.config.TTSBackend.SpeechRate + speechRateStep)
+		case ActionSlower:
+			m.setSpeechRate(m.config.TTSBackend.SpeechRate - speechRateStep)
+		case ActionNextLine:
+			rows := m.rows()
+			if len(rows) == 0 {
 				break
 			}
 			if m.focusRow+1 >= len(rows) {
@@ -315,60 +778,77 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			m.focusRow++
 
-			focusedRow := rows[m.focusRow]
-			m.focusWord = min(max(len(strings.Split(strings.TrimSpace(focusedRow), " "))-1, 0), m.focusWord)
+			m.focusWord = min(max(len(rowWords(rows[m.focusRow]))-1, 0), m.focusWord)
 
-			highlightedCompletion := HighlightFocusWord(wrappedCompletion, m.focusRow, m.focusWord)
-			setViewportContent(&m, highlightedCompletion)
-			log.Printf("FocusWord j: %v %v", m.focusWord, m.focusRow)
+			m.refreshViewport()
+			slog.Debug("FocusWord j", "word", m.focusWord, "row", m.focusRow)
 
 			// If we're not at scrolloff, don't scroll
 			visibleLines := m.viewport.VisibleLineCount()
 			if (visibleLines+m.viewport.YOffset)-m.focusRow > scrolloff {
 				return m, EmptyCmd
 			}
-		case "k":
+		case ActionPrevLine:
 			if m.focusRow-1 < 0 {
 				break
 			}
 			m.focusRow--
 
-			wrappedCompletion := getWrappedContent(m.content, m.viewport.Width)
-			rows := strings.Split(strings.TrimSpace(wrappedCompletion), "\n")
+			rows := m.rows()
 			if len(rows) == 0 {
 				break
 			}
 
-			focusedRow := rows[m.focusRow]
-			m.focusWord = min(max(len(strings.Split(strings.TrimSpace(focusedRow), " "))-1, 0), m.focusWord)
+			m.focusWord = min(max(len(rowWords(rows[m.focusRow]))-1, 0), m.focusWord)
 
-			highlightedCompletion := HighlightFocusWord(wrappedCompletion, m.focusRow, m.focusWord)
-			setViewportContent(&m, highlightedCompletion)
+			m.refreshViewport()
 
 			// If we're not at scrolloff, don't scroll
 			if m.focusRow-(m.viewport.YOffset-1) > scrolloff {
 				return m, EmptyCmd
 			}
-		case "w":
-			wrappedCompletion := getWrappedContent(m.content, m.viewport.Width)
-			rows := strings.Split(strings.TrimSpace(wrappedCompletion), "\n")
+		case ActionLineStart, ActionLineEnd:
+			rows := m.rows()
+			if len(rows) == 0 {
+				break
+			}
+			m.focusWord = 0
+			if action == ActionLineEnd {
+				m.focusWord = max(len(rowWords(rows[m.focusRow]))-1, 0)
+			}
+			m.refreshViewport()
+		case ActionTop:
+			m.focusRow, m.focusWord = 0, 0
+			m.refreshViewport()
+			m.viewport.GotoTop()
+			return m, EmptyCmd
+		case ActionBottom:
+			rows := m.rows()
+			if len(rows) == 0 {
+				break
+			}
+			m.focusRow, m.focusWord = len(rows)-1, 0
+			m.refreshViewport()
+			m.viewport.GotoBottom()
+			return m, EmptyCmd
+		case ActionNextWord, ActionWordEnd:
+			rows := m.rows()
 			if len(rows) == 0 {
 				break
 			}
 
-			focusedRow := rows[m.focusRow]
-			if m.focusWord+1 >= len(strings.Split(focusedRow, " ")) && m.focusRow+1 >= len(rows) {
+			words := len(rowWords(rows[m.focusRow]))
+			if m.focusWord+1 >= words && m.focusRow+1 >= len(rows) {
 				break
 			}
 
-			if m.focusWord+1 >= len(strings.Split(strings.TrimSpace(focusedRow), " ")) {
+			if m.focusWord+1 >= words {
 				m.focusRow++
 				m.focusWord = -1
 			}
 
 			m.focusWord++
-			highlightedCompletion := HighlightFocusWord(wrappedCompletion, m.focusRow, m.focusWord)
-			setViewportContent(&m, highlightedCompletion)
+			m.refreshViewport()
 
 			// If we're not at scrolloff, don't scroll
 			visibleLines := m.viewport.VisibleLineCount()
@@ -376,20 +856,16 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, EmptyCmd
 			}
 			m.viewport.ScrollDown(1)
-		case "b":
-			wrappedCompletion := getWrappedContent(m.content, m.viewport.Width)
+		case ActionPrevWord:
 			if m.focusWord-1 < 0 && m.focusRow-1 < 0 {
 				break
 			} else if m.focusWord-1 < 0 {
 				m.focusRow = max(0, m.focusRow-1)
-				rows := strings.Split(strings.TrimSpace(wrappedCompletion), "\n")
-				focusedRow := strings.Split(strings.TrimSpace(rows[m.focusRow]), " ")
-				m.focusWord = len(focusedRow)
+				m.focusWord = len(rowWords(m.rows()[m.focusRow]))
 			}
 
 			m.focusWord--
-			highlightedCompletion := HighlightFocusWord(wrappedCompletion, m.focusRow, m.focusWord)
-			setViewportContent(&m, highlightedCompletion)
+			m.refreshViewport()
 
 			// If we're not at scrolloff, don't scroll
 			if m.focusRow-(m.viewport.YOffset-1) > scrolloff {
@@ -397,53 +873,72 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			m.viewport.ScrollUp(1)
 			return m, EmptyCmd
-		case "ctrl+b":
-			if m.cancelSpeak != nil {
-				m.cancelSpeak()
-			}
-
-			if time.Since(m.recorder.Stopped) < time.Second {
-				return m, EmptyCmd
-			}
+		case ActionRecord:
+			m.speech.Clear()
 
 			if m.recorder.IsRecording() {
 				m.recorder.Stop()
-				m.UpdateStatus("Ready")
-				return m, func() tea.Msg {
-					transcription, err := transcribeWithGroq(m.recorder.Content, m.apiKey, m.config.Language)
-					log.Println(transcription)
-					if err != nil {
-						log.Printf("Error transcribing audio: %v\n", err)
-						return EmptyCmd
-					}
-					return TranscriptionReceived{transcription: transcription}
-				}
+				return m, m.transcribeRecording()
 			}
 
-			m.UpdateStatus("Recording")
-			return m, func() tea.Msg {
-				m.recorder.Start()
-				return ""
+			return m, m.startRecording()
+		case ActionPlayRecording:
+			if m.lastRecording == nil || m.recorder.IsRecording() {
+				m.UpdateStatus("No recording to play")
+				return m, EmptyCmd
+			}
+			m.speech.Clear()
+			m.setStatus(statusSpeaking, "Playing recording")
+			return m, PlayRecording(m.speech, m.lastRecording)
+		case ActionRetranscribe:
+			if m.lastRecording == nil || m.recorder.IsRecording() {
+				m.UpdateStatus("No recording to transcribe")
+				return m, EmptyCmd
+			}
+			return m, m.transcribe(m.lastRecording)
+		case ActionResponseLength:
+			m.config.ResponseStyle = m.config.ResponseStyle.Next()
+			for _, s := range m.sessions {
+				s.updatePrompt(m.config)
 			}
-		case "ctrl+c", "q":
+			m.UpdateStatus(fmt.Sprintf("Response style: %s", m.config.ResponseStyle))
+		case ActionNewTab:
+			m.newSession()
+		case ActionNextTab:
+			m.switchSession(m.activeIndex() + 1)
+		case ActionPrevTab:
+			m.switchSession(m.activeIndex() - 1)
+		case ActionCloseTab:
+			m.closeSession()
+		case ActionToggleSidebar:
+			m.toggleSidebar()
+		case ActionSidebarWider:
+			m.resizeSidebar(sidebarWidthStep)
+		case ActionSidebarNarrower:
+			m.resizeSidebar(-sidebarWidthStep)
+		case ActionFixVoice:
+			return m, m.fixVoice()
+		case ActionHelp:
+			m.showHelp = true
+		case ActionQuit:
 			return m, tea.Quit
 		}
 	case tea.WindowSizeMsg:
 		m.fullWidth = msg.Width
+		m.fullHeight = msg.Height
 		headerHeight := lipgloss.Height(m.headerView()) + 1
-		viewportWidth := msg.Width*3/4 + 1
+		viewportWidth := m.viewportWidth()
 		viewportHeight := msg.Height - headerHeight
 
 		if !m.ready {
 			viewport := viewport.New(viewportWidth, viewportHeight)
 			viewport.YPosition = headerHeight
-			viewport.SetContent(m.content)
 			m.viewport = viewport
+			m.refreshViewport()
 			m.ready = true
-		} else {
-			m.viewport.Width = viewportWidth
-			m.viewport.Height = viewportHeight
 		}
+		// resize rewraps the conversation to the new width
+		m.resize()
 	}
 
 	var cmds []tea.Cmd
@@ -454,96 +949,267 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
-var titleStyle = func() lipgloss.Style {
-	b := lipgloss.RoundedBorder()
-	b.BottomRight = "┴"
-	return lipgloss.NewStyle().BorderStyle(b).Padding(0, 1)
-}()
+func (m *model) startRecording() tea.Cmd {
+	recorder := m.recorder
+	m.recordingID++
+	m.recordingStarted = time.Now()
+	m.setStatus(statusRecording, m.recordingProgress())
+	tick := recordingTick(m.recordingID)
+	cues := m.config.AudioCues
+	id := m.recordingID
+	record := func() tea.Msg {
+		if _, err := recorder.Start(); err != nil {
+			return RecordingFailed{err: err}
+		}
+		if cues {
+			go playCue(stopCue)
+		}
+		return RecordingFinished{id: id, byVAD: recorder.StoppedByVAD()}
+	}
+	if m.config.VAD.Enabled {
+		return tea.Batch(record, vadTick(), tick)
+	}
+	return tea.Batch(record, tick)
+}
+
+// transcribeRecording sends the last recording to the transcriber as a new
+// turn of the active session
+func (m *model) transcribeRecording() tea.Cmd {
+	wav := m.recorder.Content()
+	if wav == nil {
+		// Start failed, RecordingFailed reports it
+		return nil
+	}
+	m.transcribedRecording = m.recordingID
+	if isEmptyRecording(wav, m.config.MinRecording(), m.config.SilenceThreshold()) {
+		m.UpdateStatus("Nothing recorded")
+		return nil
+	}
+	m.lastRecording = wav
+	if m.config.SaveRecordings {
+		return tea.Batch(m.transcribe(m.lastRecording), SaveRecording(m.lastRecording, m.config.RecordingsDir, m.config.KeepRecordings))
+	}
+	return m.transcribe(m.lastRecording)
+}
+
+// transcribe sends the recording to the transcriber as a new turn of the
+// active session
+func (m *model) transcribe(wav []byte) tea.Cmd {
+	if m.transcriptionDisabled {
+		m.setStatus(statusError, "Transcription disabled, the Groq key is invalid")
+		return nil
+	}
+	m.setStatus(statusBusy, "Transcribing")
+	turn := m.nextTurn(m.Session)
+	m.Session.awaitTranscription(turn)
+	return m.transcribeAttempt(m.Session.id, turn, 0, wav)
+}
+
+func (m model) transcribeAttempt(sessionID int, turn int, attempt int, wav []byte) tea.Cmd {
+	transcriber, language := m.transcriber, languageCode(m.config.Language)
+	timeout := time.Duration(m.config.STTBackend.TimeoutSeconds) * time.Second
+	maxRetries := m.config.STTBackend.MaxRetries
+	failedStatus := "Transcription failed, press " + m.keymap.Key(ActionRetranscribe) + " to retry"
+	var prompt string
+	if session := m.findSession(sessionID); session != nil && m.config.STTBackend.PromptHint {
+		prompt = whisperPrompt(session.messages)
+	}
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		transcription, err := transcriber.Transcribe(ctx, wav, language, prompt)
+		slog.Debug("Transcription", "text", transcription.Text)
+		if err != nil {
+			slog.Error("Failed to transcribe audio", "attempt", attempt+1, "error", err)
+			if isRetryable(err) && attempt < maxRetries {
+				return TranscriptionRetry{sessionID: sessionID, turn: turn, attempt: attempt + 1, wav: wav}
+			}
+			return TranscriptionFailed{sessionID: sessionID, turn: turn, status: failedStatus}
+		}
+		return TranscriptionReceived{sessionID: sessionID, turn: turn, transcription: transcription.Text, confidence: transcription.Confidence}
+	}
+}
+
+// addWarning adds a warning to the ones shown above the header
+func (m *model) addWarning(warning string) {
+	if warning == "" {
+		return
+	}
+	if m.warning != "" {
+		warning = m.warning + " │ " + warning
+	}
+	m.warning = warning
+}
 
 func (m model) getFocusedWord() string {
-	rows := strings.Split(strings.TrimSpace(m.content), "\n")
+	rows := m.rows()
+	if m.focusRow >= len(rows) {
+		return ""
+	}
 
-	row := strings.TrimSpace(rows[m.focusRow])
-	return strings.Split(row, " ")[m.focusWord]
+	words := rowWords(rows[m.focusRow])
+	if m.focusWord < 0 || m.focusWord >= len(words) {
+		return ""
+	}
+	return words[m.focusWord]
 }
 
 func (m model) headerView() string {
-	title := titleStyle.Render("LazyLang")
+	title := styles.title.Render("LazyLang")
 
 	blockLength := max(0, m.fullWidth-lipgloss.Width(title))
 
-	line := strings.Repeat("─", blockLength)
+	line := strings.Repeat(styles.line, blockLength)
 
-	statusLength := max(0, blockLength-lipgloss.Width(m.status))
-	statusLine := strings.Repeat(" ", statusLength) + m.status
+	tabs := fmt.Sprintf(" %s │ %s", m.tabsView(), m.config.ResponseStyle)
+	if m.config.Profile != "" {
+		tabs = fmt.Sprintf(" %s │ %s │ %s", m.config.Profile, m.tabsView(), m.config.ResponseStyle)
+	}
+	if m.config.Muted {
+		tabs += " │ 🔇"
+	}
+	status := m.statusView()
+	statusLength := max(0, blockLength-lipgloss.Width(tabs)-lipgloss.Width(status))
+	statusLine := tabs + strings.Repeat(" ", statusLength) + status
 
 	s := lipgloss.JoinVertical(lipgloss.Center, statusLine, line)
 
-	return lipgloss.JoinHorizontal(lipgloss.Center, title, s)
+	header := lipgloss.JoinHorizontal(lipgloss.Center, title, s)
+	if m.warning != "" {
+		return lipgloss.JoinVertical(lipgloss.Left, styles.warning.Width(m.fullWidth).Render(m.warning), header)
+	}
+	return header
 }
 
+// viewportWidth leaves sidebar_width percent of the width to the sidebar, the
+// whole width without it
+func (m model) viewportWidth() int {
+	if !m.sidebarShown() {
+		return m.fullWidth
+	}
+	return m.fullWidth - m.sidebarColumns()
+}
+
+// sidebarView lists the translated words, it is empty when translation is
+// disabled or the sidebar hidden
 func (m model) sidebarView() string {
-	b := lipgloss.NewStyle().
+	if !m.sidebarShown() {
+		return ""
+	}
+	b := styles.sidebar.
 		Height(m.viewport.Height).
-		Width(m.fullWidth*1/4 - 1).
-		Border(lipgloss.NormalBorder()).
-		BorderLeft(true).
-		BorderTop(false).
-		BorderRight(false).
-		BorderBottom(false)
+		Width(m.sidebarWidth())
 
-	return b.Render(m.wordsStore.List())
+	entries := m.sidebarEntries()
+	for i, word := range m.wordsStore.Words() {
+		if word == m.sidebarFocus {
+			entries[i] = styles.focus.Render(entries[i])
+		}
+	}
+	var s strings.Builder
+	for _, entry := range entries {
+		s.WriteString(entry + "\n")
+	}
+	return b.Render(s.String())
+}
+
+// sidebarWidth is the width of the sidebar text, without its border
+func (m model) sidebarWidth() int {
+	return m.sidebarColumns() - 1
+}
+
+// sidebarEntries are the lines of every saved word
+func (m model) sidebarEntries() []string {
+	if m.config.ShowTransliteration && hasTransliteration(m.config.Language) {
+		return m.wordsStore.Entries(transliteratedLabel(m.config.Language))
+	}
+	return m.wordsStore.Entries(func(word string) string { return word })
 }
 
 func (m model) View() string {
 	content := lipgloss.JoinHorizontal(lipgloss.Center, m.viewport.View(), m.sidebarView())
+	if m.showHelp {
+		content = m.helpView(lipgloss.Width(content), lipgloss.Height(content))
+	}
+	if footer := m.inputView(); footer != "" {
+		return fmt.Sprintf("%s\n%s\n%s", m.headerView(), content, footer)
+	}
 	return fmt.Sprintf("%s\n%s\n", m.headerView(), content)
 }
 
+// appContext is cancelled when the program exits, so requests still running
+// in commands are given up
+var appContext, cancelAppContext = context.WithCancel(context.Background())
+
 func main() {
-	apiKey := os.Getenv("GROQ_API_KEY")
-	if apiKey == "" {
-		fmt.Println("Error: GROQ_API_KEY environment variable not set")
-		os.Exit(1)
+	configPath, args := takeFlag(os.Args[1:], "config")
+	SetConfigPath(configPath)
+	profile, args := takeFlag(args, "profile")
+	debugLogging, args = takeSwitch(args, "debug")
+	if runSubcommand(args, profile) {
+		return
 	}
 
-	config, err := GetConfig(apiKey)
+	config, err := GetConfig(profile)
+
+	var configErr ConfigError
+	if errors.As(err, &configErr) {
+		log.Fatalf("Error: %v\nRun `lazylang config check` to see the config in effect", configErr)
+	} else if errors.Is(err, errUnknownProfile) || errors.Is(err, errNoProfile) {
+		log.Fatalf("Error: %v", err)
+	}
 
-    var syntaxErr *json.SyntaxError
-	if errors.As(err, &syntaxErr) {
-		log.Fatalf("Error parsing config: %v", syntaxErr)
-	} else if errors.Is(err, invalidApiKey) {
-		log.Fatalf("Error: Invalid API key")
+	logFile, logErr := setupLogging(config.Log)
+	if logErr != nil {
+		noteStorageProblem(fmt.Sprintf("Nothing is logged: %v", logErr))
+	} else {
+		defer logFile.Close()
 	}
+	// Noting now shows the problems of the voices directory above the header
+	voicesDir(config.TTSBackend.VoicesDir)
 
 	if err != nil {
 		slog.Error("Failed to get config", "error", err)
 	}
 	slog.Info("Config", "config", config)
 
+	if err := configureHTTP(config); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
 
-	p := tea.NewProgram(
-		initialModel(apiKey, config),
-		tea.WithAltScreen(),       // use the full size of the terminal in its "alternate screen buffer"
-		tea.WithMouseCellMotion(), // turn on mouse support so we can track the mouse wheel
-	)
-	f, err := tea.LogToFile("tea.log", "")
+	apiKey, err := groqAPIKey(config)
 	if err != nil {
-		fmt.Println("could not run program:", err)
+		fmt.Println("Error:", err)
 		os.Exit(1)
 	}
-	defer f.Close()
 
+	p := tea.NewProgram(
+		initialModel(apiKey, config),
+		tea.WithAltScreen(),       // use the full size of the terminal in its "alternate screen buffer"
+		tea.WithMouseCellMotion(), // turn on mouse support for the wheel and clicks on words
+	)
 	m, err := p.Run()
+	cancelAppContext()
 	my := m.(model)
-	if my.cancelSpeak != nil {
-		my.cancelSpeak()
+	my.speech.Clear()
+	if closer, ok := my.speaker.(io.Closer); ok {
+		closer.Close()
 	}
 
 	if err != nil {
 		fmt.Println("could not run program:", err)
 		os.Exit(1)
 	}
+
+	if !my.config.DisableRecap {
+		recap := BuildRecap(my, time.Now())
+		fmt.Print(recap)
+		if err := appendRecap(recap); err != nil {
+			slog.Error("Failed to save session recap", "error", err)
+		}
+	}
 }
 
 // waitForCtrlB waits for the user to press Ctrl+B
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// newTestModel is a model in a 80x24 window, nothing is recorded, spoken or
+// sent as Init isn't run
+func newTestModel(t *testing.T, messages ...Message) model {
+	t.Helper()
+	config := NewConfig()
+	config.Theme.Preset = "dark"
+	return newConfiguredTestModel(t, config, messages...)
+}
+
+// newConfiguredTestModel is newTestModel with the config
+func newConfiguredTestModel(t *testing.T, config Config, messages ...Message) model {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	m := initialModel("test", config)
+	next, _ := m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	m = next.(model)
+	for _, message := range messages {
+		m.addMessage(m.Session, message)
+	}
+	return m
+}
+
+// update sends msg to the model like the program does
+func update(t *testing.T, m model, msg tea.Msg) model {
+	t.Helper()
+	next, _ := m.Update(msg)
+	return next.(model)
+}
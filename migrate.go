@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// ConfigVersion is the version of the config file written by this build, a
+// file without version is version 0
+const ConfigVersion = 1
+
+// migrations[i] upgrades a config of version i to version i+1
+var migrations = []func(config map[string]any){
+	fromBaseline,
+}
+
+// fromBaseline upgrades the configs of the first release, without a
+// version. They hold language, target_translation_language,
+// libre_translate_url, the type and voice of tts_backend and the type and
+// model of stt_backend, which all mean the same in version 1; the fields
+// added since are left out and get their defaults
+func fromBaseline(config map[string]any) {}
+
+// FutureVersionError is a config written by a newer LazyLang, its fields
+// can't be trusted to mean the same
+type FutureVersionError struct {
+	Version int
+}
+
+func (e FutureVersionError) Error() string {
+	return fmt.Sprintf("the config is version %d but this LazyLang only knows version %d, update LazyLang", e.Version, ConfigVersion)
+}
+
+// migrateConfig upgrades the config file to ConfigVersion, it reports whether
+// anything changed. Invalid JSON is returned as it is for parseConfig to
+// report
+func migrateConfig(data []byte) ([]byte, bool, error) {
+	var config map[string]any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// Numbers are kept as written
+	dec.UseNumber()
+	if err := dec.Decode(&config); err != nil {
+		return data, false, nil
+	}
+
+	version, err := configVersion(config)
+	if err != nil {
+		return data, false, err
+	}
+	if version > ConfigVersion {
+		return data, false, FutureVersionError{Version: version}
+	}
+	if version == ConfigVersion {
+		return data, false, nil
+	}
+
+	migrate(config, version)
+	if profiles, ok := config["profiles"].(map[string]any); ok {
+		for _, profile := range profiles {
+			if profile, ok := profile.(map[string]any); ok {
+				migrate(profile, version)
+			}
+		}
+	}
+	config["version"] = ConfigVersion
+
+	migrated, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return data, false, err
+	}
+	return migrated, true, nil
+}
+
+func migrate(config map[string]any, version int) {
+	for _, migration := range migrations[version:] {
+		migration(config)
+	}
+	delete(config, "version")
+}
+
+func configVersion(config map[string]any) (int, error) {
+	value, ok := config["version"]
+	if !ok {
+		return 0, nil
+	}
+	number, ok := value.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("version should be a number, not %v", value)
+	}
+	version, err := number.Int64()
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("version should be a whole number, not %v", number)
+	}
+	return int(version), nil
+}
+
+// saveMigrated replaces the config file with the migrated one, the original
+// is kept next to it as .bak
+func saveMigrated(path string, original []byte, migrated []byte) error {
+	if err := os.WriteFile(path+".bak", original, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(path, migrated, 0644)
+}
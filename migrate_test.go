@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useConfigFile copies the config file into a temporary directory and has
+// GetConfig read it from there
+func useConfigFile(t *testing.T, data []byte) string {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(ConfigEnv, path)
+	return path
+}
+
+func TestMigrateBaselineConfig(t *testing.T) {
+	original, err := os.ReadFile(filepath.Join("testdata", "config_v0.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := useConfigFile(t, original)
+
+	config, err := GetConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Language != "es" || config.TargetTranslationLanguage != "en" || config.LibreTranslateURL != "http://translate.local:5000" {
+		t.Errorf("languages are %q to %q with %q", config.Language, config.TargetTranslationLanguage, config.LibreTranslateURL)
+	}
+	if config.TTSBackend.Type != "piper" || config.TTSBackend.Voice != "es_ES-davefx-medium.onnx" {
+		t.Errorf("tts_backend is %q with %q", config.TTSBackend.Type, config.TTSBackend.Voice)
+	}
+	if config.STTBackend.Type != "hosted" || config.STTBackend.Model != "whisper-large-v3-turbo" {
+		t.Errorf("stt_backend is %q with %q", config.STTBackend.Type, config.STTBackend.Model)
+	}
+
+	backup, err := os.ReadFile(path + ".bak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(backup, original) {
+		t.Errorf("the backup is\n%s\nnot the original\n%s", backup, original)
+	}
+	migrated, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(migrated, &file); err != nil {
+		t.Fatal(err)
+	}
+	if file.Version != ConfigVersion {
+		t.Errorf("the migrated file is version %d", file.Version)
+	}
+
+	// The migrated file is read as it is
+	if _, changed, err := migrateConfig(migrated); changed || err != nil {
+		t.Errorf("the migrated file is migrated again, %v", err)
+	}
+}
+
+func TestMigrateFutureConfig(t *testing.T) {
+	original := []byte(`{"version": 2, "language": "de", "tts_backend": {"voice": "de_DE-thorsten-medium"}}`)
+
+	_, changed, err := migrateConfig(original)
+	var future FutureVersionError
+	if !errors.As(err, &future) || future.Version != 2 {
+		t.Fatalf("error is %v", err)
+	}
+	if changed {
+		t.Error("the future config is changed")
+	}
+
+	path := useConfigFile(t, original)
+	if _, err := GetConfig(""); err == nil {
+		t.Fatal("the future config is loaded")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, original) {
+		t.Errorf("the future config is rewritten to\n%s", data)
+	}
+	if _, err := os.Stat(path + ".bak"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("a backup is written, %v", err)
+	}
+}
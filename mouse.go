@@ -0,0 +1,108 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// doubleClickInterval is how soon a second click on the same word translates
+// it, terminals report single clicks only
+const doubleClickInterval = 400 * time.Millisecond
+
+type wordClick struct {
+	pos wordPos
+	at  time.Time
+}
+
+// click focuses the word or the saved word under a left click, clicks on
+// anything else and while a prompt or an overlay is open are ignored. The
+// wheel is left to the viewport
+func (m *model) click(msg tea.MouseMsg) (tea.Cmd, bool) {
+	if msg.Action != tea.MouseActionPress || msg.Button != tea.MouseButtonLeft {
+		return nil, false
+	}
+	if m.typing || m.showHelp || m.askReset || m.confirming != nil || m.choosing != nil || m.sentenceTranslation != nil || m.visualAnchor != nil {
+		return nil, true
+	}
+	y := msg.Y - lipgloss.Height(m.headerView())
+	if y < 0 || y >= m.viewport.Height {
+		return nil, true
+	}
+	if msg.X >= m.viewport.Width {
+		m.clickSidebar(y)
+		return nil, true
+	}
+
+	pos, ok := m.wordAt(msg.X, y+m.viewport.YOffset)
+	if !ok {
+		return nil, true
+	}
+	m.focusRow, m.focusWord = pos.row, pos.word
+	m.refreshViewport()
+	if m.lastClick.pos != pos || time.Since(m.lastClick.at) > doubleClickInterval {
+		m.lastClick = wordClick{pos: pos, at: time.Now()}
+		return nil, true
+	}
+	// A third click starts over
+	m.lastClick = wordClick{}
+	return m.translateFocusedWord(), true
+}
+
+// wordAt maps a column and a line of the conversation to a word of the
+// navigable rows. Rows longer than the viewport take more than one line, as
+// setViewportContent wraps them
+func (m model) wordAt(x int, line int) (wordPos, bool) {
+	width := m.viewport.Width
+	if width <= 0 {
+		return wordPos{}, false
+	}
+	start, nav := 0, 0
+	for _, row := range renderRows(m.messages, width) {
+		indent := 0
+		if row.border {
+			indent = ansi.StringWidth(aiBorder)
+		}
+		lines := max(1, (indent+ansi.StringWidth(row.text)+width-1)/width)
+		if line < start+lines {
+			if !row.navigable {
+				return wordPos{}, false
+			}
+			column := (line-start)*width + x - indent
+			end := 0
+			for i, word := range rowWords(row.text) {
+				begin := end
+				end = begin + ansi.StringWidth(word)
+				if column >= begin && column < end {
+					return wordPos{nav, i}, true
+				}
+				// The space after the word
+				end++
+			}
+			return wordPos{}, false
+		}
+		start += lines
+		if row.navigable {
+			nav++
+		}
+	}
+	return wordPos{}, false
+}
+
+// clickSidebar focuses the saved word on the line of the sidebar
+func (m *model) clickSidebar(y int) {
+	if !m.sidebarShown() {
+		return
+	}
+	style := lipgloss.NewStyle().Width(m.sidebarWidth())
+	start := 0
+	for i, entry := range m.sidebarEntries() {
+		start += lipgloss.Height(style.Render(entry))
+		if y < start {
+			m.sidebarFocus = m.wordsStore.Words()[i]
+			return
+		}
+	}
+}
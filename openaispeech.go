@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"lazylang/piper"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const openAIAPIBaseURL = "https://api.openai.com/v1"
+
+// openAIPCMSampleRate is the rate of the pcm response format
+const openAIPCMSampleRate = 24000
+
+// SpeechAPIError is returned when a text to speech API answered with an error
+// status
+type SpeechAPIError struct {
+	Provider   string
+	StatusCode int
+	Body       string
+}
+
+func (e SpeechAPIError) Error() string {
+	return fmt.Sprintf("%s error (status %d): %s", e.Provider, e.StatusCode, e.Body)
+}
+
+// OpenAISpeaker uses the /audio/speech endpoint of OpenAI or a compatible
+// server such as openedai-speech
+type OpenAISpeaker struct {
+	baseURL string
+	// apiKey may be empty for local servers
+	apiKey string
+	model  string
+	voice  string
+	// format is pcm or wav
+	format string
+
+	speaking bool
+	mu       sync.RWMutex
+}
+
+type openAISpeechRequest struct {
+	Model          string `json:"model"`
+	Input          string `json:"input"`
+	Voice          string `json:"voice"`
+	ResponseFormat string `json:"response_format"`
+}
+
+func (o *OpenAISpeaker) IsSpeaking() bool {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.speaking
+}
+
+// Speak plays the audio while it is downloaded, cancelling ctx aborts both
+func (o *OpenAISpeaker) Speak(ctx context.Context, text string) error {
+	o.mu.Lock()
+	o.speaking = true
+	o.mu.Unlock()
+	defer func() {
+		o.mu.Lock()
+		o.speaking = false
+		o.mu.Unlock()
+	}()
+
+	body, err := json.Marshal(openAISpeechRequest{Model: o.model, Input: text, Voice: o.voice, ResponseFormat: o.format})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(o.baseURL, "/")+"/audio/speech", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(resp.Body)
+		return SpeechAPIError{Provider: "OpenAI", StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(message))}
+	}
+
+	if o.format != "wav" {
+		return piper.Play(ctx, resp.Body, openAIPCMSampleRate, 1)
+	}
+	audio := bufio.NewReader(resp.Body)
+	rate, channels, err := readWAVHeader(audio)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return err
+	}
+	return piper.Play(ctx, audio, rate, channels)
+}
+
+// readWAVHeader consumes the header of a 16 bit PCM WAV stream, streaming
+// servers leave the sizes unset so only the format is read
+func readWAVHeader(r io.Reader) (sampleRate int, channels int, err error) {
+	header := make([]byte, wavHeaderSize)
+	_, err = io.ReadFull(r, header)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read WAV header: %w", err)
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return 0, 0, fmt.Errorf("response is not a WAV file")
+	}
+	if bits := binary.LittleEndian.Uint16(header[34:36]); bits != 16 {
+		return 0, 0, fmt.Errorf("unsupported WAV format with %d bits per sample", bits)
+	}
+	channels = int(binary.LittleEndian.Uint16(header[22:24]))
+	sampleRate = int(binary.LittleEndian.Uint32(header[24:28]))
+	return sampleRate, channels, nil
+}
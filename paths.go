@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"lazylang/piper"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// errNoStorage means neither the usual nor a temporary directory could be
+// written, LazyLang runs without saving anything then
+var errNoStorage = errors.New("no writable directory, nothing is saved")
+
+var (
+	storageMu       sync.Mutex
+	storageProblems []string
+)
+
+// noteStorageProblem remembers why files are not stored where they should,
+// it is shown above the header
+func noteStorageProblem(problem string) {
+	storageMu.Lock()
+	defer storageMu.Unlock()
+	storageProblems = append(storageProblems, problem)
+}
+
+func storageWarning() string {
+	storageMu.Lock()
+	defer storageMu.Unlock()
+	return strings.Join(storageProblems, " │ ")
+}
+
+var (
+	resolveConfigDir = sync.OnceValues(func() (string, error) {
+		return usableDir("config", "XDG_CONFIG_HOME", ".config")
+	})
+	resolveStateDir = sync.OnceValues(func() (string, error) {
+		return usableDir("log", "XDG_STATE_HOME", ".local", "state")
+	})
+)
+
+// ConfigDir is $XDG_CONFIG_HOME/lazylang, ~/.config/lazylang by default,
+// recordings and the session log are kept there whichever config is used
+func ConfigDir() (string, error) {
+	return resolveConfigDir()
+}
+
+// StateDir is $XDG_STATE_HOME/lazylang, ~/.local/state/lazylang by default
+func StateDir() (string, error) {
+	return resolveStateDir()
+}
+
+// preferredDir is the lazylang directory in the XDG directory named by env,
+// or in home joined with elems
+func preferredDir(env string, elems ...string) (string, error) {
+	// The spec asks to ignore relative paths
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "lazylang"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the home directory: %w", err)
+	}
+	return filepath.Join(append(append([]string{home}, elems...), "lazylang")...), nil
+}
+
+// usableDir is the preferred directory, or one in the temporary directory
+// when that can't be written
+func usableDir(name string, env string, elems ...string) (string, error) {
+	dir, err := preferredDir(env, elems...)
+	if err == nil {
+		if err = checkWritable(dir); err == nil {
+			return dir, nil
+		}
+	}
+
+	temp := tempDir(name)
+	if tempErr := checkWritable(temp); tempErr != nil {
+		noteStorageProblem(fmt.Sprintf("Nothing is saved, no %s directory is writable", name))
+		return "", fmt.Errorf("%w: %v", errNoStorage, err)
+	}
+	noteStorageProblem(fmt.Sprintf("The %s directory can't be used, using %s until the next reboot", name, temp))
+	return temp, nil
+}
+
+// tempDir is a directory of the user in the temporary directory
+func tempDir(name string) string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("lazylang-%d", os.Getuid()), name)
+}
+
+// checkWritable creates dir and a file in it
+func checkWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
+
+var (
+	preparedVoicesDirsMu sync.Mutex
+	// preparedVoicesDirs are the voices directories by the configured one,
+	// each is checked once so its problem is noted once
+	preparedVoicesDirs = map[string]piper.Dir{}
+)
+
+// voicesDir is the voices directory of voices_dir, see prepareVoicesDir
+func voicesDir(configured string) (piper.Dir, error) {
+	preparedVoicesDirsMu.Lock()
+	defer preparedVoicesDirsMu.Unlock()
+	if dir, ok := preparedVoicesDirs[configured]; ok {
+		return dir, nil
+	}
+	dir, err := prepareVoicesDir(configured)
+	if err != nil {
+		return dir, err
+	}
+	preparedVoicesDirs[configured] = dir
+	return dir, nil
+}
+
+// prepareVoicesDir downloads voices into a temporary directory when the
+// voices directory can't be written, and not at all when that fails too
+func prepareVoicesDir(configured string) (piper.Dir, error) {
+	path, err := piper.ResolveVoicesDir(configured)
+	if err == nil {
+		if err = checkWritable(path); err == nil {
+			return piper.Dir{Path: path}, nil
+		}
+		// The voices already there can still be used
+		if _, statErr := os.Stat(path); statErr == nil {
+			noteStorageProblem(fmt.Sprintf("Voices can't be downloaded, %s is not writable", path))
+			return piper.Dir{Path: path, ReadOnly: true}, nil
+		}
+	}
+
+	temp := tempDir("piper-voices")
+	if tempErr := checkWritable(temp); tempErr != nil {
+		noteStorageProblem("Voices can't be downloaded, no voices directory is writable")
+		if path == "" {
+			return piper.Dir{}, err
+		}
+		return piper.Dir{Path: path, ReadOnly: true}, nil
+	}
+	noteStorageProblem(fmt.Sprintf("The voices directory is not writable, downloading voices into %s", temp))
+	return piper.Dir{Path: temp}, nil
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"lazylang/piper"
+	"path/filepath"
+	"testing"
+)
+
+func TestVoicesDirConfigured(t *testing.T) {
+	t.Setenv(piper.VoicesDirEnv, t.TempDir())
+	configured := filepath.Join(t.TempDir(), "voices")
+
+	dir, err := voicesDir(configured)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != (piper.Dir{Path: configured}) {
+		t.Errorf("voicesDir(%q) = %+v, want the writable configured directory", configured, dir)
+	}
+}
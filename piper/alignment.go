@@ -0,0 +1,133 @@
+package piper
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// alignScript synthesizes the lines of stdin with piper's Python module and
+// writes the phoneme alignments of every sentence to fd 3, before its raw
+// audio on stdout. A voice without alignments writes null
+const alignScript = `
+import json, os, sys
+from piper import PiperVoice, SynthesisConfig
+
+voice = PiperVoice.load(sys.argv[1])
+speaker = int(sys.argv[3])
+config = SynthesisConfig(length_scale=float(sys.argv[2]), speaker_id=speaker if speaker >= 0 else None)
+timings = os.fdopen(3, "w")
+for line in sys.stdin:
+    for chunk in voice.synthesize(line.strip(), syn_config=config, include_alignments=True):
+        alignments = chunk.phoneme_alignments
+        if alignments is None:
+            timings.write("null\n")
+        else:
+            timings.write(json.dumps([{"phoneme": a.phoneme, "samples": a.num_samples} for a in alignments]) + "\n")
+        timings.flush()
+        sys.stdout.buffer.write(chunk.audio_int16_bytes)
+        sys.stdout.buffer.flush()
+`
+
+// alignProbe exits with 0 when the installed piper can align phonemes
+const alignProbe = `
+import inspect, sys
+from piper import PiperVoice
+sys.exit(0 if "include_alignments" in inspect.signature(PiperVoice.synthesize).parameters else 1)
+`
+
+// phonemeAlignment is how many samples of the audio a phoneme takes
+type phonemeAlignment struct {
+	Phoneme string `json:"phoneme"`
+	Samples int64  `json:"samples"`
+}
+
+// wordTimings follows the played audio with the word being spoken, the
+// words start at the first phoneme after a space in the alignments piper
+// writes for every sentence
+type wordTimings struct {
+	mu sync.Mutex
+	// starts are the samples the words start at
+	starts []int64
+	// end is the length of the sentences read so far
+	end int64
+	// unaligned is set once a sentence came without alignments, the words
+	// are then not followed at all
+	unaligned bool
+
+	current int
+	onWord  func(index int)
+}
+
+func newWordTimings(onWord func(int)) *wordTimings {
+	return &wordTimings{current: -1, onWord: onWord}
+}
+
+// read parses the alignments of the sentences from r until it ends
+func (t *wordTimings) read(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var sentence []phonemeAlignment
+		if err := json.Unmarshal(scanner.Bytes(), &sentence); err != nil {
+			slog.Warn("Failed to read the phoneme alignments of piper", "error", err)
+			sentence = nil
+		}
+		t.add(sentence)
+	}
+}
+
+// add appends the words of a sentence, nil marks a sentence piper didn't
+// align
+func (t *wordTimings) add(sentence []phonemeAlignment) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if sentence == nil {
+		t.unaligned = true
+		return
+	}
+	inWord := false
+	for _, phoneme := range sentence {
+		switch phoneme.Phoneme {
+		// Spaces part words, ^ and $ begin and end the sentence and _ pads
+		// the phonemes
+		case " ", "^", "$", "_":
+			inWord = false
+		default:
+			if !inWord {
+				t.starts = append(t.starts, t.end)
+			}
+			inWord = true
+		}
+		t.end += phoneme.Samples
+	}
+}
+
+// played reports the word at the playback position, in bytes of 16 bit mono
+// audio, when it changed
+func (t *wordTimings) played(played int64) {
+	t.mu.Lock()
+	if t.unaligned {
+		t.mu.Unlock()
+		return
+	}
+	sample := played / 2
+	index := -1
+	for i, start := range t.starts {
+		if start > sample {
+			break
+		}
+		index = i
+	}
+	changed := index > t.current
+	if changed {
+		t.current = index
+	}
+	t.mu.Unlock()
+
+	if changed {
+		t.onWord(index)
+	}
+}
@@ -0,0 +1,41 @@
+package piper
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWordTimings(t *testing.T) {
+	// Hallo Welt. Wie geht's?
+	alignments := `[{"phoneme": "^", "samples": 100}, {"phoneme": "h", "samples": 200}, {"phoneme": "a", "samples": 300}, {"phoneme": " ", "samples": 50}, {"phoneme": "v", "samples": 250}, {"phoneme": ".", "samples": 100}, {"phoneme": "$", "samples": 100}]
+[{"phoneme": "^", "samples": 100}, {"phoneme": "v", "samples": 200}, {"phoneme": " ", "samples": 50}, {"phoneme": "g", "samples": 200}, {"phoneme": "$", "samples": 100}]
+`
+	var words []int
+	timings := newWordTimings(func(index int) { words = append(words, index) })
+	timings.read(strings.NewReader(alignments))
+
+	if want := []int64{100, 650, 1200, 1450}; !slices.Equal(timings.starts, want) {
+		t.Fatalf("words start at %v, want %v", timings.starts, want)
+	}
+	// Positions are in bytes of 16 bit samples
+	for _, sample := range []int64{0, 50, 100, 600, 700, 1300, 1200, 1500, 2000} {
+		timings.played(2 * sample)
+	}
+	if want := []int{0, 1, 2, 3}; !slices.Equal(words, want) {
+		t.Errorf("spoken words are %v, want %v", words, want)
+	}
+}
+
+func TestWordTimingsWithoutAlignments(t *testing.T) {
+	var words []int
+	timings := newWordTimings(func(index int) { words = append(words, index) })
+	timings.read(strings.NewReader(`[{"phoneme": "h", "samples": 200}]` + "\nnull\n"))
+
+	for _, sample := range []int64{0, 100, 1000} {
+		timings.played(2 * sample)
+	}
+	if len(words) != 0 {
+		t.Errorf("words %v are highlighted without alignments", words)
+	}
+}
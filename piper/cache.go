@@ -0,0 +1,59 @@
+package piper
+
+import (
+	"bytes"
+	"context"
+	"errors"
+)
+
+// maxCachedPCM caps the replay cache at about four minutes of 22050Hz audio
+const maxCachedPCM = 10 * 1024 * 1024
+
+var ErrNothingToReplay = errors.New("nothing to replay")
+
+// cappedBuffer keeps what is written to it until it grows beyond limit, it
+// then drops everything and ignores further writes
+type cappedBuffer struct {
+	buf      bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (c *cappedBuffer) Write(p []byte) (int, error) {
+	if c.overflow {
+		return len(p), nil
+	}
+	if c.buf.Len()+len(p) > c.limit {
+		c.overflow = true
+		c.buf = bytes.Buffer{}
+		return len(p), nil
+	}
+	return c.buf.Write(p)
+}
+
+// Bytes returns the cached audio, nil when it overflowed
+func (c *cappedBuffer) Bytes() []byte {
+	if c.overflow {
+		return nil
+	}
+	return c.buf.Bytes()
+}
+
+// Replay plays the last utterance again without running piper-tts
+func (p *PiperVoice) Replay(ctx context.Context) error {
+	p.mu.Lock()
+	pcm, sampleRate := p.lastPCM, p.lastRate
+	if pcm == nil {
+		p.mu.Unlock()
+		return ErrNothingToReplay
+	}
+	p.speaking = true
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		p.speaking = false
+		p.mu.Unlock()
+	}()
+	return Play(ctx, bytes.NewReader(pcm), sampleRate, 1)
+}
@@ -0,0 +1,58 @@
+package piper
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/gen2brain/malgo"
+)
+
+// FindDevice returns the id of the first device whose name contains name,
+// ignoring case
+func FindDevice(ctx malgo.Context, kind malgo.DeviceType, name string) (*malgo.DeviceID, error) {
+	devices, err := ctx.Devices(kind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	for _, device := range devices {
+		if strings.Contains(strings.ToLower(device.Name()), strings.ToLower(name)) {
+			id := device.ID
+			return &id, nil
+		}
+	}
+	return nil, fmt.Errorf("no device matching %q", name)
+}
+
+var (
+	outputDevice   string
+	outputDeviceMu sync.RWMutex
+)
+
+// SetOutputDevice makes Play use the playback device whose name contains
+// name, empty uses the default device
+func SetOutputDevice(name string) {
+	outputDeviceMu.Lock()
+	defer outputDeviceMu.Unlock()
+	outputDevice = name
+}
+
+// selectOutputDevice points the config at the configured output device, a
+// missing device keeps the default
+func selectOutputDevice(ctx malgo.Context, deviceConfig *malgo.DeviceConfig) {
+	outputDeviceMu.RLock()
+	name := outputDevice
+	outputDeviceMu.RUnlock()
+	if name == "" {
+		return
+	}
+
+	id, err := FindDevice(ctx, malgo.Playback, name)
+	if err != nil {
+		log.Printf("Output device %q not available, using the default: %v", name, err)
+		return
+	}
+	deviceConfig.Playback.DeviceID = id.Pointer()
+}
@@ -0,0 +1,213 @@
+package piper
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const modelContent = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+var modelFile = func() VoiceFile {
+	sum := md5.Sum([]byte(modelContent))
+	return VoiceFile{SizeBytes: int64(len(modelContent)), MD5Digest: hex.EncodeToString(sum[:])}
+}()
+
+// rangeServer serves modelContent through handle and records the Range
+// header of every request
+type rangeServer struct {
+	mu     sync.Mutex
+	ranges []string
+}
+
+func (s *rangeServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.ranges...)
+}
+
+func newRangeServer(t *testing.T, handle func(w http.ResponseWriter, r *http.Request)) *rangeServer {
+	t.Helper()
+	s := &rangeServer{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.ranges = append(s.ranges, r.Header.Get("Range"))
+		s.mu.Unlock()
+		handle(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	target, _ := url.Parse(server.URL)
+	previous := httpClient
+	SetHTTPClient(&http.Client{Transport: redirect{target}})
+	t.Cleanup(func() { SetHTTPClient(previous) })
+	return s
+}
+
+func serveModel(w http.ResponseWriter, r *http.Request) {
+	http.ServeContent(w, r, "model.onnx", time.Time{}, strings.NewReader(modelContent))
+}
+
+// ignoreRange always answers with the whole file
+func ignoreRange(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(modelContent))
+}
+
+// withPart is a voices directory holding the part of an earlier download
+func withPart(t *testing.T, part string) Dir {
+	t.Helper()
+	dir := Dir{Path: t.TempDir()}
+	if part != "" {
+		if err := os.WriteFile(dir.file("model.onnx.part"), []byte(part), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func checkDownloaded(t *testing.T, dir Dir) {
+	t.Helper()
+	data, err := os.ReadFile(dir.file("model.onnx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != modelContent {
+		t.Errorf("downloaded %q, want %q", data, modelContent)
+	}
+	if _, err := os.Stat(dir.file("model.onnx.part")); !os.IsNotExist(err) {
+		t.Error("the part file is left")
+	}
+}
+
+func TestDownloadResumes(t *testing.T) {
+	tests := []struct {
+		name   string
+		handle func(w http.ResponseWriter, r *http.Request)
+		part   string
+		want   string
+	}{
+		{"fresh download", serveModel, "", ""},
+		{"range honored", serveModel, modelContent[:10], "bytes=10-"},
+		{"range ignored restarts", ignoreRange, modelContent[:10], "bytes=10-"},
+		{"part longer than the file", serveModel, modelContent + "extra", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newRangeServer(t, tt.handle)
+			dir := withPart(t, tt.part)
+
+			var reported int64
+			progress := func(file string, done, total int64) { reported = done }
+			if err := downloadFile(dir, "de/de_DE/test/low/model.onnx", modelFile, progress); err != nil {
+				t.Fatal(err)
+			}
+			checkDownloaded(t, dir)
+			if got := server.requests(); len(got) != 1 || got[0] != tt.want {
+				t.Errorf("Range headers %q, want %q", got, tt.want)
+			}
+			if reported != modelFile.SizeBytes {
+				t.Errorf("progress ended at %d", reported)
+			}
+		})
+	}
+}
+
+func TestDownloadAlreadyComplete(t *testing.T) {
+	server := newRangeServer(t, serveModel)
+	dir := withPart(t, modelContent)
+	if err := downloadFile(dir, "de/de_DE/test/low/model.onnx", modelFile, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkDownloaded(t, dir)
+	if got := server.requests(); len(got) != 0 {
+		t.Errorf("a complete part was downloaded again: %q", got)
+	}
+}
+
+func TestInterruptedDownloadResumes(t *testing.T) {
+	// The first response breaks off after 10 bytes
+	var interrupted atomic.Bool
+	server := newRangeServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if !interrupted.CompareAndSwap(false, true) {
+			serveModel(w, r)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(modelContent)))
+		w.Write([]byte(modelContent[:10]))
+		w.(http.Flusher).Flush()
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conn.Close()
+	})
+	dir := withPart(t, "")
+
+	if err := downloadFile(dir, "de/de_DE/test/low/model.onnx", modelFile, nil); err == nil {
+		t.Fatal("the interrupted download succeeded")
+	}
+	part, err := os.ReadFile(dir.file("model.onnx.part"))
+	if err != nil || string(part) != modelContent[:10] {
+		t.Fatalf("part file is %q, %v", part, err)
+	}
+	if _, err := os.Stat(dir.file("model.onnx")); !os.IsNotExist(err) {
+		t.Error("the incomplete file was finalized")
+	}
+
+	if err := downloadFile(dir, "de/de_DE/test/low/model.onnx", modelFile, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkDownloaded(t, dir)
+	if got := server.requests(); len(got) != 2 || got[1] != "bytes=10-" {
+		t.Errorf("Range headers %q", got)
+	}
+}
+
+func TestCorruptedPartStartsOver(t *testing.T) {
+	server := newRangeServer(t, serveModel)
+	dir := withPart(t, "XXXXXXXXXX")
+
+	err := downloadFile(dir, "de/de_DE/test/low/model.onnx", modelFile, nil)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("corrupted download gave %v", err)
+	}
+	if _, err := os.Stat(dir.file("model.onnx.part")); !os.IsNotExist(err) {
+		t.Error("the corrupted part is kept")
+	}
+	if _, err := os.Stat(dir.file("model.onnx")); !os.IsNotExist(err) {
+		t.Error("the corrupted file was finalized")
+	}
+
+	if err := downloadFile(dir, "de/de_DE/test/low/model.onnx", modelFile, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkDownloaded(t, dir)
+	if got := server.requests(); len(got) != 2 || got[1] != "" {
+		t.Errorf("Range headers %q", got)
+	}
+}
+
+func TestUnsatisfiableRangeStartsOver(t *testing.T) {
+	newRangeServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+	})
+	dir := withPart(t, modelContent[:10])
+
+	if err := downloadFile(dir, "de/de_DE/test/low/model.onnx", modelFile, nil); err == nil {
+		t.Fatal("download succeeded")
+	}
+	if _, err := os.Stat(filepath.Join(dir.Path, "model.onnx.part")); !os.IsNotExist(err) {
+		t.Error("the part the server refused is kept")
+	}
+}
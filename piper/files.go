@@ -0,0 +1,123 @@
+package piper
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// VoicesDirEnv overrides the directory voices are stored in, for example to
+// keep them on another disk
+const VoicesDirEnv = "PIPER_VOICES_DIR"
+
+// ErrDownloadsDisabled is returned when no voices directory can be written
+var ErrDownloadsDisabled = errors.New("voice downloads are disabled, no voices directory is writable")
+
+// Dir is the directory models, their configs and voices.json are stored in
+type Dir struct {
+	Path string
+	// ReadOnly refuses to download or cache anything, the voices already
+	// stored are still used
+	ReadOnly bool
+}
+
+var legacyNotice sync.Once
+
+// ResolveVoicesDir is the configured voices directory, PIPER_VOICES_DIR or
+// the default, a leading ~ of the configured one is the home directory. It
+// fails when the home directory is unknown
+func ResolveVoicesDir(configured string) (string, error) {
+	if rest, ok := strings.CutPrefix(configured, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to find the home directory: %w", err)
+		}
+		return filepath.Join(home, rest), nil
+	}
+	if configured != "" {
+		return configured, nil
+	}
+	if dir := os.Getenv(VoicesDirEnv); dir != "" {
+		return dir, nil
+	}
+	return defaultVoicesDir()
+}
+
+// defaultVoicesDir is piper-voices in the XDG data directory, the
+// ~/.piper-voices of older versions is kept using until it is moved
+func defaultVoicesDir() (string, error) {
+	home, homeErr := os.UserHomeDir()
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		if homeErr != nil {
+			return "", fmt.Errorf("failed to find the home directory: %w", homeErr)
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	dir := filepath.Join(dataHome, "piper-voices")
+	if homeErr != nil {
+		return dir, nil
+	}
+
+	legacy := filepath.Join(home, ".piper-voices")
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if info, err := os.Stat(legacy); err == nil && info.IsDir() {
+			legacyNotice.Do(func() {
+				slog.Warn("Using the voices of an older version, move them or set voices_dir", "dir", legacy, "moveTo", dir)
+			})
+			return legacy, nil
+		}
+	}
+	return dir, nil
+}
+
+// file is the path of a file in the voices directory
+func (d Dir) file(name string) string {
+	return filepath.Join(d.Path, name)
+}
+
+// create makes sure files may be written to the voices directory
+func (d Dir) create() error {
+	if d.ReadOnly {
+		return ErrDownloadsDisabled
+	}
+	if err := os.MkdirAll(d.Path, 0755); err != nil {
+		return fmt.Errorf("failed to create voices directory: %w", err)
+	}
+	return nil
+}
+
+// writeFile stores data in the voices directory, creating it if needed. The
+// data is written next to the file and renamed over it, so an interrupted
+// write never leaves a truncated file behind
+func (d Dir) writeFile(name string, data []byte) error {
+	if err := d.create(); err != nil {
+		return err
+	}
+
+	filePath := d.file(name)
+	tmp, err := os.CreateTemp(d.Path, name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write file %s: %w", filePath, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filePath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write file %s: %w", filePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package piper
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveVoicesDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	data := t.TempDir()
+
+	tests := []struct {
+		name       string
+		configured string
+		env        string
+		want       string
+	}{
+		{"configured", "/srv/voices", "/env/voices", "/srv/voices"},
+		{"configured in home", "~/voices", "", filepath.Join(home, "voices")},
+		{"environment", "", "/env/voices", "/env/voices"},
+		{"default", "", "", filepath.Join(data, "piper-voices")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(VoicesDirEnv, tt.env)
+			t.Setenv("XDG_DATA_HOME", data)
+			got, err := ResolveVoicesDir(tt.configured)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveVoicesDir(%q) = %q, want %q", tt.configured, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveVoicesDirWithoutHome(t *testing.T) {
+	t.Setenv("HOME", "")
+	if _, err := ResolveVoicesDir("~/voices"); err == nil {
+		t.Error("expected an error when the home directory is unknown")
+	}
+}
+
+// redirect sends every request to the test server instead of Hugging Face
+type redirect struct {
+	target *url.URL
+}
+
+func (r redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = r.target.Scheme
+	req.URL.Host = r.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// serveVoices serves a voices list with the voice key made of files, and
+// the files under their names in the voices repository
+func serveVoices(t *testing.T, key string, files map[string]string) *httptest.Server {
+	t.Helper()
+	voice := VoiceInfo{Key: key, Language: VoiceLanguage{Code: "de_DE", Family: "de"}, Quality: "low", Files: map[string]VoiceFile{}}
+	for name, content := range files {
+		sum := md5.Sum([]byte(content))
+		voice.Files[name] = VoiceFile{SizeBytes: int64(len(content)), MD5Digest: hex.EncodeToString(sum[:])}
+	}
+	list, err := json.Marshal(map[string]VoiceInfo{key: voice})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/voices.json") {
+			w.Write(list)
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, "/rhasspy/piper-voices/resolve/v1.0.0/")
+		content, ok := files[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, name, time.Time{}, strings.NewReader(content))
+	}))
+	t.Cleanup(server.Close)
+
+	target, _ := url.Parse(server.URL)
+	previous := httpClient
+	SetHTTPClient(&http.Client{Transport: redirect{target}})
+	t.Cleanup(func() { SetHTTPClient(previous) })
+	return server
+}
+
+func TestDownloadVoiceIntoConfiguredDir(t *testing.T) {
+	// The default directory must stay untouched
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	t.Setenv(VoicesDirEnv, "")
+
+	key := "de_DE-test-low"
+	serveVoices(t, key, map[string]string{
+		"de/de_DE/test/low/" + key + ".onnx":      "model",
+		"de/de_DE/test/low/" + key + ".onnx.json": `{"audio": {"sample_rate": 16000}, "language": {"code": "de_DE"}}`,
+		"de/de_DE/test/low/MODEL_CARD":            "card",
+	})
+
+	path, err := ResolveVoicesDir(filepath.Join(t.TempDir(), "voices"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := Dir{Path: path}
+	if err := DownloadVoice(dir, "de", key, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"voices.json", key + ".onnx", key + ".onnx.json"} {
+		if _, err := os.Stat(filepath.Join(path, name)); err != nil {
+			t.Errorf("%s is not in the configured directory: %v", name, err)
+		}
+	}
+	defaultDir, _ := ResolveVoicesDir("")
+	if _, err := os.Stat(defaultDir); !os.IsNotExist(err) {
+		t.Errorf("the default directory %s was created", defaultDir)
+	}
+
+	voice := NewPiperVoice(dir, WithModel(key+".onnx"))
+	if !voice.ModelInstalled() {
+		t.Error("the voice isn't installed in the configured directory")
+	}
+	if rate := voice.outputSampleRate(voice.Model); rate != 16000 {
+		t.Errorf("sample rate = %d, want the 16000 of the downloaded config", rate)
+	}
+	installed, err := ListInstalledVoices(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(installed) != 1 || installed[0].Key != key || installed[0].Language != "de_DE" {
+		t.Errorf("installed voices = %+v", installed)
+	}
+	if err := VerifyVoice(dir, key); err != nil {
+		t.Errorf("VerifyVoice: %v", err)
+	}
+}
+
+func TestDownloadVoiceReadOnly(t *testing.T) {
+	key := "de_DE-test-low"
+	serveVoices(t, key, map[string]string{"de/de_DE/test/low/" + key + ".onnx": "model"})
+
+	dir := Dir{Path: filepath.Join(t.TempDir(), "voices"), ReadOnly: true}
+	err := DownloadVoice(dir, "de", key, nil)
+	if !errors.Is(err, ErrDownloadsDisabled) {
+		t.Fatalf("DownloadVoice = %v, want ErrDownloadsDisabled", err)
+	}
+	if _, err := os.Stat(dir.Path); !os.IsNotExist(err) {
+		t.Errorf("the read only directory was created")
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := Dir{Path: filepath.Join(t.TempDir(), "voices")}
+	if err := dir.writeFile("voices.json", []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+	if err := dir.writeFile("voices.json", []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(dir.file("voices.json"))
+	if err != nil || string(data) != "new" {
+		t.Errorf("voices.json is %q, %v", data, err)
+	}
+	info, err := os.Stat(dir.file("voices.json"))
+	if err != nil || info.Mode().Perm() != 0o644 {
+		t.Errorf("voices.json mode %v, %v", info.Mode(), err)
+	}
+	entries, err := os.ReadDir(dir.Path)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("the directory holds %v, %v", entries, err)
+	}
+}
+
+func TestWriteFileFailureKeepsFile(t *testing.T) {
+	dir := Dir{Path: t.TempDir()}
+	if err := dir.writeFile("voices.json", []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+	// A directory in the way of the rename makes the write fail
+	if err := os.Mkdir(dir.file("in-the-way"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir.file("in-the-way/file"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := dir.writeFile("in-the-way", []byte("new")); err == nil {
+		t.Fatal("writing over a directory succeeded")
+	}
+
+	entries, err := os.ReadDir(dir.Path)
+	if err != nil || len(entries) != 2 {
+		t.Errorf("the failed write left %v, %v", entries, err)
+	}
+	if data, _ := os.ReadFile(dir.file("voices.json")); string(data) != "old" {
+		t.Errorf("voices.json is %q", data)
+	}
+}
+
+func TestWriteFileReadOnly(t *testing.T) {
+	dir := Dir{Path: filepath.Join(t.TempDir(), "voices"), ReadOnly: true}
+	if err := dir.writeFile("voices.json", []byte("{}")); !errors.Is(err, ErrDownloadsDisabled) {
+		t.Errorf("writeFile = %v", err)
+	}
+	if _, err := os.Stat(dir.Path); !os.IsNotExist(err) {
+		t.Error("the read only directory was created")
+	}
+}
@@ -0,0 +1,138 @@
+package piper
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// InstalledVoice is a voice downloaded to the voices directory
+type InstalledVoice struct {
+	Key      string
+	Language string
+	// Size is the size of the model and its config on disk in bytes
+	Size int64
+}
+
+// ListInstalledVoices returns the voices downloaded to dir ordered by key
+func ListInstalledVoices(dir Dir) ([]InstalledVoice, error) {
+	models, err := filepath.Glob(dir.file("*.onnx"))
+	if err != nil {
+		return nil, err
+	}
+
+	var voices []InstalledVoice
+	for _, model := range models {
+		voice := InstalledVoice{Key: strings.TrimSuffix(filepath.Base(model), ".onnx")}
+		for _, file := range []string{model, model + ".json"} {
+			if info, err := os.Stat(file); err == nil {
+				voice.Size += info.Size()
+			}
+		}
+		if data, err := os.ReadFile(model + ".json"); err == nil {
+			var config struct {
+				Language VoiceLanguage `json:"language"`
+			}
+			if json.Unmarshal(data, &config) == nil {
+				voice.Language = config.Language.Code
+			}
+		}
+		voices = append(voices, voice)
+	}
+	sort.Slice(voices, func(i, j int) bool {
+		return voices[i].Key < voices[j].Key
+	})
+	return voices, nil
+}
+
+// ModelInstalled reports whether the model of the voice and its config were
+// downloaded
+func (p *PiperVoice) ModelInstalled() bool {
+	for _, file := range []string{p.Model, p.Model + ".json"} {
+		if _, err := os.Stat(p.dir.file(file)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// VerifyVoice checks the files of an installed voice against the sizes and
+// checksums of the voices list
+func VerifyVoice(dir Dir, key string) error {
+	key = strings.TrimSuffix(key, ".onnx")
+	voices, err := FetchVoices(dir)
+	if err != nil {
+		return err
+	}
+	voice, ok := voices[key]
+	if !ok {
+		return fmt.Errorf("voice %s is not in the voices list", key)
+	}
+	for filename, file := range voice.Files {
+		name := filepath.Base(filename)
+		// Only the model and its config are downloaded
+		if !strings.HasSuffix(name, ".onnx") && !strings.HasSuffix(name, ".onnx.json") {
+			continue
+		}
+		f, err := os.Open(dir.file(name))
+		if err != nil {
+			return fmt.Errorf("%s is missing", name)
+		}
+		hash := md5.New()
+		_, err = io.Copy(hash, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if sum := hex.EncodeToString(hash.Sum(nil)); file.MD5Digest != "" && sum != file.MD5Digest {
+			return fmt.Errorf("%s is corrupted, its checksum is %s instead of %s", name, sum, file.MD5Digest)
+		}
+	}
+	return nil
+}
+
+// Version returns the installed piper-tts version
+func Version() (string, error) {
+	out, err := exec.Command(piperPython(), "-c", "import importlib.metadata as m; print(m.version('piper-tts'))").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// DeleteVoice removes the model of a voice and its config from dir
+func DeleteVoice(dir Dir, key string) error {
+	key = strings.TrimSuffix(key, ".onnx")
+	model := dir.file(key + ".onnx")
+	if _, err := os.Stat(model); err != nil {
+		return fmt.Errorf("voice %s is not installed", key)
+	}
+	for _, file := range []string{model, model + ".json"} {
+		if err := os.Remove(file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// FormatSize renders a byte count for humans
+func FormatSize(bytes int64) string {
+	switch {
+	case bytes >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(bytes)/(1<<30))
+	case bytes >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/(1<<20))
+	case bytes >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(bytes)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", bytes)
+	}
+}
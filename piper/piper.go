@@ -1,11 +1,14 @@
 package piper
 
 import (
-	"bufio"
 	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"log"
 	"log/slog"
@@ -14,17 +17,14 @@ import (
 	"os/exec"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
-	"sync/atomic"
+	"time"
 
-	"github.com/gen2brain/malgo"
 	"golang.org/x/text/unicode/norm"
 )
 
-var home, _ = os.UserHomeDir()
-var voicesDir = filepath.Join(home, ".piper-voices")
-
 const voicesURL = "https://huggingface.co/rhasspy/piper-voices/resolve/main/voices.json"
 const baseDownloadURL = "https://huggingface.co/rhasspy/piper-voices/resolve/v1.0.0"
 
@@ -53,8 +53,21 @@ type VoiceFile struct {
 	MD5Digest string `json:"md5_digest"`
 }
 
-// cachedVoices holds the downloaded voices.json data
-var cachedVoices map[string]VoiceInfo
+// httpClient downloads the voices list and the voices
+var httpClient = http.DefaultClient
+
+// SetHTTPClient makes downloads use client, for example one going through a
+// proxy
+func SetHTTPClient(client *http.Client) {
+	httpClient = client
+}
+
+var (
+	// cachedVoices holds the downloaded voices.json data of every voices
+	// directory
+	cachedVoices   = map[string]map[string]VoiceInfo{}
+	cachedVoicesMu sync.Mutex
+)
 
 func MarshalVoices(body []byte) (map[string]VoiceInfo, error) {
 	var voices map[string]VoiceInfo
@@ -64,24 +77,62 @@ func MarshalVoices(body []byte) (map[string]VoiceInfo, error) {
 	return voices, nil
 }
 
-// FetchVoices downloads and caches the voices.json file
-func FetchVoices() (map[string]VoiceInfo, error) {
-	voicesFile := filepath.Join(voicesDir, "voices.json")
-	if cachedVoices != nil {
-		return cachedVoices, nil
-	} else if _, err := os.Stat(voicesFile); err == nil {
-		buff, err := os.ReadFile(filepath.Join(voicesDir, "voices.json"))
+// voicesCacheTTL is how long the downloaded voices.json is used before it is
+// fetched again to pick up newly published voices
+const voicesCacheTTL = 7 * 24 * time.Hour
+
+// FetchVoices returns the voices.json data, downloading it when the copy on
+// disk is missing, older than voicesCacheTTL or corrupt
+func FetchVoices(dir Dir) (map[string]VoiceInfo, error) {
+	return fetchVoices(dir, false)
+}
 
+// RefreshVoices downloads voices.json even when the copy on disk is recent
+func RefreshVoices(dir Dir) (map[string]VoiceInfo, error) {
+	return fetchVoices(dir, true)
+}
+
+func fetchVoices(dir Dir, forceRefresh bool) (map[string]VoiceInfo, error) {
+	cachedVoicesMu.Lock()
+	defer cachedVoicesMu.Unlock()
+	if voices, ok := cachedVoices[dir.Path]; ok && !forceRefresh {
+		return voices, nil
+	}
+
+	// A stale copy is still better than nothing when offline
+	var stale map[string]VoiceInfo
+	voicesFile := dir.file("voices.json")
+	if info, err := os.Stat(voicesFile); err == nil && !forceRefresh {
+		buff, err := os.ReadFile(voicesFile)
 		if err == nil {
 			voices, err := MarshalVoices(buff)
-			if err == nil {
-				cachedVoices = voices
+			if err != nil {
+				log.Printf("Cached voices.json is corrupt, downloading it again: %v", err)
+			} else if time.Since(info.ModTime()) < voicesCacheTTL {
+				cachedVoices[dir.Path] = voices
 				return voices, nil
+			} else {
+				stale = voices
 			}
 		}
 	}
 
-	resp, err := http.Get(voicesURL)
+	voices, err := downloadVoices(dir)
+	if err != nil && stale != nil {
+		log.Printf("Using the outdated voices.json: %v", err)
+		cachedVoices[dir.Path] = stale
+		return stale, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cachedVoices[dir.Path] = voices
+	return voices, nil
+}
+
+// downloadVoices fetches voices.json and writes it to the voices directory
+func downloadVoices(dir Dir) (map[string]VoiceInfo, error) {
+	resp, err := httpClient.Get(voicesURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch voices.json: %w", err)
 	}
@@ -101,28 +152,16 @@ func FetchVoices() (map[string]VoiceInfo, error) {
 		return nil, err
 	}
 
-	cachedVoices = voices
-	return voices, nil
-}
-
-func saveToFile(data []byte, filename string) error {
-	err := os.MkdirAll(voicesDir, 0755)
+	err = dir.writeFile("voices.json", body)
 	if err != nil {
-		return fmt.Errorf("failed to create voices directory: %w", err)
+		log.Printf("Failed to cache voices.json: %v", err)
 	}
-
-	filePath := filepath.Join(voicesDir, filename)
-	err = os.WriteFile(filePath, data, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to write file %s: %w", filePath, err)
-	}
-
-	return nil
+	return voices, nil
 }
 
 // ListLanguages prints all available languages for Piper TTS
-func ListLanguages() error {
-	voices, err := FetchVoices()
+func ListLanguages(dir Dir) error {
+	voices, err := FetchVoices(dir)
 	if err != nil {
 		return err
 	}
@@ -154,8 +193,8 @@ func ListLanguages() error {
 }
 
 // ListVoices prints all available voices for a specific language
-func ListVoices(language string) error {
-	voices, err := FetchVoices()
+func ListVoices(dir Dir, language string) error {
+	voices, err := FetchVoices(dir)
 	if err != nil {
 		return err
 	}
@@ -188,20 +227,93 @@ func ListVoices(language string) error {
 			speakers = fmt.Sprintf(" [%d speakers]", voice.NumSpkrs)
 		}
 		fmt.Printf("  %-40s %-10s %s%s\n", voice.Key, voice.Quality, voice.Language.Code, speakers)
+		if len(voice.SpeakerID) > 1 {
+			fmt.Printf("      speakers: %s\n", speakerList(voice.SpeakerID, 10))
+		}
 	}
 
 	return nil
 }
 
-// DownloadVoice downloads a voice model and its config file
-func DownloadVoice(language string, voice string) error {
-	voices, err := FetchVoices()
+// speakerList names up to limit speakers ordered by id
+func speakerList(speakers map[string]int, limit int) string {
+	names := make([]string, 0, len(speakers))
+	for name := range speakers {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return speakers[names[i]] < speakers[names[j]]
+	})
+	if len(names) > limit {
+		return fmt.Sprintf("%s … (%d more)", strings.Join(names[:limit], ", "), len(names)-limit)
+	}
+	return strings.Join(names, ", ")
+}
+
+// voiceConfig is the part of the <model>.onnx.json file describing the
+// audio and the speakers
+type voiceConfig struct {
+	Audio struct {
+		SampleRate int `json:"sample_rate"`
+	} `json:"audio"`
+	NumSpeakers int            `json:"num_speakers"`
+	SpeakerID   map[string]int `json:"speaker_id_map"`
+}
+
+func loadVoiceConfig(dir Dir, model string) (voiceConfig, error) {
+	var config voiceConfig
+	data, err := os.ReadFile(dir.file(model + ".json"))
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("failed to parse %s.json: %w", model, err)
+	}
+	return config, nil
+}
+
+// ResolveSpeaker maps a speaker name or numeric id to the id of the model,
+// it reads the downloaded model config or falls back to voices.json
+func ResolveSpeaker(dir Dir, model string, speaker string) (int, error) {
+	config, err := loadVoiceConfig(dir, model)
+	if err != nil {
+		voices, err := FetchVoices(dir)
+		if err != nil {
+			return 0, err
+		}
+		voice, ok := voices[strings.TrimSuffix(model, ".onnx")]
+		if !ok {
+			return 0, fmt.Errorf("voice %s not found", model)
+		}
+		config = voiceConfig{NumSpeakers: voice.NumSpkrs, SpeakerID: voice.SpeakerID}
+	}
+
+	if config.NumSpeakers <= 1 {
+		return 0, fmt.Errorf("voice %s has a single speaker", model)
+	}
+	if id, ok := config.SpeakerID[speaker]; ok {
+		return id, nil
+	}
+	if id, err := strconv.Atoi(speaker); err == nil && id >= 0 && id < config.NumSpeakers {
+		return id, nil
+	}
+	return 0, fmt.Errorf("speaker %q not found in %s, available: %s", speaker, model, speakerList(config.SpeakerID, 20))
+}
+
+// ProgressFunc reports the bytes written of a file, total is -1 when the
+// server didn't send a length
+type ProgressFunc func(file string, done, total int64)
+
+// DownloadVoice downloads a voice model and its config file into dir,
+// progress may be nil
+func DownloadVoice(dir Dir, language string, voice string, progress ProgressFunc) error {
+	voices, err := FetchVoices(dir)
 	if err != nil {
 		return err
 	}
 
 	// Build the voice key to look up
-	voiceKey := strings.TrimRight(voice, ".onnx")
+	voiceKey := strings.TrimSuffix(voice, ".onnx")
 
 	voiceInfo, exists := voices[voiceKey]
 	if !exists {
@@ -221,47 +333,197 @@ func DownloadVoice(language string, voice string) error {
 	}
 
 	// Create voices directory
-	if err := os.MkdirAll(voicesDir, 0755); err != nil {
-		return fmt.Errorf("failed to create voices directory: %w", err)
+	if err := dir.create(); err != nil {
+		return err
 	}
 
 	// Download each file associated with the voice
-	for filename := range voiceInfo.Files {
-		// Build download URL based on voice key structure
-		// Voice keys are like "en_US-lessac-medium", files are like "en_US-lessac-medium.onnx"
-		downloadURL := fmt.Sprintf("%s/%s", baseDownloadURL, filename)
-		log.Println("Downloading", downloadURL)
-
-		resp, err := http.Get(downloadURL)
-		if err != nil {
-			return fmt.Errorf("failed to download %s: %w", filename, err)
+	for filename, file := range voiceInfo.Files {
+		if err := downloadFile(dir, filename, file, progress); err != nil {
+			return err
 		}
-		defer resp.Body.Close()
+	}
+
+	return nil
+}
+
+type progressWriter struct {
+	file     string
+	done     int64
+	total    int64
+	progress ProgressFunc
+}
 
-		if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("failed to download %s: status %d", filename, resp.StatusCode)
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.done += int64(len(p))
+	w.progress(w.file, w.done, w.total)
+	return len(p), nil
+}
+
+// downloadFile streams a file of the voices repository into dir. It is
+// written to a .part file first, which is kept when the connection drops so
+// the next attempt resumes it with a range request. The file is only renamed
+// once it has the expected size and checksum
+func downloadFile(dir Dir, filename string, file VoiceFile, progress ProgressFunc) error {
+	// Voice keys are like "en_US-lessac-medium", files are like
+	// "en/en_US/lessac/medium/en_US-lessac-medium.onnx"
+	downloadURL := fmt.Sprintf("%s/%s", baseDownloadURL, filename)
+	localFilename := filepath.Base(filename)
+	partPath := dir.file(localFilename + ".part")
+
+	// The checksum covers the bytes downloaded before
+	hash := md5.New()
+	var offset int64
+	if part, err := os.Open(partPath); err == nil {
+		offset, err = io.Copy(hash, part)
+		part.Close()
+		if err != nil || (file.SizeBytes > 0 && offset > file.SizeBytes) {
+			offset = 0
+			hash.Reset()
 		}
+	}
 
-		data, err := io.ReadAll(resp.Body)
+	if file.SizeBytes <= 0 || offset < file.SizeBytes {
+		var err error
+		offset, err = fetchPart(downloadURL, partPath, offset, hash, file.SizeBytes, progress)
 		if err != nil {
-			return fmt.Errorf("failed to read %s: %w", filename, err)
+			return fmt.Errorf("failed to download %s: %w", filename, err)
 		}
+	}
 
-		// Extract just the filename from the path
-		localFilename := filepath.Base(filename)
-		if err := saveToFile(data, localFilename); err != nil {
-			return err
-		}
+	if file.SizeBytes > 0 && offset != file.SizeBytes {
+		return fmt.Errorf("failed to download %s: got %d of %d bytes", filename, offset, file.SizeBytes)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); file.MD5Digest != "" && sum != file.MD5Digest {
+		// Resuming a corrupted file would never succeed
+		os.Remove(partPath)
+		return fmt.Errorf("checksum mismatch for %s: got %s, want %s", localFilename, sum, file.MD5Digest)
 	}
 
+	if err := os.Rename(partPath, dir.file(localFilename)); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", localFilename, err)
+	}
 	return nil
 }
 
+// fetchPart appends the bytes from offset on to the part file and returns its
+// new size, a server ignoring the range restarts the file from the beginning
+func fetchPart(url string, partPath string, offset int64, sum hash.Hash, size int64, progress ProgressFunc) (int64, error) {
+	log.Println("Downloading", url, "from", offset)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return offset, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return offset, err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		offset = 0
+		sum.Reset()
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	default:
+		// 416 when the part is somehow longer than the file, start over next time
+		if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+			os.Remove(partPath)
+		}
+		return offset, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	part, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return offset, err
+	}
+
+	total := size
+	if total <= 0 && resp.ContentLength > 0 {
+		total = offset + resp.ContentLength
+	}
+	w := io.MultiWriter(part, sum)
+	if progress != nil {
+		name := strings.TrimSuffix(filepath.Base(partPath), ".part")
+		w = io.MultiWriter(part, sum, &progressWriter{file: name, done: offset, total: total, progress: progress})
+	}
+	n, err := io.Copy(w, resp.Body)
+	if closeErr := part.Close(); err == nil {
+		err = closeErr
+	}
+	return offset + n, err
+}
+
 type PiperVoice struct {
 	Language string
 	Model    string
+	// dir holds the models
+	dir Dir
+	// lengthScale stretches the phonemes, above 1 speaks slower
+	lengthScale float64
+	// speaker is the speaker id of multi-speaker models, -1 uses the default
+	speaker int
+	// lastPCM is the audio of the last completed Speak call at lastRate,
+	// for Replay
+	lastPCM  []byte
+	lastRate int
+	// sampleRates are read from the model configs on their first Speak
+	sampleRates map[string]int
+	// server keeps piper running between utterances, nil starts piper-tts
+	// for every one
+	server   *server
 	speaking bool
 	mu       sync.RWMutex
+	// alignOnce probes once whether piper can report when the words are
+	// spoken
+	alignOnce sync.Once
+	aligns    bool
+}
+
+// defaultSampleRate is the rate of most Piper voices, used when the model
+// config can't be read
+const defaultSampleRate = 22050
+
+// outputSampleRate returns the sample rate of the raw output of piper-tts
+// for the model, it is cached after the first call
+func (p *PiperVoice) outputSampleRate(model string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if rate, ok := p.sampleRates[model]; ok {
+		return rate
+	}
+
+	rate := defaultSampleRate
+	config, err := loadVoiceConfig(p.dir, model)
+	if err != nil || config.Audio.SampleRate <= 0 {
+		slog.Warn("Sample rate of the voice unknown, using the default", "model", model, "error", err, "sampleRate", defaultSampleRate)
+	} else {
+		rate = config.Audio.SampleRate
+	}
+	if p.sampleRates == nil {
+		p.sampleRates = make(map[string]int)
+	}
+	p.sampleRates[model] = rate
+	return rate
+}
+
+const (
+	minLengthScale = 0.5
+	maxLengthScale = 2.0
+)
+
+func clampLengthScale(lengthScale float64) float64 {
+	clamped := max(minLengthScale, min(lengthScale, maxLengthScale))
+	if clamped != lengthScale {
+		slog.Warn("Length scale out of range, clamping", "lengthScale", lengthScale, "clamped", clamped)
+	}
+	return clamped
 }
 
 type PiperOption func(*PiperVoice)
@@ -278,15 +540,54 @@ func WithModel(model string) PiperOption {
 	}
 }
 
-func NewPiperVoice(options ...PiperOption) *PiperVoice {
+// WithLengthScale sets the --length_scale of piper-tts, 1 is the speed the
+// voice was trained with
+func WithLengthScale(lengthScale float64) PiperOption {
+	return func(pv *PiperVoice) {
+		pv.lengthScale = clampLengthScale(lengthScale)
+	}
+}
+
+// WithServer keeps a piper HTTP server running so short phrases don't wait
+// for the model to load, piper-tts is run for every utterance when the
+// installed version has no server
+func WithServer() PiperOption {
+	return func(pv *PiperVoice) {
+		pv.server = &server{}
+	}
+}
+
+// Close stops the piper HTTP server
+func (p *PiperVoice) Close() error {
+	if p.server != nil {
+		p.server.close()
+	}
+	return nil
+}
+
+// WithSpeaker selects a speaker of a multi-speaker model, see ResolveSpeaker
+func WithSpeaker(id int) PiperOption {
+	return func(pv *PiperVoice) {
+		pv.speaker = id
+	}
+}
+
+// NewPiperVoice speaks with the models in dir
+func NewPiperVoice(dir Dir, options ...PiperOption) *PiperVoice {
 	pv := PiperVoice{
-		Language: "de",
-		Model:    "de_DE-karlsson-low.onnx",
+		Language:    "de",
+		Model:       "de_DE-karlsson-low.onnx",
+		dir:         dir,
+		lengthScale: 1,
+		speaker:     -1,
 	}
 
 	for _, option := range options {
 		option(&pv)
 	}
+	if pv.server != nil {
+		pv.server.modelFile = pv.dir.file(pv.Model)
+	}
 	return &pv
 }
 
@@ -299,6 +600,40 @@ func (e ErrorModelNotFound) Error() string {
 	return fmt.Sprintf("Model %s not found for language %s", e.Model, e.Language)
 }
 
+// ErrPiperNotInstalled is returned when piper-tts is not on the PATH
+var ErrPiperNotInstalled = errors.New("piper-tts not installed, install it with `pip install piper-tts`")
+
+// PiperExecError is returned when piper-tts exited with an error, Stderr
+// holds everything it printed
+type PiperExecError struct {
+	ExitCode int
+	Stderr   string
+}
+
+func (e PiperExecError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("piper-tts exited with status %d", e.ExitCode)
+	}
+	return fmt.Sprintf("piper-tts exited with status %d: %s", e.ExitCode, e.Stderr)
+}
+
+// FirstLine is the first non empty line piper printed, usually the reason it
+// failed
+func (e PiperExecError) FirstLine() string {
+	for _, line := range strings.Split(e.Stderr, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// Installed reports whether piper-tts can be run
+func Installed() bool {
+	_, err := exec.LookPath("piper-tts")
+	return err == nil
+}
+
 type StoppedSpeaking struct {
 }
 
@@ -306,16 +641,116 @@ func (e StoppedSpeaking) Error() string {
 	return "Stopped speaking"
 }
 
+// SetLengthScale changes the speed of the following Speak calls, it returns
+// the value after clamping
+func (p *PiperVoice) SetLengthScale(lengthScale float64) float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lengthScale = clampLengthScale(lengthScale)
+	p.lastPCM = nil
+	return p.lengthScale
+}
+
 func (p *PiperVoice) IsSpeaking() bool {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 	return p.speaking
 }
 
+// command builds the piper-tts process reading text from stdin, text is
+// returned with one sentence per line as it is synthesized
+func (p *PiperVoice) command(ctx context.Context, model string, text string, lengthScale float64) (*exec.Cmd, string, error) {
+	modelFile := p.dir.file(model)
+	_, err := os.Stat(modelFile)
+
+	slog.Debug("Searching for", "modelFile", modelFile)
+	if err != nil {
+		return nil, "", ErrorModelNotFound{Model: model, Language: p.Language}
+	}
+
+	// Create piper command
+	// Piper reads from stdin and outputs WAV to stdout
+	args := []string{"--model", modelFile, "--output_raw", "--length_scale", strconv.FormatFloat(lengthScale, 'f', 2, 64)}
+	// The speaker belongs to the main model
+	if p.speaker >= 0 && model == p.Model {
+		args = append(args, "--speaker", strconv.Itoa(p.speaker))
+	}
+	cmd := exec.CommandContext(ctx, "piper-tts", args...)
+
+	// piper-tts synthesizes every line on its own and streams it as soon
+	// as it is ready, one sentence per line starts playback early
+	text = strings.Join(SplitSentences(norm.NFC.String(text)), "\n")
+	cmd.Stdin = bytes.NewBufferString(text + "\n")
+	return cmd, text, nil
+}
+
+// execError wraps the error of a finished piper-tts process
+func execError(err error, stderr *bytes.Buffer) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return PiperExecError{ExitCode: exitErr.ExitCode(), Stderr: strings.TrimSpace(stderr.String())}
+	}
+	return fmt.Errorf("piper-tts failed: %w", err)
+}
+
+// Synthesize returns the raw 16 bit mono PCM of text without playing it
+func (p *PiperVoice) Synthesize(ctx context.Context, text string) (pcm []byte, sampleRate int, err error) {
+	p.mu.RLock()
+	lengthScale := p.lengthScale
+	p.mu.RUnlock()
+
+	cmd, _, err := p.command(ctx, p.Model, text, lengthScale)
+	if err != nil {
+		return nil, 0, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, 0, ErrPiperNotInstalled
+	}
+	if err != nil {
+		return nil, 0, execError(err, &stderr)
+	}
+	return stdout.Bytes(), p.outputSampleRate(p.Model), nil
+}
+
 // speakWithPiper generates speech using Piper TTS and plays it
 func (p *PiperVoice) Speak(piper_ctx context.Context, text string) error {
+	return p.speak(piper_ctx, p.Model, text, 0, nil)
+}
+
+// SpeakScaled is Speak at another length scale than the configured one, for
+// example slower to practise single words
+func (p *PiperVoice) SpeakScaled(piper_ctx context.Context, text string, lengthScale float64) error {
+	return p.speak(piper_ctx, p.Model, text, clampLengthScale(lengthScale), nil)
+}
+
+// SpeakVoice is Speak with another downloaded model, an empty model uses the
+// main one
+func (p *PiperVoice) SpeakVoice(piper_ctx context.Context, text string, model string) error {
+	if model == "" {
+		model = p.Model
+	}
+	return p.speak(piper_ctx, model, text, 0, nil)
+}
+
+// SpeakTracked is Speak calling onWord with the index of the word of text
+// being played, taken from piper's phoneme alignments. Without them, from an
+// older piper or a voice that can't align, onWord is never called
+func (p *PiperVoice) SpeakTracked(piper_ctx context.Context, text string, onWord func(index int)) error {
+	return p.speak(piper_ctx, p.Model, text, 0, onWord)
+}
+
+// speak plays text with model, a zero lengthScale uses the configured one
+func (p *PiperVoice) speak(piper_ctx context.Context, model string, text string, lengthScale float64, onWord func(index int)) error {
 	p.mu.Lock()
 	p.speaking = true
+	p.lastPCM = nil
+	if lengthScale == 0 {
+		lengthScale = p.lengthScale
+	}
 	p.mu.Unlock()
 
 	defer func() {
@@ -323,133 +758,167 @@ func (p *PiperVoice) Speak(piper_ctx context.Context, text string) error {
 		p.speaking = false
 		p.mu.Unlock()
 	}()
-	err := func() error {
-		modelFile := filepath.Join(voicesDir, p.Model)
-		_, err := os.Stat(modelFile)
 
-		slog.Debug("Searching for", "modelFile", modelFile)
-		if err != nil {
-			return ErrorModelNotFound{Model: p.Model, Language: p.Language}
+	// Only piper's Python module tells when the words are spoken
+	if onWord != nil && model == p.Model && p.canAlign() {
+		return p.speakAligned(piper_ctx, text, lengthScale, onWord)
+	}
+
+	// The server only loads the main model
+	if p.server != nil && model == p.Model {
+		err := p.speakServer(piper_ctx, text, lengthScale)
+		if !errors.Is(err, errServerUnsupported) {
+			return err
 		}
+	}
+	return p.speakOnce(piper_ctx, model, text, lengthScale)
+}
 
-		// Create piper command
-		// Piper reads from stdin and outputs WAV to stdout
-		piperCmd := exec.CommandContext(piper_ctx, "piper-tts", "--model", modelFile, "--output_raw")
+// speakOnce runs piper-tts for this utterance only
+func (p *PiperVoice) speakOnce(piper_ctx context.Context, model string, text string, lengthScale float64) error {
+	piperCmd, text, err := p.command(piper_ctx, model, text, lengthScale)
+	if err != nil {
+		return err
+	}
 
-		text = strings.ReplaceAll(text, "\n", " ")
-		text = norm.NFC.String(text)
-		piperCmd.Stdin = bytes.NewBufferString(text)
+	// Connect piper stdout to the playback device
+	pipe, err := piperCmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create pipe: %w", err)
+	}
 
-		// Connect piper stdout to paplay stdin
-		pipe, err := piperCmd.StdoutPipe()
-		if err != nil {
-			return fmt.Errorf("failed to create pipe: %w", err)
-		}
+	// Capture stderr for debugging
+	var piperStderr bytes.Buffer
+	piperCmd.Stderr = &piperStderr
 
-		// Capture stderr for debugging
-		var piperStderr bytes.Buffer
-		piperCmd.Stderr = &piperStderr
+	// Start piper
+	err = piperCmd.Start()
+	if errors.Is(err, exec.ErrNotFound) {
+		return ErrPiperNotInstalled
+	}
+	if err != nil {
+		return fmt.Errorf("failed to start piper: %w", err)
+	}
 
-		ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, func(message string) {
-			// log.Printf("LOG <%v>\n", message)
-		})
-		if err != nil {
-			return err
-		}
-		defer func() {
-			_ = ctx.Uninit()
-			ctx.Free()
-		}()
-
-		deviceConfig := malgo.DefaultDeviceConfig(malgo.Playback)
-		deviceConfig.Playback.Format = malgo.FormatS16
-		deviceConfig.Playback.Channels = 1
-		deviceConfig.SampleRate = 22050
-		deviceConfig.Alsa.NoMMap = 1
-
-		reader := bufio.NewReaderSize(pipe, 64*1024)
-		eofReached := atomic.Bool{}
-		playbackDone := make(chan struct{})
-		silenceCallbacks := atomic.Int32{}
-		onSamples := func(pOutputSample, pInputSamples []byte, framecount uint32) {
-			select {
-			case <-piper_ctx.Done():
-				return
-			default:
-				if eofReached.Load() {
-					for i := range pOutputSample {
-						pOutputSample[i] = 0
-					}
-					// After a few silence callbacks, signal that playback is truly done
-					if silenceCallbacks.Add(1) >= 4 {
-						select {
-						case playbackDone <- struct{}{}:
-						default:
-						}
-					}
-					return
-				}
-				n, err := io.ReadFull(reader, pOutputSample)
-				if err == io.EOF || err == io.ErrUnexpectedEOF {
-					eofReached.Store(true)
-					for i := n; i < len(pOutputSample); i++ {
-						pOutputSample[i] = 0
-					}
-					return
-				}
-				if err != nil {
-					slog.Info("Read error", "error", err)
-					eofReached.Store(true)
-					for i := range pOutputSample {
-						pOutputSample[i] = 0
-					}
-					return
-				}
-			}
+	// IMPORTANT: piperCmd.Wait() must be called AFTER all reads from the pipe complete,
+	// because Wait() closes the pipe and discards any unread data in the OS buffer.
+	sampleRate := p.outputSampleRate(model)
+	pcm, err := playPCM(piper_ctx, pipe, sampleRate, nil)
+	if err != nil || piper_ctx.Err() != nil {
+		_ = piperCmd.Wait()
+		return err
+	}
 
-		}
+	piperErr := piperCmd.Wait()
+	if piperErr != nil && piper_ctx.Err() != context.Canceled {
+		return execError(piperErr, &piperStderr)
+	}
 
-		deviceCallbacks := malgo.DeviceCallbacks{
-			Data: onSamples,
-		}
+	p.mu.Lock()
+	p.lastPCM, p.lastRate = pcm, sampleRate
+	p.mu.Unlock()
 
-		device, err := malgo.InitDevice(ctx.Context, deviceConfig, deviceCallbacks)
-		if err != nil {
-			return err
+	slog.Debug("Speaking", "text", text)
+	return nil
+}
+
+// speakServer has the running piper HTTP server synthesize the utterance
+func (p *PiperVoice) speakServer(piper_ctx context.Context, text string, lengthScale float64) error {
+	if _, err := os.Stat(p.dir.file(p.Model)); err != nil {
+		return ErrorModelNotFound{Model: p.Model, Language: p.Language}
+	}
+	text = strings.Join(SplitSentences(norm.NFC.String(text)), "\n")
+	audio, err := p.server.synthesize(piper_ctx, text, lengthScale, p.speaker)
+	if err != nil {
+		if piper_ctx.Err() != nil {
+			return nil
 		}
-		defer device.Uninit()
+		return err
+	}
+	defer audio.Close()
 
-		go func() {
-			err = device.Start()
-			if err != nil {
-				slog.Error("failed to start device:", "error", err)
-			}
-		}()
-		defer device.Stop()
+	sampleRate := p.outputSampleRate(p.Model)
+	pcm, err := playPCM(piper_ctx, audio, sampleRate, nil)
+	if err != nil || piper_ctx.Err() != nil {
+		return err
+	}
 
-		// Start piper
-		err = piperCmd.Start()
-		if err != nil {
-			return fmt.Errorf("failed to start piper: %w", err)
-		}
+	p.mu.Lock()
+	p.lastPCM, p.lastRate = pcm, sampleRate
+	p.mu.Unlock()
 
-		// Wait for playback to actually finish (silence callbacks confirm device drained)
-		// IMPORTANT: piperCmd.Wait() must be called AFTER all reads from the pipe complete,
-		// because Wait() closes the pipe and discards any unread data in the OS buffer.
-		select {
-		case <-piper_ctx.Done():
-			return nil
-		case <-playbackDone:
-		}
+	slog.Debug("Speaking", "text", text)
+	return nil
+}
+
+// playPCM plays the raw output of piper and returns it for the replay
+// cache, nil when it was too long
+func playPCM(ctx context.Context, r io.Reader, sampleRate int, onPlayed func(played int64)) ([]byte, error) {
+	cache := &cappedBuffer{limit: maxCachedPCM}
+	err := PlayTracked(ctx, io.TeeReader(r, cache), sampleRate, 1, onPlayed)
+	return cache.Bytes(), err
+}
 
-		piperErr := piperCmd.Wait()
-		if piperErr != nil && piper_ctx.Err() != context.Canceled {
-			return piperErr
+// canAlign reports whether the installed piper reports phoneme alignments,
+// it is probed on the first call
+func (p *PiperVoice) canAlign() bool {
+	p.alignOnce.Do(func() {
+		p.aligns = exec.Command(piperPython(), "-c", alignProbe).Run() == nil
+		if !p.aligns {
+			slog.Info("piper can't align phonemes, the spoken word is not followed")
 		}
+	})
+	return p.aligns
+}
 
-		log.Printf("Speaking: %s", text)
-		return nil
-	}()
+// speakAligned runs piper's Python module, which writes the phoneme
+// alignments of every sentence next to its audio
+func (p *PiperVoice) speakAligned(piper_ctx context.Context, text string, lengthScale float64, onWord func(index int)) error {
+	modelFile := p.dir.file(p.Model)
+	if _, err := os.Stat(modelFile); err != nil {
+		return ErrorModelNotFound{Model: p.Model, Language: p.Language}
+	}
+	piperCmd := exec.CommandContext(piper_ctx, piperPython(), "-c", alignScript, modelFile, strconv.FormatFloat(lengthScale, 'f', 2, 64), strconv.Itoa(p.speaker))
+	text = strings.Join(SplitSentences(norm.NFC.String(text)), "\n")
+	piperCmd.Stdin = bytes.NewBufferString(text + "\n")
 
-	return err
+	pipe, err := piperCmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create pipe: %w", err)
+	}
+	alignments, alignmentsWriter, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create pipe: %w", err)
+	}
+	defer alignments.Close()
+	piperCmd.ExtraFiles = []*os.File{alignmentsWriter}
+	var piperStderr bytes.Buffer
+	piperCmd.Stderr = &piperStderr
+
+	err = piperCmd.Start()
+	alignmentsWriter.Close()
+	if err != nil {
+		return fmt.Errorf("failed to start piper: %w", err)
+	}
+
+	timings := newWordTimings(onWord)
+	go timings.read(alignments)
+	sampleRate := p.outputSampleRate(p.Model)
+	pcm, err := playPCM(piper_ctx, pipe, sampleRate, timings.played)
+	if err != nil || piper_ctx.Err() != nil {
+		_ = piperCmd.Wait()
+		return err
+	}
+
+	piperErr := piperCmd.Wait()
+	if piperErr != nil && piper_ctx.Err() != context.Canceled {
+		return execError(piperErr, &piperStderr)
+	}
+
+	p.mu.Lock()
+	p.lastPCM, p.lastRate = pcm, sampleRate
+	p.mu.Unlock()
+
+	slog.Debug("Speaking", "text", text)
+	return nil
 }
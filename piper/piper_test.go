@@ -0,0 +1,108 @@
+package piper
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testdata holds model configs as they are published with the voices
+var testdata = Dir{Path: "testdata", ReadOnly: true}
+
+func TestLoadVoiceConfig(t *testing.T) {
+	tests := []struct {
+		model      string
+		sampleRate int
+		speakers   int
+	}{
+		{"de_DE-thorsten-high.onnx", 22050, 1},
+		{"en_US-lessac-low.onnx", 16000, 1},
+		{"en_GB-vctk-medium.onnx", 22050, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			config, err := loadVoiceConfig(testdata, tt.model)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if config.Audio.SampleRate != tt.sampleRate || config.NumSpeakers != tt.speakers {
+				t.Errorf("sample rate %d with %d speakers", config.Audio.SampleRate, config.NumSpeakers)
+			}
+		})
+	}
+
+	if _, err := loadVoiceConfig(testdata, "de_DE-broken-low.onnx"); err == nil || !strings.Contains(err.Error(), "de_DE-broken-low.onnx.json") {
+		t.Errorf("malformed config gave %v", err)
+	}
+	if _, err := loadVoiceConfig(testdata, "de_DE-missing-low.onnx"); !os.IsNotExist(err) {
+		t.Errorf("missing config gave %v", err)
+	}
+}
+
+func TestOutputSampleRate(t *testing.T) {
+	tests := []struct {
+		model string
+		want  int
+	}{
+		{"en_US-lessac-low.onnx", 16000},
+		{"de_DE-thorsten-high.onnx", 22050},
+		{"de_DE-broken-low.onnx", defaultSampleRate},
+		{"de_DE-norate-x_low.onnx", defaultSampleRate},
+		{"de_DE-missing-low.onnx", defaultSampleRate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			voice := NewPiperVoice(testdata, WithModel(tt.model))
+			if got := voice.outputSampleRate(tt.model); got != tt.want {
+				t.Errorf("sample rate %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutputSampleRateIsCached(t *testing.T) {
+	dir := Dir{Path: t.TempDir()}
+	data, err := os.ReadFile(testdata.file("en_US-lessac-low.onnx.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir.Path, "en_US-lessac-low.onnx.json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	voice := NewPiperVoice(dir, WithModel("en_US-lessac-low.onnx"))
+	if got := voice.outputSampleRate("en_US-lessac-low.onnx"); got != 16000 {
+		t.Fatalf("sample rate %d", got)
+	}
+	if err := os.Remove(filepath.Join(dir.Path, "en_US-lessac-low.onnx.json")); err != nil {
+		t.Fatal(err)
+	}
+	if got := voice.outputSampleRate("en_US-lessac-low.onnx"); got != 16000 {
+		t.Errorf("sample rate %d after the config was removed", got)
+	}
+}
+
+func TestResolveSpeaker(t *testing.T) {
+	tests := []struct {
+		speaker string
+		want    int
+		wantErr bool
+	}{
+		{"p236", 1, false},
+		{"3", 3, false},
+		{"4", 0, true},
+		{"-1", 0, true},
+		{"p999", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ResolveSpeaker(testdata, "en_GB-vctk-medium.onnx", tt.speaker)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ResolveSpeaker(%q) = %d, %v", tt.speaker, got, err)
+		}
+	}
+
+	if _, err := ResolveSpeaker(testdata, "de_DE-thorsten-high.onnx", "0"); err == nil || !strings.Contains(err.Error(), "single speaker") {
+		t.Errorf("single speaker voice gave %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+package piper
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/gen2brain/malgo"
+)
+
+// Play streams raw S16 little endian PCM from r to the default playback
+// device until the stream ends or ctx is cancelled
+func Play(play_ctx context.Context, r io.Reader, sampleRate int, channels int) error {
+	return PlayTracked(play_ctx, r, sampleRate, channels, nil)
+}
+
+// PlayTracked is Play reporting the number of bytes handed to the device
+// after every callback, onPlayed runs on the audio thread and must not block
+func PlayTracked(play_ctx context.Context, r io.Reader, sampleRate int, channels int, onPlayed func(played int64)) error {
+	ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, func(message string) {
+		// log.Printf("LOG <%v>\n", message)
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = ctx.Uninit()
+		ctx.Free()
+	}()
+
+	deviceConfig := malgo.DefaultDeviceConfig(malgo.Playback)
+	deviceConfig.Playback.Format = malgo.FormatS16
+	deviceConfig.Playback.Channels = uint32(channels)
+	deviceConfig.SampleRate = uint32(sampleRate)
+	deviceConfig.Alsa.NoMMap = 1
+	selectOutputDevice(ctx.Context, &deviceConfig)
+
+	reader := bufio.NewReaderSize(r, 64*1024)
+	eofReached := atomic.Bool{}
+	playbackDone := make(chan struct{})
+	silenceCallbacks := atomic.Int32{}
+	var played int64
+	onSamples := func(pOutputSample, pInputSamples []byte, framecount uint32) {
+		select {
+		case <-play_ctx.Done():
+			return
+		default:
+			if eofReached.Load() {
+				for i := range pOutputSample {
+					pOutputSample[i] = 0
+				}
+				// After a few silence callbacks, signal that playback is truly done
+				if silenceCallbacks.Add(1) >= 4 {
+					select {
+					case playbackDone <- struct{}{}:
+					default:
+					}
+				}
+				return
+			}
+			n, err := io.ReadFull(reader, pOutputSample)
+			scaleSamples(pOutputSample[:n], Volume())
+			if onPlayed != nil {
+				played += int64(n)
+				onPlayed(played)
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				eofReached.Store(true)
+				for i := n; i < len(pOutputSample); i++ {
+					pOutputSample[i] = 0
+				}
+				return
+			}
+			if err != nil {
+				slog.Info("Read error", "error", err)
+				eofReached.Store(true)
+				for i := range pOutputSample {
+					pOutputSample[i] = 0
+				}
+				return
+			}
+		}
+
+	}
+
+	deviceCallbacks := malgo.DeviceCallbacks{
+		Data: onSamples,
+	}
+
+	device, err := malgo.InitDevice(ctx.Context, deviceConfig, deviceCallbacks)
+	if err != nil {
+		return err
+	}
+	defer device.Uninit()
+
+	if err := device.Start(); err != nil {
+		return fmt.Errorf("failed to start playback device: %w", err)
+	}
+	defer device.Stop()
+
+	// Wait for playback to actually finish (silence callbacks confirm device drained)
+	select {
+	case <-play_ctx.Done():
+		return nil
+	case <-playbackDone:
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package piper
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// DefaultQualityPreference is the order voice qualities are tried in, medium
+// sounds good and still synthesizes fast on slow machines
+var DefaultQualityPreference = []string{"medium", "low", "high", "x_low"}
+
+// ResolveVoice picks the voice of the voices list in dir for a language
+// family such as de, or a language code such as de_AT whose voices come
+// first, preferring the qualities in the order given and single speaker
+// voices among equals, ties are broken by key so the choice is the same on
+// every run
+func ResolveVoice(dir Dir, language string, preferences []string) (VoiceInfo, error) {
+	voices, err := FetchVoices(dir)
+	if err != nil {
+		return VoiceInfo{}, err
+	}
+	voice, ok := selectVoice(voices, language, preferences)
+	if !ok {
+		return VoiceInfo{}, fmt.Errorf("no voice for language %q", language)
+	}
+	return voice, nil
+}
+
+func selectVoice(voices map[string]VoiceInfo, language string, preferences []string) (VoiceInfo, bool) {
+	if len(preferences) == 0 {
+		preferences = DefaultQualityPreference
+	}
+	// Qualities missing from the preferences come last
+	rank := func(quality string) int {
+		if i := slices.Index(preferences, quality); i >= 0 {
+			return i
+		}
+		return len(preferences)
+	}
+
+	family, _, _ := strings.Cut(language, "_")
+	var candidates []VoiceInfo
+	for _, voice := range voices {
+		if voice.Language.Family == family {
+			candidates = append(candidates, voice)
+		}
+	}
+	if len(candidates) == 0 {
+		return VoiceInfo{}, false
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if (a.Language.Code == language) != (b.Language.Code == language) {
+			return a.Language.Code == language
+		}
+		if rank(a.Quality) != rank(b.Quality) {
+			return rank(a.Quality) < rank(b.Quality)
+		}
+		if (a.NumSpkrs <= 1) != (b.NumSpkrs <= 1) {
+			return a.NumSpkrs <= 1
+		}
+		return a.Key < b.Key
+	})
+	return candidates[0], true
+}
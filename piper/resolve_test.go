@@ -0,0 +1,66 @@
+package piper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fixtureVoices is a voices directory holding a fresh copy of the voices list
+// in testdata, which has several voices per language
+func fixtureVoices(t *testing.T) Dir {
+	t.Helper()
+	data, err := os.ReadFile(testdata.file("voices.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := Dir{Path: t.TempDir()}
+	if err := os.WriteFile(filepath.Join(dir.Path, "voices.json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestResolveVoice(t *testing.T) {
+	dir := fixtureVoices(t)
+	tests := []struct {
+		name        string
+		language    string
+		preferences []string
+		want        string
+	}{
+		// de_DE-mls-medium has many speakers
+		{"medium single speaker first", "de", nil, "de_DE-thorsten-medium"},
+		{"configured order", "de", []string{"high", "medium"}, "de_DE-thorsten-high"},
+		{"low", "de", []string{"low"}, "de_DE-karlsson-low"},
+		{"x_low", "de", []string{"x_low", "low"}, "de_DE-eva_k-x_low"},
+		{"ties broken by key", "en", nil, "en_GB-alan-medium"},
+		{"no listed quality sorts by key", "de", []string{"ultra"}, "de_DE-eva_k-x_low"},
+		{"region first", "en_US", nil, "en_US-lessac-medium"},
+		{"region before quality", "en_US", []string{"high"}, "en_US-amy-low"},
+		{"quality within the region", "en_GB", []string{"low"}, "en_GB-southern_english_female-low"},
+		{"Brazilian Portuguese", "pt_BR", nil, "pt_BR-faber-medium"},
+		{"European Portuguese", "pt_PT", nil, "pt_PT-tugão-medium"},
+		{"unknown region falls back to the family", "de_AT", nil, "de_DE-thorsten-medium"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Map iteration changes between runs, the choice must not
+			for range 20 {
+				voice, err := ResolveVoice(dir, tt.language, tt.preferences)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if voice.Key != tt.want {
+					t.Fatalf("ResolveVoice(%q, %v) = %s, want %s", tt.language, tt.preferences, voice.Key, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveVoiceUnknownLanguage(t *testing.T) {
+	if voice, err := ResolveVoice(fixtureVoices(t), "xx", nil); err == nil {
+		t.Errorf("ResolveVoice found %s", voice.Key)
+	}
+}
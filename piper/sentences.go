@@ -0,0 +1,68 @@
+package piper
+
+import (
+	"strings"
+	"unicode"
+)
+
+// abbreviations end with a period without ending the sentence, lower case
+var abbreviations = map[string]bool{
+	"z.b.": true, "d.h.": true, "u.a.": true, "usw.": true, "bzw.": true,
+	"ca.": true, "nr.": true, "dr.": true, "prof.": true, "str.": true,
+	"vgl.": true, "evtl.": true, "ggf.": true, "inkl.": true, "etc.": true,
+	"mr.": true, "mrs.": true, "ms.": true, "e.g.": true, "i.e.": true,
+	"vs.": true, "st.": true, "sr.": true, "jr.": true, "p.ex.": true,
+}
+
+// endsSentence reports whether word, the last word before a space, closes a
+// sentence
+func endsSentence(word string) bool {
+	trimmed := strings.TrimRight(word, `"'»“”)`)
+	if trimmed == "" {
+		return false
+	}
+	last := trimmed[len(trimmed)-1]
+	if last == '!' || last == '?' || strings.HasSuffix(trimmed, "…") {
+		return true
+	}
+	if last != '.' {
+		return false
+	}
+	if abbreviations[strings.ToLower(trimmed)] {
+		return false
+	}
+	core := strings.TrimSuffix(trimmed, ".")
+	// Initials such as "A." and ordinals such as "3." in German dates
+	if len([]rune(core)) == 1 {
+		return false
+	}
+	return !isNumber(core)
+}
+
+func isNumber(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// SplitSentences splits text into sentences so the first one can be spoken
+// while the rest is still synthesized
+func SplitSentences(text string) []string {
+	words := strings.Fields(text)
+	var sentences []string
+	var current []string
+	for _, word := range words {
+		current = append(current, word)
+		if endsSentence(word) {
+			sentences = append(sentences, strings.Join(current, " "))
+			current = nil
+		}
+	}
+	if len(current) > 0 {
+		sentences = append(sentences, strings.Join(current, " "))
+	}
+	return sentences
+}
@@ -0,0 +1,214 @@
+package piper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// serverStartTimeout is how long loading the model may take
+const serverStartTimeout = 30 * time.Second
+
+// errServerUnsupported is returned when the piper HTTP server can't be
+// started, the voice then runs piper-tts for every utterance
+var errServerUnsupported = errors.New("piper http server not available")
+
+// server keeps a piper HTTP server running for one model, it is started on
+// the first request and again when it died
+type server struct {
+	mu          sync.Mutex
+	modelFile   string
+	cmd         *exec.Cmd
+	url         string
+	exited      chan struct{}
+	unsupported bool
+}
+
+type serverRequest struct {
+	Text        string  `json:"text"`
+	LengthScale float64 `json:"length_scale"`
+	SpeakerID   *int    `json:"speaker_id,omitempty"`
+}
+
+// piperPython is the interpreter piper-tts was installed with, read from the
+// shebang of its script so virtual environments work
+func piperPython() string {
+	path, err := exec.LookPath("piper-tts")
+	if err != nil {
+		return "python3"
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "python3"
+	}
+	defer f.Close()
+	line, _ := bufio.NewReader(f).ReadString('\n')
+	if interpreter, ok := strings.CutPrefix(strings.TrimSpace(line), "#!"); ok && !strings.Contains(interpreter, " ") {
+		return interpreter
+	}
+	return "python3"
+}
+
+// freePort asks the kernel for a port nobody listens on
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// ensure returns the URL of the running server, starting it when needed
+func (s *server) ensure() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.unsupported {
+		return "", errServerUnsupported
+	}
+	if s.cmd != nil {
+		select {
+		case <-s.exited:
+			log.Println("piper http server exited, restarting it")
+		default:
+			return s.url, nil
+		}
+	}
+
+	err := s.start()
+	if err != nil {
+		log.Printf("Failed to start the piper http server: %v", err)
+		s.unsupported = true
+		return "", errServerUnsupported
+	}
+	return s.url, nil
+}
+
+func (s *server) start() error {
+	port, err := freePort()
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(piperPython(), "-m", "piper.http_server", "--model", s.modelFile, "--host", "127.0.0.1", "--port", strconv.Itoa(port))
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	exited := make(chan struct{})
+	go func() {
+		_ = cmd.Wait()
+		close(exited)
+	}()
+
+	address := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	deadline := time.After(serverStartTimeout)
+	for {
+		select {
+		case <-exited:
+			return fmt.Errorf("exited: %s", strings.TrimSpace(stderr.String()))
+		case <-deadline:
+			_ = cmd.Process.Kill()
+			return fmt.Errorf("not listening after %s", serverStartTimeout)
+		case <-time.After(100 * time.Millisecond):
+		}
+		conn, err := net.Dial("tcp", address)
+		if err == nil {
+			conn.Close()
+			break
+		}
+	}
+
+	s.cmd, s.exited, s.url = cmd, exited, "http://"+address
+	return nil
+}
+
+// synthesize returns the PCM of text, the caller closes it
+func (s *server) synthesize(ctx context.Context, text string, lengthScale float64, speaker int) (io.ReadCloser, error) {
+	url, err := s.ensure()
+	if err != nil {
+		return nil, err
+	}
+	request := serverRequest{Text: text, LengthScale: lengthScale}
+	if speaker >= 0 {
+		request.SpeakerID = &speaker
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("piper http server: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("piper http server (status %d): %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	audio := bufio.NewReader(resp.Body)
+	if err := skipWAVHeader(audio); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{audio, resp.Body}, nil
+}
+
+// close stops the server
+func (s *server) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cmd != nil {
+		_ = s.cmd.Process.Kill()
+		<-s.exited
+		s.cmd = nil
+	}
+}
+
+// skipWAVHeader reads the chunks of a WAV stream up to the start of the
+// samples
+func skipWAVHeader(r *bufio.Reader) error {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("failed to read WAV header: %w", err)
+	}
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return fmt.Errorf("piper http server answered without a WAV file")
+	}
+	chunk := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return fmt.Errorf("failed to read WAV header: %w", err)
+		}
+		if string(chunk[0:4]) == "data" {
+			return nil
+		}
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+			return fmt.Errorf("failed to read WAV header: %w", err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package piper
+
+import (
+	"encoding/binary"
+	"math"
+	"sync/atomic"
+)
+
+const (
+	MinVolume = 0.0
+	MaxVolume = 2.0
+)
+
+// volumeBits holds the float64 volume multiplier read on the audio thread
+var volumeBits atomic.Uint64
+
+func init() {
+	volumeBits.Store(math.Float64bits(1))
+}
+
+// SetVolume changes the multiplier applied to all playback, it returns the
+// value after clamping
+func SetVolume(volume float64) float64 {
+	volume = max(MinVolume, min(volume, MaxVolume))
+	volumeBits.Store(math.Float64bits(volume))
+	return volume
+}
+
+func Volume() float64 {
+	return math.Float64frombits(volumeBits.Load())
+}
+
+// scaleSamples multiplies little endian S16 samples in place, saturating at
+// the int16 bounds
+func scaleSamples(pcm []byte, volume float64) {
+	if volume == 1 {
+		return
+	}
+	for i := 0; i+1 < len(pcm); i += 2 {
+		sample := float64(int16(binary.LittleEndian.Uint16(pcm[i:])))
+		scaled := max(math.MinInt16, min(math.Round(sample*volume), math.MaxInt16))
+		binary.LittleEndian.PutUint16(pcm[i:], uint16(int16(scaled)))
+	}
+}
@@ -0,0 +1,67 @@
+package piper
+
+import (
+	"encoding/binary"
+	"math"
+	"slices"
+	"testing"
+)
+
+func le16(samples ...int16) []byte {
+	pcm := make([]byte, 2*len(samples))
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(sample))
+	}
+	return pcm
+}
+
+func TestScaleSamples(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []int16
+		volume  float64
+		want    []int16
+	}{
+		{"unity", []int16{1, -1, math.MaxInt16, math.MinInt16}, 1, []int16{1, -1, math.MaxInt16, math.MinInt16}},
+		{"half", []int16{1000, -1000, 3}, 0.5, []int16{500, -500, 2}},
+		{"muted", []int16{1000, -1000}, 0, []int16{0, 0}},
+		{"double", []int16{1000, -1000}, 2, []int16{2000, -2000}},
+		{"saturates at the top", []int16{20000, math.MaxInt16}, 2, []int16{math.MaxInt16, math.MaxInt16}},
+		{"saturates at the bottom", []int16{-20000, math.MinInt16}, 2, []int16{math.MinInt16, math.MinInt16}},
+		{"quiet samples aren't clipped", []int16{16383, -16384, 16384}, 2, []int16{32766, -32768, 32767}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pcm := le16(tt.samples...)
+			scaleSamples(pcm, tt.volume)
+			if want := le16(tt.want...); !slices.Equal(pcm, want) {
+				t.Errorf("got %v, want %v", pcm, want)
+			}
+		})
+	}
+
+	// A trailing half sample is left alone
+	odd := append(le16(1000), 0x7f)
+	scaleSamples(odd, 2)
+	if want := append(le16(2000), 0x7f); !slices.Equal(odd, want) {
+		t.Errorf("odd buffer is %v, want %v", odd, want)
+	}
+}
+
+func TestSetVolume(t *testing.T) {
+	t.Cleanup(func() { SetVolume(1) })
+	tests := []struct {
+		volume, want float64
+	}{
+		{0.5, 0.5},
+		{MaxVolume, MaxVolume},
+		{MaxVolume + 0.1, MaxVolume},
+		{-0.1, MinVolume},
+		{1, 1},
+	}
+	for _, tt := range tests {
+		if got := SetVolume(tt.volume); got != tt.want || Volume() != tt.want {
+			t.Errorf("SetVolume(%v) = %v, Volume() = %v, want %v", tt.volume, got, Volume(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func profileNames(config Config) []string {
+	names := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	errNoProfile      = errors.New("several profiles and none chosen")
+	errUnknownProfile = errors.New("no profile")
+)
+
+// selectProfile returns the profile named on the command line, the default
+// profile or the one picked, a config without profiles is used as it is
+func selectProfile(config Config, name string, pick func(names []string) (string, error)) (Config, error) {
+	if len(config.Profiles) == 0 {
+		if name != "" {
+			return config, fmt.Errorf("%w %q, the config has no profiles", errUnknownProfile, name)
+		}
+		return config, nil
+	}
+
+	names := profileNames(config)
+	switch {
+	case name != "":
+	case config.DefaultProfile != "":
+		name = config.DefaultProfile
+	case len(names) == 1:
+		name = names[0]
+	case pick == nil:
+		return config, errNoProfile
+	default:
+		var err error
+		if name, err = pick(names); err != nil {
+			return config, err
+		}
+	}
+
+	profile, ok := config.Profiles[name]
+	if !ok {
+		return config, fmt.Errorf("%w %q, the profiles are %s", errUnknownProfile, name, strings.Join(names, ", "))
+	}
+	profile.Profile = name
+	return profile, nil
+}
+
+// pickProfile asks on the terminal which profile to start with
+func pickProfile(names []string) (string, error) {
+	for i, name := range names {
+		fmt.Printf("%d) %s\n", i+1, name)
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("Profile: ")
+		if !scanner.Scan() {
+			return "", errNoProfile
+		}
+		answer := strings.TrimSpace(scanner.Text())
+		if i, err := strconv.Atoi(answer); err == nil && i >= 1 && i <= len(names) {
+			return names[i-1], nil
+		}
+		for _, name := range names {
+			if name == answer {
+				return name, nil
+			}
+		}
+	}
+}
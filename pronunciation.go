@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"lazylang/piper"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type RepeatPrompted struct{}
+
+type WordScore struct {
+	Word    string
+	Matched bool
+}
+
+// normalizeTokens lowercases the words so casing doesn't count as a mistake
+func normalizeTokens(tokens []string) []string {
+	normalized := make([]string, len(tokens))
+	for i, token := range tokens {
+		normalized[i] = strings.ToLower(token)
+	}
+	return normalized
+}
+
+// ScorePronunciation aligns the spoken words against the expected sentence
+// using a word-level Levenshtein distance and reports which target words were
+// matched together with a percentage score
+func ScorePronunciation(expected string, spoken string) ([]WordScore, int) {
+	target := isAlpha.FindAllString(expected, -1)
+	want := normalizeTokens(target)
+	got := normalizeTokens(isAlpha.FindAllString(spoken, -1))
+
+	if len(want) == 0 {
+		return nil, 0
+	}
+
+	dist := make([][]int, len(want)+1)
+	for i := range dist {
+		dist[i] = make([]int, len(got)+1)
+		dist[i][0] = i
+	}
+	for j := range dist[0] {
+		dist[0][j] = j
+	}
+
+	for i := 1; i <= len(want); i++ {
+		for j := 1; j <= len(got); j++ {
+			cost := 1
+			if want[i-1] == got[j-1] {
+				cost = 0
+			}
+			dist[i][j] = min(dist[i-1][j]+1, dist[i][j-1]+1, dist[i-1][j-1]+cost)
+		}
+	}
+
+	// Walk back through the table to find which target words were aligned
+	// with an identical spoken word
+	scores := make([]WordScore, len(want))
+	i, j := len(want), len(got)
+	for i > 0 {
+		switch {
+		case j > 0 && want[i-1] == got[j-1] && dist[i][j] == dist[i-1][j-1]:
+			scores[i-1] = WordScore{Word: target[i-1], Matched: true}
+			i--
+			j--
+		case j > 0 && dist[i][j] == dist[i-1][j-1]+1:
+			scores[i-1] = WordScore{Word: target[i-1]}
+			i--
+			j--
+		case dist[i][j] == dist[i-1][j]+1:
+			scores[i-1] = WordScore{Word: target[i-1]}
+			i--
+		default:
+			j--
+		}
+	}
+
+	mistakes := dist[len(want)][len(got)]
+	score := max(0, (len(want)-mistakes)*100/len(want))
+	return scores, score
+}
+
+// scoredText is the plain text of a score message, the words are colored
+// from Message.Scores when rendered
+func scoredText(scores []WordScore) string {
+	words := make([]string, len(scores))
+	for i, s := range scores {
+		words[i] = s.Word
+	}
+	return strings.Join(words, " ")
+}
+
+func (s WordScore) style() lipgloss.Style {
+	if s.Matched {
+		return styles.matchedWord
+	}
+	return styles.mismatchedWord
+}
+
+// SpeakForRepeat plays the target sentence and signals that the student can
+// now repeat it
+func SpeakForRepeat(text string, m model) tea.Cmd {
+	speaker := m.speaker
+	return func() tea.Msg {
+		err := m.speech.Do(func(ctx context.Context) error {
+			return speaker.Speak(ctx, speakableText(text))
+		})
+		if err != nil {
+			switch err.(type) {
+			case piper.StoppedSpeaking:
+				return ""
+			default:
+				return StatusChanged{kind: statusError, status: "Failed to speak"}
+			}
+		}
+		return RepeatPrompted{}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestScorePronunciation(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		spoken   string
+		matched  []bool
+		score    int
+	}{
+		{"exact", "Ich habe Hunger.", "ich habe hunger", []bool{true, true, true}, 100},
+		{"wrong word", "Ich habe Hunger", "ich hatte Hunger", []bool{true, false, true}, 66},
+		{"missing word", "Ich habe Hunger", "ich Hunger", []bool{true, false, true}, 66},
+		{"extra word", "Ich habe Hunger", "ich habe sehr Hunger", []bool{true, true, true}, 66},
+		{"nothing spoken", "Ich habe Hunger", "", []bool{false, false, false}, 0},
+		// The scores follow the words of the expected text however they
+		// lowercase
+		{"dotted capital I", "İstanbul güzel", "istanbul güzel", []bool{true, true}, 100},
+		{"dotted capital I spoken", "İstanbul", "İstanbul", []bool{true}, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scores, score := ScorePronunciation(tt.expected, tt.spoken)
+			matched := make([]bool, len(scores))
+			for i, s := range scores {
+				matched[i] = s.Matched
+			}
+			if !reflect.DeepEqual(matched, tt.matched) || score != tt.score {
+				t.Errorf("got %v %d%%, want %v %d%%", matched, score, tt.matched, tt.score)
+			}
+		})
+	}
+}
+
+func TestScorePronunciationWithoutWords(t *testing.T) {
+	if scores, score := ScorePronunciation("…", "hallo"); scores != nil || score != 0 {
+		t.Errorf("got %v %d", scores, score)
+	}
+}
+
+func TestScoreMessageNavigatesPlainWords(t *testing.T) {
+	scores, _ := ScorePronunciation("Ich habe Hunger", "ich hatte Hunger")
+	m := newTestModel(t, Message{Role: RoleScore, Text: scoredText(scores), Scores: scores})
+	for word, want := range []string{"Score:", "Ich", "habe", "Hunger"} {
+		m.focusRow, m.focusWord = 0, word
+		if got := m.getFocusedWord(); got != want {
+			t.Errorf("word %d is %q, want %q", word, got, want)
+		}
+	}
+	rendered := ansi.Strip(renderConversation(m.messages, 40, 0, 2, nil))
+	if rendered != "Score: Ich habe Hunger \n" {
+		t.Errorf("rendered %q", rendered)
+	}
+}
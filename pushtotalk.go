@@ -0,0 +1,70 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// RecordMode selects how recordings are started and stopped
+type RecordMode string
+
+const (
+	// ToggleRecording starts and stops with ctrl+b
+	ToggleRecording RecordMode = "toggle"
+	// HoldRecording records while space is held, terminals only send key
+	// repeats so the recording stops once they stop arriving
+	HoldRecording RecordMode = "hold"
+	// TapRecording starts with ctrl+b and stops on any key
+	TapRecording RecordMode = "tap"
+)
+
+// holdTimeout is longer than the initial key repeat delay of most terminals
+const holdTimeout = 600 * time.Millisecond
+
+type HoldCheck struct{}
+
+func (r RecordMode) recordingStatus() string {
+	switch r {
+	case HoldRecording:
+		return "Recording (hold space)"
+	case TapRecording:
+		return "Recording (any key stops)"
+	default:
+		return "Recording"
+	}
+}
+
+func holdCheck() tea.Cmd {
+	return tea.Tick(holdTimeout/4, func(time.Time) tea.Msg {
+		return HoldCheck{}
+	})
+}
+
+// holdKey handles a space press in hold mode, the first press starts the
+// recording and every repeat keeps it alive
+func (m *model) holdKey() tea.Cmd {
+	m.lastHoldKey = time.Now()
+	if m.recorder.IsRecording() {
+		return nil
+	}
+	m.speech.Clear()
+	return tea.Batch(m.startRecording(), holdCheck())
+}
+
+// checkHold stops the recording once space hasn't been repeated for
+// holdTimeout
+func (m *model) checkHold() tea.Cmd {
+	if !m.recorder.IsRecording() {
+		// The recorder may not have started yet
+		if time.Since(m.lastHoldKey) < holdTimeout {
+			return holdCheck()
+		}
+		return nil
+	}
+	if time.Since(m.lastHoldKey) < holdTimeout {
+		return holdCheck()
+	}
+	m.recorder.Stop()
+	return m.transcribeRecording()
+}
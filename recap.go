@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const recapTimeout = 10 * time.Second
+
+func GetSessionsLogPath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "sessions.log"), nil
+}
+
+func (m model) transcript() string {
+	var st strings.Builder
+	for _, s := range m.sessions {
+		for _, msg := range s.messages {
+			fmt.Fprintf(&st, "%s: %s\n", msg.Role, msg.Text)
+		}
+	}
+	return st.String()
+}
+
+func (m model) turns() int {
+	turns := 0
+	for _, s := range m.sessions {
+		for _, msg := range s.messages {
+			if msg.Role == RoleUser {
+				turns++
+			}
+		}
+	}
+	return turns
+}
+
+// summarize asks the LLM for a short summary of the conversation in the
+// language being learned, it gives up after recapTimeout so quitting is never
+// blocked for long
+func summarize(m model, transcript string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), recapTimeout)
+	defer cancel()
+
+	prompt := fmt.Sprintf("Summarize in one short paragraph in %s what the following conversation between a student and a teacher was about:\n\n%s", languageName(m.config.Language), transcript)
+	return generateChatCompletion(ctx, m.llm, prompt)
+}
+
+// BuildRecap describes the session that just ended
+func BuildRecap(m model, ended time.Time) string {
+	var st strings.Builder
+	fmt.Fprintf(&st, "Session %s\n", m.started.Format("2006-01-02 15:04"))
+	fmt.Fprintf(&st, "Duration: %s\n", ended.Sub(m.started).Round(time.Second))
+	fmt.Fprintf(&st, "Turns: %d\n", m.turns())
+
+	if words := m.wordsStore.List(); words != "" {
+		fmt.Fprintf(&st, "Saved words:\n%s", words)
+	}
+
+	transcript := m.transcript()
+	if transcript != "" {
+		summary, err := summarize(m, transcript)
+		if err != nil {
+			slog.Error("Failed to summarize session", "error", err)
+		} else {
+			fmt.Fprintf(&st, "Summary: %s\n", strings.TrimSpace(summary))
+		}
+	}
+	return st.String()
+}
+
+func appendRecap(recap string) error {
+	path, err := GetSessionsLogPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "%s\n", recap)
+	return err
+}
@@ -3,93 +3,274 @@ package main
 import (
 	"bytes"
 	"encoding/binary"
-	"encoding/json"
-	"fmt"
-	"io"
-	"log"
-	"mime/multipart"
-	"net/http"
+	"errors"
+	"log/slog"
 	"sync"
 	"time"
+)
+
+// recorderState is the lifecycle of a single recording
+type recorderState int
 
-	"github.com/gen2brain/malgo"
+const (
+	recorderIdle recorderState = iota
+	// recorderStarting is set while the audio device is being opened, a
+	// Stop in this state cancels the recording before it captures anything
+	recorderStarting
+	recorderRecording
 )
 
+// ErrAlreadyRecording is returned by Start while a recording is running
+var ErrAlreadyRecording = errors.New("already recording")
+
 type Recorder struct {
-	recording bool
-	Content   []byte
-	done      chan struct{}
-	finished  chan struct{}
-	Stopped   time.Time
-	mu        sync.RWMutex
+	mu      sync.RWMutex
+	state   recorderState
+	content []byte
+	stopped time.Time
+	// stop is closed by Stop, finished is closed once Start returned, both
+	// are created for every recording
+	stop          chan struct{}
+	finished      chan struct{}
+	stopRequested bool
+
+	// Voice activity detection, disabled when vadThreshold is zero
+	vadThreshold float64
+	vadSilence   time.Duration
+	vad          *vadState
+	stoppedByVAD bool
+
+	// gain amplifies the captured samples, autoNormalize raises the peak
+	// of the finished recording to -3 dBFS
+	gain          float64
+	autoNormalize bool
+
+	// Silence quieter than trimThreshold is cut from both ends of the
+	// recording, keeping trimPadding around the speech
+	trimThreshold float64
+	trimPadding   time.Duration
+
+	// noiseGateMargin enables the noise gate, frames quieter than the
+	// measured floor plus the margin are silenced
+	noiseGateMargin float64
+	gate            *noiseGate
+
+	// startCue runs before the device is opened so its sound isn't captured
+	startCue func()
+
+	// audio opens the microphone, miniaudio unless replaced
+	audio AudioContext
+
+	// inputDevice is matched against capture device names, empty uses the
+	// default device
+	inputDevice string
+}
+
+type RecorderOption func(*Recorder)
+
+// WithVAD stops the recording once the RMS level stayed below threshold for
+// the silence duration after speech was detected
+func WithVAD(threshold float64, silence time.Duration) RecorderOption {
+	return func(r *Recorder) {
+		r.vadThreshold = threshold
+		r.vadSilence = silence
+	}
+}
+
+// WithGain amplifies quiet microphones, samples are clipped to the int16 range
+func WithGain(gain float64) RecorderOption {
+	return func(r *Recorder) {
+		r.gain = gain
+	}
+}
+
+// WithAutoNormalize scales every recording so its peak is at -3 dBFS
+func WithAutoNormalize() RecorderOption {
+	return func(r *Recorder) {
+		r.autoNormalize = true
+	}
+}
+
+// WithSilenceTrim cuts the dead air before and after the speech
+func WithSilenceTrim(threshold float64, padding time.Duration) RecorderOption {
+	return func(r *Recorder) {
+		r.trimThreshold = threshold
+		r.trimPadding = padding
+	}
+}
+
+// WithNoiseGate silences keyboard and background noise between words
+func WithNoiseGate(margin float64) RecorderOption {
+	return func(r *Recorder) {
+		r.noiseGateMargin = margin
+	}
+}
+
+// WithStartCue plays a sound, blocking, before every recording
+func WithStartCue(cue func()) RecorderOption {
+	return func(r *Recorder) {
+		r.startCue = cue
+	}
+}
+
+func WithInputDevice(name string) RecorderOption {
+	return func(r *Recorder) {
+		r.inputDevice = name
+	}
+}
+
+// WithAudioContext replaces the miniaudio backend, for example with a fake
+// device
+func WithAudioContext(audio AudioContext) RecorderOption {
+	return func(r *Recorder) {
+		r.audio = audio
+	}
 }
 
-func NewRecorder() *Recorder {
-	return &Recorder{
-		recording: false,
-		done:      make(chan struct{}),
-		finished:  make(chan struct{}),
+func NewRecorder(options ...RecorderOption) *Recorder {
+	r := &Recorder{audio: malgoAudioContext{}}
+	for _, option := range options {
+		option(r)
 	}
+	return r
 }
 
+// SpeechDetected reports whether voice activity detection heard speech in the
+// current recording
+func (r *Recorder) SpeechDetected() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.vad != nil && r.vad.speechStarted
+}
+
+// StoppedByVAD reports whether the last recording was ended by voice activity
+// detection rather than Stop
+func (r *Recorder) StoppedByVAD() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.stoppedByVAD
+}
+
+// IsRecording is true from the call to Start until it returns, including
+// while the device is still being opened
 func (r *Recorder) IsRecording() bool {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	return r.recording
+	return r.state != recorderIdle
+}
+
+// Content is the WAV data of the last finished recording, nil when it failed
+func (r *Recorder) Content() []byte {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.content
+}
+
+// Stopped is when the last recording finished
+func (r *Recorder) Stopped() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.stopped
 }
 
-// recordAudio captures audio from the microphone until Ctrl+B is pressed
+// Start captures audio from the microphone until Stop is called or voice
+// activity detection heard the speech end
 func (r *Recorder) Start() ([]byte, error) {
-	ctx, err := malgo.InitContext(nil, malgo.ContextConfig{}, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize audio context: %w", err)
+	r.mu.Lock()
+	if r.state != recorderIdle {
+		r.mu.Unlock()
+		return nil, ErrAlreadyRecording
 	}
+	r.state = recorderStarting
+	r.stop = make(chan struct{})
+	r.finished = make(chan struct{})
+	r.stopRequested = false
+	r.content = nil
+	r.stoppedByVAD = false
+	r.vad = nil
+	r.gate = nil
+	stop, finished := r.stop, r.finished
+	r.mu.Unlock()
+
+	// Whatever happens Stop must not wait forever
 	defer func() {
-		_ = ctx.Uninit()
-		ctx.Free()
+		r.mu.Lock()
+		r.state = recorderIdle
+		r.stopped = time.Now()
+		r.mu.Unlock()
+		close(finished)
 	}()
 
-	deviceConfig := malgo.DefaultDeviceConfig(malgo.Capture)
-	deviceConfig.Capture.Format = malgo.FormatS16
-	deviceConfig.Capture.Channels = uint32(channels)
-	deviceConfig.SampleRate = uint32(sampleRate)
+	if r.startCue != nil {
+		r.startCue()
+	}
 
 	var capturedBytes []byte
+	silenceDetected := make(chan struct{})
 
-	onRecvFrames := func(pOutputSample, pInputSamples []byte, framecount uint32) {
+	onFrames := func(frames []byte) {
 		r.mu.Lock()
-		capturedBytes = append(capturedBytes, pInputSamples...)
+		if r.gate != nil {
+			r.gate.process(frames)
+		}
+		capturedBytes = append(capturedBytes, frames...)
+		if r.vad != nil && !r.vad.triggered && r.vad.process(frames, time.Now()) {
+			close(silenceDetected)
+		}
 		r.mu.Unlock()
 	}
 
-	callbacks := malgo.DeviceCallbacks{
-		Data: onRecvFrames,
+	device, err := r.audio.OpenCapture(r.inputDevice, onFrames)
+	if err != nil {
+		return nil, err
 	}
+	defer device.Close()
 
-	device, err := malgo.InitDevice(ctx.Context, deviceConfig, callbacks)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize capture device: %w", err)
+	captureRate := device.SampleRate()
+	captureChannels := device.Channels()
+	if captureRate != sampleRate || captureChannels != channels {
+		slog.Info("Capturing", "sampleRate", captureRate, "channels", captureChannels)
 	}
 
+	// The callback only runs once the device started
+	r.mu.Lock()
+	if r.vadThreshold > 0 {
+		r.vad = newVADState(r.vadThreshold, r.vadSilence, captureRate*captureChannels/10)
+	}
+	if r.noiseGateMargin > 0 {
+		calibration := int(noiseGateCalibration.Seconds() * float64(captureRate*captureChannels))
+		hangover := int(noiseGateHangover.Seconds() * float64(captureRate*captureChannels))
+		r.gate = newNoiseGate(r.noiseGateMargin, calibration, hangover)
+	}
+	r.mu.Unlock()
+
 	err = device.Start()
 	if err != nil {
-		device.Uninit()
-		return nil, fmt.Errorf("failed to start capture device: %w", err)
+		return nil, err
 	}
 
 	r.mu.Lock()
-	r.recording = true
+	r.state = recorderRecording
 	r.mu.Unlock()
 
-	log.Println("Recording")
-
-	// Wait until stopped
-	<-r.done
+	slog.Debug("Recording")
+
+	// Wait until stopped, a manual stop wins over voice activity detection
+	select {
+	case <-stop:
+	case <-silenceDetected:
+		select {
+		case <-stop:
+		default:
+			r.mu.Lock()
+			r.stoppedByVAD = true
+			r.mu.Unlock()
+		}
+	}
 
 	device.Stop()
-	device.Uninit()
 
-	// Convert raw PCM bytes to []int16
+	// Convert raw PCM bytes to []int16, the callback no longer runs
 	r.mu.Lock()
 	raw := capturedBytes
 	r.mu.Unlock()
@@ -98,35 +279,57 @@ func (r *Recorder) Start() ([]byte, error) {
 	for i := range allSamples {
 		allSamples[i] = int16(raw[2*i]) | int16(raw[2*i+1])<<8
 	}
+	allSamples = resample(downmix(allSamples, captureChannels), captureRate, sampleRate)
+	if r.gain > 0 && r.gain != 1 {
+		applyGain(allSamples, r.gain)
+	}
+	if r.trimThreshold > 0 {
+		allSamples = trimSilence(allSamples, r.trimThreshold, trimFrame, paddingSamples(r.trimPadding))
+	}
+	if r.autoNormalize {
+		applyGain(allSamples, normalizeGain(allSamples))
+	}
 
 	// Convert to WAV format
 	wavData := samplesToWAV(allSamples, sampleRate, channels)
-	r.Content = wavData
 	r.mu.Lock()
-	r.recording = false
+	r.content = wavData
 	r.mu.Unlock()
-
-	r.Stopped = time.Now()
-	close(r.finished)
 	return wavData, nil
 }
 
+// Stop ends the running recording and waits until Start returned, it does
+// nothing when no recording is running
 func (r *Recorder) Stop() {
-	if !r.recording {
+	r.mu.Lock()
+	if r.state == recorderIdle {
+		r.mu.Unlock()
 		return
 	}
-	r.done <- struct{}{}
-	<-r.finished
-	// Reset channels for next recording
-	r.done = make(chan struct{})
-	r.finished = make(chan struct{})
+	if !r.stopRequested {
+		r.stopRequested = true
+		close(r.stop)
+	}
+	finished := r.finished
+	r.mu.Unlock()
+
+	<-finished
 }
 
 // samplesToWAV converts raw audio samples to WAV format
 func samplesToWAV(samples []int16, sampleRate, channels int) []byte {
+	pcm := make([]byte, len(samples)*2)
+	for i, sample := range samples {
+		binary.LittleEndian.PutUint16(pcm[2*i:], uint16(sample))
+	}
+	return pcmToWAV(pcm, sampleRate, channels)
+}
+
+// pcmToWAV puts a WAV header in front of 16 bit little endian PCM
+func pcmToWAV(pcm []byte, sampleRate, channels int) []byte {
 	var buf bytes.Buffer
 
-	dataSize := len(samples) * 2 // 2 bytes per sample (16-bit)
+	dataSize := len(pcm)
 	fileSize := wavHeaderSize + dataSize - 8
 
 	// RIFF header
@@ -151,82 +354,7 @@ func samplesToWAV(samples []int16, sampleRate, channels int) []byte {
 	binary.Write(&buf, binary.LittleEndian, int32(dataSize))
 
 	// Write audio data
-	for _, sample := range samples {
-		binary.Write(&buf, binary.LittleEndian, sample)
-	}
+	buf.Write(pcm)
 
 	return buf.Bytes()
 }
-
-// transcribeWithGroq sends audio to Groq API for transcription
-func transcribeWithGroq(audioData []byte, apiKey string, language string) (string, error) {
-	var requestBody bytes.Buffer
-	writer := multipart.NewWriter(&requestBody)
-
-	// Add audio file
-	part, err := writer.CreateFormFile("file", "audio.wav")
-	if err != nil {
-		return "", fmt.Errorf("failed to create form file: %w", err)
-	}
-	_, err = part.Write(audioData)
-	if err != nil {
-		return "", fmt.Errorf("failed to write audio data: %w", err)
-	}
-
-	// Add model field
-	err = writer.WriteField("model", "whisper-large-v3")
-	if err != nil {
-		return "", fmt.Errorf("failed to write model field: %w", err)
-	}
-
-	// Add Language field
-	err = writer.WriteField("language", language)
-	if err != nil {
-		return "", fmt.Errorf("failed to write language field: %w", err)
-	}
-
-	// Add response format
-	err = writer.WriteField("response_format", "json")
-	if err != nil {
-		return "", fmt.Errorf("failed to write response_format field: %w", err)
-	}
-
-	err = writer.Close()
-	if err != nil {
-		return "", fmt.Errorf("failed to close writer: %w", err)
-	}
-
-	// Create request
-	req, err := http.NewRequest("POST", groqAudioAPIURL, &requestBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-
-	// Send request
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
-	}
-
-	var transcriptionResp GroqTranscriptionResponse
-	err = json.Unmarshal(body, &transcriptionResp)
-	if err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	return transcriptionResp.Text, nil
-}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// fakeAudio opens fake devices that deliver frames from their own goroutine,
+// like miniaudio does, once started
+type fakeAudio struct {
+	frames   [][]byte
+	rate     int
+	channels int
+	openErr  error
+	startErr error
+
+	opened atomic.Int32
+	closed atomic.Int32
+	// delivered receives a value once the frames of a recording were delivered
+	delivered chan struct{}
+}
+
+func newFakeAudio(frames ...[]byte) *fakeAudio {
+	return &fakeAudio{frames: frames, rate: sampleRate, channels: channels, delivered: make(chan struct{}, 1)}
+}
+
+type fakeDevice struct {
+	audio    *fakeAudio
+	onFrames func([]byte)
+	done     chan struct{}
+}
+
+func (a *fakeAudio) OpenCapture(deviceName string, onFrames func(frames []byte)) (CaptureDevice, error) {
+	if a.openErr != nil {
+		return nil, a.openErr
+	}
+	a.opened.Add(1)
+	return &fakeDevice{audio: a, onFrames: onFrames}, nil
+}
+
+func (d *fakeDevice) Start() error {
+	if d.audio.startErr != nil {
+		return d.audio.startErr
+	}
+	d.done = make(chan struct{})
+	go func() {
+		defer close(d.done)
+		for _, frame := range d.audio.frames {
+			d.onFrames(bytes.Clone(frame))
+		}
+		select {
+		case d.audio.delivered <- struct{}{}:
+		default:
+		}
+	}()
+	return nil
+}
+
+// Stop waits for the frames, the callback doesn't run after a device stopped
+func (d *fakeDevice) Stop() error {
+	if d.done != nil {
+		<-d.done
+	}
+	return nil
+}
+
+func (d *fakeDevice) Close() {
+	d.audio.closed.Add(1)
+}
+
+func (d *fakeDevice) SampleRate() int { return d.audio.rate }
+func (d *fakeDevice) Channels() int   { return d.audio.channels }
+
+// pcm encodes samples as little endian S16
+func pcm(samples ...int16) []byte {
+	return bytes.Clone(samplesToWAV(samples, sampleRate, channels)[wavHeaderSize:])
+}
+
+type recordResult struct {
+	wav []byte
+	err error
+}
+
+// record runs Start in the background like the recording command does
+func record(r *Recorder) <-chan recordResult {
+	result := make(chan recordResult, 1)
+	go func() {
+		wav, err := r.Start()
+		result <- recordResult{wav, err}
+	}()
+	return result
+}
+
+// within fails the test when f doesn't return in time
+func within(t *testing.T, what string, f func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s blocked", what)
+	}
+}
+
+func waitRecording(t *testing.T, r *Recorder) {
+	t.Helper()
+	within(t, "starting", func() {
+		for !r.IsRecording() {
+			time.Sleep(time.Millisecond)
+		}
+	})
+}
+
+func TestRecorderStartStop(t *testing.T) {
+	audio := newFakeAudio(pcm(1, 2, 3), pcm(4, 5))
+	r := NewRecorder(WithAudioContext(audio))
+
+	result := record(r)
+	<-audio.delivered
+	if !r.IsRecording() {
+		t.Fatal("not recording after the device started")
+	}
+	within(t, "Stop", r.Stop)
+	if r.IsRecording() {
+		t.Error("still recording after Stop")
+	}
+
+	got := <-result
+	if got.err != nil {
+		t.Fatal(got.err)
+	}
+	if want := samplesToWAV([]int16{1, 2, 3, 4, 5}, sampleRate, channels); !bytes.Equal(got.wav, want) {
+		t.Errorf("recorded %v, want %v", got.wav[wavHeaderSize:], want[wavHeaderSize:])
+	}
+	if !bytes.Equal(r.Content(), got.wav) {
+		t.Error("Content isn't the recording")
+	}
+	if r.Stopped().IsZero() {
+		t.Error("Stopped isn't set")
+	}
+	if audio.opened.Load() != 1 || audio.closed.Load() != 1 {
+		t.Errorf("device opened %d and closed %d times", audio.opened.Load(), audio.closed.Load())
+	}
+}
+
+func TestRecorderStopWhenIdle(t *testing.T) {
+	r := NewRecorder(WithAudioContext(newFakeAudio()))
+	within(t, "Stop", r.Stop)
+	if r.IsRecording() {
+		t.Error("recording after Stop")
+	}
+}
+
+func TestRecorderAlreadyRecording(t *testing.T) {
+	audio := newFakeAudio(pcm(1))
+	r := NewRecorder(WithAudioContext(audio))
+	result := record(r)
+	waitRecording(t, r)
+
+	if _, err := r.Start(); !errors.Is(err, ErrAlreadyRecording) {
+		t.Errorf("second Start gave %v", err)
+	}
+	r.Stop()
+	if got := <-result; got.err != nil {
+		t.Errorf("the first recording failed: %v", got.err)
+	}
+	if audio.opened.Load() != 1 {
+		t.Errorf("device opened %d times", audio.opened.Load())
+	}
+}
+
+func TestRecorderOpenFailure(t *testing.T) {
+	audio := newFakeAudio(pcm(1))
+	audio.openErr = ErrNoCaptureDevice
+	r := NewRecorder(WithAudioContext(audio))
+
+	wav, err := r.Start()
+	if !errors.Is(err, ErrNoCaptureDevice) || wav != nil {
+		t.Fatalf("Start gave %v, %v", wav, err)
+	}
+	if r.IsRecording() || r.Content() != nil {
+		t.Error("a failed Start left a recording")
+	}
+	within(t, "Stop after a failed Start", r.Stop)
+
+	// The next recording works once the device is back
+	audio.openErr = nil
+	result := record(r)
+	<-audio.delivered
+	r.Stop()
+	if got := <-result; got.err != nil || len(got.wav) != wavHeaderSize+2 {
+		t.Errorf("recording after the failure gave %d bytes, %v", len(got.wav), got.err)
+	}
+}
+
+func TestRecorderStartFailure(t *testing.T) {
+	audio := newFakeAudio(pcm(1))
+	audio.startErr = ErrCaptureFailed
+	r := NewRecorder(WithAudioContext(audio))
+
+	if _, err := r.Start(); !errors.Is(err, ErrCaptureFailed) {
+		t.Fatalf("Start gave %v", err)
+	}
+	if audio.closed.Load() != 1 {
+		t.Errorf("device closed %d times after it failed to start", audio.closed.Load())
+	}
+	if r.IsRecording() {
+		t.Error("recording after a failed Start")
+	}
+	within(t, "Stop after a failed Start", r.Stop)
+}
+
+func TestRecorderStopWhileStarting(t *testing.T) {
+	cuePlaying, endCue := make(chan struct{}), make(chan struct{})
+	audio := newFakeAudio(pcm(1))
+	r := NewRecorder(WithAudioContext(audio), WithStartCue(func() {
+		close(cuePlaying)
+		<-endCue
+	}))
+
+	result := record(r)
+	<-cuePlaying
+	if !r.IsRecording() {
+		t.Error("not recording while the device is opened")
+	}
+	stopped := make(chan struct{})
+	go func() {
+		r.Stop()
+		close(stopped)
+	}()
+	close(endCue)
+
+	within(t, "Stop while starting", func() { <-stopped })
+	if got := <-result; got.err != nil {
+		t.Errorf("Start gave %v", got.err)
+	}
+	if r.IsRecording() {
+		t.Error("recording after Stop")
+	}
+}
+
+// TestRecorderStartStopRepeatedly is meant for the race detector, Stop is
+// called from several goroutines while frames arrive
+func TestRecorderStartStopRepeatedly(t *testing.T) {
+	audio := newFakeAudio(pcm(1, 2), pcm(3, 4), pcm(5, 6))
+	r := NewRecorder(WithAudioContext(audio), WithVAD(0.5, time.Second), WithNoiseGate(0.01))
+
+	for i := 0; i < 50; i++ {
+		result := record(r)
+		waitRecording(t, r)
+
+		var wg sync.WaitGroup
+		for j := 0; j < 3; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				r.Stop()
+				r.IsRecording()
+				r.Content()
+			}()
+		}
+		within(t, "concurrent Stops", wg.Wait)
+		if got := <-result; got.err != nil {
+			t.Fatalf("recording %d failed: %v", i, got.err)
+		}
+	}
+	if opened, closed := audio.opened.Load(), audio.closed.Load(); opened != 50 || closed != 50 {
+		t.Errorf("device opened %d and closed %d times", opened, closed)
+	}
+}
+
+func TestRecordingFailureStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		openErr  error
+		startErr error
+		want     string
+	}{
+		{"no audio system", fmt.Errorf("%w: %w", ErrAudioUnavailable, errors.New("no backend")), nil, "Audio system unavailable"},
+		{"no device", fmt.Errorf("%w: %w", ErrNoCaptureDevice, errors.New("init failed")), nil, "No capture device found"},
+		{"busy device", nil, fmt.Errorf("%w: %w", ErrCaptureFailed, errors.New("device busy")), "Microphone busy or unavailable"},
+		{"unknown", errors.New("unknown"), nil, "Microphone unavailable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(t)
+			audio := newFakeAudio(pcm(1))
+			audio.openErr, audio.startErr = tt.openErr, tt.startErr
+			m.recorder = NewRecorder(WithAudioContext(audio))
+			m.handsFree = true
+
+			cmd := m.startRecording()
+			if m.status.kind != statusRecording {
+				t.Fatalf("status before the device opened is %q", m.status.text)
+			}
+			// The recording runs first, then the timer
+			record := cmd().(tea.BatchMsg)[0]
+			msg := record()
+			if _, ok := msg.(RecordingFailed); !ok {
+				t.Fatalf("recording gave %T", msg)
+			}
+			m = update(t, m, msg)
+
+			if m.status.kind != statusError || m.status.text != tt.want {
+				t.Errorf("status is %q", m.status.text)
+			}
+			if m.handsFree {
+				t.Error("hands-free mode keeps listening to a broken microphone")
+			}
+			if m.recorder.IsRecording() {
+				t.Error("still recording")
+			}
+			if cmd := m.transcribeRecording(); cmd != nil {
+				t.Error("the failed recording is transcribed")
+			}
+
+			// Once the microphone works again the next recording starts
+			audio.openErr, audio.startErr = nil, nil
+			cmd = m.startRecording()
+			if m.status.kind != statusRecording {
+				t.Errorf("status of the next recording is %q", m.status.text)
+			}
+			result := make(chan tea.Msg, 1)
+			go func() { result <- cmd().(tea.BatchMsg)[0]() }()
+			<-audio.delivered
+			m.recorder.Stop()
+			if msg := <-result; msg != (RecordingFinished{id: m.recordingID}) {
+				t.Errorf("the next recording gave %#v", msg)
+			}
+		})
+	}
+}
+
+func TestRecordingFinishedTwice(t *testing.T) {
+	fake := &fakeTranscriber{text: "Hallo", confidence: 0.9}
+	m := newTranscriberModel(t, fake)
+	audio := newFakeAudio(pcm(tone(sampleRate, 8000)...))
+	m.recorder = NewRecorder(WithAudioContext(audio))
+
+	cmd := m.startRecording()
+	result := make(chan tea.Msg, 1)
+	go func() { result <- cmd().(tea.BatchMsg)[0]() }()
+	<-audio.delivered
+	// The user stops the recording just as VAD ends it
+	m.recorder.Stop()
+	if m.transcribeRecording() == nil {
+		t.Fatal("the recording isn't transcribed")
+	}
+	finished := (<-result).(RecordingFinished)
+	finished.byVAD = true
+	m = update(t, m, finished)
+
+	if len(m.Session.transcribing) != 1 {
+		t.Errorf("the recording is transcribed %d times", len(m.Session.transcribing))
+	}
+
+	// VAD still finishes the next recording
+	cmd = m.startRecording()
+	go func() { result <- cmd().(tea.BatchMsg)[0]() }()
+	<-audio.delivered
+	m.recorder.Stop()
+	finished = (<-result).(RecordingFinished)
+	finished.byVAD = true
+	m = update(t, m, finished)
+	if len(m.Session.transcribing) != 2 {
+		t.Errorf("the next recording isn't transcribed, %d transcriptions", len(m.Session.transcribing))
+	}
+}
+
+func TestAlreadyRecordingIsIgnored(t *testing.T) {
+	m := newTestModel(t)
+	m.setStatus(statusRecording, "0:01")
+	m = update(t, m, RecordingFailed{err: ErrAlreadyRecording})
+	if m.status.kind != statusRecording {
+		t.Errorf("status is %q", m.status.text)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func defaultRecordingsDir() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "recordings"), nil
+}
+
+// saveRecording writes the WAV into dir named after the current time and
+// removes the oldest recordings beyond keep, zero keeps everything
+func saveRecording(dir string, wav []byte, keep int) error {
+	if dir == "" {
+		return errNoStorage
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create recordings directory: %w", err)
+	}
+
+	name := fmt.Sprintf("recording-%s.wav", time.Now().Format("20060102-150405.000"))
+	if err := os.WriteFile(filepath.Join(dir, name), wav, 0644); err != nil {
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+
+	if keep <= 0 {
+		return nil
+	}
+	return pruneRecordings(dir, keep)
+}
+
+func pruneRecordings(dir string, keep int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to list recordings: %w", err)
+	}
+
+	var recordings []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), "recording-") && strings.HasSuffix(entry.Name(), ".wav") {
+			recordings = append(recordings, entry.Name())
+		}
+	}
+	// The timestamp in the name sorts chronologically
+	sort.Strings(recordings)
+
+	for len(recordings) > keep {
+		if err := os.Remove(filepath.Join(dir, recordings[0])); err != nil {
+			return fmt.Errorf("failed to remove old recording: %w", err)
+		}
+		recordings = recordings[1:]
+	}
+	return nil
+}
+
+// SaveRecording stores the recording in the background so the transcription
+// isn't delayed, failures are only logged
+func SaveRecording(wav []byte, dir string, keep int) tea.Cmd {
+	return func() tea.Msg {
+		if err := saveRecording(dir, wav, keep); err != nil {
+			slog.Error("Failed to save recording", "error", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// countdownFrom is how long before the max duration the elapsed time turns
+// into a countdown
+const countdownFrom = 10 * time.Second
+
+// RecordingTick updates the elapsed time of the recording with the given id,
+// ticks of earlier recordings are dropped
+type RecordingTick struct {
+	id int
+}
+
+func recordingTick(id int) tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return RecordingTick{id: id}
+	})
+}
+
+func formatDuration(d time.Duration) string {
+	seconds := int(d.Round(time.Second).Seconds())
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// recordingProgress is the status shown while recording
+func (m model) recordingProgress() string {
+	status := m.config.RecordMode.recordingStatus()
+	if m.config.VAD.Enabled {
+		status = "Listening…"
+		if m.recorder.SpeechDetected() {
+			status = "Detected speech…"
+		}
+	}
+
+	elapsed := time.Since(m.recordingStarted)
+	maxDuration := time.Duration(m.config.MaxRecordingSeconds) * time.Second
+	if maxDuration > 0 && maxDuration-elapsed <= countdownFrom {
+		left := max(maxDuration-elapsed, 0)
+		return fmt.Sprintf("%s %s (stops in %ds)", status, formatDuration(elapsed), int(left.Round(time.Second).Seconds()))
+	}
+	return fmt.Sprintf("%s %s", status, formatDuration(elapsed))
+}
+
+// checkRecordingTime refreshes the elapsed time and stops the recording once
+// it reached the max duration, the ticker ends with the recording
+func (m *model) checkRecordingTime(id int) tea.Cmd {
+	if id != m.recordingID || !m.recorder.IsRecording() {
+		return nil
+	}
+	maxDuration := time.Duration(m.config.MaxRecordingSeconds) * time.Second
+	if maxDuration > 0 && time.Since(m.recordingStarted) >= maxDuration {
+		m.recorder.Stop()
+		return m.transcribeRecording()
+	}
+	m.setStatus(statusRecording, m.recordingProgress())
+	return recordingTick(id)
+}
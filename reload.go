@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"lazylang/piper"
+	"os"
+	"reflect"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// configPollInterval is how often the config file is checked for changes
+const configPollInterval = 2 * time.Second
+
+// ConfigPolled carries the modification time of the config file, changed is
+// set when it differs from the previous poll
+type ConfigPolled struct {
+	modTime time.Time
+	changed bool
+}
+
+// ConfigReloaded is the config read again after the file changed
+type ConfigReloaded struct {
+	config Config
+	err    error
+}
+
+func configModTime() time.Time {
+	path, err := GetConfigPath()
+	if err != nil {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// watchConfig polls the config file, a missing file counts as unchanged
+func watchConfig(modTime time.Time) tea.Cmd {
+	return tea.Tick(configPollInterval, func(time.Time) tea.Msg {
+		current := configModTime()
+		if current.IsZero() {
+			return ConfigPolled{modTime: modTime}
+		}
+		return ConfigPolled{modTime: current, changed: !current.Equal(modTime)}
+	})
+}
+
+// reloadConfig reads the profile in use again, resolving a voice may need the
+// network so it runs outside of Update
+func reloadConfig(profile string) tea.Cmd {
+	return func() tea.Msg {
+		path, err := GetConfigPath()
+		if err != nil {
+			return ConfigReloaded{err: err}
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return ConfigReloaded{err: err}
+		}
+		config, err := loadConfig(path, data, profile, nil)
+		return ConfigReloaded{config: config, err: err}
+	}
+}
+
+// applyConfig switches to the reloaded config, the muting, speech rate,
+// volume and response style changed with keys are kept unless they were
+// changed in the file too
+func (m *model) applyConfig(msg ConfigReloaded) tea.Cmd {
+	if msg.err != nil {
+		m.setStatus(statusError, fmt.Sprintf("Config not reloaded: %v", msg.err))
+		return nil
+	}
+	next, loaded := msg.config, m.loadedConfig
+	if next.HandsFree {
+		next.VAD.Enabled = true
+	}
+
+	keymap, err := NewKeymap(next.Keys)
+	if err != nil {
+		m.setStatus(statusError, fmt.Sprintf("Config not reloaded: %v", err))
+		return nil
+	}
+	if next.Proxy != loaded.Proxy || next.CACertFile != loaded.CACertFile {
+		if err := configureHTTP(next); err != nil {
+			m.setStatus(statusError, fmt.Sprintf("Config not reloaded: %v", err))
+			return nil
+		}
+	}
+	// The log file stays open until the next start
+	if err := setLogLevel(next.Log); err != nil {
+		m.setStatus(statusError, fmt.Sprintf("Config not reloaded: %v", err))
+		return nil
+	}
+
+	if next.Muted == loaded.Muted {
+		next.Muted = m.config.Muted
+	}
+	if next.Volume == loaded.Volume {
+		next.Volume = m.config.Volume
+	}
+	if next.HideSidebar == loaded.HideSidebar {
+		next.HideSidebar = m.config.HideSidebar
+	}
+	if next.SidebarWidth == loaded.SidebarWidth {
+		next.SidebarWidth = m.config.SidebarWidth
+	}
+	if next.ResponseStyle == loaded.ResponseStyle {
+		next.ResponseStyle = m.config.ResponseStyle
+	}
+	if next.TTSBackend.SpeechRate == loaded.TTSBackend.SpeechRate {
+		next.TTSBackend.SpeechRate = m.config.TTSBackend.SpeechRate
+	}
+
+	transcriber := m.transcriber
+	if !reflect.DeepEqual(next.STTBackend, loaded.STTBackend) {
+		if transcriber, err = NewTranscriber(next.STTBackend, m.apiKey); err != nil {
+			m.setStatus(statusError, fmt.Sprintf("Config not reloaded: %v", err))
+			return nil
+		}
+	}
+	var translator Translator
+	if next.Translation.IsEnabled() {
+		if translator, err = NewTranslator(next); err != nil {
+			m.setStatus(statusError, fmt.Sprintf("Config not reloaded: %v", err))
+			return nil
+		}
+	}
+	var cmd tea.Cmd
+	speakerChanged := !reflect.DeepEqual(next.TTSBackend, m.config.TTSBackend) || next.Language != m.config.Language
+	if speakerChanged {
+		var ok bool
+		if cmd, ok = m.replaceSpeaker(next); !ok {
+			return nil
+		}
+	}
+	m.transcriber = transcriber
+	m.translator = translator
+	piper.SetOutputDevice(next.OutputDevice)
+	piper.SetVolume(next.Volume)
+
+	languageChanged := next.Language != m.config.Language
+	if next.Translation.Dictionary != m.config.Translation.Dictionary || next.Translation.IsEnabled() && !m.config.Translation.IsEnabled() {
+		m.dictionary = nil
+		if next.Translation.IsEnabled() && next.Translation.Dictionary != "" {
+			cmd = tea.Batch(cmd, loadDictionary(next.Translation.Dictionary))
+		}
+	}
+	pairChanged := next.Language != m.config.Language || next.TargetTranslationLanguage != m.config.TargetTranslationLanguage || next.Translation.Provider != m.config.Translation.Provider || libreTranslateURL(next) != libreTranslateURL(m.config)
+	if next.Translation.IsEnabled() && pairChanged {
+		cmd = tea.Batch(cmd, checkLanguagePair(translator, next))
+	}
+	layoutChanged := next.Translation.IsEnabled() != m.config.Translation.IsEnabled() || next.HideSidebar != m.config.HideSidebar || next.SidebarWidth != m.config.SidebarWidth
+	m.keymap = keymap
+	m.config = next
+	m.loadedConfig = msg.config
+	for _, s := range m.sessions {
+		s.updatePrompt(m.config)
+	}
+
+	if languageChanged {
+		m.askReset = true
+		m.resize()
+	}
+	// The sidebar appeared, disappeared or changed its width
+	if layoutChanged {
+		m.relayout()
+		m.refreshViewport()
+	}
+	if next.Theme != loaded.Theme {
+		for _, problem := range applyTheme(next.Theme) {
+			m.addWarning(problem)
+		}
+		m.spinner.Style = styles.busyStatus
+		m.refreshViewport()
+	}
+	// Replacing the speaker cleared the warnings
+	if speakerChanged {
+		m.addVoiceMismatchWarning()
+	}
+	m.UpdateStatus("Config reloaded")
+	return cmd
+}
+
+// replaceSpeaker builds the speaker of the reloaded config, speech in
+// progress is stopped and a missing voice is downloaded
+func (m *model) replaceSpeaker(next Config) (tea.Cmd, bool) {
+	speaker, warning, err := NewSpeaker(next.TTSBackend, next.Language)
+	if err != nil {
+		m.setStatus(statusError, fmt.Sprintf("Config not reloaded: %v", err))
+		return nil, false
+	}
+	m.speech.Clear()
+	if closer, ok := m.speaker.(io.Closer); ok {
+		closer.Close()
+	}
+	m.speaker = speaker
+	m.warning = warning
+	m.addWarning(storageWarning())
+
+	m.downloadingVoice = missingVoice(speaker)
+	if m.downloadingVoice == "" {
+		return nil, true
+	}
+	return downloadVoice(DownloadModel{model: m.downloadingVoice, language: next.Language}, next.TTSBackend.VoicesDir), true
+}
+
+// resetKey answers whether the conversation is cleared after the language
+// changed, other keys keep working as usual
+func (m *model) resetKey(key string) bool {
+	switch key {
+	case "y":
+		for _, s := range m.sessions {
+			s.reset(m.config)
+		}
+		m.refreshViewport()
+		m.UpdateStatus("Conversation cleared")
+	case "n", "esc":
+		m.UpdateStatus("Conversation kept")
+	default:
+		return false
+	}
+	m.askReset = false
+	m.resize()
+	return true
+}
+
+func (m model) resetView() string {
+	return styles.confirm.Width(m.fullWidth).Render(fmt.Sprintf("The language changed to %s, clear the conversation?  [y clear, n keep]", m.config.Language))
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	groqKeyEnv = "GROQ_API_KEY"
+	// groqKeyFileEnv names a file holding the Groq key
+	groqKeyFileEnv = "GROQ_API_KEY_FILE"
+	// The key is stored in the keyring as the groq account of the lazylang
+	// service
+	keyringService = "lazylang"
+	keyringAccount = "groq"
+)
+
+var errNoAPIKey = errors.New("Groq API key not set, set GROQ_API_KEY or GROQ_API_KEY_FILE, api_key_file in the config, or store it in the keyring")
+
+// groqAPIKey looks the Groq key up in GROQ_API_KEY, then the file named by
+// GROQ_API_KEY_FILE or api_key_file, then the system keyring
+func groqAPIKey(config Config) (string, error) {
+	if key := os.Getenv(groqKeyEnv); key != "" {
+		return key, nil
+	}
+
+	path := os.Getenv(groqKeyFileEnv)
+	if path == "" {
+		path = config.APIKeyFile
+	}
+	if path != "" {
+		return readKeyFile(path)
+	}
+
+	if key := keyringKey(); key != "" {
+		return key, nil
+	}
+	return "", errNoAPIKey
+}
+
+func readKeyFile(path string) (string, error) {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, rest)
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the API key file: %w", err)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		slog.Warn("The API key file can be read by other users, restrict it with chmod 600", "path", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the API key file: %w", err)
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return "", fmt.Errorf("the API key file %s is empty", path)
+	}
+	return key, nil
+}
+
+// keyringKey asks the system keyring through its command line tool,
+// secret-tool on Linux and security on macOS, for the key
+func keyringKey() string {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keyringService, "-a", keyringAccount, "-w")
+	default:
+		cmd = exec.Command("secret-tool", "lookup", "service", keyringService, "username", keyringAccount)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// sentenceOverlayLines is how many wrapped lines of the sentence and of its
+// translation are shown, longer ones are cut
+const sentenceOverlayLines = 3
+
+// SentenceTranslated is the translation of the sentence around the focused
+// word, it is shown below the conversation until dismissed
+type SentenceTranslated struct {
+	sentence    string
+	translation string
+	err         error
+}
+
+func translateSentence(translator Translator, sentence string, config Config) tea.Cmd {
+	return func() tea.Msg {
+		translation, err := translate(translator, sentence, config)
+		return SentenceTranslated{sentence: sentence, translation: translation, err: err}
+	}
+}
+
+// translateFocusedSentence translates the whole sentence of the focused word,
+// separable verbs and idioms make no sense word by word
+func (m *model) translateFocusedSentence() tea.Cmd {
+	if !m.config.Translation.IsEnabled() {
+		m.UpdateStatus("Translation disabled")
+		return nil
+	}
+	sentence, _, ok := m.focusedSentence()
+	sentence = strings.TrimSpace(sentence)
+	if !ok || sentence == "" {
+		m.UpdateStatus("Nothing to translate")
+		return nil
+	}
+	m.setStatus(statusBusy, "Translating sentence")
+	return translateSentence(m.translator, sentence, m.config)
+}
+
+func (m *model) sentenceTranslated(msg SentenceTranslated) {
+	if msg.err != nil {
+		m.setStatus(statusError, translationFailure("Failed to translate", msg.err))
+		return
+	}
+	m.sentenceTranslation = &msg
+	m.setStatus(statusReady, "Ready")
+	m.resize()
+}
+
+// sentenceKey closes the translation on esc, other keys keep working as usual
+func (m *model) sentenceKey(key string) bool {
+	if key != "esc" {
+		return false
+	}
+	m.sentenceTranslation = nil
+	m.resize()
+	return true
+}
+
+func (m model) sentenceView() string {
+	width := max(m.fullWidth, 1)
+	return lipgloss.JoinVertical(lipgloss.Left,
+		styles.sentence.Render(wrapLines(m.sentenceTranslation.sentence, width, sentenceOverlayLines)),
+		styles.translation.Render(wrapLines("→ "+m.sentenceTranslation.translation+"  [esc close]", width, sentenceOverlayLines)),
+	)
+}
+
+// wrapLines wraps text to width and cuts it after maxLines, marking the cut
+// with an ellipsis
+func wrapLines(text string, width int, maxLines int) string {
+	lines := strings.Split(lipgloss.NewStyle().Width(width).Render(text), "\n")
+	if len(lines) <= maxLines {
+		return strings.Join(lines, "\n")
+	}
+	lines = lines[:maxLines]
+	last := strings.TrimRight(lines[maxLines-1], " ")
+	lines[maxLines-1] = ansi.Truncate(last, width-1, "") + "…"
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/tmc/langchaingo/chains"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/memory"
+	"github.com/tmc/langchaingo/prompts"
+)
+
+// Session holds the state of one conversation tab
+type Session struct {
+	id       int
+	name     string
+	llmChain *chains.LLMChain
+	messages []Message
+	// nextMessageID is the id of the last added message
+	nextMessageID int
+	// instructions are extra prompt sentences added with slash commands
+	instructions []string
+
+	focusWord int
+	focusRow  int
+	yOffset   int
+
+	// turn is the id of the latest student turn
+	turn       int
+	busy       bool
+	queue      []pendingTurn
+	cancelTurn context.CancelFunc
+	// transcribing are the turns of recordings still being transcribed in
+	// the order they were made, transcribed holds those that arrived early
+	transcribing []int
+	transcribed  map[int]transcribedTurn
+
+	lastCompletion string
+	// repeatTarget is the sentence the student is asked to repeat, when set
+	// the next transcription is scored instead of sent to the LLM
+	repeatTarget string
+}
+
+func NewSession(id int, llm llms.Model, prompt prompts.PromptTemplate) *Session {
+	llmChain := chains.NewLLMChain(llm, prompt)
+	llmChain.Memory = memory.NewConversationBuffer()
+	return &Session{
+		id:       id,
+		name:     fmt.Sprintf("Chat %d", id),
+		llmChain: llmChain,
+	}
+}
+
+// addInstruction appends a sentence to the session prompt, the conversation
+// memory is kept
+func (s *Session) addInstruction(config Config, instruction string) {
+	s.instructions = append(s.instructions, instruction)
+	s.updatePrompt(config)
+}
+
+// updatePrompt rebuilds the prompt template after the config changed, the
+// conversation memory is kept
+func (s *Session) updatePrompt(config Config) {
+	s.llmChain.Prompt = NewPrompt(config, s.instructions)
+}
+
+// reset clears the conversation and its memory
+func (s *Session) reset(config Config) {
+	s.messages = nil
+	s.instructions = nil
+	s.updatePrompt(config)
+	s.focusRow, s.focusWord, s.yOffset = 0, 0, 0
+	s.lastCompletion, s.repeatTarget = "", ""
+	if s.cancelTurn != nil {
+		s.cancelTurn()
+	}
+	s.turn++
+	s.busy, s.queue = false, nil
+	s.transcribing, s.transcribed = nil, nil
+	s.llmChain.Memory = memory.NewConversationBuffer()
+}
+
+// message finds a message by its ID, nil once the conversation was cleared
+func (s *Session) message(id int) *Message {
+	for i := range s.messages {
+		if s.messages[i].ID == id {
+			return &s.messages[i]
+		}
+	}
+	return nil
+}
+
+func (m model) findSession(id int) *Session {
+	for _, s := range m.sessions {
+		if s.id == id {
+			return s
+		}
+	}
+	return nil
+}
+
+func (m model) isActive(s *Session) bool {
+	return s == m.Session
+}
+
+// switchSession makes the session at index active, remembering the scroll
+// position of the one we leave
+func (m *model) switchSession(index int) {
+	if len(m.sessions) == 0 {
+		return
+	}
+	index = (index + len(m.sessions)) % len(m.sessions)
+
+	m.speech.Clear()
+	m.Session.yOffset = m.viewport.YOffset
+	m.Session = m.sessions[index]
+	m.refreshViewport()
+	m.viewport.SetYOffset(m.Session.yOffset)
+}
+
+func (m model) activeIndex() int {
+	for i, s := range m.sessions {
+		if m.isActive(s) {
+			return i
+		}
+	}
+	return 0
+}
+
+func (m *model) newSession() {
+	m.nextSessionID++
+	// The prompt follows the response style and the config of now
+	s := NewSession(m.nextSessionID, m.llm, NewPrompt(m.config, nil))
+	m.sessions = append(m.sessions, s)
+	m.switchSession(len(m.sessions) - 1)
+}
+
+func (m *model) closeSession() {
+	if len(m.sessions) <= 1 {
+		m.UpdateStatus("Can't close the last tab")
+		return
+	}
+	// The answer in flight and the speech of the tab go with it
+	if m.Session.cancelTurn != nil {
+		m.Session.cancelTurn()
+	}
+	m.speech.Clear()
+	index := m.activeIndex()
+	m.sessions = append(m.sessions[:index], m.sessions[index+1:]...)
+	m.Session = m.sessions[min(index, len(m.sessions)-1)]
+	m.refreshViewport()
+	m.viewport.SetYOffset(m.Session.yOffset)
+}
+
+func (m model) tabsView() string {
+	tabs := make([]string, len(m.sessions))
+	for i, s := range m.sessions {
+		if m.isActive(s) {
+			tabs[i] = styles.activeTab.Render(s.name)
+		} else {
+			tabs[i] = s.name
+		}
+	}
+	return strings.Join(tabs, " │ ")
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tmc/langchaingo/prompts"
+)
+
+func promptOf(t *testing.T, s *Session) string {
+	t.Helper()
+	prompt, ok := s.llmChain.Prompt.(prompts.PromptTemplate)
+	if !ok {
+		t.Fatalf("prompt is a %T", s.llmChain.Prompt)
+	}
+	return prompt.Template
+}
+
+func TestNewTabFollowsResponseStyle(t *testing.T) {
+	m := newTestModel(t)
+	m = update(t, m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("L")})
+	if m.config.ResponseStyle != NormalResponse {
+		t.Fatalf("response style is %s", m.config.ResponseStyle)
+	}
+	m.newSession()
+	if want := NormalResponse.Instruction(); !strings.Contains(promptOf(t, m.Session), want) {
+		t.Errorf("new tab prompt lacks %q:\n%s", want, promptOf(t, m.Session))
+	}
+}
+
+func TestCompletionOfClosedTab(t *testing.T) {
+	m := newTurnModel(t, QueueTurns)
+	m.newSession()
+	closed := m.Session
+	turn := m.nextTurn(closed)
+	m.sendTurn(closed, turn, "Hallo")
+	cancel, cancelled := closed.cancelTurn, false
+	closed.cancelTurn = func() {
+		cancelled = true
+		cancel()
+	}
+
+	m.closeSession()
+	if !cancelled {
+		t.Error("the turn of the closed tab is still in flight")
+	}
+	status := m.status
+	m = update(t, m, ReadyCompletion{sessionID: closed.id, turn: turn, completion: "Guten Tag", addContent: true})
+	if len(m.messages) != 0 || m.status != status {
+		t.Errorf("the answer of the closed tab gave %q with status %q", transcript(m), m.status.text)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import "fmt"
+
+const (
+	// sidebarWidthStep is how much < and > move the split, in percent of
+	// the width
+	sidebarWidthStep = 5
+	minSidebarWidth  = 10
+	maxSidebarWidth  = 60
+)
+
+// sidebarShown reports whether the translated words are shown next to the
+// conversation
+func (m model) sidebarShown() bool {
+	return m.config.Translation.IsEnabled() && !m.config.HideSidebar
+}
+
+// sidebarColumns is the width of the sidebar with its border
+func (m model) sidebarColumns() int {
+	return m.fullWidth * clampSidebarWidth(m.config.SidebarWidth) / 100
+}
+
+func clampSidebarWidth(percent int) int {
+	return min(max(percent, minSidebarWidth), maxSidebarWidth)
+}
+
+// toggleSidebar hides or shows the sidebar, the conversation is rewrapped to
+// the new width
+func (m *model) toggleSidebar() {
+	if !m.config.Translation.IsEnabled() {
+		m.UpdateStatus("The sidebar needs translation")
+		return
+	}
+	m.config.HideSidebar = !m.config.HideSidebar
+	m.relayout()
+	if m.config.HideSidebar {
+		m.UpdateStatus("Sidebar hidden")
+	} else {
+		m.UpdateStatus("Sidebar shown")
+	}
+}
+
+// resizeSidebar moves the split by step percent of the width
+func (m *model) resizeSidebar(step int) {
+	if !m.sidebarShown() {
+		m.UpdateStatus("The sidebar is hidden")
+		return
+	}
+	m.config.SidebarWidth = clampSidebarWidth(clampSidebarWidth(m.config.SidebarWidth) + step)
+	m.relayout()
+	m.UpdateStatus(fmt.Sprintf("Sidebar width: %d%%", m.config.SidebarWidth))
+}
+
+// relayout rewraps the conversation after the split changed and scrolls the
+// focused row back into view
+func (m *model) relayout() {
+	m.resize()
+	m.scrollToFocus()
+}
+
+// scrollToFocus scrolls the viewport the least to show the focused row with
+// scrolloff rows around it
+func (m *model) scrollToFocus() {
+	line, nav := 0, 0
+	for _, row := range renderRows(m.messages, m.viewport.Width) {
+		if row.navigable {
+			if nav == m.focusRow {
+				break
+			}
+			nav++
+		}
+		line++
+	}
+	switch {
+	case line-scrolloff < m.viewport.YOffset:
+		m.viewport.SetYOffset(line - scrolloff)
+	case line+scrolloff >= m.viewport.YOffset+m.viewport.Height:
+		m.viewport.SetYOffset(line + scrolloff - m.viewport.Height + 1)
+	}
+}
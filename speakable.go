@@ -0,0 +1,124 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	markdownLink  = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	bareURL       = regexp.MustCompile(`\b(?:https?://|www\.)\S*[^\s.,!?;:)]`)
+	listMarker    = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+`)
+	headingMarker = regexp.MustCompile(`^\s*(?:#{1,6}|>+)\s*`)
+	emphasis      = regexp.MustCompile(`\*+|~~|` + "`+")
+	spaces        = regexp.MustCompile(`[ \t]+`)
+)
+
+// speakableText removes what a voice would read out literally, markdown
+// syntax, code fences and emoji, and says link instead of URLs. The original
+// text is still what is shown
+func speakableText(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			continue
+		}
+		line = markdownLink.ReplaceAllString(line, "$1")
+		line = bareURL.ReplaceAllString(line, "link")
+		line = listMarker.ReplaceAllString(line, "")
+		line = headingMarker.ReplaceAllString(line, "")
+		line = emphasis.ReplaceAllString(line, "")
+		line = stripUnderscores(line)
+		line = stripEmoji(line)
+		line = strings.TrimSpace(spaces.ReplaceAllString(line, " "))
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// spokenWords maps every word of speakableText(text) to the index of the
+// word of text it was read from, the speaker counts the words it says while
+// the conversation shows text. Markup and emoji words say nothing and a URL
+// says one word
+func spokenWords(text string) []int {
+	var words []int
+	shown := 0
+	for _, line := range strings.Split(text, "\n") {
+		fields := strings.Fields(line)
+		// Markup depends on what follows it, so each word says the words of
+		// the rest of the line from it on minus those of the rest after it
+		spoken := len(strings.Fields(speakableText(line)))
+		for i := range fields {
+			if spoken == 0 {
+				break
+			}
+			rest := len(strings.Fields(speakableText(strings.Join(fields[i+1:], " "))))
+			for range spoken - rest {
+				words = append(words, shown+i)
+			}
+			spoken = min(spoken, rest)
+		}
+		shown += len(fields)
+	}
+	return words
+}
+
+// stripUnderscores removes the underscores of markdown emphasis, those
+// inside a word such as snake_case stay
+func stripUnderscores(line string) string {
+	runes := []rune(line)
+	isWord := func(i int) bool {
+		return i >= 0 && i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsNumber(runes[i]))
+	}
+	var b strings.Builder
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '_' {
+			b.WriteRune(runes[i])
+			continue
+		}
+		end := i
+		for end < len(runes) && runes[end] == '_' {
+			end++
+		}
+		if isWord(i-1) && isWord(end) {
+			b.WriteString(string(runes[i:end]))
+		}
+		i = end - 1
+	}
+	return b.String()
+}
+
+// stripEmoji removes emoji with the spaces in front of them, so no space is
+// left before the punctuation following an emoji
+func stripEmoji(line string) string {
+	var b strings.Builder
+	for _, r := range line {
+		if !isEmoji(r) {
+			b.WriteRune(r)
+			continue
+		}
+		trimmed := strings.TrimRight(b.String(), " \t")
+		b.Reset()
+		b.WriteString(trimmed)
+	}
+	return b.String()
+}
+
+// isEmoji covers the emoji and pictograph blocks with the joiners and
+// variation selectors that glue them together
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF:
+		return true
+	case r >= 0x2600 && r <= 0x27BF:
+		return true
+	case r >= 0x2B00 && r <= 0x2BFF:
+		return true
+	case r == 0x200D || r == 0xFE0F || r == 0xFE0E:
+		return true
+	}
+	return false
+}
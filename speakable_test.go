@@ -0,0 +1,67 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSpeakableText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain", "Wie geht es dir?", "Wie geht es dir?"},
+		{"bold", "Das ist **sehr** gut", "Das ist sehr gut"},
+		{"italics", "Das ist *sehr* gut", "Das ist sehr gut"},
+		{"underscores", "Das ist __sehr__ _gut_", "Das ist sehr gut"},
+		{"words with underscores", "snake_case bleibt", "snake_case bleibt"},
+		{"strikethrough", "Das ist ~~schlecht~~ gut", "Das ist schlecht gut"},
+		{"inline code", "Sag `Hallo` zu mir", "Sag Hallo zu mir"},
+		{"code fence", "Schau:\n```go\nfmt.Println()\n```\nFertig", "Schau:\nfmt.Println()\nFertig"},
+		{"heading", "## Vokabeln\nHund", "Vokabeln\nHund"},
+		{"quote", "> Zitat", "Zitat"},
+		{"bullet list", "- eins\n* zwei\n+ drei", "eins\nzwei\ndrei"},
+		{"numbered list", "1. eins\n2) zwei", "eins\nzwei"},
+		{"minus in a sentence", "drei - zwei", "drei - zwei"},
+		{"markdown link", "Lies [den Artikel](https://example.com/a) jetzt", "Lies den Artikel jetzt"},
+		{"url", "Siehe https://example.com/a?b=c hier", "Siehe link hier"},
+		{"url before punctuation", "Geh auf www.dw.com.", "Geh auf link."},
+		{"url in parentheses", "(https://example.com)", "(link)"},
+		{"emoji", "Super 🎉 gemacht 👍🏽", "Super gemacht"},
+		{"emoji sequence", "Familie 👨‍👩‍👧 da", "Familie da"},
+		{"symbol emoji", "Sonne ☀️ heute", "Sonne heute"},
+		{"umlauts and punctuation stay", "Schön, dass du da bist – wirklich!", "Schön, dass du da bist – wirklich!"},
+		{"only markup", "```\n---\n", "---"},
+		{"empty lines are dropped", "eins\n\n\nzwei", "eins\nzwei"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := speakableText(tt.text); got != tt.want {
+				t.Errorf("speakableText(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnswerIsSpokenWithoutMarkdown(t *testing.T) {
+	config := NewConfig()
+	config.Theme.Preset = "dark"
+	config.Karaoke = false
+	config.ShowGloss = false
+	m := newConfiguredTestModel(t, config)
+	speaker := &fakeSpeaker{}
+	m.speaker = speaker
+
+	answer := "**Gut** gemacht 🎉, siehe https://dw.com"
+	next, cmd := m.Update(ReadyCompletion{sessionID: m.Session.id, turn: m.Session.turn, completion: answer, addContent: true})
+	m = next.(model)
+	cmdMessages(cmd)
+
+	if got := transcript(m); !slices.Equal(got, []string{"AI: " + answer}) {
+		t.Errorf("conversation shows %q", got)
+	}
+	if want := []string{"Gut gemacht, siehe link"}; !slices.Equal(speaker.spoken(), want) {
+		t.Errorf("spoke %q, want %q", speaker.spoken(), want)
+	}
+}
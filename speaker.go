@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"lazylang/piper"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Speaker reads text aloud, Speak blocks until playback finished or ctx was
+// cancelled
+type Speaker interface {
+	Speak(ctx context.Context, text string) error
+	IsSpeaking() bool
+}
+
+const elevenLabsAPIURL = "https://api.elevenlabs.io/v1/text-to-speech"
+
+// elevenLabsSampleRate matches the pcm_16000 output format
+const elevenLabsSampleRate = 16000
+
+// replayer is implemented by speakers caching their last utterance
+type replayer interface {
+	Replay(ctx context.Context) error
+}
+
+// ReplayAnswer plays the last spoken answer again from the cache
+func ReplayAnswer(speech *SpeechQueue, r replayer) tea.Cmd {
+	return func() tea.Msg {
+		err := speech.Do(r.Replay)
+		if _, ok := err.(piper.StoppedSpeaking); ok {
+			return ""
+		}
+		if errors.Is(err, piper.ErrNothingToReplay) {
+			return StatusChanged{status: "Nothing to replay"}
+		}
+		if err != nil {
+			slog.Error("Failed to replay", "error", err)
+			return StatusChanged{kind: statusError, status: "Failed to replay"}
+		}
+		return StatusChanged{kind: statusReady, status: "Ready"}
+	}
+}
+
+// replayAnswer handles R, only Piper caches its audio
+func (m *model) replayAnswer() tea.Cmd {
+	r, ok := m.speaker.(replayer)
+	if !ok {
+		m.UpdateStatus("Replay needs the piper tts backend")
+		return nil
+	}
+	m.speech.Clear()
+	m.setStatus(statusSpeaking, "Replaying answer")
+	return ReplayAnswer(m.speech, r)
+}
+
+// voiceSpeaker can speak with another voice than the configured one
+type voiceSpeaker interface {
+	SpeakVoice(ctx context.Context, text string, voice string) error
+}
+
+// speakerVoice is the Piper model for messages of role, your own lines use
+// the secondary voice when there is one
+func (m model) speakerVoice(role Role) string {
+	voice := m.config.TTSBackend.SecondaryVoice
+	if role != RoleUser || voice == "" {
+		return ""
+	}
+	if !strings.HasSuffix(voice, ".onnx") {
+		voice += ".onnx"
+	}
+	return voice
+}
+
+// speakFocusedSentence reads the sentence under the focus aloud, student
+// lines too so they can be compared with the teacher
+func (m *model) speakFocusedSentence() tea.Cmd {
+	sentence, msg, ok := m.focusedSentence()
+	if !ok {
+		m.UpdateStatus("Nothing to speak")
+		return nil
+	}
+	m.speech.Clear()
+	m.setStatus(statusSpeaking, fmt.Sprintf("Replaying %s: %s", msg.Role, sentence))
+	speaker, speech, voice := m.speaker, m.speech, m.speakerVoice(msg.Role)
+	return func() tea.Msg {
+		err := speech.Do(func(ctx context.Context) error {
+			if s, ok := speaker.(voiceSpeaker); ok && voice != "" {
+				return s.SpeakVoice(ctx, speakableText(sentence), voice)
+			}
+			return speaker.Speak(ctx, speakableText(sentence))
+		})
+		switch err := err.(type) {
+		case piper.StoppedSpeaking:
+			return ""
+		case piper.ErrorModelNotFound:
+			// Nothing to speak once downloaded, s plays the sentence then
+			return DownloadModel{model: err.Model, language: err.Language}
+		}
+		if err != nil {
+			slog.Error("Failed to speak", "error", err)
+			return StatusChanged{kind: statusError, status: "Failed to speak"}
+		}
+		return StatusChanged{kind: statusReady, status: "Ready"}
+	}
+}
+
+// scaledSpeaker can speak at another rate than the configured one
+type scaledSpeaker interface {
+	SpeakScaled(ctx context.Context, text string, lengthScale float64) error
+}
+
+// wordRepeatPause separates the two repetitions of a practised word
+const wordRepeatPause = 700 * time.Millisecond
+
+// pronounceFocusedWord handles p, it says the focused word twice at the word
+// practice rate
+func (m *model) pronounceFocusedWord() tea.Cmd {
+	word := isAlpha.FindString(m.getFocusedWord())
+	if word == "" {
+		m.UpdateStatus("Nothing to pronounce")
+		return nil
+	}
+	speaker, ok := m.speaker.(scaledSpeaker)
+	if !ok {
+		m.UpdateStatus("Slow pronunciation needs the piper tts backend")
+		return nil
+	}
+	m.speech.Clear()
+	m.setStatus(statusSpeaking, "Pronouncing "+word)
+	speech, lengthScale := m.speech, 1/m.config.TTSBackend.WordPracticeRate
+	return func() tea.Msg {
+		err := speech.Do(func(ctx context.Context) error {
+			if err := speaker.SpeakScaled(ctx, word, lengthScale); err != nil {
+				return err
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(wordRepeatPause):
+			}
+			return speaker.SpeakScaled(ctx, word, lengthScale)
+		})
+		switch err := err.(type) {
+		case piper.StoppedSpeaking:
+			return ""
+		case piper.ErrorModelNotFound:
+			return DownloadModel{model: err.Model, language: err.Language}
+		}
+		if err != nil {
+			slog.Error("Failed to speak", "error", err)
+			return StatusChanged{kind: statusError, status: "Failed to speak"}
+		}
+		return StatusChanged{kind: statusReady, status: "Ready"}
+	}
+}
+
+const (
+	speechRateStep = 0.1
+	volumeStep     = 0.1
+)
+
+// toggleMute stops speaking answers, the current one is cut off. Answers
+// arriving while muted are never spoken, also not after unmuting
+func (m *model) toggleMute() {
+	m.config.Muted = !m.config.Muted
+	if !m.config.Muted {
+		m.UpdateStatus("Unmuted")
+		return
+	}
+	m.speech.Clear()
+	// The answer just cut off may still count as speaking
+	m.status = Status{kind: statusInfo, text: "Muted", at: time.Now()}
+}
+
+func (m *model) setVolume(volume float64) {
+	m.config.Volume = piper.SetVolume(volume)
+	m.UpdateStatus(fmt.Sprintf("Volume: %.0f%%", m.config.Volume*100))
+}
+
+// setSpeechRate changes the rate of the following answers, only Piper
+// supports it
+func (m *model) setSpeechRate(rate float64) {
+	voice, ok := m.speaker.(*piper.PiperVoice)
+	if !ok {
+		m.UpdateStatus("Speech rate needs the piper tts backend")
+		return
+	}
+	m.config.TTSBackend.SpeechRate = 1 / voice.SetLengthScale(1/rate)
+	m.UpdateStatus(fmt.Sprintf("Speech rate: %.1fx", m.config.TTSBackend.SpeechRate))
+}
+
+// NewSpeaker builds the speaker selected by the tts_backend config, when
+// piper-tts is missing the fallback backend is used if there is one and
+// warning explains how to install it
+func NewSpeaker(backend TTSBackend, language string) (speaker Speaker, warning string, err error) {
+	if isPiper(backend) && !piper.Installed() {
+		if backend.Fallback != nil {
+			slog.Warn("piper-tts not found, using the fallback tts backend", "type", backend.Fallback.Type)
+			speaker, err := newSpeaker(*backend.Fallback, language)
+			return speaker, "", err
+		}
+		warning = "piper-tts not found, answers are not spoken. Install it with `pip install piper-tts`"
+	}
+	speaker, err = newSpeaker(backend, language)
+	return speaker, warning, err
+}
+
+func isPiper(backend TTSBackend) bool {
+	return backend.Type == "" || backend.Type == "piper"
+}
+
+func newSpeaker(backend TTSBackend, language string) (Speaker, error) {
+	switch backend.Type {
+	case "", "piper":
+		dir, err := voicesDir(backend.VoicesDir)
+		if err != nil {
+			return nil, err
+		}
+		options := []piper.PiperOption{piper.WithModel(backend.Voice), piper.WithLanguage(language), piper.WithLengthScale(1 / backend.SpeechRate)}
+		if backend.Server {
+			options = append(options, piper.WithServer())
+		}
+		if backend.Speaker != "" {
+			id, err := piper.ResolveSpeaker(dir, backend.Voice, backend.Speaker)
+			if err != nil {
+				return nil, err
+			}
+			options = append(options, piper.WithSpeaker(id))
+		}
+		return piper.NewPiperVoice(dir, options...), nil
+	case "elevenlabs":
+		env := backend.APIKeyEnv
+		if env == "" {
+			env = "ELEVENLABS_API_KEY"
+		}
+		apiKey := os.Getenv(env)
+		if apiKey == "" {
+			return nil, fmt.Errorf("%s environment variable not set for the elevenlabs tts backend", env)
+		}
+		if backend.Voice == "" {
+			return nil, fmt.Errorf("tts_backend.voice must be an ElevenLabs voice id")
+		}
+		model := backend.Model
+		if model == "" {
+			model = "eleven_multilingual_v2"
+		}
+		return &ElevenLabsSpeaker{apiKey: apiKey, voiceID: backend.Voice, model: model}, nil
+	case "espeak":
+		voice := backend.Voice
+		if voice == "" {
+			voice = language
+		}
+		return &EspeakSpeaker{voice: voice}, nil
+	case "openai":
+		env := backend.APIKeyEnv
+		if env == "" {
+			env = "OPENAI_API_KEY"
+		}
+		// Local servers usually run without a key
+		apiKey := os.Getenv(env)
+		baseURL := backend.BaseURL
+		if baseURL == "" {
+			baseURL = openAIAPIBaseURL
+		}
+		if baseURL == openAIAPIBaseURL && apiKey == "" {
+			return nil, fmt.Errorf("%s environment variable not set for the openai tts backend", env)
+		}
+		model := backend.Model
+		if model == "" {
+			model = "tts-1"
+		}
+		voice := backend.Voice
+		if voice == "" {
+			voice = "alloy"
+		}
+		format := backend.Format
+		if format == "" {
+			format = "pcm"
+		}
+		if format != "pcm" && format != "wav" {
+			return nil, fmt.Errorf("tts_backend.format must be pcm or wav, not %q", format)
+		}
+		return &OpenAISpeaker{baseURL: baseURL, apiKey: apiKey, model: model, voice: voice, format: format}, nil
+	default:
+		return nil, fmt.Errorf("unknown tts backend %q", backend.Type)
+	}
+}
+
+// ElevenLabsSpeaker streams speech from the ElevenLabs API to the playback
+// device
+type ElevenLabsSpeaker struct {
+	apiKey  string
+	voiceID string
+	model   string
+
+	speaking bool
+	mu       sync.RWMutex
+}
+
+type elevenLabsRequest struct {
+	Text    string `json:"text"`
+	ModelID string `json:"model_id"`
+}
+
+func (e *ElevenLabsSpeaker) IsSpeaking() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.speaking
+}
+
+func (e *ElevenLabsSpeaker) Speak(ctx context.Context, text string) error {
+	e.mu.Lock()
+	e.speaking = true
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.speaking = false
+		e.mu.Unlock()
+	}()
+
+	body, err := json.Marshal(elevenLabsRequest{Text: text, ModelID: e.model})
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/%s/stream?output_format=pcm_%d", elevenLabsAPIURL, e.voiceID, elevenLabsSampleRate)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("xi-api-key", e.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(resp.Body)
+		return SpeechAPIError{Provider: "ElevenLabs", StatusCode: resp.StatusCode, Body: string(message)}
+	}
+
+	return piper.Play(ctx, resp.Body, elevenLabsSampleRate, 1)
+}
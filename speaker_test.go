@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"lazylang/piper"
+	"slices"
+	"sync"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestVolumeKeys(t *testing.T) {
+	t.Cleanup(func() { piper.SetVolume(1) })
+	m := newTestModel(t)
+	up, down := tea.KeyMsg{Type: tea.KeyCtrlUp}, tea.KeyMsg{Type: tea.KeyCtrlDown}
+
+	m = update(t, m, up)
+	if m.status.text != "Volume: 110%" || piper.Volume() != m.config.Volume {
+		t.Errorf("status %q with volume %v", m.status.text, piper.Volume())
+	}
+	for range 20 {
+		m = update(t, m, up)
+	}
+	if m.config.Volume != piper.MaxVolume || m.status.text != "Volume: 200%" {
+		t.Errorf("volume went past the maximum: %v", m.config.Volume)
+	}
+	for range 30 {
+		m = update(t, m, down)
+	}
+	if m.config.Volume != piper.MinVolume || m.status.text != "Volume: 0%" {
+		t.Errorf("volume went below the minimum: %v", m.config.Volume)
+	}
+}
+
+// fakeSpeaker remembers what it was asked to say
+type fakeSpeaker struct {
+	mu   sync.Mutex
+	said []string
+}
+
+func (s *fakeSpeaker) Speak(ctx context.Context, text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.said = append(s.said, text)
+	return nil
+}
+
+func (s *fakeSpeaker) IsSpeaking() bool {
+	return false
+}
+
+func (s *fakeSpeaker) spoken() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.said)
+}
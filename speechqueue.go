@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"lazylang/piper"
+	"sync"
+)
+
+// SpeechQueue plays utterances one after another, so an answer arriving while
+// the previous one is spoken waits instead of playing over it
+type SpeechQueue struct {
+	mu      sync.Mutex
+	pending []*utterance
+	current *utterance
+	running bool
+}
+
+type utterance struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	play   func(ctx context.Context) error
+	done   chan error
+}
+
+func NewSpeechQueue() *SpeechQueue {
+	return &SpeechQueue{}
+}
+
+// Do queues play and blocks until it finished, it returns
+// piper.StoppedSpeaking when Clear dropped or interrupted it
+func (q *SpeechQueue) Do(play func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	u := &utterance{ctx: ctx, cancel: cancel, play: play, done: make(chan error, 1)}
+
+	q.mu.Lock()
+	q.pending = append(q.pending, u)
+	if !q.running {
+		q.running = true
+		go q.run()
+	}
+	q.mu.Unlock()
+
+	return <-u.done
+}
+
+func (q *SpeechQueue) run() {
+	for {
+		q.mu.Lock()
+		u := q.pending[0]
+		q.pending = q.pending[1:]
+		q.current = u
+		q.mu.Unlock()
+
+		var err error
+		if u.ctx.Err() == nil {
+			err = u.play(u.ctx)
+		}
+		if u.ctx.Err() != nil {
+			err = piper.StoppedSpeaking{}
+		}
+		u.cancel()
+
+		// The queue stops counting as speaking before the result is
+		// delivered, so the Ready status that follows isn't swallowed
+		q.mu.Lock()
+		q.current = nil
+		running := len(q.pending) > 0
+		q.running = running
+		q.mu.Unlock()
+
+		u.done <- err
+		if !running {
+			return
+		}
+	}
+}
+
+// Clear stops the current utterance and drops the queued ones
+func (q *SpeechQueue) Clear() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.current != nil {
+		q.current.cancel()
+	}
+	for _, u := range q.pending {
+		u.cancel()
+	}
+}
+
+// IsSpeaking is true while an utterance plays or waits
+func (q *SpeechQueue) IsSpeaking() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.running
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"lazylang/piper"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeSynthesizer plays utterances until they are released or cancelled and
+// records the order they played in
+type fakeSynthesizer struct {
+	mu      sync.Mutex
+	played  []string
+	playing atomic.Int32
+	overlap atomic.Bool
+	// started receives the text of every utterance that starts playing
+	started chan string
+	release chan struct{}
+}
+
+func newFakeSynthesizer() *fakeSynthesizer {
+	return &fakeSynthesizer{started: make(chan string, 100), release: make(chan struct{})}
+}
+
+// play is an utterance blocking until released or cancelled
+func (s *fakeSynthesizer) play(text string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if s.playing.Add(1) > 1 {
+			s.overlap.Store(true)
+		}
+		defer s.playing.Add(-1)
+		s.started <- text
+		select {
+		case <-s.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		s.mu.Lock()
+		s.played = append(s.played, text)
+		s.mu.Unlock()
+		return nil
+	}
+}
+
+func (s *fakeSynthesizer) order() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.played)
+}
+
+// speak queues text in the background, its result is sent on the channel
+func speak(q *SpeechQueue, s *fakeSynthesizer, text string) <-chan error {
+	result := make(chan error, 1)
+	go func() { result <- q.Do(s.play(text)) }()
+	return result
+}
+
+// waitQueued waits until n utterances wait behind the playing one
+func waitQueued(t *testing.T, q *SpeechQueue, n int) {
+	t.Helper()
+	within(t, "queueing", func() {
+		for {
+			q.mu.Lock()
+			queued := len(q.pending)
+			q.mu.Unlock()
+			if queued == n {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+}
+
+func TestSpeechQueuePlaysInOrder(t *testing.T) {
+	q, s := NewSpeechQueue(), newFakeSynthesizer()
+	if q.IsSpeaking() {
+		t.Fatal("an empty queue is speaking")
+	}
+
+	first := speak(q, s, "eins")
+	<-s.started
+	second := speak(q, s, "zwei")
+	waitQueued(t, q, 1)
+	third := speak(q, s, "drei")
+	waitQueued(t, q, 2)
+	if !q.IsSpeaking() {
+		t.Error("not speaking with three utterances queued")
+	}
+
+	for range 3 {
+		s.release <- struct{}{}
+	}
+	for _, result := range []<-chan error{first, second, third} {
+		if err := <-result; err != nil {
+			t.Errorf("utterance failed: %v", err)
+		}
+	}
+	if want := []string{"eins", "zwei", "drei"}; !slices.Equal(s.order(), want) {
+		t.Errorf("played %q, want %q", s.order(), want)
+	}
+	if s.overlap.Load() {
+		t.Error("utterances overlapped")
+	}
+	if q.IsSpeaking() {
+		t.Error("still speaking after the queue ran out")
+	}
+}
+
+func TestSpeechQueueClear(t *testing.T) {
+	q, s := NewSpeechQueue(), newFakeSynthesizer()
+	current := speak(q, s, "eins")
+	<-s.started
+	pending := speak(q, s, "zwei")
+	waitQueued(t, q, 1)
+
+	q.Clear()
+	for _, result := range []<-chan error{current, pending} {
+		var stopped piper.StoppedSpeaking
+		if err := <-result; !errors.As(err, &stopped) {
+			t.Errorf("cleared utterance gave %v", err)
+		}
+	}
+	if len(s.order()) != 0 {
+		t.Errorf("played %q after Clear", s.order())
+	}
+	select {
+	case text := <-s.started:
+		t.Errorf("the dropped utterance %q started", text)
+	default:
+	}
+	within(t, "the queue stopping", func() {
+		for q.IsSpeaking() {
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	// The queue plays again after a Clear
+	next := speak(q, s, "drei")
+	<-s.started
+	s.release <- struct{}{}
+	if err := <-next; err != nil {
+		t.Errorf("utterance after Clear failed: %v", err)
+	}
+}
+
+func TestSpeechQueueError(t *testing.T) {
+	q := NewSpeechQueue()
+	failure := errors.New("piper crashed")
+	if err := q.Do(func(ctx context.Context) error { return failure }); !errors.Is(err, failure) {
+		t.Errorf("Do gave %v", err)
+	}
+	if err := q.Do(func(ctx context.Context) error { return nil }); err != nil {
+		t.Errorf("the queue doesn't play after an error: %v", err)
+	}
+}
+
+// TestSpeechQueueConcurrent is meant for the race detector, utterances are
+// queued and cleared from many goroutines
+func TestSpeechQueueConcurrent(t *testing.T) {
+	q := NewSpeechQueue()
+	var playing atomic.Int32
+	var overlap atomic.Bool
+	play := func(ctx context.Context) error {
+		if playing.Add(1) > 1 {
+			overlap.Store(true)
+		}
+		defer playing.Add(-1)
+		select {
+		case <-time.After(time.Millisecond):
+		case <-ctx.Done():
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.Do(play)
+			if i%10 == 0 {
+				q.Clear()
+			}
+			q.IsSpeaking()
+		}()
+	}
+	within(t, "the utterances", wg.Wait)
+	if overlap.Load() {
+		t.Error("utterances overlapped")
+	}
+	if q.IsSpeaking() {
+		t.Error("still speaking after every utterance returned")
+	}
+}
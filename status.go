@@ -0,0 +1,103 @@
+package main
+
+import (
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+)
+
+// statusKind tells what the status is about, it decides how the status is
+// shown and what may replace it
+type statusKind int
+
+const (
+	// statusInfo is feedback on a key or a command
+	statusInfo statusKind = iota
+	// statusReady waits for the student
+	statusReady
+	// statusBusy waits for a transcription, an answer, a translation or a
+	// download, it is shown with a spinner
+	statusBusy
+	statusSpeaking
+	statusRecording
+	statusError
+)
+
+// errorHold is how long an error stays before a less important status
+// replaces it while audio is recorded or spoken
+const errorHold = 3 * time.Second
+
+// Status is the line shown at the right of the header
+type Status struct {
+	kind statusKind
+	text string
+	// at is when the status was set
+	at time.Time
+}
+
+// priority orders the kinds shown while audio is recorded or spoken, a status
+// is only replaced by one at least as important
+func (k statusKind) priority() int {
+	switch k {
+	case statusBusy:
+		return 1
+	case statusSpeaking:
+		return 2
+	case statusRecording:
+		return 3
+	case statusError:
+		return 4
+	}
+	return 0
+}
+
+// replacedBy reports whether next may replace the status. Any status replaces
+// another when no audio is active. While audio is recorded or spoken errors
+// always show, a fresh error stays errorHold and other statuses need to be at
+// least as important as the shown one, so the recording time isn't hidden by
+// feedback but a failure is never dropped
+func (s Status) replacedBy(next Status, audioActive bool) bool {
+	switch {
+	case !audioActive, next.kind == statusError:
+		return true
+	case s.kind == statusError:
+		return next.at.Sub(s.at) >= errorHold
+	}
+	return next.kind.priority() >= s.kind.priority()
+}
+
+// UpdateStatus shows feedback on a key or a command
+func (m *model) UpdateStatus(text string) {
+	m.setStatus(statusInfo, text)
+}
+
+// setStatus shows a status of the kind unless the shown one is more important
+func (m *model) setStatus(kind statusKind, text string) {
+	next := Status{kind: kind, text: text, at: time.Now()}
+	if !m.status.replacedBy(next, m.recorder.IsRecording() || m.speech.IsSpeaking()) {
+		return
+	}
+	m.status = next
+}
+
+func newStatusSpinner() spinner.Model {
+	return spinner.New(spinner.WithSpinner(spinner.MiniDot), spinner.WithStyle(styles.busyStatus))
+}
+
+// statusView renders the status in the color of its kind, a recording gets
+// a red dot and a busy status the spinner
+func (m model) statusView() string {
+	switch m.status.kind {
+	case statusReady:
+		return styles.readyStatus.Render(m.status.text)
+	case statusBusy:
+		return m.spinner.View() + " " + styles.busyStatus.Render(m.status.text)
+	case statusSpeaking:
+		return styles.speakingStatus.Render(m.status.text)
+	case statusRecording:
+		return styles.recordStatus.Render("● " + m.status.text)
+	case statusError:
+		return styles.errorStatus.Render(m.status.text)
+	}
+	return m.status.text
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+)
+
+func TestStatusReplacedBy(t *testing.T) {
+	start := time.Now()
+	status := func(kind statusKind, after time.Duration) Status {
+		return Status{kind: kind, text: "status", at: start.Add(after)}
+	}
+
+	tests := []struct {
+		name        string
+		shown, next Status
+		audio       bool
+		want        bool
+	}{
+		{"anything without audio", status(statusError, 0), status(statusInfo, time.Millisecond), false, true},
+		{"info hides no recording", status(statusRecording, 0), status(statusInfo, time.Second), true, false},
+		{"busy hides no speech", status(statusSpeaking, 0), status(statusBusy, time.Second), true, false},
+		{"recording replaces speech", status(statusSpeaking, 0), status(statusRecording, time.Second), true, true},
+		{"recording time updates", status(statusRecording, 0), status(statusRecording, time.Second), true, true},
+		{"error replaces recording", status(statusRecording, 0), status(statusError, time.Millisecond), true, true},
+		{"error replaces error", status(statusError, 0), status(statusError, time.Millisecond), true, true},
+		{"fresh error stays", status(statusError, 0), status(statusRecording, errorHold-time.Millisecond), true, false},
+		{"held error is replaced", status(statusError, 0), status(statusRecording, errorHold), true, true},
+		{"held error is replaced by info", status(statusError, 0), status(statusInfo, errorHold), true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.shown.replacedBy(tt.next, tt.audio); got != tt.want {
+				t.Errorf("replacedBy = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// newRecordingModel is recording until the test ends
+func newRecordingModel(t *testing.T) model {
+	t.Helper()
+	m := newTestModel(t)
+	m.recorder = NewRecorder(WithAudioContext(newFakeAudio(pcm(1))))
+	result := record(m.recorder)
+	waitRecording(t, m.recorder)
+	t.Cleanup(func() {
+		m.recorder.Stop()
+		<-result
+	})
+	return m
+}
+
+func TestErrorHoldsWhileRecording(t *testing.T) {
+	m := newRecordingModel(t)
+	m.setStatus(statusRecording, "0:01")
+
+	m.UpdateStatus("Copied")
+	if m.status.text != "0:01" {
+		t.Errorf("feedback hid the recording time: %q", m.status.text)
+	}
+
+	m.setStatus(statusError, "Failed to translate")
+	m.setStatus(statusRecording, "0:02")
+	if m.status.kind != statusError || m.status.text != "Failed to translate" {
+		t.Errorf("the recording time hid a fresh error: %q", m.status.text)
+	}
+
+	// Once the error was shown long enough the recording time is back
+	m.status.at = m.status.at.Add(-errorHold)
+	m.setStatus(statusRecording, "0:05")
+	if m.status.kind != statusRecording || m.status.text != "0:05" {
+		t.Errorf("status after the error expired is %q", m.status.text)
+	}
+}
+
+func TestErrorIsReplacedWithoutAudio(t *testing.T) {
+	m := newTestModel(t)
+	m.setStatus(statusError, "Failed to translate")
+	m.setStatus(statusReady, "Ready")
+	if m.status.kind != statusReady {
+		t.Errorf("status is %q", m.status.text)
+	}
+}
+
+func TestStatusChangedWhileRecording(t *testing.T) {
+	m := newRecordingModel(t)
+	m.setStatus(statusRecording, "0:01")
+
+	m = update(t, m, StatusChanged{kind: statusBusy, status: "Translating"})
+	if m.status.kind != statusRecording {
+		t.Errorf("a busy status hid the recording: %q", m.status.text)
+	}
+	m = update(t, m, StatusChanged{kind: statusError, status: "Translation timed out"})
+	if m.status.kind != statusError || m.status.text != "Translation timed out" {
+		t.Errorf("an error was dropped while recording: %q", m.status.text)
+	}
+}
+
+func TestSpinnerTicksWhileBusy(t *testing.T) {
+	m := newTestModel(t)
+	tick := spinner.TickMsg{ID: m.spinner.ID()}
+
+	m.setStatus(statusReady, "Ready")
+	next, cmd := m.Update(tick)
+	m = next.(model)
+	if m.spinning || cmd != nil {
+		t.Fatal("the spinner kept ticking after the busy status")
+	}
+
+	next, cmd = m.Update(StatusChanged{kind: statusBusy, status: "Translating"})
+	m = next.(model)
+	if !m.spinning || cmd == nil {
+		t.Fatal("the spinner didn't start with a busy status")
+	}
+	if !strings.Contains(m.statusView(), m.spinner.View()) {
+		t.Errorf("busy status %q has no spinner", m.statusView())
+	}
+
+	// A second busy status doesn't start a second spinner
+	next, cmd = m.Update(StatusChanged{kind: statusBusy, status: "Downloading"})
+	m = next.(model)
+	if cmd != nil {
+		t.Error("a second busy status started another spinner")
+	}
+	next, cmd = m.Update(tick)
+	if !next.(model).spinning || cmd == nil {
+		t.Error("the spinner stopped while busy")
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Theme colors the interface. Colors are ANSI numbers such as "205" or hex
+// colors such as "#ff5f87", empty ones are taken from the preset
+type Theme struct {
+	// Preset is "dark" or "light", empty picks one from the background of
+	// the terminal at start
+	Preset    string `json:"preset,omitempty"`
+	Focus     string `json:"focus,omitempty"`
+	UserLabel string `json:"user_label,omitempty"`
+	AILabel   string `json:"ai_label,omitempty"`
+	// Accent colors the translations and the choice between them
+	Accent  string `json:"accent,omitempty"`
+	Warning string `json:"warning,omitempty"`
+	// Dim colors borders and text around the conversation
+	Dim       string `json:"dim,omitempty"`
+	Selection string `json:"selection,omitempty"`
+	Ready     string `json:"ready,omitempty"`
+	Busy      string `json:"busy,omitempty"`
+	Speaking  string `json:"speaking,omitempty"`
+	Recording string `json:"recording,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// BorderStyle is "rounded", "normal", "thick", "double" or "ascii"
+	BorderStyle string `json:"border_style,omitempty"`
+}
+
+var themePresets = map[string]Theme{
+	"dark": {
+		Focus:       "205",
+		UserLabel:   "39",
+		AILabel:     "141",
+		Accent:      "86",
+		Warning:     "214",
+		Dim:         "243",
+		Selection:   "238",
+		Ready:       "42",
+		Busy:        "214",
+		Speaking:    "86",
+		Recording:   "196",
+		Error:       "196",
+		BorderStyle: "rounded",
+	},
+	"light": {
+		Focus:       "161",
+		UserLabel:   "25",
+		AILabel:     "91",
+		Accent:      "30",
+		Warning:     "130",
+		Dim:         "246",
+		Selection:   "253",
+		Ready:       "28",
+		Busy:        "130",
+		Speaking:    "30",
+		Recording:   "160",
+		Error:       "160",
+		BorderStyle: "rounded",
+	},
+}
+
+// borderStyles are the borders of border_style, tee joins the title to the
+// line under the header
+var borderStyles = map[string]struct {
+	border lipgloss.Border
+	tee    string
+}{
+	"rounded": {lipgloss.RoundedBorder(), "┴"},
+	"normal":  {lipgloss.NormalBorder(), "┴"},
+	"thick":   {lipgloss.ThickBorder(), "┻"},
+	"double":  {lipgloss.DoubleBorder(), "╩"},
+	"ascii":   {lipgloss.ASCIIBorder(), "+"},
+}
+
+// darkBackground picks the dark preset when none is set, it is detected once
+// at start because asking the terminal later would race the program for its
+// input
+var darkBackground = true
+
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// validColor reports whether lipgloss understands the color
+func validColor(color string) bool {
+	if hexColor.MatchString(color) {
+		return true
+	}
+	n, err := strconv.Atoi(color)
+	return err == nil && n >= 0 && n <= 255
+}
+
+// colors lists the colors of the theme with their names in the config
+func (t *Theme) colors() []struct {
+	name  string
+	color *string
+} {
+	return []struct {
+		name  string
+		color *string
+	}{
+		{"focus", &t.Focus}, {"user_label", &t.UserLabel}, {"ai_label", &t.AILabel},
+		{"accent", &t.Accent}, {"warning", &t.Warning}, {"dim", &t.Dim},
+		{"selection", &t.Selection}, {"ready", &t.Ready}, {"busy", &t.Busy},
+		{"speaking", &t.Speaking}, {"recording", &t.Recording}, {"error", &t.Error},
+	}
+}
+
+// resolve fills the theme from its preset, invalid values keep the preset's
+// and are returned as problems
+func (t Theme) resolve(dark bool) (Theme, []string) {
+	var problems []string
+	name := t.Preset
+	if _, ok := themePresets[name]; !ok {
+		if name != "" {
+			problems = append(problems, fmt.Sprintf("theme.preset %q is not dark or light", name))
+		}
+		name = "light"
+		if dark {
+			name = "dark"
+		}
+	}
+	resolved := themePresets[name]
+	resolved.Preset = name
+
+	colors := resolved.colors()
+	for i, c := range t.colors() {
+		switch {
+		case *c.color == "":
+		case !validColor(*c.color):
+			problems = append(problems, fmt.Sprintf("theme.%s %q is not a color, using %s", c.name, *c.color, *colors[i].color))
+		default:
+			*colors[i].color = *c.color
+		}
+	}
+	if t.BorderStyle != "" {
+		if _, ok := borderStyles[t.BorderStyle]; ok {
+			resolved.BorderStyle = t.BorderStyle
+		} else {
+			problems = append(problems, fmt.Sprintf("theme.border_style %q is not one of rounded, normal, thick, double, ascii", t.BorderStyle))
+		}
+	}
+	return resolved, problems
+}
+
+// themeStyles are every style of the interface, built from the theme in one
+// place
+type themeStyles struct {
+	focus          lipgloss.Style
+	selection      lipgloss.Style
+	userLabel      lipgloss.Style
+	aiLabel        lipgloss.Style
+	aiBorder       lipgloss.Style
+	gloss          lipgloss.Style
+	choice         lipgloss.Style
+	sentence       lipgloss.Style
+	translation    lipgloss.Style
+	confirm        lipgloss.Style
+	inputError     lipgloss.Style
+	warning        lipgloss.Style
+	matchedWord    lipgloss.Style
+	mismatchedWord lipgloss.Style
+	readyStatus    lipgloss.Style
+	busyStatus     lipgloss.Style
+	speakingStatus lipgloss.Style
+	recordStatus   lipgloss.Style
+	errorStatus    lipgloss.Style
+	title          lipgloss.Style
+	help           lipgloss.Style
+	helpKey        lipgloss.Style
+	activeTab      lipgloss.Style
+	sidebar        lipgloss.Style
+	// line draws the line under the header
+	line string
+}
+
+var styles = newStyles(themePresets["dark"])
+
+func newStyles(t Theme) themeStyles {
+	color := func(c string) lipgloss.Style {
+		return lipgloss.NewStyle().Foreground(lipgloss.Color(c))
+	}
+	border := borderStyles[t.BorderStyle]
+	title := border.border
+	title.BottomRight = border.tee
+	return themeStyles{
+		focus:          color(t.Focus),
+		selection:      lipgloss.NewStyle().Background(lipgloss.Color(t.Selection)),
+		userLabel:      color(t.UserLabel).Bold(true),
+		aiLabel:        color(t.AILabel).Bold(true),
+		aiBorder:       color(t.Dim),
+		gloss:          lipgloss.NewStyle().Faint(true),
+		choice:         color(t.Accent),
+		sentence:       color(t.Dim),
+		translation:    color(t.Accent),
+		confirm:        color(t.Warning),
+		inputError:     color(t.Error),
+		warning:        color(t.Warning).Bold(true),
+		matchedWord:    color(t.Ready),
+		mismatchedWord: color(t.Error),
+		readyStatus:    color(t.Ready),
+		busyStatus:     color(t.Busy),
+		speakingStatus: color(t.Speaking),
+		recordStatus:   color(t.Recording),
+		errorStatus:    color(t.Error).Bold(true),
+		title:          lipgloss.NewStyle().BorderStyle(title).Padding(0, 1),
+		help:           lipgloss.NewStyle().Border(border.border).BorderForeground(lipgloss.Color(t.Dim)).Padding(0, 1),
+		helpKey:        lipgloss.NewStyle().Bold(true),
+		activeTab:      lipgloss.NewStyle().Reverse(true),
+		sidebar:        lipgloss.NewStyle().Border(border.border, false, false, false, true).BorderForeground(lipgloss.Color(t.Dim)),
+		line:           border.border.Bottom,
+	}
+}
+
+// applyTheme rebuilds the styles from the theme config, the problems are
+// shown as warnings
+func applyTheme(t Theme) []string {
+	resolved, problems := t.resolve(darkBackground)
+	styles = newStyles(resolved)
+	return problems
+}
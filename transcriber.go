@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var groqAudioAPIURL = fmt.Sprintf("%v/audio/transcriptions", groqAPIBaseURL)
+
+const openAIAudioAPIURL = "https://api.openai.com/v1/audio/transcriptions"
+
+// GroqKeyChecked is the result of checking the Groq key after the start, so
+// being offline doesn't keep LazyLang from starting
+type GroqKeyChecked struct {
+	err error
+}
+
+func checkGroqKey(config Config, apiKey string) tea.Cmd {
+	return func() tea.Msg {
+		return GroqKeyChecked{err: isValid(config, apiKey)}
+	}
+}
+
+// groqKeyChecked shows a failed check above the header, transcription is
+// only turned off for a rejected key as Groq may be reachable again later
+func (m *model) groqKeyChecked(msg GroqKeyChecked) {
+	var warning string
+	var netErr net.Error
+	switch {
+	case msg.err == nil:
+		return
+	case errors.Is(msg.err, invalidApiKey):
+		m.transcriptionDisabled = true
+		warning = "Groq key invalid — transcription disabled"
+	case errors.As(msg.err, &netErr):
+		warning = "Groq unreachable — transcription fails until it is back"
+	default:
+		warning = fmt.Sprintf("Groq check failed: %v — transcription may fail", msg.err)
+	}
+	m.addWarning(warning)
+}
+
+// Transcriber turns a WAV recording into text, prompt is recent conversation
+// text biasing the recognition towards its vocabulary and may be empty
+type Transcriber interface {
+	Transcribe(ctx context.Context, wav []byte, language string, prompt string) (Transcription, error)
+}
+
+type Transcription struct {
+	Text string
+	// Confidence is between 0 and 1, backends without segment data report 1
+	Confidence float64
+}
+
+// maxPromptLength keeps the prompt below the 224 token limit of Whisper
+const maxPromptLength = 600
+
+// whisperPrompt joins the text of the last messages, cut at a word boundary
+// so the most recent words are kept
+func whisperPrompt(messages []Message) string {
+	var texts []string
+	for _, msg := range messages {
+		if msg.Role == RoleUser || msg.Role == RoleAI {
+			texts = append(texts, msg.Text)
+		}
+	}
+	prompt := strings.Join(texts, " ")
+	if len(prompt) <= maxPromptLength {
+		return prompt
+	}
+	prompt = prompt[len(prompt)-maxPromptLength:]
+	if i := strings.IndexByte(prompt, ' '); i >= 0 {
+		prompt = prompt[i+1:]
+	}
+	return prompt
+}
+
+// NewTranscriber builds the transcriber selected by the stt_backend config,
+// apiKey is the Groq key, the other providers read their own variable
+func NewTranscriber(backend STTBackend, apiKey string) (Transcriber, error) {
+	switch backend.Type {
+	case "", "hosted", "groq":
+		if backend.APIKeyEnv != "" {
+			apiKey = os.Getenv(backend.APIKeyEnv)
+		}
+		return &WhisperTranscriber{url: groqAudioAPIURL, apiKey: apiKey, model: backend.Model, uploadFormat: backend.UploadFormat}, nil
+	case "openai":
+		apiKey, err := backendAPIKey(backend, "OPENAI_API_KEY")
+		if err != nil {
+			return nil, err
+		}
+		model := backend.Model
+		if model == "" {
+			model = "whisper-1"
+		}
+		return &WhisperTranscriber{url: openAIAudioAPIURL, apiKey: apiKey, model: model, uploadFormat: backend.UploadFormat}, nil
+	case "deepgram":
+		apiKey, err := backendAPIKey(backend, "DEEPGRAM_API_KEY")
+		if err != nil {
+			return nil, err
+		}
+		model := backend.Model
+		if model == "" {
+			model = "nova-2"
+		}
+		return &DeepgramTranscriber{url: deepgramAPIURL, apiKey: apiKey, model: model}, nil
+	default:
+		return nil, fmt.Errorf("unknown stt backend %q", backend.Type)
+	}
+}
+
+// backendAPIKey reads the key from api_key_env, falling back to the usual
+// variable of the provider
+func backendAPIKey(backend STTBackend, defaultEnv string) (string, error) {
+	env := backend.APIKeyEnv
+	if env == "" {
+		env = defaultEnv
+	}
+	apiKey := os.Getenv(env)
+	if apiKey == "" {
+		return "", fmt.Errorf("%s environment variable not set for the %s stt backend", env, backend.Type)
+	}
+	return apiKey, nil
+}
+
+// TranscriptionError is returned when the API answered with an error status
+type TranscriptionError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e TranscriptionError) Error() string {
+	return fmt.Sprintf("API error (status %d): %s", e.StatusCode, e.Body)
+}
+
+// isRetryable reports whether the transcription may succeed when sent again,
+// that is on rate limits, server errors, timeouts and network failures
+func isRetryable(err error) bool {
+	var apiErr TranscriptionError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr)
+}
+
+type GroqTranscriptionResponse struct {
+	Text     string        `json:"text"`
+	Segments []GroqSegment `json:"segments"`
+}
+
+type GroqSegment struct {
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	AvgLogprob float64 `json:"avg_logprob"`
+}
+
+// confidence is the token probability averaged over the segments, weighted by
+// their duration
+func (r GroqTranscriptionResponse) confidence() float64 {
+	var logprob, duration float64
+	for _, segment := range r.Segments {
+		d := max(segment.End-segment.Start, 0.01)
+		logprob += segment.AvgLogprob * d
+		duration += d
+	}
+	if duration == 0 {
+		return 1
+	}
+	return math.Exp(logprob / duration)
+}
+
+// WhisperTranscriber uses an OpenAI compatible transcription endpoint such as
+// the Whisper models hosted by Groq or OpenAI
+type WhisperTranscriber struct {
+	url    string
+	apiKey string
+	model  string
+	// uploadFormat is wav, flac or opus
+	uploadFormat string
+}
+
+// Transcribe sends audio to the API for transcription
+func (g *WhisperTranscriber) Transcribe(ctx context.Context, audioData []byte, language string, prompt string) (Transcription, error) {
+	audio := encodeForUpload(ctx, audioData, g.uploadFormat)
+
+	var requestBody bytes.Buffer
+	writer := multipart.NewWriter(&requestBody)
+
+	// Add audio file
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, audio.filename))
+	header.Set("Content-Type", audio.mimeType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return Transcription{}, fmt.Errorf("failed to create form file: %w", err)
+	}
+	_, err = part.Write(audio.data)
+	if err != nil {
+		return Transcription{}, fmt.Errorf("failed to write audio data: %w", err)
+	}
+
+	// Add model field
+	err = writer.WriteField("model", g.model)
+	if err != nil {
+		return Transcription{}, fmt.Errorf("failed to write model field: %w", err)
+	}
+
+	// Add Language field
+	err = writer.WriteField("language", language)
+	if err != nil {
+		return Transcription{}, fmt.Errorf("failed to write language field: %w", err)
+	}
+
+	if prompt != "" {
+		err = writer.WriteField("prompt", prompt)
+		if err != nil {
+			return Transcription{}, fmt.Errorf("failed to write prompt field: %w", err)
+		}
+	}
+
+	// Add response format
+	err = writer.WriteField("response_format", "verbose_json")
+	if err != nil {
+		return Transcription{}, fmt.Errorf("failed to write response_format field: %w", err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return Transcription{}, fmt.Errorf("failed to close writer: %w", err)
+	}
+
+	// Create request
+	req, err := http.NewRequestWithContext(ctx, "POST", g.url, &requestBody)
+	if err != nil {
+		return Transcription{}, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+g.apiKey)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	// Send request
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return Transcription{}, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Transcription{}, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return Transcription{}, TranscriptionError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var transcriptionResp GroqTranscriptionResponse
+	err = json.Unmarshal(body, &transcriptionResp)
+	if err != nil {
+		return Transcription{}, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return Transcription{Text: transcriptionResp.Text, Confidence: transcriptionResp.confidence()}, nil
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// fakeTranscriber answers every recording with text, or fails with err, and
+// remembers what it was sent
+type fakeTranscriber struct {
+	text       string
+	confidence float64
+	err        error
+
+	wav      []byte
+	language string
+	prompt   string
+	calls    int
+}
+
+func (f *fakeTranscriber) Transcribe(ctx context.Context, wav []byte, language string, prompt string) (Transcription, error) {
+	f.calls++
+	f.wav, f.language, f.prompt = wav, language, prompt
+	if f.err != nil {
+		return Transcription{}, f.err
+	}
+	return Transcription{Text: f.text, Confidence: f.confidence}, nil
+}
+
+func newTranscriberModel(t *testing.T, transcriber Transcriber, messages ...Message) model {
+	t.Helper()
+	config := NewConfig()
+	config.Theme.Preset = "dark"
+	config.Muted = true
+	m := newConfiguredTestModel(t, config, messages...)
+	m.transcriber = transcriber
+	return m
+}
+
+// cmdMessages runs cmd and the commands it batches
+func cmdMessages(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+	msg := cmd()
+	batch, ok := msg.(tea.BatchMsg)
+	if !ok {
+		return []tea.Msg{msg}
+	}
+	var msgs []tea.Msg
+	for _, cmd := range batch {
+		msgs = append(msgs, cmdMessages(cmd)...)
+	}
+	return msgs
+}
+
+func TestTranscriptionBecomesTurn(t *testing.T) {
+	fake := &fakeTranscriber{text: " Wie spät ist es? ", confidence: 0.9}
+	m := newTranscriberModel(t, fake,
+		Message{Role: RoleUser, Text: "Hallo"},
+		Message{Role: RoleAI, Text: "Guten Tag"},
+	)
+	wav := []byte("recording")
+
+	cmd := m.transcribe(wav)
+	if m.status.kind != statusBusy || m.status.text != "Transcribing" {
+		t.Errorf("status while transcribing is %q", m.status.text)
+	}
+	m = update(t, m, cmd())
+
+	if string(fake.wav) != "recording" {
+		t.Errorf("transcriber got %q", fake.wav)
+	}
+	if fake.language != languageCode(m.config.Language) {
+		t.Errorf("transcriber got language %q", fake.language)
+	}
+	if fake.prompt != "Hallo Guten Tag" {
+		t.Errorf("transcriber got prompt %q", fake.prompt)
+	}
+	want := []string{"You: Hallo", "AI: Guten Tag", "You: Wie spät ist es?"}
+	if got := transcript(m); !slices.Equal(got, want) {
+		t.Errorf("conversation %q, want %q", got, want)
+	}
+	if m.status.kind != statusBusy || m.status.text != "Waiting for the teacher" {
+		t.Errorf("status after the transcription is %q", m.status.text)
+	}
+}
+
+func TestTranscriptionWithoutPromptHint(t *testing.T) {
+	fake := &fakeTranscriber{text: "Danke", confidence: 0.9}
+	m := newTranscriberModel(t, fake, Message{Role: RoleUser, Text: "Hallo"})
+	m.config.STTBackend.PromptHint = false
+
+	m.transcribe([]byte("recording"))()
+	if fake.prompt != "" {
+		t.Errorf("transcriber got prompt %q", fake.prompt)
+	}
+}
+
+func TestUnsureTranscriptionIsHeldForReview(t *testing.T) {
+	fake := &fakeTranscriber{text: "Wie spät", confidence: 0.2}
+	m := newTranscriberModel(t, fake)
+
+	m = update(t, m, m.transcribe([]byte("recording"))())
+	if m.confirming == nil || m.confirming.text != "Wie spät" {
+		t.Fatalf("the unsure transcription wasn't held: %+v", m.confirming)
+	}
+	if got := transcript(m); len(got) != 0 {
+		t.Errorf("the unsure transcription was sent: %q", got)
+	}
+}
+
+func TestFailedTranscription(t *testing.T) {
+	fake := &fakeTranscriber{err: errors.New("bad request")}
+	m := newTranscriberModel(t, fake)
+
+	msg := m.transcribe([]byte("recording"))()
+	if _, ok := msg.(TranscriptionFailed); !ok {
+		t.Fatalf("a permanent error gave %T", msg)
+	}
+	m = update(t, m, msg)
+	if m.status.kind != statusError || m.status.text != "Transcription failed, press T to retry" {
+		t.Errorf("status is %q", m.status.text)
+	}
+	if len(m.Session.transcribing) != 0 {
+		t.Errorf("the failed recording still holds %v", m.Session.transcribing)
+	}
+}
+
+func TestRetryableTranscriptionError(t *testing.T) {
+	fake := &fakeTranscriber{err: TranscriptionError{StatusCode: http.StatusTooManyRequests, Body: "slow down"}}
+	m := newTranscriberModel(t, fake)
+	m.config.STTBackend.MaxRetries = 1
+
+	msg := m.transcribe([]byte("recording"))()
+	retry, ok := msg.(TranscriptionRetry)
+	if !ok {
+		t.Fatalf("a rate limit gave %T", msg)
+	}
+	if retry.attempt != 1 || string(retry.wav) != "recording" {
+		t.Errorf("retry is %+v", retry)
+	}
+	m = update(t, m, retry)
+	if m.status.text != "Transcription failed (retrying…)" {
+		t.Errorf("status while retrying is %q", m.status.text)
+	}
+
+	// The last attempt gives up
+	msg = m.transcribeAttempt(retry.sessionID, retry.turn, retry.attempt, retry.wav)()
+	if _, ok := msg.(TranscriptionFailed); !ok {
+		t.Errorf("the last attempt gave %T", msg)
+	}
+	if fake.calls != 2 {
+		t.Errorf("transcriber was called %d times", fake.calls)
+	}
+}
+
+func TestRetranscribeKey(t *testing.T) {
+	fake := &fakeTranscriber{text: "Noch einmal", confidence: 0.9}
+	m := newTranscriberModel(t, fake)
+	retranscribe := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(m.keymap.Key(ActionRetranscribe))}
+
+	m = update(t, m, retranscribe)
+	if fake.calls != 0 || m.status.text != "No recording to transcribe" {
+		t.Fatalf("without a recording the key gave %q", m.status.text)
+	}
+
+	m.lastRecording = []byte("recording")
+	next, cmd := m.Update(retranscribe)
+	m = next.(model)
+	for _, msg := range cmdMessages(cmd) {
+		m = update(t, m, msg)
+	}
+	if string(fake.wav) != "recording" {
+		t.Errorf("transcriber got %q", fake.wav)
+	}
+	if got := transcript(m); !slices.Equal(got, []string{"You: Noch einmal"}) {
+		t.Errorf("conversation %q", got)
+	}
+}
+
+func TestWhisperTranscribe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, _ := io.ReadAll(file); string(body) != "wav" || header.Header.Get("Content-Type") != "audio/wav" {
+			t.Errorf("file %q of type %q", body, header.Header.Get("Content-Type"))
+		}
+		fields := map[string]string{"model": "whisper-1", "language": "de", "prompt": "Hallo", "response_format": "verbose_json"}
+		for field, want := range fields {
+			if got := r.FormValue(field); got != want {
+				t.Errorf("%s = %q, want %q", field, got, want)
+			}
+		}
+		w.Write([]byte(`{"text": "Guten Morgen", "segments": [{"start": 0, "end": 1, "avg_logprob": -0.1}]}`))
+	}))
+	defer server.Close()
+
+	whisper := &WhisperTranscriber{url: server.URL, apiKey: "secret", model: "whisper-1"}
+	transcription, err := whisper.Transcribe(context.Background(), []byte("wav"), "de", "Hallo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transcription.Text != "Guten Morgen" || math.Abs(transcription.Confidence-math.Exp(-0.1)) > 1e-9 {
+		t.Errorf("transcription = %+v", transcription)
+	}
+}
+
+func TestWhisperWithoutSegments(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := r.MultipartForm.Value["prompt"]; ok {
+			t.Error("an empty prompt was sent")
+		}
+		w.Write([]byte(`{"text": "Hallo"}`))
+	}))
+	defer server.Close()
+
+	whisper := &WhisperTranscriber{url: server.URL, apiKey: "secret", model: "whisper-1"}
+	transcription, err := whisper.Transcribe(context.Background(), []byte("wav"), "de", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transcription.Text != "Hallo" || transcription.Confidence != 1 {
+		t.Errorf("transcription = %+v", transcription)
+	}
+}
+
+func TestWhisperErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		retryable bool
+		wantErr   string
+	}{
+		{"auth failure", http.StatusUnauthorized, `{"error": {"message": "Incorrect API key"}}`, false, "status 401"},
+		{"rate limit", http.StatusTooManyRequests, `{}`, true, "status 429"},
+		{"server error", http.StatusInternalServerError, `oops`, true, "status 500"},
+		{"malformed", http.StatusOK, `<html>`, false, "failed to parse response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			whisper := &WhisperTranscriber{url: server.URL, apiKey: "secret", model: "whisper-1"}
+			_, err := whisper.Transcribe(context.Background(), []byte("wav"), "de", "")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %v, want %q", err, tt.wantErr)
+			}
+			if isRetryable(err) != tt.retryable {
+				t.Errorf("retryable is %v", isRetryable(err))
+			}
+		})
+	}
+}
+
+func TestNewTranscriber(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "openai")
+	t.Setenv("DEEPGRAM_API_KEY", "deepgram")
+	t.Setenv("MY_KEY", "mine")
+	tests := []struct {
+		backend STTBackend
+		url     string
+		apiKey  string
+		model   string
+	}{
+		{STTBackend{Type: "hosted", Model: "whisper-large-v3"}, groqAudioAPIURL, "groq", "whisper-large-v3"},
+		{STTBackend{Type: "groq", APIKeyEnv: "MY_KEY"}, groqAudioAPIURL, "mine", ""},
+		{STTBackend{Type: "openai"}, openAIAudioAPIURL, "openai", "whisper-1"},
+		{STTBackend{Type: "openai", APIKeyEnv: "MY_KEY", Model: "gpt-4o-transcribe"}, openAIAudioAPIURL, "mine", "gpt-4o-transcribe"},
+		{STTBackend{Type: "deepgram"}, deepgramAPIURL, "deepgram", "nova-2"},
+	}
+	for _, tt := range tests {
+		transcriber, err := NewTranscriber(tt.backend, "groq")
+		if err != nil {
+			t.Fatalf("%+v: %v", tt.backend, err)
+		}
+		var url, apiKey, model string
+		switch transcriber := transcriber.(type) {
+		case *WhisperTranscriber:
+			url, apiKey, model = transcriber.url, transcriber.apiKey, transcriber.model
+		case *DeepgramTranscriber:
+			url, apiKey, model = transcriber.url, transcriber.apiKey, transcriber.model
+		}
+		if url != tt.url || apiKey != tt.apiKey || model != tt.model {
+			t.Errorf("%+v: %s with key %q and model %q", tt.backend, url, apiKey, model)
+		}
+	}
+
+	t.Setenv("DEEPGRAM_API_KEY", "")
+	if _, err := NewTranscriber(STTBackend{Type: "deepgram"}, "groq"); err == nil || !strings.Contains(err.Error(), "DEEPGRAM_API_KEY") {
+		t.Errorf("missing key gave %v", err)
+	}
+	if _, err := NewTranscriber(STTBackend{Type: "whisper.cpp"}, "groq"); err == nil {
+		t.Error("unknown backend was accepted")
+	}
+}
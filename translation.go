@@ -0,0 +1,465 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lazylang/piper"
+	"log/slog"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// TranslationReceived is the translations of a word or phrase, the best one
+// first
+type TranslationReceived struct {
+	Word         string
+	Translations []string
+	// Offline translations come from the dictionary
+	Offline bool
+	// Reverse translations are from the target translation language into the
+	// language
+	Reverse bool
+	// Source is the language detected for the word when it isn't the
+	// configured one
+	Source string
+	Err    error
+}
+
+// GlossReceived is the gloss of a message, found by its ID as the session may
+// have been cleared or grown while it was translated
+type GlossReceived struct {
+	sessionID int
+	messageID int
+	gloss     string
+}
+
+// translationAttempts is one try and one retry after a transient failure
+const translationAttempts = 2
+
+// maxConcurrentTranslations bounds the requests translateEach sends at once
+const maxConcurrentTranslations = 4
+
+// maxTranslationAlternatives is how many translations of a word are offered
+// to choose from
+const maxTranslationAlternatives = 3
+
+// Translator translates text between the configured languages, source and
+// target are config language codes such as de or pt-BR
+type Translator interface {
+	Translate(ctx context.Context, text string, source string, target string) (string, error)
+	// TranslateBatch translates the texts in as few requests as the provider
+	// allows, the results are in the order of the texts. The error is set
+	// when the whole batch failed
+	TranslateBatch(ctx context.Context, texts []string, source string, target string) ([]BatchTranslation, error)
+}
+
+// BatchTranslation is the translation of one text of a batch, or why it
+// failed
+type BatchTranslation struct {
+	Translation string
+	Err         error
+}
+
+// AlternativesTranslator is a Translator that also offers other translations
+// of a word, the best one comes first
+type AlternativesTranslator interface {
+	Translator
+	TranslateAlternatives(ctx context.Context, text string, source string, target string, count int) ([]string, error)
+}
+
+// The translators wrap these errors so the status tells the user what to fix
+var (
+	// errTranslationAuth is returned when the provider rejects the key or the
+	// basic auth credentials
+	errTranslationAuth = errors.New("the translation provider rejected the credentials")
+	// errTranslationQuota is returned when the character quota or the rate
+	// limit is exhausted
+	errTranslationQuota = errors.New("the translation quota is exhausted")
+	// errUnsupportedPair is returned when the provider doesn't translate
+	// between the languages
+	errUnsupportedPair = errors.New("the translation provider doesn't support the language pair")
+)
+
+// TranslationError is returned when the provider answered with an error
+// status the common errors don't cover
+type TranslationError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+}
+
+func (e TranslationError) Error() string {
+	return fmt.Sprintf("%s error (status %d): %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// UnsupportedPairError is returned when the provider doesn't translate from
+// Source into Target, Targets are the languages it translates Source into
+type UnsupportedPairError struct {
+	Provider string
+	Source   string
+	Target   string
+	Targets  []string
+}
+
+func (e UnsupportedPairError) Error() string {
+	if len(e.Targets) == 0 {
+		return fmt.Sprintf("%s doesn't translate from %s", e.Provider, e.Source)
+	}
+	return fmt.Sprintf("%s doesn't translate %s into %s, only into %s", e.Provider, e.Source, e.Target, strings.Join(e.Targets, ", "))
+}
+
+func (e UnsupportedPairError) Unwrap() error {
+	return errUnsupportedPair
+}
+
+// Hint tells which target_translation_language would work
+func (e UnsupportedPairError) Hint() string {
+	if len(e.Targets) == 0 {
+		return fmt.Sprintf("%s doesn't translate from %s, set another translation provider", e.Provider, e.Source)
+	}
+	return fmt.Sprintf("%s doesn't translate %s into %s, set target_translation_language to one of %s", e.Provider, e.Source, e.Target, strings.Join(e.Targets, ", "))
+}
+
+// NewTranslator builds the translator selected by translation.provider
+func NewTranslator(config Config) (Translator, error) {
+	switch config.Translation.Provider {
+	case "", "libretranslate":
+		return &LibreTranslator{
+			url:    libreTranslateURL(config),
+			apiKey: libreTranslateAPIKey(config),
+			auth:   config.LibreTranslateAuth,
+		}, nil
+	case "deepl":
+		apiKey, err := translationAPIKey(config.Translation, "DEEPL_AUTH_KEY")
+		if err != nil {
+			return nil, err
+		}
+		return &DeepLTranslator{url: deepLURL(apiKey), apiKey: apiKey}, nil
+	case "google":
+		apiKey, err := translationAPIKey(config.Translation, "GOOGLE_TRANSLATE_API_KEY")
+		if err != nil {
+			return nil, err
+		}
+		return &GoogleTranslator{url: googleTranslateAPIURL, apiKey: apiKey}, nil
+	default:
+		return nil, fmt.Errorf("unknown translation provider %q", config.Translation.Provider)
+	}
+}
+
+// translationAPIKey reads the key from translation.api_key_env, falling back
+// to the usual variable of the provider
+func translationAPIKey(translation TranslationConfig, defaultEnv string) (string, error) {
+	env := translation.APIKeyEnv
+	if env == "" {
+		env = defaultEnv
+	}
+	apiKey := os.Getenv(env)
+	if apiKey == "" {
+		return "", fmt.Errorf("%s environment variable not set for the %s translation provider", env, translation.Provider)
+	}
+	return apiKey, nil
+}
+
+// translationFailure tells the failures the user can fix apart from others,
+// and a server that is down from one refusing the request
+func translationFailure(status string, err error) string {
+	var apiErr TranslationError
+	var pairErr UnsupportedPairError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &pairErr):
+		return pairErr.Hint()
+	case errors.Is(err, errTranslationAuth):
+		return "Translation auth failed"
+	case errors.Is(err, errTranslationQuota):
+		return "Translation quota exhausted"
+	case errors.Is(err, errUnsupportedPair):
+		return "Translation between these languages is not supported"
+	case errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout():
+		return "Translation timed out"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "Translation server down, connection refused"
+	case errors.As(err, &netErr):
+		return "Translation server unreachable"
+	case errors.As(err, &apiErr) && apiErr.StatusCode >= 500:
+		return fmt.Sprintf("Translation server error (HTTP %d)", apiErr.StatusCode)
+	case errors.As(err, &apiErr):
+		return fmt.Sprintf("Translation request rejected (HTTP %d)", apiErr.StatusCode)
+	}
+	return status
+}
+
+// isTransientTranslationError reports whether sending the text again may
+// succeed, that is after timeouts, network failures and server errors
+func isTransientTranslationError(err error) bool {
+	var apiErr TranslationError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500
+	}
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr)
+}
+
+// translate sends the text to the translator of the model, from the language
+// into the target translation language
+func translate(translator Translator, text string, config Config) (string, error) {
+	var translation string
+	err := retryTranslation(config, func(ctx context.Context) error {
+		var err error
+		translation, err = translator.Translate(ctx, text, config.Language, config.TargetTranslationLanguage)
+		return err
+	})
+	return translation, err
+}
+
+// translateBatch sends the texts to the translator of the model in one batch.
+// A text the provider rejects fails the whole batch, so they are then sent
+// one by one to translate the others
+func translateBatch(translator Translator, texts []string, config Config) ([]BatchTranslation, error) {
+	var results []BatchTranslation
+	err := retryTranslation(config, func(ctx context.Context) error {
+		var err error
+		results, err = translator.TranslateBatch(ctx, texts, config.Language, config.TargetTranslationLanguage)
+		return err
+	})
+	var apiErr TranslationError
+	if errors.As(err, &apiErr) && apiErr.StatusCode < 500 && len(texts) > 1 {
+		slog.Warn("Batch translation rejected, translating one by one", "texts", len(texts), "error", err)
+		ctx, cancel := context.WithTimeout(appContext, time.Duration(config.Translation.TimeoutSeconds)*time.Second)
+		defer cancel()
+		return translateEach(ctx, translator, texts, config.Language, config.TargetTranslationLanguage), nil
+	}
+	return results, err
+}
+
+// translateEach translates the texts one request each, a few at a time, for
+// providers without batches
+func translateEach(ctx context.Context, translator Translator, texts []string, source string, target string) []BatchTranslation {
+	results := make([]BatchTranslation, len(texts))
+	slots := make(chan struct{}, maxConcurrentTranslations)
+	var wg sync.WaitGroup
+	for i, text := range texts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			translation, err := translator.Translate(ctx, text, source, target)
+			results[i] = BatchTranslation{Translation: translation, Err: err}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// batchResults checks that a provider answered every text of a batch
+func batchResults(provider string, texts []string, translations []string) ([]BatchTranslation, error) {
+	if len(translations) != len(texts) {
+		return nil, fmt.Errorf("%s returned %d translations for %d texts", provider, len(translations), len(texts))
+	}
+	results := make([]BatchTranslation, len(texts))
+	for i, translation := range translations {
+		results[i].Translation = translation
+	}
+	return results, nil
+}
+
+// translateAlternatives is translate with the alternatives of translators
+// offering them, duplicates are left out
+func translateAlternatives(translator Translator, text string, config Config) ([]string, error) {
+	alternatives, ok := translator.(AlternativesTranslator)
+	if !ok {
+		translation, err := translate(translator, text, config)
+		if err != nil {
+			return nil, err
+		}
+		return []string{translation}, nil
+	}
+	var translations []string
+	err := retryTranslation(config, func(ctx context.Context) error {
+		var err error
+		translations, err = alternatives.TranslateAlternatives(ctx, text, config.Language, config.TargetTranslationLanguage, maxTranslationAlternatives-1)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return uniqueTranslations(translations), nil
+}
+
+// retryTranslation calls the translator with a timeout. It is retried once
+// after a transient failure and given up when the program exits
+func retryTranslation(config Config, call func(ctx context.Context) error) error {
+	timeout := time.Duration(config.Translation.TimeoutSeconds) * time.Second
+	var err error
+	for attempt := 1; attempt <= translationAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(appContext, timeout)
+		err = call(ctx)
+		cancel()
+		if err == nil || !isTransientTranslationError(err) || appContext.Err() != nil {
+			return err
+		}
+		slog.Warn("Translation failed", "attempt", attempt, "error", err)
+	}
+	return err
+}
+
+// uniqueTranslations drops empty translations and the ones differing from an
+// earlier one only in case, keeping at most maxTranslationAlternatives
+func uniqueTranslations(translations []string) []string {
+	var unique []string
+	for _, translation := range translations {
+		translation = strings.TrimSpace(translation)
+		if translation == "" || slices.ContainsFunc(unique, func(u string) bool { return strings.EqualFold(u, translation) }) {
+			continue
+		}
+		unique = append(unique, translation)
+	}
+	return unique[:min(len(unique), maxTranslationAlternatives)]
+}
+
+// lookUp translates a word or phrase, the dictionary is asked first with
+// offline_first and otherwise when the provider failed
+func lookUp(translator Translator, dictionary *Dictionary, word string, config Config) ([]string, bool, error) {
+	if dictionary != nil && config.Translation.OfflineFirst {
+		if meanings, ok := dictionary.Meanings(word); ok {
+			return meanings, true, nil
+		}
+	}
+	translations, err := translateAlternatives(translator, word, config)
+	if err != nil && dictionary != nil && appContext.Err() == nil {
+		if meanings, ok := dictionary.Meanings(word); ok {
+			slog.Info("Translated offline", "word", word, "error", err)
+			return meanings, true, nil
+		}
+	}
+	if err == nil && len(translations) == 0 {
+		err = fmt.Errorf("no translation of %q", word)
+	}
+	return translations, false, err
+}
+
+func GetTranslation(word string, m model) tea.Cmd {
+	translator, dictionary, config, languages := m.translator, m.dictionary, m.config, m.detectedLanguages
+	return func() tea.Msg {
+		// The dictionary only has words of the configured language
+		var source string
+		if language := languages.sourceLanguage(translator, word, config); language != config.Language {
+			source, config.Language, dictionary = language, language, nil
+		}
+		translations, offline, err := lookUp(translator, dictionary, word, config)
+		if err != nil {
+			slog.Error("Failed to translate", "word", word, "error", err)
+		}
+		return TranslationReceived{Word: word, Translations: translations, Offline: offline, Source: source, Err: err}
+	}
+}
+
+// GetReverseTranslation translates a word of the target translation language
+// into the language, the dictionary only goes the other way
+func GetReverseTranslation(word string, m model) tea.Cmd {
+	translator, config := m.translator, m.config
+	config.Language, config.TargetTranslationLanguage = config.TargetTranslationLanguage, config.Language
+	return func() tea.Msg {
+		translations, _, err := lookUp(translator, nil, word, config)
+		if err != nil {
+			slog.Error("Failed to translate", "word", word, "error", err)
+		}
+		return TranslationReceived{Word: word, Translations: translations, Reverse: true, Err: err}
+	}
+}
+
+// translationDirection is the languages a lookup translates between, such as
+// de → en
+func translationDirection(config Config, reverse bool) string {
+	if reverse {
+		return config.TargetTranslationLanguage + " → " + config.Language
+	}
+	return config.Language + " → " + config.TargetTranslationLanguage
+}
+
+// GetGloss translates a whole AI reply sentence by sentence, so it can be
+// shown underneath it. Sentences that failed to translate are marked with an
+// ellipsis
+func GetGloss(sessionID int, messageID int, text string, m model) tea.Cmd {
+	translator, config := m.translator, m.config
+	return func() tea.Msg {
+		sentences := piper.SplitSentences(text)
+		if len(sentences) == 0 {
+			return GlossReceived{sessionID: sessionID, messageID: messageID}
+		}
+		results, err := translateBatch(translator, sentences, config)
+		if err != nil {
+			slog.Error("Failed to translate gloss", "error", err)
+			return StatusChanged{kind: statusError, status: translationFailure("Failed to translate gloss", err)}
+		}
+		var gloss []string
+		for i, result := range results {
+			if result.Err != nil {
+				slog.Error("Failed to translate gloss", "sentence", i, "error", result.Err)
+				err = result.Err
+				gloss = append(gloss, "…")
+				continue
+			}
+			gloss = append(gloss, result.Translation)
+		}
+		if err != nil && !slices.ContainsFunc(results, func(r BatchTranslation) bool { return r.Err == nil }) {
+			return StatusChanged{kind: statusError, status: translationFailure("Failed to translate gloss", err)}
+		}
+		return GlossReceived{sessionID: sessionID, messageID: messageID, gloss: strings.Join(gloss, " ")}
+	}
+}
+
+// WordsTranslated is the translations of a list of words for the sidebar
+type WordsTranslated struct {
+	words   []string
+	results []BatchTranslation
+	err     error
+}
+
+// GetWordsTranslation translates a list of words in one batch
+func GetWordsTranslation(words []string, m model) tea.Cmd {
+	translator, config := m.translator, m.config
+	return func() tea.Msg {
+		results, err := translateBatch(translator, words, config)
+		if err != nil {
+			slog.Error("Failed to translate words", "words", len(words), "error", err)
+		}
+		return WordsTranslated{words: words, results: results, err: err}
+	}
+}
+
+// wordsTranslated adds the translated words to the sidebar, the status tells
+// how many failed
+func (m *model) wordsTranslated(msg WordsTranslated) tea.Cmd {
+	if msg.err != nil {
+		m.setStatus(statusError, translationFailure("Failed to translate", msg.err))
+		return nil
+	}
+	var failed error
+	var cmds []tea.Cmd
+	failures := 0
+	for i, result := range msg.results {
+		if result.Err != nil {
+			slog.Error("Failed to translate", "word", msg.words[i], "error", result.Err)
+			failed = result.Err
+			failures++
+			continue
+		}
+		cmds = append(cmds, m.saveWord(msg.words[i], result.Translation, m.config.Language))
+	}
+	if failures > 0 {
+		m.setStatus(statusError, fmt.Sprintf("%s (%d of %d words)", translationFailure("Failed to translate", failed), failures, len(msg.words)))
+	} else {
+		m.UpdateStatus(fmt.Sprintf("Translated %d words", len(msg.words)))
+	}
+	return tea.Batch(cmds...)
+}
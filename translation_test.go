@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGlossReceivedFindsItsMessage(t *testing.T) {
+	m := newTestModel(t,
+		Message{Role: RoleUser, Text: "Hallo"},
+		Message{Role: RoleAI, Text: "Guten Tag"},
+	)
+	id := m.messages[1].ID
+	m.addMessage(m.Session, Message{Role: RoleUser, Text: "Wie geht's?"})
+
+	m = update(t, m, GlossReceived{sessionID: m.Session.id, messageID: id, gloss: "Good day"})
+	if m.messages[1].Gloss != "Good day" || m.messages[2].Gloss != "" {
+		t.Errorf("gloss went to the wrong message: %+v", m.messages)
+	}
+}
+
+func TestGlossReceivedAfterClear(t *testing.T) {
+	m := newTestModel(t, Message{Role: RoleAI, Text: "Guten Tag"})
+	id := m.messages[0].ID
+	m.Session.reset(m.config)
+
+	m = update(t, m, GlossReceived{sessionID: m.Session.id, messageID: id, gloss: "Good day"})
+	if len(m.messages) != 0 {
+		t.Errorf("gloss of a cleared message added %+v", m.messages)
+	}
+
+	m.addMessage(m.Session, Message{Role: RoleAI, Text: "Neu"})
+	m = update(t, m, GlossReceived{sessionID: m.Session.id, messageID: id, gloss: "Good day"})
+	if m.messages[0].Gloss != "" {
+		t.Errorf("gloss of a cleared message went to %+v", m.messages[0])
+	}
+}
+
+func TestNewTranslator(t *testing.T) {
+	t.Setenv("DEEPL_AUTH_KEY", "key:fx")
+	t.Setenv("MY_GOOGLE_KEY", "google")
+	t.Setenv("GOOGLE_TRANSLATE_API_KEY", "")
+	t.Setenv("LIBRETRANSLATE_URL", "")
+
+	config := NewConfig()
+	translator, err := NewTranslator(config)
+	if libre, ok := translator.(*LibreTranslator); err != nil || !ok || libre.url != config.LibreTranslateURL {
+		t.Errorf("default translator = %#v %v, want LibreTranslate", translator, err)
+	}
+
+	config.Translation.Provider = "deepl"
+	translator, err = NewTranslator(config)
+	if deepL, ok := translator.(*DeepLTranslator); err != nil || !ok || deepL.url != deepLFreeAPIURL || deepL.apiKey != "key:fx" {
+		t.Errorf("deepl translator = %#v %v", translator, err)
+	}
+
+	config.Translation.Provider = "google"
+	if _, err := NewTranslator(config); err == nil {
+		t.Error("google without a key should fail")
+	}
+	config.Translation.APIKeyEnv = "MY_GOOGLE_KEY"
+	translator, err = NewTranslator(config)
+	if google, ok := translator.(*GoogleTranslator); err != nil || !ok || google.apiKey != "google" {
+		t.Errorf("google translator = %#v %v", translator, err)
+	}
+
+	config.Translation.Provider = "bing"
+	if _, err := NewTranslator(config); err == nil {
+		t.Error("an unknown provider should fail")
+	}
+}
+
+// batchServer is a LibreTranslate instance that rejects lists like those
+// before 1.3 and translates texts[i] into translations[i], an empty
+// translation rejects the text. Earlier texts are answered later, so the
+// results come back out of order
+func batchServer(t *testing.T, texts []string, translations []string) (*LibreTranslator, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	libre := libreServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var body struct {
+			Q any `json:"q"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		text, ok := body.Q.(string)
+		i := slices.Index(texts, text)
+		if !ok || i < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "Invalid request: q must be a string"}`))
+			return
+		}
+		if translations[i] == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "Invalid request: text too long"}`))
+			return
+		}
+		time.Sleep(time.Duration(len(texts)-i) * 2 * time.Millisecond)
+		json.NewEncoder(w).Encode(map[string]string{"translatedText": translations[i]})
+	})
+	return libre, &requests
+}
+
+func translationConfig() Config {
+	config := NewConfig()
+	config.Language, config.TargetTranslationLanguage = "de", "en"
+	return config
+}
+
+func TestTranslateBatchFallsBackToSingleTexts(t *testing.T) {
+	texts := []string{"eins", "zwei", "drei", "vier", "fünf"}
+	libre, requests := batchServer(t, texts, []string{"one", "two", "", "four", "five"})
+
+	results, err := translateBatch(libre, texts, translationConfig())
+	if err != nil {
+		t.Fatalf("a rejected batch failed: %v", err)
+	}
+	want := []string{"one", "two", "", "four", "five"}
+	if len(results) != len(want) {
+		t.Fatalf("results = %+v", results)
+	}
+	for i, result := range results {
+		if result.Translation != want[i] {
+			t.Errorf("text %d translated to %q, want %q", i, result.Translation, want[i])
+		}
+		if failed := result.Err != nil; failed != (i == 2) {
+			t.Errorf("text %d failed with %v", i, result.Err)
+		}
+	}
+	var apiErr TranslationError
+	if !errors.As(results[2].Err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("error of the rejected text = %v", results[2].Err)
+	}
+	if got := requests.Load(); got != 1+int32(len(texts)) {
+		t.Errorf("%d requests for the batch and %d texts", got, len(texts))
+	}
+}
+
+func TestTranslateBatchSingleTextIsNotSentAgain(t *testing.T) {
+	libre, requests := batchServer(t, []string{"eins"}, []string{""})
+	if _, err := translateBatch(libre, []string{"eins"}, translationConfig()); err == nil {
+		t.Error("a rejected text was translated")
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("%d requests", got)
+	}
+}
+
+func TestTranslateBatchServerError(t *testing.T) {
+	var requests atomic.Int32
+	libre := libreServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	_, err := translateBatch(libre, []string{"eins", "zwei"}, translationConfig())
+	var apiErr TranslationError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("error = %v", err)
+	}
+	// Retried once as a batch, server errors aren't blamed on a text
+	if got := requests.Load(); got != translationAttempts {
+		t.Errorf("%d requests", got)
+	}
+}
+
+// countingTranslator translates texts to upper case, recording how many
+// requests ran at once
+type countingTranslator struct {
+	running, most atomic.Int32
+}
+
+func (c *countingTranslator) Translate(ctx context.Context, text string, source string, target string) (string, error) {
+	running := c.running.Add(1)
+	defer c.running.Add(-1)
+	for most := c.most.Load(); running > most && !c.most.CompareAndSwap(most, running); most = c.most.Load() {
+	}
+	time.Sleep(5 * time.Millisecond)
+	return strings.ToUpper(text), nil
+}
+
+func (c *countingTranslator) TranslateBatch(ctx context.Context, texts []string, source string, target string) ([]BatchTranslation, error) {
+	return nil, errors.New("no batches")
+}
+
+func TestTranslateEachBoundsRequests(t *testing.T) {
+	var texts []string
+	for i := range 20 {
+		texts = append(texts, fmt.Sprintf("text %d", i))
+	}
+	translator := &countingTranslator{}
+
+	results := translateEach(context.Background(), translator, texts, "de", "en")
+	for i, result := range results {
+		if result.Err != nil || result.Translation != strings.ToUpper(texts[i]) {
+			t.Errorf("text %d = %+v", i, result)
+		}
+	}
+	if most := translator.most.Load(); most > maxConcurrentTranslations {
+		t.Errorf("%d requests at once", most)
+	}
+}
+
+func TestWordsTranslatedPartially(t *testing.T) {
+	words := []string{"eins", "zwei", "drei", "vier", "fünf"}
+	libre, _ := batchServer(t, words, []string{"one", "two", "", "four", "five"})
+	m := newTestModel(t)
+	m.translator = libre
+	m.config.Language, m.config.TargetTranslationLanguage = "de", "en"
+	m.config.ShowIPA, m.config.ShowExamples = false, false
+
+	m = update(t, m, GetWordsTranslation(words, m)())
+	if want := "Translation request rejected (HTTP 400) (1 of 5 words)"; m.status.kind != statusError || m.status.text != want {
+		t.Errorf("status = %q, want %q", m.status.text, want)
+	}
+	for _, word := range words {
+		if m.wordsStore.Has(word) == (word == "drei") {
+			t.Errorf("%s saved: %v", word, m.wordsStore.Has(word))
+		}
+	}
+}
+
+func TestGlossOfPartiallyTranslatedReply(t *testing.T) {
+	libre, _ := batchServer(t,
+		[]string{"Eins.", "Zwei.", "Drei.", "Vier.", "Fünf."},
+		[]string{"One.", "Two.", "", "Four.", "Five."})
+	m := newTestModel(t, Message{Role: RoleAI, Text: "Eins. Zwei. Drei. Vier. Fünf."})
+	m.translator = libre
+	m.config.Language, m.config.TargetTranslationLanguage = "de", "en"
+	message := m.messages[0]
+
+	msg := GetGloss(m.Session.id, message.ID, message.Text, m)()
+	gloss, ok := msg.(GlossReceived)
+	if !ok {
+		t.Fatalf("gloss gave %#v", msg)
+	}
+	if want := "One. Two. … Four. Five."; gloss.gloss != want {
+		t.Errorf("gloss = %q, want %q", gloss.gloss, want)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// transliteration spells the letters of a script in Latin ones, pairs are
+// tried before single letters
+type transliteration struct {
+	letters map[rune]string
+	pairs   map[string]string
+}
+
+// cyrillic follows the Russian BGN/PCGN romanization without diacritics
+var cyrillic = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo",
+	'ж': "zh", 'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m",
+	'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u",
+	'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+}
+
+// withLetters is the Cyrillic table with the letters a language reads
+// differently
+func withLetters(letters map[rune]string) map[rune]string {
+	table := maps.Clone(cyrillic)
+	maps.Copy(table, letters)
+	return table
+}
+
+var greekLetters = map[rune]string{
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i",
+	'θ': "th", 'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x",
+	'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y",
+	'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+	'ά': "a", 'έ': "e", 'ή': "i", 'ί': "i", 'ό': "o", 'ύ': "y", 'ώ': "o",
+	'ϊ': "i", 'ϋ': "y", 'ΐ': "i", 'ΰ': "y",
+}
+
+// transliterations are the languages whose script is spelled in Latin letters
+// with show_transliteration, by language code
+var transliterations = map[string]transliteration{
+	"ru": {letters: cyrillic},
+	"be": {letters: withLetters(map[rune]string{'г': "h", 'і': "i", 'ў': "w"})},
+	"uk": {letters: withLetters(map[rune]string{'г': "h", 'ґ': "g", 'и': "y", 'і': "i", 'ї': "yi", 'є': "ye", 'х': "kh", '\'': ""})},
+	"bg": {letters: withLetters(map[rune]string{'х': "h", 'щ': "sht", 'ъ': "a", 'ь': "y"})},
+	// Serbian has a Latin alphabet of its own
+	"sr": {letters: withLetters(map[rune]string{
+		'ђ': "đ", 'ж': "ž", 'ј': "j", 'љ': "lj", 'њ': "nj", 'ћ': "ć", 'х': "h",
+		'ц': "c", 'ч': "č", 'џ': "dž", 'ш': "š",
+	})},
+	"el": {letters: greekLetters, pairs: map[string]string{
+		"ου": "ou", "ού": "ou", "αυ": "av", "αύ": "av", "ευ": "ev", "εύ": "ev",
+		"γγ": "ng", "γκ": "gk",
+	}},
+}
+
+// hasTransliteration reports whether words of the language are transliterated
+func hasTransliteration(language string) bool {
+	_, ok := transliterations[languageCode(language)]
+	return ok
+}
+
+// transliteratedLanguages are the codes of the languages with a
+// transliteration, Chinese would need pinyin for every character and isn't
+// one of them
+func transliteratedLanguages() []string {
+	return slices.Sorted(maps.Keys(transliterations))
+}
+
+// transliterate spells the text in Latin letters, other characters are kept.
+// A capital becomes a capital letter followed by small ones unless the next
+// letter is a capital too, so Щи is Shchi and ЩИ is SHCHI
+func transliterate(text string, language string) string {
+	table, ok := transliterations[languageCode(language)]
+	if !ok {
+		return text
+	}
+	var st strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		lower := unicode.ToLower(r)
+		latin, ok := "", false
+		size := 1
+		if i+1 < len(runes) {
+			latin, ok = table.pairs[string([]rune{lower, unicode.ToLower(runes[i+1])})]
+			size = 2
+		}
+		if !ok {
+			latin, ok = table.letters[lower]
+			size = 1
+		}
+		if !ok {
+			st.WriteRune(r)
+			continue
+		}
+		switch {
+		case lower == r || latin == "":
+			st.WriteString(latin)
+		case i+size < len(runes) && unicode.IsUpper(runes[i+size]):
+			st.WriteString(strings.ToUpper(latin))
+		default:
+			first := []rune(latin)
+			st.WriteString(string(unicode.ToUpper(first[0])) + string(first[1:]))
+		}
+		i += size - 1
+	}
+	return st.String()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTransliterate(t *testing.T) {
+	tests := []struct {
+		script   string
+		language string
+		text     string
+		want     string
+	}{
+		{"russian", "ru", "Привет, мир!", "Privet, mir!"},
+		{"russian", "ru", "объём", "obyom"},
+		{"russian capitals", "ru", "Щи и ЩИ", "Shchi i SHCHI"},
+		{"russian region", "ru-RU", "Ёлка", "Yolka"},
+		{"ukrainian", "uk", "Київ", "Kyyiv"},
+		{"ukrainian", "uk", "гривня", "hryvnya"},
+		{"belarusian", "be", "Гродна", "Hrodna"},
+		{"belarusian", "be", "дзякуй і ўсё", "dzyakuy i wsyo"},
+		{"bulgarian", "bg", "България", "Balgariya"},
+		{"bulgarian", "bg", "щастие", "shtastie"},
+		{"serbian", "sr", "Београд", "Beograd"},
+		{"serbian", "sr", "Љубав и ћевапи", "Ljubav i ćevapi"},
+		{"greek", "el", "Αθήνα", "Athina"},
+		{"greek pairs", "el", "ευχαριστώ", "evcharisto"},
+		{"greek pairs", "el", "Ουρανός άγγελος", "Ouranos angelos"},
+		{"chinese", "zh", "你好", "你好"},
+		{"latin", "de", "Straße", "Straße"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.script, func(t *testing.T) {
+			if got := transliterate(tt.text, tt.language); got != tt.want {
+				t.Errorf("transliterate(%q, %q) = %q, want %q", tt.text, tt.language, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransliteratedLanguages(t *testing.T) {
+	codes := transliteratedLanguages()
+	if want := []string{"be", "bg", "el", "ru", "sr", "uk"}; !slices.Equal(codes, want) {
+		t.Errorf("transliterated languages are %v, want %v", codes, want)
+	}
+	if slices.Contains(codes, "zh") {
+		t.Errorf("Chinese is listed but not transliterated")
+	}
+}
+
+func TestUntransliteratedLanguage(t *testing.T) {
+	tests := []struct {
+		language string
+		show     bool
+		problem  bool
+	}{
+		{"zh", true, true},
+		{"Chinese", true, true},
+		{"zh", false, false},
+		{"ru", true, false},
+		{"", true, false},
+	}
+	for _, tt := range tests {
+		config := Config{Language: tt.language, ShowTransliteration: tt.show}
+		problems := untransliteratedLanguage("", config)
+		if (len(problems) > 0) != tt.problem {
+			t.Errorf("language %q show %v: problems %v", tt.language, tt.show, problems)
+		}
+		if tt.problem && !strings.HasPrefix(problems[0].Message, "show_transliteration") {
+			t.Errorf("problem %q doesn't name show_transliteration", problems[0].Message)
+		}
+	}
+}
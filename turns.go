@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// TurnPolicy decides what happens when the student starts a new turn while
+// the previous completion is still in flight
+type TurnPolicy string
+
+const (
+	// QueueTurns answers turns one after another in the order they were made
+	QueueTurns TurnPolicy = "queue"
+	// CancelTurns drops the in-flight turn in favour of the new one
+	CancelTurns TurnPolicy = "cancel"
+)
+
+type CompletionFailed struct {
+	sessionID int
+	turn      int
+	status    string
+}
+
+type pendingTurn struct {
+	turn int
+	text string
+}
+
+// TranscriptionFailed releases the turn of a recording that couldn't be
+// transcribed so the recordings after it aren't held back
+type TranscriptionFailed struct {
+	sessionID int
+	turn      int
+	status    string
+}
+
+// transcribedTurn is a transcription that arrived before the recordings made
+// earlier were transcribed
+type transcribedTurn struct {
+	text       string
+	confidence float64
+	failed     bool
+}
+
+// nextTurn assigns an id to a new student turn, in cancel mode the turn in
+// flight is cancelled so its messages arrive stale and are dropped
+func (m *model) nextTurn(s *Session) int {
+	s.turn++
+	if m.config.TurnPolicy == CancelTurns {
+		if s.cancelTurn != nil {
+			s.cancelTurn()
+		}
+		s.queue = nil
+		s.busy = false
+	}
+	return s.turn
+}
+
+// isStale reports whether a message from the given turn should be dropped
+func (m model) isStale(s *Session, turn int) bool {
+	return m.config.TurnPolicy == CancelTurns && turn != s.turn
+}
+
+// sendTurn adds the student's text to the conversation and asks the LLM for
+// an answer, in queue mode it waits until the previous answer arrived
+func (m *model) sendTurn(s *Session, turn int, text string) tea.Cmd {
+	if s.busy {
+		s.queue = append(s.queue, pendingTurn{turn: turn, text: text})
+		m.setStatus(statusBusy, "Waiting for previous answer")
+		return nil
+	}
+
+	s.busy = true
+	m.addMessage(s, Message{Role: RoleUser, Text: text})
+	if m.isActive(s) {
+		m.setStatus(statusBusy, "Waiting for the teacher")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancelTurn = cancel
+	return GetLlmCompletion(ctx, text, s, turn, *m)
+}
+
+// finishTurn marks the session idle and starts the next queued turn
+func (m *model) finishTurn(s *Session) tea.Cmd {
+	s.busy = false
+	if len(s.queue) == 0 {
+		return nil
+	}
+	next := s.queue[0]
+	s.queue = s.queue[1:]
+	return m.sendTurn(s, next.turn, next.text)
+}
+
+// awaitTranscription reserves the place of a recording in the conversation,
+// transcriptions are handled in the order the recordings were made
+func (s *Session) awaitTranscription(turn int) {
+	s.transcribing = append(s.transcribing, turn)
+}
+
+// transcriptionArrived stores the result of a turn, handling starts once every
+// earlier recording arrived
+func (s *Session) transcriptionArrived(turn int, t transcribedTurn) {
+	if s.transcribed == nil {
+		s.transcribed = make(map[int]transcribedTurn)
+	}
+	s.transcribed[turn] = t
+}
+
+// drainTranscriptions handles the transcriptions at the front of the queue,
+// it pauses while one waits for review
+func (m *model) drainTranscriptions(s *Session) tea.Cmd {
+	var cmds []tea.Cmd
+	for len(s.transcribing) > 0 && m.confirming == nil {
+		turn := s.transcribing[0]
+		t, ok := s.transcribed[turn]
+		if !ok {
+			break
+		}
+		s.transcribing = s.transcribing[1:]
+		delete(s.transcribed, turn)
+		if t.failed || m.isStale(s, turn) {
+			continue
+		}
+		cmds = append(cmds, m.handleTranscription(s, turn, t))
+	}
+	return tea.Batch(cmds...)
+}
+
+// handleTranscription scores a repeat attempt or sends the text as the
+// student's turn, unsure transcriptions are held for review first
+func (m *model) handleTranscription(s *Session, turn int, t transcribedTurn) tea.Cmd {
+	text := strings.TrimSpace(t.text)
+	if s.repeatTarget != "" {
+		scores, score := ScorePronunciation(s.repeatTarget, text)
+		s.repeatTarget = ""
+		m.addMessage(s, Message{Role: RoleUser, Text: text})
+		m.addMessage(s, Message{Role: RoleScore, Text: scoredText(scores), Scores: scores})
+		m.UpdateStatus(fmt.Sprintf("Pronunciation score: %d%%", score))
+		return nil
+	}
+
+	if m.needsConfirmation(t.confidence) {
+		m.confirmTranscription(pendingTranscription{sessionID: s.id, turn: turn, text: text, confidence: t.confidence})
+		return nil
+	}
+	return m.sendTurn(s, turn, text)
+}
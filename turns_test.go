@@ -0,0 +1,192 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// transcript lists the messages of the active session as "Role: Text"
+func transcript(m model) []string {
+	var lines []string
+	for _, msg := range m.messages {
+		lines = append(lines, string(msg.Role)+": "+msg.Text)
+	}
+	return lines
+}
+
+func newTurnModel(t *testing.T, policy TurnPolicy) model {
+	t.Helper()
+	config := NewConfig()
+	config.Theme.Preset = "dark"
+	config.TurnPolicy = policy
+	config.Muted = true
+	return newConfiguredTestModel(t, config)
+}
+
+func TestQueuedTurnsAnswerInOrder(t *testing.T) {
+	m := newTurnModel(t, QueueTurns)
+	s := m.Session
+	first := m.nextTurn(s)
+	m.sendTurn(s, first, "Eins")
+	second := m.nextTurn(s)
+	m.sendTurn(s, second, "Zwei")
+
+	if got := transcript(m); !slices.Equal(got, []string{"You: Eins"}) {
+		t.Fatalf("the second turn was sent before the first was answered: %q", got)
+	}
+
+	m = update(t, m, ReadyCompletion{sessionID: s.id, turn: first, completion: "Antwort eins", addContent: true})
+	m = update(t, m, ReadyCompletion{sessionID: s.id, turn: second, completion: "Antwort zwei", addContent: true})
+
+	want := []string{"You: Eins", "AI: Antwort eins", "You: Zwei", "AI: Antwort zwei"}
+	if got := transcript(m); !slices.Equal(got, want) {
+		t.Errorf("conversation %q, want %q", got, want)
+	}
+	if s.busy || len(s.queue) != 0 {
+		t.Errorf("session still busy %v with %d queued", s.busy, len(s.queue))
+	}
+}
+
+func TestCancelledTurnIsDropped(t *testing.T) {
+	m := newTurnModel(t, CancelTurns)
+	s := m.Session
+	first := m.nextTurn(s)
+	m.sendTurn(s, first, "Eins")
+	second := m.nextTurn(s)
+	m.sendTurn(s, second, "Zwei")
+
+	// The answer to the second turn overtakes the cancelled first one
+	m = update(t, m, ReadyCompletion{sessionID: s.id, turn: second, completion: "Antwort zwei", addContent: true})
+	m = update(t, m, ReadyCompletion{sessionID: s.id, turn: first, completion: "Antwort eins", addContent: true})
+	m = update(t, m, CompletionFailed{sessionID: s.id, turn: first, status: "Failed get completion"})
+
+	want := []string{"You: Eins", "You: Zwei", "AI: Antwort zwei"}
+	if got := transcript(m); !slices.Equal(got, want) {
+		t.Errorf("conversation %q, want %q", got, want)
+	}
+	if m.status.kind == statusError {
+		t.Errorf("the cancelled turn failing showed %q", m.status.text)
+	}
+}
+
+func TestCompletionFailedStartsQueuedTurn(t *testing.T) {
+	m := newTurnModel(t, QueueTurns)
+	s := m.Session
+	first := m.nextTurn(s)
+	m.sendTurn(s, first, "Eins")
+	second := m.nextTurn(s)
+	m.sendTurn(s, second, "Zwei")
+
+	m = update(t, m, CompletionFailed{sessionID: s.id, turn: first, status: "Failed get completion"})
+	if got := transcript(m); !slices.Equal(got, []string{"You: Eins", "You: Zwei"}) {
+		t.Errorf("conversation %q, want the queued turn sent", got)
+	}
+}
+
+func TestCompletionOfAnotherTab(t *testing.T) {
+	m := newTurnModel(t, QueueTurns)
+	background := m.Session
+	turn := m.nextTurn(background)
+	m.sendTurn(background, turn, "Hallo")
+	m.newSession()
+
+	m = update(t, m, ReadyCompletion{sessionID: background.id, turn: turn, completion: "Guten Tag", addContent: true})
+	if len(m.messages) != 0 {
+		t.Errorf("the answer went to the active tab: %q", transcript(m))
+	}
+	if len(background.messages) != 2 || background.messages[1].Text != "Guten Tag" {
+		t.Errorf("background tab has %+v", background.messages)
+	}
+}
+
+// transcribeTurn starts transcribing a recording like a stopped recording does
+func transcribeTurn(m *model) int {
+	turn := m.nextTurn(m.Session)
+	m.Session.awaitTranscription(turn)
+	return turn
+}
+
+func TestTranscriptionsArriveOutOfOrder(t *testing.T) {
+	for _, policy := range []TurnPolicy{QueueTurns, CancelTurns} {
+		t.Run(string(policy), func(t *testing.T) {
+			m := newTurnModel(t, policy)
+			s := m.Session
+			first := transcribeTurn(&m)
+			second := transcribeTurn(&m)
+
+			// The second recording was shorter and is transcribed first
+			m = update(t, m, TranscriptionReceived{sessionID: s.id, turn: second, transcription: "Zwei", confidence: 1})
+			if got := transcript(m); len(got) != 0 {
+				t.Fatalf("the second recording overtook the first: %q", got)
+			}
+			m = update(t, m, TranscriptionReceived{sessionID: s.id, turn: first, transcription: "Eins", confidence: 1})
+
+			want := []string{"You: Eins"}
+			if policy == CancelTurns {
+				// The first turn was cancelled when the second recording started
+				want = []string{"You: Zwei"}
+			}
+			if got := transcript(m); !slices.Equal(got, want) {
+				t.Errorf("conversation %q, want %q", got, want)
+			}
+			if len(s.transcribing) != 0 || len(s.transcribed) != 0 {
+				t.Errorf("transcriptions left: %v %v", s.transcribing, s.transcribed)
+			}
+		})
+	}
+}
+
+func TestOutOfOrderTurnsAnswerInOrder(t *testing.T) {
+	m := newTurnModel(t, QueueTurns)
+	s := m.Session
+	first := transcribeTurn(&m)
+	second := transcribeTurn(&m)
+	third := transcribeTurn(&m)
+
+	m = update(t, m, TranscriptionReceived{sessionID: s.id, turn: third, transcription: "Drei", confidence: 1})
+	m = update(t, m, TranscriptionReceived{sessionID: s.id, turn: first, transcription: "Eins", confidence: 1})
+	m = update(t, m, ReadyCompletion{sessionID: s.id, turn: first, completion: "Antwort eins", addContent: true})
+	m = update(t, m, TranscriptionReceived{sessionID: s.id, turn: second, transcription: "Zwei", confidence: 1})
+	m = update(t, m, ReadyCompletion{sessionID: s.id, turn: second, completion: "Antwort zwei", addContent: true})
+	m = update(t, m, ReadyCompletion{sessionID: s.id, turn: third, completion: "Antwort drei", addContent: true})
+
+	want := []string{
+		"You: Eins", "AI: Antwort eins",
+		"You: Zwei", "AI: Antwort zwei",
+		"You: Drei", "AI: Antwort drei",
+	}
+	if got := transcript(m); !slices.Equal(got, want) {
+		t.Errorf("conversation %q, want %q", got, want)
+	}
+}
+
+func TestFailedTranscriptionReleasesLaterTurns(t *testing.T) {
+	m := newTurnModel(t, QueueTurns)
+	s := m.Session
+	first := transcribeTurn(&m)
+	second := transcribeTurn(&m)
+
+	m = update(t, m, TranscriptionReceived{sessionID: s.id, turn: second, transcription: "Zwei", confidence: 1})
+	m = update(t, m, TranscriptionFailed{sessionID: s.id, turn: first, status: "Transcription failed"})
+
+	if got := transcript(m); !slices.Equal(got, []string{"You: Zwei"}) {
+		t.Errorf("conversation %q", got)
+	}
+}
+
+func TestRecordingWhileTurnIsPending(t *testing.T) {
+	m := newTurnModel(t, QueueTurns)
+	audio := newFakeAudio(pcm(1))
+	m.recorder = NewRecorder(WithAudioContext(audio))
+	s := m.Session
+	turn := transcribeTurn(&m)
+	m.sendTurn(s, turn, "Eins")
+
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlB})
+	m = next.(model)
+	if cmd == nil || m.status.kind != statusRecording {
+		t.Errorf("recording didn't start while the answer is pending, status %q", m.status.text)
+	}
+}
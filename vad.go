@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const vadTickInterval = 200 * time.Millisecond
+
+type VADTick struct{}
+
+type RecordingFinished struct {
+	// id is the recordingID of the recording that ended
+	id    int
+	byVAD bool
+}
+
+func vadTick() tea.Cmd {
+	return tea.Tick(vadTickInterval, func(time.Time) tea.Msg {
+		return VADTick{}
+	})
+}
+
+// vadState tracks the RMS energy of a sliding window of captured samples
+type vadState struct {
+	threshold float64
+	silence   time.Duration
+
+	window     []float64
+	windowSize int
+	sumSquares float64
+
+	speechStarted bool
+	lastSpeech    time.Time
+	triggered     bool
+}
+
+func newVADState(threshold float64, silence time.Duration, windowSize int) *vadState {
+	return &vadState{
+		threshold:  threshold,
+		silence:    silence,
+		windowSize: windowSize,
+	}
+}
+
+func (v *vadState) rms() float64 {
+	if len(v.window) == 0 {
+		return 0
+	}
+	return math.Sqrt(v.sumSquares / float64(len(v.window)))
+}
+
+// process feeds little endian S16 samples into the window and reports whether
+// the silence after speech lasted long enough to stop the recording
+func (v *vadState) process(raw []byte, now time.Time) bool {
+	for i := 0; i+1 < len(raw); i += 2 {
+		sample := float64(int16(raw[i])|int16(raw[i+1])<<8) / math.MaxInt16
+		square := sample * sample
+		v.window = append(v.window, square)
+		v.sumSquares += square
+		if len(v.window) > v.windowSize {
+			v.sumSquares -= v.window[0]
+			v.window = v.window[1:]
+		}
+	}
+
+	if v.rms() >= v.threshold {
+		v.speechStarted = true
+		v.lastSpeech = now
+		return false
+	}
+
+	if v.speechStarted && now.Sub(v.lastSpeech) >= v.silence {
+		v.triggered = true
+		return true
+	}
+	return false
+}
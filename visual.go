@@ -0,0 +1,131 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// wordPos is a word of the navigable rows, as focusRow and focusWord
+type wordPos struct {
+	row  int
+	word int
+}
+
+func (p wordPos) before(q wordPos) bool {
+	return p.row < q.row || p.row == q.row && p.word < q.word
+}
+
+// selection is the range of words selected in visual mode, both ends are
+// included and it may span wrapped rows
+type selection struct {
+	from wordPos
+	to   wordPos
+}
+
+func (s *selection) contains(row int, word int) bool {
+	if s == nil {
+		return false
+	}
+	p := wordPos{row, word}
+	return !p.before(s.from) && !s.to.before(p)
+}
+
+func (s *selection) hasRow(row int) bool {
+	return s != nil && s.from.row <= row && row <= s.to.row
+}
+
+// selection is the range between the word visual mode started on and the
+// focused word, nil outside of visual mode
+func (m model) selection() *selection {
+	if m.visualAnchor == nil {
+		return nil
+	}
+	from, to := *m.visualAnchor, wordPos{m.focusRow, m.focusWord}
+	if to.before(from) {
+		from, to = to, from
+	}
+	return &selection{from: from, to: to}
+}
+
+// startVisual selects the focused word, w and b extend the selection
+func (m *model) startVisual() {
+	if len(m.rows()) == 0 {
+		m.UpdateStatus("Nothing to select")
+		return
+	}
+	m.visualAnchor = &wordPos{m.focusRow, m.focusWord}
+	m.UpdateStatus("Select with w/b, enter translates, esc cancels")
+	m.refreshViewport()
+}
+
+// visualKey translates the selection on the translate key and cancels it on
+// esc, other keys keep working so moving the focus extends the selection
+func (m *model) visualKey(key string) (tea.Cmd, bool) {
+	switch {
+	case key == "esc":
+		m.visualAnchor = nil
+		m.refreshViewport()
+		m.UpdateStatus("Selection cancelled")
+		return nil, true
+	case m.keymap.Action(key) == ActionTranslate:
+		phrase := m.selectedPhrase()
+		m.visualAnchor = nil
+		m.refreshViewport()
+		if phrase == "" {
+			m.UpdateStatus("Nothing to translate")
+			return nil, true
+		}
+		return m.translateWord(phrase), true
+	}
+	return nil, false
+}
+
+// selectedPhrase joins the selected words without the punctuation around
+// them, role labels are left out
+func (m model) selectedPhrase() string {
+	sel := m.selection()
+	var words []string
+	nav := 0
+	for _, row := range renderRows(m.messages, m.viewport.Width) {
+		if !row.navigable {
+			continue
+		}
+		for i, word := range rowWords(row.text) {
+			if !sel.contains(nav, i) || row.firstWord+i == 0 {
+				continue
+			}
+			word = strings.TrimFunc(word, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+			if word != "" {
+				words = append(words, word)
+			}
+		}
+		nav++
+	}
+	return strings.Join(words, " ")
+}
+
+// highlightSelection renders a row with its selected words marked, the space
+// between two selected words is marked too so the phrase reads as one
+func highlightSelection(row string, navIndex int, focusWord int, focused bool, sel *selection) string {
+	var st strings.Builder
+	words := rowWords(row)
+	for i, word := range words {
+		style := lipgloss.NewStyle()
+		if sel.contains(navIndex, i) {
+			style = styles.selection
+		}
+		if focused && i == focusWord {
+			style = style.Inherit(styles.focus)
+		}
+		st.WriteString(style.Render(word))
+		if i+1 < len(words) && sel.contains(navIndex, i) && sel.contains(navIndex, i+1) {
+			st.WriteString(styles.selection.Render(" "))
+		} else {
+			st.WriteRune(' ')
+		}
+	}
+	return st.String()
+}
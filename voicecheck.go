@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"lazylang/piper"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// voiceFamily is the language family of a Piper voice key such as
+// de_DE-karlsson-low.onnx, custom models without that shape have none
+func voiceFamily(voice string) (string, bool) {
+	code, _, ok := strings.Cut(voice, "-")
+	if !ok {
+		return "", false
+	}
+	family, _, ok := strings.Cut(code, "_")
+	if !ok || languageTag.FindString(family) == "" {
+		return "", false
+	}
+	return strings.ToLower(family), true
+}
+
+// voiceMismatch reports the language of a Piper voice that doesn't speak
+// the configured language
+func voiceMismatch(config Config) (string, bool) {
+	if !isPiper(config.TTSBackend) || config.Language == "" {
+		return "", false
+	}
+	family, ok := voiceFamily(config.TTSBackend.Voice)
+	if !ok || family == languageCode(config.Language) {
+		return "", false
+	}
+	return family, true
+}
+
+// addVoiceMismatchWarning explains a voice speaking another language above
+// the header
+func (m *model) addVoiceMismatchWarning() {
+	m.addWarning(m.voiceMismatchWarning())
+}
+
+func (m model) voiceMismatchWarning() string {
+	family, ok := voiceMismatch(m.config)
+	if !ok {
+		return ""
+	}
+	voice := strings.TrimSuffix(m.config.TTSBackend.Voice, ".onnx")
+	return fmt.Sprintf("The voice %s speaks %s, not %s. Press %s to switch to a %s voice", voice, languageName(family), languageName(m.config.Language), m.keymap.Key(ActionFixVoice), languageName(m.config.Language))
+}
+
+// VoiceResolved is the voice found for the language after the voice didn't
+// match it
+type VoiceResolved struct {
+	voice string
+	err   error
+}
+
+func resolveVoice(language string, backend TTSBackend) tea.Cmd {
+	return func() tea.Msg {
+		dir, err := voicesDir(backend.VoicesDir)
+		if err != nil {
+			return VoiceResolved{err: fmt.Errorf("No %s voice found: %w", languageName(language), err)}
+		}
+		voice, err := piper.ResolveVoice(dir, voiceLanguage(language), backend.QualityPreference)
+		if err != nil {
+			return VoiceResolved{err: fmt.Errorf("No %s voice found: %w", languageName(language), err)}
+		}
+		return VoiceResolved{voice: voice.Key + ".onnx"}
+	}
+}
+
+// fixVoice looks up a voice for the language when the configured one speaks
+// another language
+func (m *model) fixVoice() tea.Cmd {
+	if _, ok := voiceMismatch(m.config); !ok {
+		m.UpdateStatus("The voice already speaks " + languageName(m.config.Language))
+		return nil
+	}
+	m.setStatus(statusBusy, "Looking for a "+languageName(m.config.Language)+" voice")
+	return resolveVoice(m.config.Language, m.config.TTSBackend)
+}
+
+// voiceResolved switches to the voice found, it is downloaded when missing
+// and kept until the next start unless it is set in the config
+func (m *model) voiceResolved(msg VoiceResolved) tea.Cmd {
+	if msg.err != nil {
+		m.setStatus(statusError, msg.err.Error())
+		return nil
+	}
+	next := m.config
+	next.TTSBackend.Voice = msg.voice
+	cmd, ok := m.replaceSpeaker(next)
+	if !ok {
+		return nil
+	}
+	m.config.TTSBackend.Voice = msg.voice
+	if cmd == nil {
+		m.UpdateStatus(fmt.Sprintf("Using %s, set tts_backend.voice to keep it", strings.TrimSuffix(msg.voice, ".onnx")))
+	}
+	return cmd
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"lazylang/piper"
+	"net/http"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestVoiceFamily(t *testing.T) {
+	tests := []struct {
+		voice  string
+		family string
+		ok     bool
+	}{
+		{"de_DE-karlsson-low.onnx", "de", true},
+		{"es_MX-claude-high", "es", true},
+		{"EN_us-lessac-medium.onnx", "en", true},
+		{"custom.onnx", "", false},
+		{"my-voice.onnx", "", false},
+		{"toolong_XX-voice.onnx", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		family, ok := voiceFamily(tt.voice)
+		if family != tt.family || ok != tt.ok {
+			t.Errorf("voiceFamily(%q) = %q, %v, want %q, %v", tt.voice, family, ok, tt.family, tt.ok)
+		}
+	}
+}
+
+func TestVoiceMismatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		backend  TTSBackend
+		family   string
+		mismatch bool
+	}{
+		{"matching", "de", TTSBackend{Voice: "de_DE-karlsson-low.onnx"}, "", false},
+		{"matching region", "pt-BR", TTSBackend{Voice: "pt_PT-tugão-medium.onnx"}, "", false},
+		{"mismatching", "es", TTSBackend{Voice: "de_DE-karlsson-low.onnx"}, "de", true},
+		{"mismatching piper type", "fr", TTSBackend{Type: "piper", Voice: "en_US-lessac-medium.onnx"}, "en", true},
+		{"unknown voice", "es", TTSBackend{Voice: "custom.onnx"}, "", false},
+		{"other backend", "es", TTSBackend{Type: "espeak", Voice: "de"}, "", false},
+		{"no language", "", TTSBackend{Voice: "de_DE-karlsson-low.onnx"}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Language = tt.language
+			config.TTSBackend = tt.backend
+			family, mismatch := voiceMismatch(config)
+			if family != tt.family || mismatch != tt.mismatch {
+				t.Errorf("voiceMismatch = %q, %v, want %q, %v", family, mismatch, tt.family, tt.mismatch)
+			}
+		})
+	}
+}
+
+// newMismatchModel speaks Spanish with the default German voice, voices are
+// resolved from the voices list in testdata
+func newMismatchModel(t *testing.T) model {
+	t.Helper()
+	config := NewConfig()
+	config.Theme.Preset = "dark"
+	config.Language = "es"
+	config.TTSBackend.VoicesDir = fixtureVoicesDir(t)
+	return newConfiguredTestModel(t, config)
+}
+
+func TestVoiceMismatchWarning(t *testing.T) {
+	m := newMismatchModel(t)
+	want := "The voice de_DE-karlsson-low speaks German, not Spanish. Press V to switch to a Spanish voice"
+	if !strings.Contains(m.warning, want) {
+		t.Errorf("warning is %q, want %q", m.warning, want)
+	}
+
+	m = newTestModel(t)
+	if strings.Contains(m.warning, "The voice") {
+		t.Errorf("a matching voice warns %q", m.warning)
+	}
+}
+
+// offlineTransport fails every request
+type offlineTransport struct{}
+
+func (offlineTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("offline")
+}
+
+func TestFixVoice(t *testing.T) {
+	piper.SetHTTPClient(&http.Client{Transport: offlineTransport{}})
+	t.Cleanup(func() { piper.SetHTTPClient(http.DefaultClient) })
+	m := newMismatchModel(t)
+
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(m.keymap.Key(ActionFixVoice))})
+	m = next.(model)
+	if m.status.text != "Looking for a Spanish voice" {
+		t.Errorf("status is %q", m.status.text)
+	}
+	var resolved VoiceResolved
+	for _, msg := range cmdMessages(cmd) {
+		if msg, ok := msg.(VoiceResolved); ok {
+			resolved = msg
+		}
+	}
+	if resolved.err != nil || resolved.voice != "es_ES-davefx-medium.onnx" {
+		t.Fatalf("resolved %+v", resolved)
+	}
+
+	next, cmd = m.Update(resolved)
+	m = next.(model)
+	if m.config.TTSBackend.Voice != "es_ES-davefx-medium.onnx" {
+		t.Errorf("voice is %s", m.config.TTSBackend.Voice)
+	}
+	// The fixture only lists the voice, it is downloaded
+	if m.downloadingVoice != "es_ES-davefx-medium.onnx" {
+		t.Errorf("downloading %q", m.downloadingVoice)
+	}
+	if _, mismatch := voiceMismatch(m.config); mismatch {
+		t.Error("the voice still doesn't match")
+	}
+
+	// The download is over before the voices directory is removed
+	failed := false
+	for _, msg := range cmdMessages(cmd) {
+		if _, ok := msg.(DownloadFailed); ok {
+			failed = true
+		}
+	}
+	if !failed {
+		t.Error("the download didn't fail offline")
+	}
+}
+
+func TestFixVoiceWhenMatching(t *testing.T) {
+	m := newTestModel(t)
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(m.keymap.Key(ActionFixVoice))})
+	m = next.(model)
+	if cmd != nil || m.status.text != "The voice already speaks German" {
+		t.Errorf("status is %q", m.status.text)
+	}
+}
+
+func TestFixVoiceWithoutVoice(t *testing.T) {
+	m := newMismatchModel(t)
+	m.config.Language = "ja"
+	m = update(t, m, resolveVoice(m.config.Language, m.config.TTSBackend)())
+	if m.status.kind != statusError || !strings.HasPrefix(m.status.text, "No ja voice found") {
+		t.Errorf("status is %q", m.status.text)
+	}
+	if m.config.TTSBackend.Voice != "de_DE-karlsson-low.onnx" {
+		t.Errorf("voice changed to %s", m.config.TTSBackend.Voice)
+	}
+}
@@ -6,28 +6,114 @@ import (
 )
 
 type WordsStore struct {
-	words map[string]string
+	words map[string]wordEntry
 	order []string
 }
 
+type wordEntry struct {
+	meaning string
+	// ipa is the pronunciation such as /haʊ̯s/, empty until looked up
+	ipa string
+	// example is a sentence using the word, written by the LLM
+	example string
+	// note is the form of the word in the sentence it was translated from
+	note string
+}
+
 func NewWordsStore() *WordsStore {
 	return &WordsStore{
-		words: make(map[string]string),
+		words: make(map[string]wordEntry),
 		order: []string{},
 	}
 }
 
 func (ws *WordsStore) List() string {
+	return ws.list(func(word string) string { return word })
+}
+
+// ListTransliterated is List with the words spelled in Latin letters too
+func (ws *WordsStore) ListTransliterated(language string) string {
+	return ws.list(transliteratedLabel(language))
+}
+
+func (ws *WordsStore) list(label func(word string) string) string {
 	var s strings.Builder
-	for _, word := range ws.order {
-		fmt.Fprintf(&s, "%s: %s\n", word, ws.words[word])
+	for _, entry := range ws.Entries(label) {
+		s.WriteString(entry + "\n")
 	}
 	return s.String()
 }
 
+// transliteratedLabel shows a word with its transliteration when it differs
+func transliteratedLabel(language string) func(word string) string {
+	return func(word string) string {
+		if latin := transliterate(word, language); latin != word {
+			return fmt.Sprintf("%s [%s]", word, latin)
+		}
+		return word
+	}
+}
+
+// Entries are the lines of every word in the order of Words, label formats
+// the word itself
+func (ws *WordsStore) Entries(label func(word string) string) []string {
+	entries := make([]string, len(ws.order))
+	for i, word := range ws.order {
+		entry := ws.words[word]
+		lines := []string{fmt.Sprintf("%s: %s", label(word), entry.meaning)}
+		if entry.ipa != "" {
+			lines[0] = fmt.Sprintf("%s %s: %s", label(word), entry.ipa, entry.meaning)
+		}
+		if entry.note != "" {
+			lines = append(lines, "  "+entry.note)
+		}
+		if entry.example != "" {
+			lines = append(lines, "  „"+entry.example+"“")
+		}
+		entries[i] = strings.Join(lines, "\n")
+	}
+	return entries
+}
+
+// Words are the saved words in the order they were saved
+func (ws *WordsStore) Words() []string {
+	return ws.order
+}
+
 func (ws *WordsStore) Add(word string, meaning string) {
-	if _, ok := ws.words[word]; !ok {
+	entry, ok := ws.words[word]
+	if !ok {
 		ws.order = append(ws.order, word)
 	}
-	ws.words[word] = meaning
+	entry.meaning = meaning
+	ws.words[word] = entry
+}
+
+// SetIPA adds the pronunciation to a saved word
+func (ws *WordsStore) SetIPA(word string, ipa string) {
+	if entry, ok := ws.words[word]; ok && ipa != "" {
+		entry.ipa = ipa
+		ws.words[word] = entry
+	}
+}
+
+// SetExample replaces the example sentence of a saved word
+func (ws *WordsStore) SetExample(word string, example string) {
+	if entry, ok := ws.words[word]; ok && example != "" {
+		entry.example = example
+		ws.words[word] = entry
+	}
+}
+
+// SetNote adds the grammar note to a saved word
+func (ws *WordsStore) SetNote(word string, note string) {
+	if entry, ok := ws.words[word]; ok && note != "" {
+		entry.note = note
+		ws.words[word] = entry
+	}
+}
+
+func (ws *WordsStore) Has(word string) bool {
+	_, ok := ws.words[word]
+	return ok
 }